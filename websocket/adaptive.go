@@ -0,0 +1,62 @@
+package websocket
+
+import "time"
+
+const (
+	defaultCoalesceInterval    = 10 * time.Millisecond
+	defaultMaxCoalesceInterval = time.Second
+	// Queued messages per doubling of the adaptive flush interval
+	coalesceQueueStep = 16
+)
+
+// coalesceInterval grows CoalesceInterval with the RTT and the queued messages of conn, up to
+// MaxCoalesceInterval. Runs on the operations goroutine.
+func (cm *ConnectionManager) coalesceInterval(conn *Connection) time.Duration {
+	interval := cm.opts.CoalesceInterval
+	if rtt := conn.RTT(); rtt > interval {
+		interval = rtt
+	}
+	for queued := len(conn.backlog) + len(conn.held) + len(conn.queue); queued >= coalesceQueueStep; queued /= 2 {
+		interval *= 2
+		if interval >= cm.opts.MaxCoalesceInterval {
+			break
+		}
+	}
+	if interval > cm.opts.MaxCoalesceInterval {
+		interval = cm.opts.MaxCoalesceInterval
+	}
+	return interval
+}
+
+// coalesceFor keeps the keyed update msg for conn until its next flush, runs on the operations goroutine
+func (cm *ConnectionManager) coalesceFor(conn *Connection, msg *Message) {
+	if msg == nil {
+		return
+	}
+	if conn.coalesced == nil {
+		conn.coalesced = &coalescedKeys{index: make(map[string]int)}
+		if cm.coalescing == nil {
+			cm.coalescing = make(map[*Connection]bool)
+		}
+		cm.coalescing[conn] = true
+	}
+	conn.coalesced.add(msg)
+}
+
+// flushAdaptive delivers the coalesced updates of the connections whose flush interval elapsed, runs on the
+// operations goroutine
+func (cm *ConnectionManager) flushAdaptive() {
+	now := cm.clock.Now()
+	for conn := range cm.coalescing {
+		if now.Before(conn.nextFlush) {
+			continue
+		}
+		pending := conn.coalesced
+		conn.coalesced = nil
+		delete(cm.coalescing, conn)
+		for _, msg := range pending.messages {
+			cm.deliver(conn, msg)
+		}
+		conn.nextFlush = now.Add(cm.coalesceInterval(conn))
+	}
+}
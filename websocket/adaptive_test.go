@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveCoalesceInterval(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.AdaptiveCoalesce = true
+		o.MaxCoalesceInterval = time.Second
+	})
+	for _, c := range []struct {
+		rtt    time.Duration
+		queued int
+		want   time.Duration
+	}{
+		{0, 0, defaultCoalesceInterval},
+		{50 * time.Millisecond, 0, 50 * time.Millisecond},
+		{0, 2 * coalesceQueueStep, 4 * defaultCoalesceInterval},
+		{800 * time.Millisecond, 4 * coalesceQueueStep, time.Second},
+	} {
+		conn := &Connection{manager: cm, backlog: make([]*Message, c.queued)}
+		conn.rtt.Store(int64(c.rtt))
+		if got := cm.coalesceInterval(conn); got != c.want {
+			t.Errorf("rtt %v with %d queued: interval %v, want %v", c.rtt, c.queued, got, c.want)
+		}
+	}
+}
+
+func TestAdaptiveCoalesceFlushesPerConnection(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.AdaptiveCoalesce = true
+	})
+	client, conn := testServer(t, cm, nil)()
+	cm.SubscribeKeyed(conn, "prices")
+	cm.PublishKeyed("prices", "a", &Message{Type: "price", Data: 1.0})
+	cm.PublishKeyed("prices", "a", &Message{Type: "price", Data: 2.0})
+	conn.Topics()
+	clock.Advance(defaultCoalesceInterval)
+
+	if msg := readType(t, client, "price"); msg.Key != "a" || msg.Data != 2.0 {
+		t.Fatalf("got %+v, want the latest update only", msg)
+	}
+}
@@ -0,0 +1,40 @@
+package websocket
+
+import (
+	"net/http"
+)
+
+// affinityHeader adds the affinity cookie and header of Options to the upgrade response header. The value is
+// the affinity cookie of the request when the client sends one back, so reconnects keep their value, and id
+// otherwise.
+func (cm *ConnectionManager) affinityHeader(r *http.Request, header http.Header, id string) http.Header {
+	if cm.opts.AffinityCookie == "" && cm.opts.AffinityHeader == "" {
+		return header
+	}
+	value := id
+	if cm.opts.AffinityCookie != "" {
+		if cookie, err := r.Cookie(cm.opts.AffinityCookie); err == nil && cookie.Value != "" {
+			value = cookie.Value
+		}
+	}
+	header = header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if cm.opts.AffinityCookie != "" {
+		cookie := &http.Cookie{
+			Name:     cm.opts.AffinityCookie,
+			Value:    value,
+			Path:     "/",
+			MaxAge:   int(cm.opts.AffinityMaxAge.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		}
+		header.Add("Set-Cookie", cookie.String())
+	}
+	if cm.opts.AffinityHeader != "" {
+		header.Set(cm.opts.AffinityHeader, value)
+	}
+	return header
+}
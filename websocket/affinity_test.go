@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestAffinityCookieAndHeader(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.AffinityCookie = "ws_affinity"
+		o.AffinityHeader = "X-Affinity"
+		o.AffinityMaxAge = time.Hour
+	})
+	conns := make(chan *Connection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	defer srv.Close()
+	dial := func(header http.Header) (*http.Response, *Connection) {
+		t.Helper()
+		client, resp, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+		return resp, <-conns
+	}
+	affinity := func(resp *http.Response) (cookie *http.Cookie, header string) {
+		t.Helper()
+		for _, c := range resp.Cookies() {
+			if c.Name == "ws_affinity" {
+				cookie = c
+			}
+		}
+		if cookie == nil {
+			t.Fatal("no affinity cookie")
+		}
+		return cookie, resp.Header.Get("X-Affinity")
+	}
+
+	resp, conn := dial(nil)
+	cookie, header := affinity(resp)
+	if cookie.Value != conn.ID() || header != conn.ID() {
+		t.Fatalf("affinity %q and %q, want the connection ID %s", cookie.Value, header, conn.ID())
+	}
+	if cookie.MaxAge != 3600 || !cookie.HttpOnly || cookie.Path != "/" {
+		t.Fatalf("cookie %+v", cookie)
+	}
+
+	// A reconnect sending the cookie back keeps its value
+	resp, _ = dial(http.Header{"Cookie": {"ws_affinity=first-instance"}})
+	if cookie, header := affinity(resp); cookie.Value != "first-instance" || header != "first-instance" {
+		t.Fatalf("affinity %q and %q after reconnecting", cookie.Value, header)
+	}
+}
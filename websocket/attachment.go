@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrAttachmentMissing is the read error of a message announcing an attachment that is not followed by a binary
+// frame of its size
+var ErrAttachmentMissing = errors.New("websocket: message attachment missing")
+
+// withAttachmentSize returns msg announcing the size of its attachment, or no attachment when it has none, so
+// a size decoded from untrusted input never announces a frame that does not follow
+func withAttachmentSize(msg *Message) *Message {
+	if msg.AttachmentSize == len(msg.Attachment) {
+		return msg
+	}
+	announced := *msg
+	announced.AttachmentSize = len(msg.Attachment)
+	return &announced
+}
+
+// writeAttachment writes the attachment of msg as a binary frame and returns its size
+func writeAttachment(socket Conn, msg *Message) (int64, error) {
+	if len(msg.Attachment) == 0 {
+		return 0, nil
+	}
+	err := socket.WriteMessage(websocket.BinaryMessage, msg.Attachment)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(msg.Attachment)), nil
+}
+
+// readAttachment reads the binary frame announced by msg into its attachment and returns its size
+func readAttachment(socket Conn, msg *Message) (int64, error) {
+	if msg.AttachmentSize <= 0 {
+		return 0, nil
+	}
+	frameType, r, err := socket.NextReader()
+	if err != nil {
+		return 0, err
+	}
+	if frameType != websocket.BinaryMessage {
+		return 0, ErrAttachmentMissing
+	}
+	// The announced size is not trusted for allocation, the read limit of the socket bounds the frame
+	attachment, err := io.ReadAll(io.LimitReader(r, int64(msg.AttachmentSize)+1))
+	if err != nil {
+		return 0, err
+	}
+	if len(attachment) != msg.AttachmentSize {
+		return 0, fmt.Errorf("%w: %d bytes announced, %d read", ErrAttachmentMissing, msg.AttachmentSize,
+			len(attachment))
+	}
+	msg.Attachment = attachment
+	return int64(len(attachment)), nil
+}
@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestAttachmentsFollowTheirMessage(t *testing.T) {
+	received := make(chan *Message, 1)
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, conn := testServer(t, cm, func(_ *Connection, msg *Message) { received <- msg })()
+
+	client.WriteJSON(map[string]interface{}{"type": "upload", "attachment": 3})
+	client.WriteMessage(gorilla.BinaryMessage, []byte{1, 2, 3})
+	select {
+	case msg := <-received:
+		if msg.Type != "upload" || !bytes.Equal(msg.Attachment, []byte{1, 2, 3}) {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message with attachment not received")
+	}
+
+	// A stale size without attachment is not announced
+	cm.SendTo(conn, &Message{Type: "stale", AttachmentSize: 5})
+	cm.SendTo(conn, &Message{Type: "download", Attachment: []byte{4, 5}})
+	var announced map[string]interface{}
+	if err := client.ReadJSON(&announced); err != nil || announced["attachment"] != nil {
+		t.Fatalf("read %v, %v, want no attachment announced", announced, err)
+	}
+	if err := client.ReadJSON(&announced); err != nil || announced["attachment"] != float64(2) {
+		t.Fatalf("read %v, %v, want the attachment announced", announced, err)
+	}
+	frameType, data, err := client.ReadMessage()
+	if err != nil || frameType != gorilla.BinaryMessage || !bytes.Equal(data, []byte{4, 5}) {
+		t.Fatalf("read %d %v, %v, want the attachment frame", frameType, data, err)
+	}
+}
+
+func TestMissingAttachmentRemovesConnection(t *testing.T) {
+	disconnected := make(chan error, 1)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.OnDisconnect = func(_ *Connection, err error) { disconnected <- err }
+	})
+	client, _ := testServer(t, cm, nil)()
+	client.WriteJSON(map[string]interface{}{"type": "upload", "attachment": 3})
+	client.WriteJSON(Message{Type: "not an attachment"})
+	select {
+	case err := <-disconnected:
+		if !errors.Is(err, ErrAttachmentMissing) {
+			t.Fatalf("removed with %v, want ErrAttachmentMissing", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not removed")
+	}
+}
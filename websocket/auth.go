@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+const defaultAuthTimeout = 10 * time.Second
+
+// authenticate validates an authentication message, on success the connection is activated and starts
+// receiving broadcasts, on failure it is removed
+func (cm *ConnectionManager) authenticate(conn *Connection, msg *Message) bool {
+	identity, err := cm.opts.Authenticate(msg)
+	if err != nil {
+		cm.logE(err, "Authentication failed, will remove the socket")
+		cm.rejected(RejectAuthFailed)
+		cm.publish(ErrorEvent{Conn: conn, Err: err, Time: cm.clock.Now()})
+		cm.enqueue(&socketOperation{
+			opType: remove,
+			conn:   conn,
+			err:    err,
+		})
+		return false
+	}
+
+	previous := conn.Identity()
+	conn.setIdentity(identity)
+	if _, blocked := cm.quotaBlocked(cm.opts.UsageKey(conn)); blocked {
+		cm.logV("Authenticated user exceeded its quota, will remove the socket")
+		cm.rejected(RejectQuotaExceeded)
+		cm.quotaDisconnect(conn)
+		return false
+	}
+	// The rate limit of the new identity applies from the next message on
+	conn.inbound = nil
+	cm.enqueue(&socketOperation{
+		opType: activate,
+		conn:   conn,
+	})
+	cm.reauthorize(conn)
+	if cm.opts.OnAuthenticated != nil {
+		cm.opts.OnAuthenticated(conn, previous)
+	}
+	return true
+}
+
+// authorizeTopic reports whether conn may join topic, declare it as an interest or resume its stream, with
+// Options.AnonymousTopics and Options.AuthorizeJoin
+func (cm *ConnectionManager) authorizeTopic(conn *Connection, topic string) bool {
+	if cm.opts.AllowAnonymous && cm.opts.AnonymousTopics != nil && conn.Anonymous() &&
+		!slices.Contains(cm.opts.AnonymousTopics, topic) {
+		return false
+	}
+	return cm.opts.AuthorizeJoin == nil || cm.opts.AuthorizeJoin(conn, topic)
+}
+
+// reauthorize leaves the topics and interests conn may no longer join after its identity changed, runs on the
+// reader goroutine of conn
+func (cm *ConnectionManager) reauthorize(conn *Connection) {
+	if cm.opts.AuthorizeJoin == nil && (!cm.opts.AllowAnonymous || cm.opts.AnonymousTopics == nil) {
+		return
+	}
+	for _, topic := range conn.Topics() {
+		if !cm.authorizeTopic(conn, topic) {
+			cm.logV("Topic no longer authorized, leaving it")
+			cm.Leave(conn, topic)
+		}
+	}
+	interests := conn.Interests()
+	allowed := make([]string, 0, len(interests))
+	for _, id := range interests {
+		if cm.authorizeTopic(conn, id) {
+			allowed = append(allowed, id)
+		}
+	}
+	if len(allowed) < len(interests) {
+		cm.logV("Interests no longer authorized, removing them")
+		cm.SetInterests(conn, allowed)
+	}
+}
+
+// authenticateRequest runs AuthenticateRequest before the upgrade, a failure is answered with 401
+func (cm *ConnectionManager) authenticateRequest(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	if cm.opts.AuthenticateRequest == nil {
+		return nil, nil
+	}
+	identity, err := cm.opts.AuthenticateRequest(r)
+	if err != nil {
+		cm.logE(err, "Request authentication failed")
+		return nil, cm.reject(w, r, UpgradeRejection{
+			Status: http.StatusUnauthorized,
+			Reason: RejectUnauthorized,
+		})
+	}
+	return identity, nil
+}
+
+func (cm *ConnectionManager) isAuthMessage(msg *Message) bool {
+	return cm.opts.Authenticate != nil && cm.opts.AuthMessageType != "" && msg.Type == cm.opts.AuthMessageType
+}
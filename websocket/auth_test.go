@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestFirstMessageAuthentication(t *testing.T) {
+	authenticated := make(chan *Connection, 1)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.Authenticate = func(msg *Message) (interface{}, error) {
+			if msg.Data != "secret" {
+				return nil, errors.New("bad token")
+			}
+			return "alice", nil
+		}
+		o.OnAuthenticated = func(conn *Connection, _ interface{}) { authenticated <- conn }
+	})
+	dial := testServer(t, cm, nil)
+
+	client, conn := dial()
+	cm.Send(&Message{Type: "early"})
+	client.WriteJSON(Message{Type: "auth", Data: "secret"})
+	select {
+	case <-authenticated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not authenticated")
+	}
+	if conn.Identity() != "alice" {
+		t.Fatalf("identity %v", conn.Identity())
+	}
+	cm.Send(&Message{Type: "news"})
+	var msg Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "news" {
+		t.Fatalf("first message %+v, %v, want the broadcast after authentication", msg, err)
+	}
+
+	rejected, _ := dial()
+	rejected.WriteJSON(Message{Type: "auth", Data: "wrong"})
+	if _, _, err := rejected.ReadMessage(); err == nil {
+		t.Fatal("connection failing authentication not closed")
+	}
+}
+
+func TestAnonymousConnectionUpgradesLive(t *testing.T) {
+	authenticated := make(chan struct{}, 1)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.AllowAnonymous = true
+		o.JoinMessageType = "join"
+		o.Authenticate = func(msg *Message) (interface{}, error) { return msg.Data, nil }
+		o.OnAuthenticated = func(*Connection, interface{}) { authenticated <- struct{}{} }
+		o.AnonymousTopics = []string{"lobby"}
+		o.AnonymousInboundLimit = InboundLimit{MessagesPerSecond: 3}
+		o.AuthorizeJoin = func(conn *Connection, topic string) bool {
+			return topic != "vip" || conn.Identity() == "vip-user"
+		}
+	})
+	client, conn := testServer(t, cm, nil)()
+	auth := func(identity string) {
+		t.Helper()
+		client.WriteJSON(Message{Type: "auth", Data: identity})
+		select {
+		case <-authenticated:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not authenticated", identity)
+		}
+	}
+	topics := func() []string {
+		topics := conn.Topics()
+		slices.Sort(topics)
+		return topics
+	}
+
+	if !conn.Anonymous() {
+		t.Fatal("connection not anonymous before authenticating")
+	}
+	cm.Send(&Message{Type: "hello"})
+	readType(t, client, "hello")
+	// Three messages fit the anonymous limit, the two after are dropped
+	client.WriteJSON(Message{Type: "join", Data: "lobby"})
+	client.WriteJSON(Message{Type: "join", Data: "news"})
+	for i := 0; i < 3; i++ {
+		client.WriteJSON(Message{Type: "x"})
+	}
+	eventually(t, "anonymous messages over the limit to be dropped", func() bool { return cm.Stats().Dropped == 2 })
+	if got := topics(); !slices.Equal(got, []string{"lobby"}) {
+		t.Fatalf("anonymous topics %v", got)
+	}
+
+	// The authentication message is limited too until it succeeds
+	time.Sleep(400 * time.Millisecond)
+	auth("vip-user")
+	if conn.Anonymous() {
+		t.Fatal("connection anonymous after authenticating")
+	}
+	client.WriteJSON(Message{Type: "join", Data: "news"})
+	client.WriteJSON(Message{Type: "join", Data: "vip"})
+	for i := 0; i < 5; i++ {
+		client.WriteJSON(Message{Type: "x"})
+	}
+	eventually(t, "joins after authenticating", func() bool { return len(conn.Topics()) == 3 })
+	if got := topics(); !slices.Equal(got, []string{"lobby", "news", "vip"}) {
+		t.Fatalf("topics %v", got)
+	}
+	if dropped := cm.Stats().Dropped; dropped != 2 {
+		t.Fatalf("%d dropped, the anonymous limit applied after authenticating", dropped)
+	}
+
+	// A new identity leaves the topics it may not join
+	auth("bob")
+	if got := topics(); !slices.Equal(got, []string{"lobby", "news"}) {
+		t.Fatalf("topics %v after authenticating as bob", got)
+	}
+}
+
+func TestRequestAuthenticationBeforeUpgrade(t *testing.T) {
+	cm := NewConnectionManager(
+		WithAuthenticator(func(*Message) (interface{}, error) { return nil, errors.New("not expected") }),
+		WithRequestAuthenticator(func(r *http.Request) (interface{}, error) {
+			if r.URL.Query().Get("token") != "secret" {
+				return nil, errors.New("bad token")
+			}
+			return "alice", nil
+		}),
+		func(o *Options) { o.SetupTimeout = -1 },
+	)
+	conns := make(chan *Connection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	_, resp, err := gorilla.DefaultDialer.Dial(url+"/?token=wrong", nil)
+	if err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("upgrade with a wrong token not rejected with 401: %v", err)
+	}
+	if n := cm.Rejections()[RejectUnauthorized]; n != 1 {
+		t.Fatalf("%d unauthorized rejections", n)
+	}
+
+	// An authenticated request needs no authentication message
+	client, _, err := gorilla.DefaultDialer.Dial(url+"/?token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn := <-conns
+	if conn.Identity() != "alice" || conn.Anonymous() {
+		t.Fatalf("identity %v", conn.Identity())
+	}
+	cm.Send(&Message{Type: "news"})
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readType(t, client, "news")
+}
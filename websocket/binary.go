@@ -0,0 +1,195 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// binaryMessageType is the Type of messages carrying raw binary frames, e.g. for Transform and type metrics
+	binaryMessageType    = "binary"
+	defaultStreamTimeout = time.Minute
+)
+
+var (
+	// ErrStreamClosed is returned by writes to a binary stream after Close, or after its connection was removed
+	ErrStreamClosed = errors.New("websocket: binary stream closed")
+	// ErrStreamTimeout is returned by writes to a binary stream not closed within Options.StreamTimeout, its
+	// connection is removed since the client cannot tell the message was cut off
+	ErrStreamTimeout = errors.New("websocket: binary stream timed out")
+)
+
+// SendBinary broadcasts data as a binary frame, e.g. an audio chunk, without encoding it with the codec of the
+// connections
+func (cm *ConnectionManager) SendBinary(data []byte) {
+	cm.Send(&Message{Type: binaryMessageType, Data: data, binary: true})
+}
+
+// SendBinary sends data to this connection only as a binary frame
+func (c *Connection) SendBinary(data []byte) {
+	c.manager.SendTo(c, &Message{Type: binaryMessageType, Data: data, binary: true})
+}
+
+// NextBinaryWriter waits for the turn of a binary message to conn among its queued messages and returns a writer
+// streaming it, e.g. a file in chunks without holding it in memory. The connection writes nothing else until the
+// writer is closed, so Close must always be called, and at most for Options.StreamTimeout. It returns
+// ErrConnectionClosed, a *DropError or the error of ctx when the stream does not start.
+func (c *Connection) NextBinaryWriter(ctx context.Context) (io.WriteCloser, error) {
+	cm := c.manager
+	stream := &binaryStream{
+		ready:     make(chan *streamWriter),
+		failed:    make(chan error, 1),
+		abandoned: make(chan struct{}),
+	}
+	msg := &Message{Type: binaryMessageType, binary: true, stream: stream}
+	msg.delivered = func(err error) {
+		if err != nil {
+			select {
+			case stream.failed <- err:
+			default:
+			}
+		}
+	}
+	err := cm.enqueueContext(ctx, &socketOperation{
+		opType: sendTo,
+		conn:   c,
+		msg:    msg,
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case w := <-stream.ready:
+		return w, nil
+	case err = <-stream.failed:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-cm.done:
+		err = ErrManagerClosed
+	}
+	close(stream.abandoned)
+	return nil, err
+}
+
+// binaryStream hands the socket writer of a streamed message from the writer of the connection to
+// NextBinaryWriter
+type binaryStream struct {
+	ready     chan *streamWriter
+	failed    chan error
+	abandoned chan struct{} // Closed when NextBinaryWriter gave up before the stream started
+}
+
+// streamWriter is the writer returned by NextBinaryWriter
+type streamWriter struct {
+	mu     sync.Mutex // Held by the writer of the connection until the socket writer is set
+	w      io.Writer
+	closer io.Closer
+	closed bool
+	err    error
+	done   chan struct{}
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed && s.err == ErrStreamTimeout {
+		return 0, s.err
+	}
+	if s.closed {
+		return 0, ErrStreamClosed
+	}
+	return s.w.Write(p)
+}
+
+// Close ends the binary message and lets the connection write its next messages
+func (s *streamWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+	s.err = s.closer.Close()
+	close(s.done)
+	return s.err
+}
+
+// writeBinary writes a binary message to the socket of conn and returns its size. Streamed messages block the
+// writer until the stream is closed.
+func (cm *ConnectionManager) writeBinary(conn *Connection, msg *Message) (int64, error) {
+	if msg.stream == nil {
+		data, _ := msg.Data.([]byte)
+		return int64(len(data)), conn.socket.WriteMessage(websocket.BinaryMessage, data)
+	}
+	stream := &streamWriter{done: make(chan struct{})}
+	stream.mu.Lock()
+	select {
+	case msg.stream.ready <- stream:
+	case <-msg.stream.abandoned:
+		stream.mu.Unlock()
+		return 0, nil
+	case <-cm.done:
+		stream.mu.Unlock()
+		return 0, ErrManagerClosed
+	}
+	w, err := conn.socket.NextWriter(websocket.BinaryMessage)
+	if err != nil {
+		stream.closed = true
+		stream.err = err
+		close(stream.done)
+		stream.mu.Unlock()
+		return 0, err
+	}
+	counter := &countingWriter{w: w}
+	stream.w = counter
+	stream.closer = w
+	stream.mu.Unlock()
+	expired := make(chan struct{})
+	timer := cm.clock.AfterFunc(cm.opts.StreamTimeout, func() { close(expired) })
+	defer timer.Stop()
+	select {
+	case <-stream.done:
+	case <-expired:
+		if stream.abort(ErrStreamTimeout) {
+			return counter.n, ErrStreamTimeout
+		}
+	case <-conn.stop:
+	case <-cm.done:
+	}
+	return counter.n, stream.Close()
+}
+
+// abort fails the writes of a stream left open without ending its message, it reports whether the stream was
+// still open
+func (s *streamWriter) abort(err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closed = true
+	s.err = err
+	close(s.done)
+	return true
+}
+
+// readBinary reads a binary frame of a connection whose codec uses text frames into msg when Options.OnBinary
+// is set, it reports whether it did
+func (cm *ConnectionManager) readBinary(conn *Connection, frameType int, r io.Reader, msg *Message) (bool, error) {
+	if cm.opts.OnBinary == nil || frameType != websocket.BinaryMessage || conn.codec.FrameType() == frameType {
+		return false, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return true, err
+	}
+	msg.Type = binaryMessageType
+	msg.Data = data
+	msg.binary = true
+	return true, nil
+}
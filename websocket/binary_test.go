@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// readBinary reads a binary frame from client
+func readBinary(t *testing.T, client *gorilla.Conn) []byte {
+	t.Helper()
+	frameType, data, err := client.ReadMessage()
+	if err != nil || frameType != gorilla.BinaryMessage {
+		t.Fatalf("read frame %d %q, %v, want a binary frame", frameType, data, err)
+	}
+	return data
+}
+
+func TestBinaryFrames(t *testing.T) {
+	binaries := make(chan []byte, 1)
+	texts := make(chan string, 1)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.OnBinary = func(_ *Connection, data []byte) { binaries <- data }
+	})
+	client, conn := testServer(t, cm, func(_ *Connection, msg *Message) { texts <- msg.Type })()
+	client.WriteMessage(gorilla.BinaryMessage, []byte{1, 2, 3})
+	client.WriteJSON(Message{Type: "text"})
+	select {
+	case data := <-binaries:
+		if !bytes.Equal(data, []byte{1, 2, 3}) {
+			t.Fatalf("OnBinary got %v", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("binary frame not received")
+	}
+	if msgType := <-texts; msgType != "text" {
+		t.Fatalf("received %s", msgType)
+	}
+
+	cm.SendBinary([]byte{9})
+	if data := readBinary(t, client); !bytes.Equal(data, []byte{9}) {
+		t.Fatalf("broadcast %v", data)
+	}
+	conn.SendBinary([]byte{8})
+	if data := readBinary(t, client); !bytes.Equal(data, []byte{8}) {
+		t.Fatalf("sent %v", data)
+	}
+}
+
+func TestBinaryStream(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, conn := testServer(t, cm, nil)()
+	w, err := conn.NextBinaryWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Messages queued during the stream wait for its end
+	conn.Send(&Message{Type: "after"})
+	w.Write([]byte("ab"))
+	time.Sleep(20 * time.Millisecond)
+	w.Write([]byte("cd"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("late")); !errors.Is(err, ErrStreamClosed) {
+		t.Fatalf("write after close: %v", err)
+	}
+	if data := readBinary(t, client); string(data) != "abcd" {
+		t.Fatalf("streamed %q", data)
+	}
+	readType(t, client, "after")
+
+	// A stream waiting for its turn is abandoned with its context
+	open, err := conn.NextBinaryWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := conn.NextBinaryWriter(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting stream: %v", err)
+	}
+	open.Close()
+	conn.Send(&Message{Type: "end"})
+	if data := readBinary(t, client); len(data) != 0 {
+		t.Fatalf("empty stream wrote %q", data)
+	}
+	readType(t, client, "end")
+	if n := cm.Stats().WriteErrors; n != 0 {
+		t.Fatalf("%d write errors", n)
+	}
+
+	conn.Terminate()
+	if _, err := conn.NextBinaryWriter(context.Background()); err == nil {
+		t.Fatal("stream started on a removed connection")
+	}
+}
+
+func TestStreamTimeoutRemovesConnection(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.StreamTimeout = time.Second
+	})
+	_, conn := testServer(t, cm, nil)()
+	w, err := conn.NextBinaryWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("partial"))
+	eventually(t, "the stream to time out", func() bool {
+		clock.Advance(time.Second)
+		_, err := w.Write([]byte("more"))
+		return errors.Is(err, ErrStreamTimeout)
+	})
+	eventually(t, "the connection to be removed", func() bool { return cm.Stats().Connections == 0 })
+}
@@ -0,0 +1,267 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+const defaultBrokerBuffer = 256
+
+// Broker relays broadcasts between the managers of several instances, e.g. behind a load balancer. Send,
+// SendContext, SendExcept, SendBinary, SendTemplate, SendLocalized, Publish, PublishExcept, PublishKeyed,
+// PublishTemplate and PublishEntity are published to the broker and delivered to the connections of every
+// manager subscribed to it, the excepted connections being local. SendWhere, Multicast and the sends to single
+// connections stay local. See the redisbroker and natsbroker packages.
+type Broker interface {
+	// Publish sends data to all subscribers, including the publishing instance
+	Publish(ctx context.Context, data []byte) error
+	// Subscribe calls deliver with the data of each publish until ctx is done. It is called again with a
+	// backoff when it returns before, e.g. when the subscription could not be set up.
+	Subscribe(ctx context.Context, deliver func(data []byte)) error
+}
+
+// brokered is a broadcast relayed through the Broker
+type brokered struct {
+	Origin     string                     `json:"origin"`
+	Entity     string                     `json:"entity,omitempty"` // Of PublishEntity and PublishTemplate
+	Message    json.RawMessage            `json:"message,omitempty"`
+	Attachment []byte                     `json:"attachment,omitempty"`
+	Binary     []byte                     `json:"binary,omitempty"`    // Of SendBinary
+	Template   string                     `json:"template,omitempty"`  // Of SendTemplate, with Data
+	Data       json.RawMessage            `json:"data,omitempty"`      // Data of the template
+	Localized  map[string]json.RawMessage `json:"localized,omitempty"` // Variants of SendLocalized
+	Fallback   string                     `json:"fallback,omitempty"`
+	Sessions   []sessionEvent             `json:"sessions,omitempty"` // Session tokens issued and revoked
+}
+
+// brokerRelay publishes the broadcasts of the manager in order from one goroutine
+type brokerRelay struct {
+	id    string
+	queue chan []byte
+}
+
+// startBroker publishes queued broadcasts and delivers the broadcasts of other instances until the manager
+// shuts down
+func (cm *ConnectionManager) startBroker() {
+	if cm.opts.Broker == nil {
+		return
+	}
+	cm.relay = &brokerRelay{
+		id:    cm.NewID(),
+		queue: make(chan []byte, cm.opts.BrokerBuffer),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-cm.done
+		cancel()
+	}()
+	go cm.keepRunning(ctx, "Broker subscription", func(ctx context.Context) error {
+		return cm.opts.Broker.Subscribe(ctx, cm.fromBroker)
+	})
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data := <-cm.relay.queue:
+				cm.logE(cm.opts.Broker.Publish(ctx, data), "Failed to publish to broker")
+			}
+		}
+	}()
+}
+
+// toBroker queues msg for the other instances, entity is empty for broadcasts. Messages are dropped when the
+// relay is BrokerBuffer messages behind.
+func (cm *ConnectionManager) toBroker(entity string, msg *Message) {
+	if cm.relay == nil {
+		return
+	}
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		cm.logE(err, "Failed to encode message for broker")
+		return
+	}
+	relayed := brokered{
+		Entity:     entity,
+		Message:    encoded,
+		Attachment: msg.Attachment,
+	}
+	if msg.binary {
+		relayed.Binary, _ = msg.Data.([]byte)
+	}
+	cm.relayBrokered(relayed)
+}
+
+// toBrokerTemplate queues a SendTemplate, or a PublishTemplate to topic, for the other instances, which render it
+// with their own template
+func (cm *ConnectionManager) toBrokerTemplate(topic string, name string, data interface{}) {
+	if cm.relay == nil {
+		return
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		cm.logE(err, "Failed to encode template data for broker")
+		return
+	}
+	cm.relayBrokered(brokered{Entity: topic, Template: name, Data: encoded})
+}
+
+// toBrokerLocalized queues a SendLocalized for the other instances
+func (cm *ConnectionManager) toBrokerLocalized(variants map[string]*Message, fallback string) {
+	if cm.relay == nil {
+		return
+	}
+	relayed := brokered{Localized: make(map[string]json.RawMessage, len(variants)), Fallback: fallback}
+	for locale, msg := range variants {
+		encoded, err := json.Marshal(msg)
+		if err != nil {
+			cm.logE(err, "Failed to encode message for broker")
+			return
+		}
+		relayed.Localized[locale] = encoded
+	}
+	cm.relayBrokered(relayed)
+}
+
+// toBrokerSessions shares the session tokens issued and revoked by the manager with the other instances, so
+// sessions resume on any of them, e.g. on a standby following the snapshots of the failed active instance
+func (cm *ConnectionManager) toBrokerSessions(events ...sessionEvent) {
+	if cm.relay == nil {
+		return
+	}
+	cm.relayBrokered(brokered{Sessions: events})
+}
+
+// relayBrokered queues relayed for the publishing goroutine
+func (cm *ConnectionManager) relayBrokered(relayed brokered) {
+	relayed.Origin = cm.relay.id
+	data, err := json.Marshal(relayed)
+	if err != nil {
+		cm.logE(err, "Failed to encode message for broker")
+		return
+	}
+	select {
+	case cm.relay.queue <- data:
+	default:
+		cm.logV("Broker relay full, broadcast not published")
+	}
+}
+
+// fromBroker delivers a broadcast of another instance to the local connections
+func (cm *ConnectionManager) fromBroker(data []byte) {
+	var relayed brokered
+	err := json.Unmarshal(data, &relayed)
+	if err != nil {
+		cm.logE(err, "Failed to decode broker message")
+		return
+	}
+	if relayed.Origin == cm.relay.id {
+		return
+	}
+	if relayed.Sessions != nil {
+		cm.sessions.apply(relayed.Sessions)
+		return
+	}
+	if relayed.Template != "" {
+		cm.templateFromBroker(relayed)
+		return
+	}
+	if relayed.Localized != nil {
+		cm.localizedFromBroker(relayed)
+		return
+	}
+	msg, err := DecodeMessage(relayed.Message)
+	if err != nil {
+		cm.logE(err, "Failed to decode broker message")
+		return
+	}
+	msg.Attachment = relayed.Attachment
+	if relayed.Binary != nil {
+		msg.Data = relayed.Binary
+		msg.binary = true
+	}
+	if relayed.Entity == "" {
+		cm.sendLocal(msg)
+		return
+	}
+	if msg.Key != "" && cm.opts.CoalesceInterval > 0 && !cm.opts.AdaptiveCoalesce {
+		cm.enqueue(&socketOperation{
+			opType: coalesce,
+			msg:    msg,
+			ids:    []string{relayed.Entity},
+		})
+		return
+	}
+	cm.enqueue(&socketOperation{
+		opType: publishEntity,
+		msg:    msg,
+		ids:    []string{relayed.Entity},
+	})
+}
+
+func (cm *ConnectionManager) templateFromBroker(relayed brokered) {
+	if cm.template(relayed.Template) == nil {
+		cm.logV("Unknown template from broker: " + relayed.Template)
+		return
+	}
+	var data interface{}
+	err := json.Unmarshal(relayed.Data, &data)
+	if err != nil {
+		cm.logE(err, "Failed to decode broker message")
+		return
+	}
+	cm.enqueue(&socketOperation{
+		opType:   sendTemplate,
+		template: &templateSend{topic: relayed.Entity, name: relayed.Template, data: data},
+	})
+}
+
+func (cm *ConnectionManager) localizedFromBroker(relayed brokered) {
+	variants := make(map[string]*Message, len(relayed.Localized))
+	for locale, encoded := range relayed.Localized {
+		msg, err := DecodeMessage(encoded)
+		if err != nil {
+			cm.logE(err, "Failed to decode broker message")
+			return
+		}
+		variants[locale] = msg
+	}
+	cm.enqueue(&socketOperation{
+		opType:    sendLocalized,
+		localized: &localizedMessage{variants: variants, fallback: relayed.Fallback},
+	})
+}
+
+// MemoryBroker relays broadcasts between managers of the same process, e.g. in tests
+type MemoryBroker struct {
+	mu          sync.Mutex
+	subscribers map[*func([]byte)]bool
+}
+
+// NewMemoryBroker broker without network
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: make(map[*func([]byte)]bool)}
+}
+
+// Publish calls the subscribers with data
+func (b *MemoryBroker) Publish(_ context.Context, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for deliver := range b.subscribers {
+		(*deliver)(data)
+	}
+	return nil
+}
+
+// Subscribe calls deliver with published data until ctx is done
+func (b *MemoryBroker) Subscribe(ctx context.Context, deliver func(data []byte)) error {
+	b.mu.Lock()
+	b.subscribers[&deliver] = true
+	b.mu.Unlock()
+	<-ctx.Done()
+	b.mu.Lock()
+	delete(b.subscribers, &deliver)
+	b.mu.Unlock()
+	return nil
+}
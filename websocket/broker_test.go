@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// flakyBroker fails its first subscriptions
+type flakyBroker struct {
+	*MemoryBroker
+	failures atomic.Int32
+}
+
+func (b *flakyBroker) Subscribe(ctx context.Context, deliver func([]byte)) error {
+	if b.failures.Add(-1) >= 0 {
+		return errors.New("broker down")
+	}
+	return b.MemoryBroker.Subscribe(ctx, deliver)
+}
+
+// waitSubscribed waits until n managers are subscribed to b
+func waitSubscribed(t *testing.T, b *MemoryBroker, n int) {
+	t.Helper()
+	eventually(t, "the broker subscriptions", func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.subscribers) == n
+	})
+}
+
+func TestBrokerRelaysBroadcasts(t *testing.T) {
+	b := NewMemoryBroker()
+	options := func(o *Options) { o.SetupTimeout = -1 }
+	cmA, cmB := NewConnectionManager(WithBroker(b), options), NewConnectionManager(WithBroker(b), options)
+	clientA, _ := testServer(t, cmA, nil)()
+	clientB, connB := testServer(t, cmB, nil)()
+	cmB.Join(connB, "room")
+	eventually(t, "the join", func() bool { return len(connB.Topics()) == 1 })
+	waitSubscribed(t, b, 2)
+
+	cmA.Send(&Message{Type: "hello"})
+	for _, client := range []*gorilla.Conn{clientA, clientB} {
+		readType(t, client, "hello")
+	}
+	cmA.Publish("room", &Message{Type: "news"})
+	if msg := readType(t, clientB, "news"); msg.Topic != "room" {
+		t.Fatalf("published %+v", msg)
+	}
+	// The publishing manager delivers its own broadcasts once
+	expectNone(t, clientA, 200*time.Millisecond)
+}
+
+func TestBrokerResubscribesAndRelaysVariants(t *testing.T) {
+	b := &flakyBroker{MemoryBroker: NewMemoryBroker()}
+	b.failures.Store(2)
+	options := func(o *Options) { o.SetupTimeout = -1 }
+	cmA, cmB := NewConnectionManager(WithBroker(b), options), NewConnectionManager(WithBroker(b), options)
+	_, connA := testServer(t, cmA, nil)()
+	clientB, connB := testServer(t, cmB, nil)()
+	cmB.Join(connB, "room")
+	connB.SetLocale("de")
+	eventually(t, "the join", func() bool { return len(connB.Topics()) == 1 })
+	for _, cm := range []*ConnectionManager{cmA, cmB} {
+		cm.RegisterTemplate("greet", "greeting", "hi {{.Data}}")
+	}
+	waitSubscribed(t, b.MemoryBroker, 2)
+
+	cmA.PublishExcept("room", &Message{Type: "except"}, connA)
+	cmA.SendTemplate("greet", "bob")
+	cmA.SendLocalized(map[string]*Message{"de": {Type: "hallo"}, "en": {Type: "hello"}}, "en")
+	cmA.SendExcept(&Message{Type: "send except"}, connA)
+	for _, want := range []string{"except", "greeting", "hallo", "send except"} {
+		var msg Message
+		if err := clientB.ReadJSON(&msg); err != nil || msg.Type != want {
+			t.Fatalf("read %+v, %v, want %s", msg, err, want)
+		}
+	}
+}
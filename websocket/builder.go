@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+)
+
+// Server serves several websocket endpoints from one http server, each endpoint with its own ConnectionManager,
+// e.g.
+//
+//	srv := NewServer(WithClock(clock))
+//	srv.Endpoint("/chat", onChat, WithAuthenticator(authenticate))
+//	srv.Endpoint("/feed", onFeed)
+//	srv.Metrics("/metrics")
+//	srv.ListenAndServe(":8080", ServerOptions{})
+type Server struct {
+	shared      []Option
+	endpoints   []*Endpoint
+	metricsPath string
+	handler     http.Handler
+}
+
+// Endpoint is a websocket endpoint of a Server
+type Endpoint struct {
+	Path      string
+	Manager   *ConnectionManager
+	OnReceive func(*Message)
+}
+
+// NewServer server whose endpoints all start from the shared options
+func NewServer(shared ...Option) *Server {
+	return &Server{shared: shared}
+}
+
+// Endpoint adds an endpoint on path served by a new manager created with the shared options followed by
+// options, and returns the manager
+func (s *Server) Endpoint(path string, onReceive func(*Message), options ...Option) *ConnectionManager {
+	all := append(append([]Option(nil), s.shared...), options...)
+	cm := NewConnectionManager(all...)
+	s.Handle(path, cm, onReceive)
+	return cm
+}
+
+// Handle adds an endpoint on path served by an existing manager
+func (s *Server) Handle(path string, cm *ConnectionManager, onReceive func(*Message)) *Server {
+	if onReceive == nil {
+		onReceive = func(*Message) {}
+	}
+	s.endpoints = append(s.endpoints, &Endpoint{Path: path, Manager: cm, OnReceive: onReceive})
+	return s
+}
+
+// Metrics serves the LoadSignals, Stats and TypeStats of all endpoints on path, labeled by endpoint path
+func (s *Server) Metrics(path string) *Server {
+	s.metricsPath = path
+	return s
+}
+
+// Fallback serves requests that are not websocket upgrades of an endpoint
+func (s *Server) Fallback(handler http.Handler) *Server {
+	s.handler = handler
+	return s
+}
+
+// Endpoints added so far
+func (s *Server) Endpoints() []*Endpoint {
+	return s.endpoints
+}
+
+// Handler routes upgrades to the endpoints, metrics and everything else to the fallback handler
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, endpoint := range s.endpoints {
+		endpoint := endpoint
+		mux.HandleFunc(endpoint.Path, func(w http.ResponseWriter, r *http.Request) {
+			if s.handler != nil && !websocket.IsWebSocketUpgrade(r) {
+				s.handler.ServeHTTP(w, r)
+				return
+			}
+			endpoint.Manager.Receive(w, r, endpoint.OnReceive)
+		})
+	}
+	if s.metricsPath != "" {
+		mux.Handle(s.metricsPath, s.metricsHandler())
+	}
+	if s.handler != nil && !s.handles("/") {
+		mux.Handle("/", s.handler)
+	}
+	return mux
+}
+
+// ListenAndServe serves all endpoints on addr over plain http, only the server wide fields of opts are used
+func (s *Server) ListenAndServe(addr string, opts ServerOptions) error {
+	s.logger().Verbose("Listen and serve endpoints")
+	return newHTTPServer(addr, s.Handler(), opts).ListenAndServe()
+}
+
+// ListenAndServeTLS serves all endpoints on addr over https like ConnectionManager.ListenAndServeTLS
+func (s *Server) ListenAndServeTLS(addr string, opts ServerOptions) error {
+	s.logger().Verbose("Listen and serve TLS endpoints")
+	return serveTLS(newHTTPServer(addr, s.Handler(), opts), opts, s.logger())
+}
+
+// logger of the shared options
+func (s *Server) logger() Logger {
+	var opts Options
+	for _, option := range s.shared {
+		option(&opts)
+	}
+	if opts.Logger == nil {
+		return NopLogger()
+	}
+	return opts.Logger
+}
+
+func (s *Server) handles(path string) bool {
+	if s.metricsPath == path {
+		return true
+	}
+	for _, endpoint := range s.endpoints {
+		if endpoint.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, endpoint := range s.endpoints {
+			labels := fmt.Sprintf("endpoint=%s", strconv.Quote(endpoint.Path))
+			writeLoadSignals(w, labels, endpoint.Manager.LoadSignals())
+			writeStats(w, labels, endpoint.Manager.Stats())
+			writeTypeStats(w, labels, endpoint.Manager.TypeStats())
+			writeDeliverySkew(w, labels, endpoint.Manager.DeliverySkew())
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestServerBuilderEndpoints(t *testing.T) {
+	chat := make(chan *Message, 1)
+	s := NewServer(WithEvents(4), func(o *Options) { o.SetupTimeout = -1 })
+	chatManager := s.Endpoint("/chat", func(msg *Message) { chat <- msg }, WithLimits(10, 0, 0))
+	feedManager := s.Endpoint("/feed", nil)
+	s.Metrics("/metrics").Fallback(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fallback "+r.URL.Path)
+	}))
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	if chatManager.opts.MaxConnections != 10 || feedManager.opts.MaxConnections != 0 ||
+		cap(feedManager.events) != 4 {
+		t.Fatal("endpoint options not applied on top of the shared options")
+	}
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.WriteJSON(Message{Type: "hello"})
+	select {
+	case msg := <-chat:
+		if msg.Type != "hello" {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received by the chat endpoint")
+	}
+
+	get := func(path string) string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	metrics := get("/metrics")
+	for _, want := range []string{`websocket_connections{endpoint="/chat"} 1`, `websocket_connections{endpoint="/feed"} 0`} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Fatalf("metrics missing %s:\n%s", want, metrics)
+		}
+	}
+	for _, path := range []string{"/chat", "/other"} {
+		if body := get(path); body != "fallback "+path {
+			t.Fatalf("GET %s = %q", path, body)
+		}
+	}
+}
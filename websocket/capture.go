@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const captureBuffer = 1024
+
+// Capture directions
+const (
+	CaptureIn  = "in"
+	CaptureOut = "out"
+)
+
+// CapturedMessage is a sampled message
+type CapturedMessage struct {
+	Time         time.Time `json:"time"`
+	ConnectionID string    `json:"connectionId"`
+	Direction    string    `json:"direction"`
+	Message      *Message  `json:"message"`
+}
+
+// CaptureSink stores sampled messages, e.g. in a file, a Kafka topic or an HTTP collector
+type CaptureSink interface {
+	Capture(msg CapturedMessage) error
+}
+
+// CaptureSinkFunc adapts a function to CaptureSink, e.g. one producing to Kafka
+type CaptureSinkFunc func(msg CapturedMessage) error
+
+// Capture implements CaptureSink
+func (f CaptureSinkFunc) Capture(msg CapturedMessage) error {
+	return f(msg)
+}
+
+type capturer struct {
+	cm      *ConnectionManager
+	queue   chan CapturedMessage
+	dropped atomic.Int64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newCapturer(cm *ConnectionManager) *capturer {
+	if cm.opts.CaptureSink == nil || cm.opts.CaptureRate <= 0 {
+		return nil
+	}
+	c := &capturer{
+		cm:    cm,
+		queue: make(chan CapturedMessage, captureBuffer),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	go c.run()
+	return c
+}
+
+// CaptureDropped samples dropped because CaptureSink fell behind
+func (cm *ConnectionManager) CaptureDropped() int64 {
+	if cm.capturer == nil {
+		return 0
+	}
+	return cm.capturer.dropped.Load()
+}
+
+// capture samples msg of conn
+func (c *capturer) capture(conn *Connection, direction string, msg *Message) {
+	if c == nil || !c.sample() {
+		return
+	}
+	if c.cm.opts.CaptureRedact != nil {
+		msg = c.cm.opts.CaptureRedact(msg)
+		if msg == nil {
+			return
+		}
+	}
+	// The sink runs later, while handlers and writers may still change msg
+	msg = snapshotMessage(msg)
+	captured := CapturedMessage{
+		Time:         c.cm.clock.Now(),
+		ConnectionID: conn.ID(),
+		Direction:    direction,
+		Message:      msg,
+	}
+	select {
+	case c.queue <- captured:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// snapshotMessage copies msg with its data encoded, so the copy shares nothing with msg
+func snapshotMessage(msg *Message) *Message {
+	copied := &Message{
+		Type:           msg.Type,
+		Topic:          msg.Topic,
+		Seq:            msg.Seq,
+		Key:            msg.Key,
+		Cursor:         msg.Cursor,
+		ID:             msg.ID,
+		AttachmentSize: msg.AttachmentSize,
+		Attachment:     bytes.Clone(msg.Attachment),
+	}
+	if msg.Extra != nil {
+		copied.Extra = make(map[string]json.RawMessage, len(msg.Extra))
+		for key, value := range msg.Extra {
+			copied.Extra[key] = bytes.Clone(value)
+		}
+	}
+	switch data := msg.Data.(type) {
+	case nil:
+	case string:
+		copied.Data = data
+	case []byte:
+		copied.Data = bytes.Clone(data)
+	default:
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			copied.Data = fmt.Sprintf("%v", data)
+		} else {
+			copied.Data = json.RawMessage(encoded)
+		}
+	}
+	return copied
+}
+
+func (c *capturer) sample() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.Float64() < c.cm.opts.CaptureRate
+}
+
+func (c *capturer) run() {
+	for {
+		select {
+		case <-c.cm.done:
+			return
+		case msg := <-c.queue:
+			c.cm.logE(c.cm.opts.CaptureSink.Capture(msg), "Failed to capture message")
+		}
+	}
+}
+
+// FileCaptureSink appends captured messages as JSON lines to the file at path
+func FileCaptureSink(path string) (CaptureSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(f)
+	return CaptureSinkFunc(func(msg CapturedMessage) error {
+		return encoder.Encode(msg)
+	}), nil
+}
+
+// HTTPCaptureSink posts each captured message as JSON to url
+func HTTPCaptureSink(url string, client *http.Client) CaptureSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return CaptureSinkFunc(func(msg CapturedMessage) error {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("websocket: capture sink returned %s", resp.Status)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCaptureSamplesRedactedMessages(t *testing.T) {
+	captured := make(chan CapturedMessage, 4)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.CaptureRate = 1
+		o.CaptureSink = CaptureSinkFunc(func(msg CapturedMessage) error {
+			captured <- msg
+			return nil
+		})
+		o.CaptureRedact = func(msg *Message) *Message {
+			if msg.Type == "password" {
+				return nil
+			}
+			return msg
+		}
+	})
+	client, conn := testServer(t, cm, func(conn *Connection, msg *Message) {
+		conn.Send(&Message{Type: "echo", Data: msg.Data})
+	})()
+	client.WriteJSON(Message{Type: "password", Data: "hunter2"})
+	readType(t, client, "echo")
+
+	// The password message is redacted, only the echo is captured
+	select {
+	case msg := <-captured:
+		if msg.Direction != CaptureOut || msg.ConnectionID != conn.ID() || msg.Message.Type != "echo" {
+			t.Fatalf("captured %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("echo not captured")
+	}
+}
+
+func TestCaptureCopiesMessages(t *testing.T) {
+	captured := make(chan CapturedMessage, 1)
+	cm := NewConnectionManager(func(o *Options) {
+		o.CaptureRate = 1
+		o.CaptureSink = CaptureSinkFunc(func(msg CapturedMessage) error {
+			captured <- msg
+			return nil
+		})
+	})
+	msg := &Message{Type: "in", Data: map[string]interface{}{"a": 1.0}}
+	cm.capturer.capture(&Connection{}, CaptureIn, msg)
+	msg.Data.(map[string]interface{})["a"] = 2.0
+	msg.Type = "changed"
+	select {
+	case got := <-captured:
+		raw, _ := json.Marshal(got.Message)
+		if string(raw) != `{"type":"in","data":{"a":1}}` {
+			t.Fatalf("captured %s, want the message as it was when sampled", raw)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not captured")
+	}
+}
@@ -0,0 +1,155 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the time source used for timeouts, TTLs, debouncing and scheduling. Network read and write deadlines
+// of sockets always use the system clock.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine after d
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer returned by Clock.AfterFunc
+type Timer interface {
+	Stop() bool
+}
+
+// Ticker returned by Clock.NewTicker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the default Clock
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock that only moves when advanced, for deterministic tests
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	when   time.Time
+	period time.Duration // Non zero for tickers
+	f      func()
+	c      chan time.Time
+}
+
+// NewFakeClock fake clock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now current fake time
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+// AfterFunc calls f synchronously from Advance once the fake time reaches now+d
+func (fc *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return fc.schedule(&fakeTimer{clock: fc, f: f}, d)
+}
+
+// NewTicker ticker ticking from Advance
+func (fc *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("websocket: non-positive interval for NewTicker")
+	}
+	return fakeTicker{fc.schedule(&fakeTimer{clock: fc, period: d, c: make(chan time.Time, 1)}, d)}
+}
+
+// Advance moves the fake time forward by d, firing due timers and tickers in time order
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	end := fc.now.Add(d)
+	for {
+		sort.SliceStable(fc.timers, func(i, j int) bool { return fc.timers[i].when.Before(fc.timers[j].when) })
+		if len(fc.timers) == 0 || fc.timers[0].when.After(end) {
+			break
+		}
+		t := fc.timers[0]
+		fc.now = t.when
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+			select {
+			case t.c <- fc.now:
+			default:
+			}
+			continue
+		}
+		fc.timers = fc.timers[1:]
+		fc.mu.Unlock()
+		t.f()
+		fc.mu.Lock()
+	}
+	fc.now = end
+	fc.mu.Unlock()
+}
+
+func (fc *FakeClock) schedule(t *fakeTimer, d time.Duration) *fakeTimer {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	t.when = fc.now.Add(d)
+	fc.timers = append(fc.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	fc := t.clock
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for i, pending := range fc.timers {
+		if pending == t {
+			fc.timers = append(fc.timers[:i], fc.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFakeClockFiresTimersInOrder(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	var fired []time.Duration
+	record := func() { fired = append(fired, clock.Now().Sub(start)) }
+	clock.AfterFunc(3*time.Second, record)
+	clock.AfterFunc(time.Second, func() {
+		record()
+		// Timers scheduled while advancing fire in the same Advance when due
+		clock.AfterFunc(time.Second, record)
+	})
+	stopped := clock.AfterFunc(2*time.Second, record)
+	if !stopped.Stop() {
+		t.Fatal("pending timer not stopped")
+	}
+
+	clock.Advance(time.Second / 2)
+	if len(fired) != 0 {
+		t.Fatalf("fired %v before due", fired)
+	}
+	clock.Advance(5 * time.Second)
+	if want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}; !slices.Equal(fired, want) {
+		t.Fatalf("fired at %v, want %v", fired, want)
+	}
+	if got := clock.Now().Sub(start); got != 5*time.Second+time.Second/2 {
+		t.Fatalf("now %v after advancing", got)
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	ticker := clock.NewTicker(time.Second)
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatal("ticker did not tick")
+	}
+	ticker.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker ticked")
+	default:
+	}
+}
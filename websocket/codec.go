@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrUnsupportedData is returned by BinaryCodec for messages whose data is not bytes or a string
+var ErrUnsupportedData = errors.New("websocket: codec does not support message data")
+
+// Codec encodes messages to websocket frames and decodes them, e.g. JSON, raw binary or protobuf with the
+// generated types carried in Message.Data
+type Codec interface {
+	// FrameType of encoded messages, websocket.TextMessage or websocket.BinaryMessage
+	FrameType() int
+	// Encode writes msg as one frame
+	Encode(w io.Writer, msg *Message) error
+	// Decode reads one frame into msg
+	Decode(r io.Reader, msg *Message) error
+}
+
+// JSONCodec encodes messages as JSON text frames, the default codec
+type JSONCodec struct {
+	// PreserveUnknownFields keeps unknown top level fields in Message.Extra
+	PreserveUnknownFields bool
+}
+
+// FrameType text
+func (JSONCodec) FrameType() int {
+	return websocket.TextMessage
+}
+
+// Encode msg as JSON
+func (JSONCodec) Encode(w io.Writer, msg *Message) error {
+	return json.NewEncoder(w).Encode(msg)
+}
+
+// Decode one JSON message
+func (c JSONCodec) Decode(r io.Reader, msg *Message) error {
+	var err error
+	if c.PreserveUnknownFields {
+		var raw json.RawMessage
+		err = json.NewDecoder(r).Decode(&raw)
+		if err == nil {
+			err = decodeMessage(raw, msg)
+		}
+	} else {
+		err = json.NewDecoder(r).Decode(msg)
+	}
+	if err == io.EOF {
+		// One value is expected in the message
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// BinaryCodec passes raw binary frames, decoded messages have Type and the frame bytes as Data. Only messages
+// with []byte, json.RawMessage or string data can be encoded.
+type BinaryCodec struct {
+	Type string
+}
+
+// FrameType binary
+func (BinaryCodec) FrameType() int {
+	return websocket.BinaryMessage
+}
+
+// Encode writes the data of msg as is
+func (BinaryCodec) Encode(w io.Writer, msg *Message) error {
+	var err error
+	switch data := msg.Data.(type) {
+	case []byte:
+		_, err = w.Write(data)
+	case json.RawMessage:
+		_, err = w.Write(data)
+	case string:
+		_, err = io.WriteString(w, data)
+	default:
+		err = ErrUnsupportedData
+	}
+	return err
+}
+
+// Decode reads the frame into the data of msg
+func (c BinaryCodec) Decode(r io.Reader, msg *Message) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	msg.Type = c.Type
+	msg.Data = data
+	return nil
+}
+
+// Codec of the connection
+func (c *Connection) Codec() Codec {
+	return c.codec
+}
+
+// codecFor chooses the codec of a new connection, the decision of OnBeforeUpgrade first and then the codec of
+// the negotiated subprotocol
+func (cm *ConnectionManager) codecFor(decision UpgradeDecision, subprotocol string) Codec {
+	if decision.Codec != nil {
+		return decision.Codec
+	}
+	if codec, ok := cm.opts.SubprotocolCodecs[subprotocol]; ok {
+		return codec
+	}
+	return cm.opts.Codec
+}
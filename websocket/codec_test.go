@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestBinaryCodecFrames(t *testing.T) {
+	received := make(chan *Message, 1)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.Codec = BinaryCodec{Type: "bin"}
+	})
+	client, conn := testServer(t, cm, func(_ *Connection, msg *Message) { received <- msg })()
+
+	client.WriteMessage(gorilla.BinaryMessage, []byte("from client"))
+	select {
+	case msg := <-received:
+		if data, _ := msg.Data.([]byte); msg.Type != "bin" || string(data) != "from client" {
+			t.Fatalf("decoded %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("binary frame not received")
+	}
+
+	// Data the codec cannot encode is dropped and the connection stays
+	cm.SendTo(conn, &Message{Type: "x", Data: map[string]int{"n": 1}})
+	cm.SendTo(conn, &Message{Type: "x", Data: "from server"})
+	frameType, data, err := client.ReadMessage()
+	if err != nil || frameType != gorilla.BinaryMessage || string(data) != "from server" {
+		t.Fatalf("read %d %q, %v", frameType, data, err)
+	}
+	if stats := cm.Stats(); stats.Dropped != 1 || stats.WriteErrors != 0 || stats.Connections != 1 {
+		t.Fatalf("stats %+v after the unencodable message", stats)
+	}
+}
+
+func TestCodecChosenPerConnection(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.Subprotocols = []string{"raw"}
+		o.SubprotocolCodecs = map[string]Codec{"raw": BinaryCodec{Type: "raw"}}
+		o.OnBeforeUpgrade = func(r *http.Request) UpgradeDecision {
+			if r.URL.Query().Has("chosen") {
+				return UpgradeDecision{Codec: BinaryCodec{Type: "chosen"}}
+			}
+			return UpgradeDecision{}
+		}
+	})
+	conns := make(chan *Connection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	defer srv.Close()
+	codec := func(query string, subprotocols ...string) Codec {
+		t.Helper()
+		dialer := gorilla.Dialer{Subprotocols: subprotocols}
+		client, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		return (<-conns).Codec()
+	}
+
+	if got := codec(""); got != (JSONCodec{}) {
+		t.Fatalf("default codec %#v", got)
+	}
+	if got := codec("", "raw"); got != (BinaryCodec{Type: "raw"}) {
+		t.Fatalf("codec of the raw subprotocol %#v", got)
+	}
+	if got := codec("chosen", "raw"); got != (BinaryCodec{Type: "chosen"}) {
+		t.Fatalf("codec of the decision %#v, want it to take precedence", got)
+	}
+}
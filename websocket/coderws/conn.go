@@ -0,0 +1,204 @@
+// Package coderws adapts connections of github.com/coder/websocket, formerly nhooyr.io/websocket, to
+// websocket.Conn so a ConnectionManager can manage them, e.g.
+//
+//	c, err := cws.Accept(w, r, nil)
+//	if err != nil {
+//		return
+//	}
+//	cm.Accept(r, coderws.Wrap(c), onReceive)
+package coderws
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	cws "github.com/coder/websocket"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// errTimeout is the read error once the read deadline passed
+var errTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "coderws: read deadline exceeded" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Conn is a coder/websocket connection implementing websocket.Conn. Read deadlines end the connection once they
+// pass, as reads of gorilla/websocket cannot continue after a timeout either. Pings are answered by
+// coder/websocket itself, the pong handler is called with the payload of the ping once it is answered.
+type Conn struct {
+	conn *cws.Conn
+	// readCtx is cancelled when the read deadline passes
+	readCtx    context.Context
+	cancelRead context.CancelFunc
+	expired    chan struct{}
+	expireOnce sync.Once
+
+	mu       sync.Mutex
+	deadline *time.Timer
+	reader   io.Reader // Of the previous message, drained before reading the next one
+	pong     func(appData string) error
+}
+
+var _ websocket.Conn = (*Conn)(nil)
+
+// Wrap adapts conn
+func Wrap(conn *cws.Conn) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Conn{
+		conn:       conn,
+		readCtx:    ctx,
+		cancelRead: cancel,
+		expired:    make(chan struct{}),
+	}
+}
+
+// NextReader returns the reader of the next data message
+func (c *Conn) NextReader() (int, io.Reader, error) {
+	c.mu.Lock()
+	previous := c.reader
+	c.reader = nil
+	c.mu.Unlock()
+	if previous != nil {
+		// coder/websocket fails when the previous message was not read to its end
+		if _, err := io.Copy(io.Discard, previous); err != nil {
+			return 0, nil, err
+		}
+	}
+	typ, r, err := c.conn.Reader(c.readCtx)
+	if err != nil {
+		return 0, nil, c.readError(err)
+	}
+	r = &reader{conn: c, r: r}
+	c.mu.Lock()
+	c.reader = r
+	c.mu.Unlock()
+	return int(typ), r, nil
+}
+
+// NextWriter returns a writer for the next message of messageType
+func (c *Conn) NextWriter(messageType int) (io.WriteCloser, error) {
+	return c.conn.Writer(context.Background(), cws.MessageType(messageType))
+}
+
+// WriteMessage writes data as one message of messageType
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	return c.conn.Write(context.Background(), cws.MessageType(messageType), data)
+}
+
+// WriteControl starts the close handshake for close frames and pings the peer for ping frames. Both complete in
+// the background.
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	switch messageType {
+	case gorilla.CloseMessage:
+		code, reason := cws.StatusNoStatusRcvd, ""
+		if len(data) >= 2 {
+			code, reason = cws.StatusCode(binary.BigEndian.Uint16(data)), string(data[2:])
+		}
+		go c.conn.Close(code, reason)
+		return nil
+	case gorilla.PingMessage:
+		go func() {
+			if c.conn.Ping(c.readCtx) != nil {
+				return
+			}
+			c.mu.Lock()
+			pong := c.pong
+			c.mu.Unlock()
+			if pong != nil {
+				pong(string(data))
+			}
+		}()
+		return nil
+	}
+	return errors.New("coderws: unsupported control message")
+}
+
+// SetReadDeadline ends the connection with a timeout error of pending and future reads once t passes
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deadline != nil {
+		c.deadline.Stop()
+		c.deadline = nil
+	}
+	if t.IsZero() {
+		return nil
+	}
+	c.deadline = time.AfterFunc(time.Until(t), c.expire)
+	return nil
+}
+
+// SetReadLimit bounds the size of read messages
+func (c *Conn) SetReadLimit(limit int64) {
+	c.conn.SetReadLimit(limit)
+}
+
+// SetPongHandler sets the handler called when a ping is answered
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	c.mu.Lock()
+	c.pong = h
+	c.mu.Unlock()
+}
+
+// Subprotocol negotiated during the upgrade
+func (c *Conn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+// Close closes the network connection without close frame
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	if c.deadline != nil {
+		c.deadline.Stop()
+	}
+	c.mu.Unlock()
+	err := c.conn.CloseNow()
+	c.cancelRead()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (c *Conn) expire() {
+	c.expireOnce.Do(func() {
+		close(c.expired)
+	})
+	c.cancelRead()
+}
+
+// readError translates errors of coder/websocket to the errors the manager expects
+func (c *Conn) readError(err error) error {
+	select {
+	case <-c.expired:
+		return errTimeout
+	default:
+	}
+	var closeErr cws.CloseError
+	if errors.As(err, &closeErr) {
+		return &gorilla.CloseError{Code: int(closeErr.Code), Text: closeErr.Reason}
+	}
+	return err
+}
+
+type reader struct {
+	conn *Conn
+	r    io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = r.conn.readError(err)
+	}
+	return n, err
+}
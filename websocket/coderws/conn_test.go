@@ -0,0 +1,90 @@
+package coderws
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cws "github.com/coder/websocket"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func TestManagedCoderConnection(t *testing.T) {
+	received := make(chan *websocket.Message, 2)
+	disconnected := make(chan error, 1)
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 50 * time.Millisecond
+		o.OnDisconnect = func(_ *websocket.Connection, err error) { disconnected <- err }
+	})
+	conns := make(chan *websocket.Connection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := cws.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn, err := cm.Accept(r, Wrap(c), func(_ *websocket.Connection, msg *websocket.Message) { received <- msg })
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	defer srv.Close()
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn := <-conns
+
+	client.WriteJSON(map[string]interface{}{"type": "upload", "attachment": 2})
+	client.WriteMessage(gorilla.BinaryMessage, []byte{1, 2})
+	select {
+	case msg := <-received:
+		if msg.Type != "upload" || len(msg.Attachment) != 2 {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	cm.Send(&websocket.Message{Type: "download", Attachment: []byte("blob")})
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg websocket.Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "download" || msg.AttachmentSize != 4 {
+		t.Fatalf("read %+v, %v", msg, err)
+	}
+	if _, data, err := client.ReadMessage(); err != nil || string(data) != "blob" {
+		t.Fatalf("read attachment %q, %v", data, err)
+	}
+
+	// Pings are answered while the client reads
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for conn.RTT() <= 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no RTT measured through the adapter")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cm.Disconnect(conn, 4000, "bye")
+	select {
+	case err := <-disconnected:
+		var closeErr *gorilla.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != 4000 {
+			t.Fatalf("removed with %v, want the close frame", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not removed")
+	}
+}
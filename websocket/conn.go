@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type connectionState int
+
+const (
+	statePending connectionState = iota
+	stateActive                  // Authenticated but not ready for broadcasts yet
+	stateReady
+)
+
+// Connection is a single websocket connection managed by ConnectionManager
+type Connection struct {
+	manager *ConnectionManager
+	id      string
+	socket  Conn
+	ctx     context.Context
+	pool    string
+	codec   Codec
+	shard   int
+	state   connectionState // Only accessed from the operations goroutine
+	added   time.Time       // Only accessed from the operations goroutine
+
+	presenceKey string // Only accessed from the operations goroutine
+
+	session *replaySession // With SessionReplayWindow
+
+	// Flow control state, only accessed from the operations goroutine
+	credits int
+	backlog []*Message
+
+	// Outbound queue drained by the writer goroutine, stop is closed when the connection is removed
+	queue   chan outbound
+	stop    chan struct{}
+	closing bool // Close frame queued, only accessed from the operations goroutine
+
+	// Pause state, only accessed from the operations goroutine
+	paused bool
+	held   []*Message
+
+	// Subscriptions, only accessed from the operations goroutine
+	interests map[string]bool      // Entity IDs declared with SetInterests
+	topics    map[string]bool      // Topics joined with Join, ResumeStream or SubscribeKeyed
+	leases    map[string]time.Time // Expiry of joined topics with SubscriptionLease
+	swapped   map[string]string    // Topics moved by SwapTopic to their new topic
+
+	// Adaptive coalescing state, only accessed from the operations goroutine
+	coalesced *coalescedKeys
+	nextFlush time.Time
+
+	reads   readCounters
+	inbound *inboundLimiter // With InboundLimit, only accessed from the reader goroutine
+	usage   usageCounters
+	scratch *Scratch     // Removed once the reader is done
+	rtt     atomic.Int64 // Smoothed round trip time in nanoseconds
+	calls   atomic.Int32 // HandleCall handlers running, they may outlive their timeout
+
+	deadlineArmed atomic.Bool // Read deadline follows keepalive once the first message timeout no longer applies
+	closeSent     atomic.Bool // Close frame sent, or not to be sent after Terminate
+	writeFailed   atomic.Bool // A write failed and the connection is being removed
+	quotaClosed   atomic.Bool // Disconnected by QuotaDisconnect
+	pinging       atomic.Bool // A ping of pingLoop is being written
+	readDone      chan struct{}
+
+	// Binary stream written off the fan-out shard and the writes held until it ends, with FanoutShards
+	streamMu   sync.Mutex
+	streaming  bool
+	streamHeld []*Message
+
+	mu       sync.RWMutex
+	identity interface{}
+	locale   string
+	values   map[string]interface{}
+}
+
+func newConnection(cm *ConnectionManager, id string, socket Conn) *Connection {
+	c := &Connection{
+		manager:  cm,
+		id:       id,
+		socket:   socket,
+		state:    statePending,
+		credits:  cm.opts.InitialCredits,
+		readDone: make(chan struct{}),
+		scratch:  &Scratch{parent: cm.opts.ScratchDir, budget: cm.opts.ScratchBudget},
+	}
+	c.shard = cm.shardFor(c.id)
+	if cm.opts.FanoutShards <= 0 {
+		c.queue = make(chan outbound, cm.opts.WriteQueueSize)
+		c.stop = make(chan struct{})
+	}
+	c.reads.lastRead.Store(cm.clock.Now().UnixNano())
+	socket.SetPongHandler(func(payload string) error {
+		now := cm.clock.Now()
+		c.readProgress(now, true)
+		c.measureRTT(now, payload)
+		cm.keepalive(c)
+		return nil
+	})
+	return c
+}
+
+// MarkReady lets the connection receive broadcasts when RequireReady is set, messages queued to the connection
+// before are delivered first
+func (c *Connection) MarkReady() {
+	c.manager.enqueue(&socketOperation{
+		opType: markReady,
+		conn:   c,
+	})
+}
+
+// Send sends msg to this connection only
+func (c *Connection) Send(msg *Message) {
+	c.manager.SendTo(c, msg)
+}
+
+// Context carries the values of the upgraded request context, it is not cancelled with the request
+func (c *Connection) Context() context.Context {
+	return c.ctx
+}
+
+// Pool label chosen for the connection by Options.OnBeforeUpgrade
+func (c *Connection) Pool() string {
+	return c.pool
+}
+
+// Identity returned by the authenticator for this connection, nil until authenticated
+func (c *Connection) Identity() interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.identity
+}
+
+// Anonymous true while the connection has not authenticated
+func (c *Connection) Anonymous() bool {
+	return c.Identity() == nil
+}
+
+// Disconnect closes the connection gracefully, messages already queued to it are flushed before the close frame
+func (c *Connection) Disconnect(code int, reason string) {
+	c.manager.Disconnect(c, code, reason)
+}
+
+// Terminate closes the underlying network connection immediately without a close frame
+func (c *Connection) Terminate() {
+	c.manager.Terminate(c)
+}
+
+func (c *Connection) setIdentity(identity interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.identity = identity
+}
@@ -17,10 +17,15 @@ PASS
 package websocket
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/qulia/go-log/log"
 )
 
 type socketOperationType int
@@ -29,108 +34,662 @@ const (
 	add socketOperationType = iota
 	remove
 	send
+	activate
+	sendTo
+	markReady
+	disconnect
+	shed
+	call
+	grant
+	pause
+	resume
+	interest
+	publishEntity
+	sendLocalized
+	sendTemplate
+	coalesce
+	flushCoalesced
+	join
+	leave
+	swapTopic
+	sendWhere
 )
 
 type socketOperation struct {
 	opType socketOperationType
-	socket *websocket.Conn
+	conn   *Connection
 	msg    *Message
+	close  *closeFrame
+	err    error
+
+	fraction float64
+	fn       func()
+	credits  int
+	ids      []string
+	filter   func(*Connection) bool
+	queued   time.Time // Of broadcasts, for the broadcast latency
+
+	localized *localizedMessage
+	template  *templateSend
 }
 
 // ConnectionManager manages web socket connections
 type ConnectionManager struct {
-	sockets    map[*websocket.Conn]bool // Using map for faster removal and access
+	registry   Registry
 	upgrader   websocket.Upgrader
 	operations chan *socketOperation
+	opts       Options
+	presence   *presence
+	sessions   *sessionTokens
+	events     chan Event
+	clock      Clock
+	faults     *faultInjector
+	load       loadCounters
+	entities   *entityIndex
+	templates  templates
+	rejections rejectionCounts
+	compacted  map[string]map[string]*Message // Latest message per key of keyed topics, owned by the operations goroutine
+	coalesced  map[string]*coalescedKeys      // Keyed updates waiting for the next flush, owned by the operations goroutine
+	coalescing map[*Connection]bool           // Connections with adaptively coalesced updates, owned by the operations goroutine
+	pacer      pacer                          // Owned by the operations goroutine
+	shards     []*fanoutShard
+	memory     memoryAccount
+	usage      usageAccounts
+	quotas     quotaAccounts
+	replay     replaySessions
+	byID       map[string]*Connection // Owned by the operations goroutine
+
+	handshakeTimeouts atomic.Int64
+	admitted          atomic.Int64
+	maintenance       atomic.Pointer[maintenance]
+	deprecated        deprecationCounts
+	types             typeMetrics
+	metrics           managerMetrics
+	capturer          *capturer
+	router            router
+	relay             *brokerRelay
+	jobs              *jobRelay
+	tasks             taskRegistry
+	skew              skewTracker
+
+	// Shutdown state
+	done       chan struct{}  // Closed to stop the operations and background goroutines
+	stopped    chan struct{}  // Closed when the operations goroutine returned
+	handshakes sync.WaitGroup // closeHandshake goroutines of removed connections
+	closing    atomic.Bool
+	shutdown   sync.Once
+}
+
+// NewConnectionManager connection manager with DefaultOptions changed by options, e.g.
+// NewConnectionManager(WithUpgrader(upgrader), WithAuthenticator(authenticate))
+func NewConnectionManager(options ...Option) *ConnectionManager {
+	opts := DefaultOptions()
+	for _, option := range options {
+		option(&opts)
+	}
+	return NewConnectionManagerWithOptions(opts)
 }
 
-// NewConnectionManager default connection manager
-func NewConnectionManager() *ConnectionManager {
-	log.V("New connection manager\n")
+// NewConnectionManagerWithOptions connection manager configured with opts
+func NewConnectionManagerWithOptions(opts Options) *ConnectionManager {
 	cm := new(ConnectionManager)
-	cm.upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+	opts.setDefaults()
+	cm.opts = opts
+	cm.logV("New connection manager")
+	cm.clock = opts.Clock
+	cm.faults = newFaultInjector(opts.Faults)
+	cm.presence = newPresence(opts)
+	cm.sessions = newSessionTokens(opts.SessionTokenTTL)
+	cm.types.max = opts.MaxMessageTypes
+	cm.events = make(chan Event, opts.EventsBuffer)
+	if opts.Upgrader != nil {
+		cm.upgrader = *opts.Upgrader
+	} else {
+		cm.upgrader = websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		}
+	}
+	if opts.ReadBufferSize > 0 {
+		cm.upgrader.ReadBufferSize = opts.ReadBufferSize
+	}
+	if opts.WriteBufferSize > 0 {
+		cm.upgrader.WriteBufferSize = opts.WriteBufferSize
+	}
+	if opts.CheckOrigin != nil {
+		cm.upgrader.CheckOrigin = opts.CheckOrigin
+	}
+	if opts.EnableCompression {
+		cm.upgrader.EnableCompression = true
+	}
+	if opts.Subprotocols != nil {
+		cm.upgrader.Subprotocols = opts.Subprotocols
+	}
+	if cm.upgrader.HandshakeTimeout == 0 {
+		cm.upgrader.HandshakeTimeout = opts.HandshakeTimeout
 	}
-	cm.sockets = make(map[*websocket.Conn]bool)
-	cm.operations = make(chan *socketOperation, 1)
+	if cm.upgrader.Error == nil {
+		cm.upgrader.Error = cm.upgradeError
+	}
+	cm.registry = opts.Registry
+	if cm.registry == nil {
+		cm.registry = NewMapRegistry()
+	}
+	cm.entities = newEntityIndex()
+	cm.byID = make(map[string]*Connection)
+	cm.startShards()
+	cm.operations = make(chan *socketOperation, opts.OperationsBuffer)
+	cm.done = make(chan struct{})
+	cm.stopped = make(chan struct{})
 	go func() {
-		for op := range cm.operations {
+		defer close(cm.stopped)
+		for {
+			var op *socketOperation
+			select {
+			case op = <-cm.operations:
+			case <-cm.done:
+				return
+			}
 			switch op.opType {
 			case add:
-				cm.addSocket(op.socket)
+				cm.addSocket(op.conn)
 			case remove:
-				cm.removeSocket(op.socket)
+				cm.removeSocket(op.conn, op.err)
+			case activate:
+				cm.activateSocket(op.conn)
 			case send:
-				for socket := range cm.sockets {
-					log.V("Sending message on websocket\n")
-					err := socket.WriteJSON(op.msg)
-					if err != nil {
-						log.E(err, "Write was not successful, will remove the socket\n")
-						cm.operations <- &socketOperation{
-							opType: remove,
-							socket: socket,
-							msg:    nil,
-						}
-					}
+				cm.broadcast(op.msg)
+				cm.metrics.observeBroadcast(cm.clock.Now().Sub(op.queued))
+			case sendTo:
+				if cm.registry.Contains(op.conn) {
+					cm.deliver(op.conn, op.msg)
+				} else {
+					op.msg.report(ErrConnectionClosed)
+				}
+			case markReady:
+				if op.conn.state == stateActive {
+					op.conn.state = stateReady
 				}
+			case disconnect:
+				cm.disconnect(op.conn, op.close)
+			case shed:
+				cm.shedConnections(op.fraction)
+			case call:
+				op.fn()
+			case grant:
+				cm.grantCredits(op.conn, op.credits)
+			case pause:
+				op.conn.paused = true
+			case resume:
+				cm.resumeConnection(op.conn)
+			case interest:
+				cm.updateInterests(op.conn, op.ids)
+			case publishEntity:
+				cm.publishToEntity(op.ids[0], op.msg, op.filter)
+			case sendLocalized:
+				cm.sendLocalizedMessage(op.localized)
+			case sendTemplate:
+				cm.sendTemplateMessage(op.template)
+			case coalesce:
+				cm.coalesceKeyed(op.ids[0], op.msg)
+			case flushCoalesced:
+				cm.flushKeyed()
+			case join:
+				cm.joinTopic(op.conn, op.ids[0])
+			case leave:
+				cm.leaveTopic(op.conn, op.ids[0])
+			case swapTopic:
+				cm.moveSubscribers(op.ids[0], op.ids[1])
+			case sendWhere:
+				cm.sendWhere(op.filter, op.msg)
 			}
 		}
 	}()
+	go cm.sampleLoad()
+	if cm.opts.CloseStalled {
+		go cm.closeStalled()
+	}
+	if cm.opts.SetupTimeout > 0 {
+		go cm.reapHalfOpen()
+	}
+	if cm.opts.SubscriptionLease > 0 {
+		go cm.expireLeasesLoop()
+	}
+	if cm.opts.CoalesceInterval > 0 {
+		go cm.flushCoalescedLoop()
+	}
+	if cm.opts.PingInterval > 0 {
+		go cm.pingLoop()
+	}
+	if cm.opts.NoopInterval > 0 {
+		go cm.noopLoop()
+	}
+	if cm.opts.OnUsageExport != nil {
+		go cm.exportUsage()
+	}
+	cm.capturer = newCapturer(cm)
+	cm.startBroker()
+	cm.startJobs()
 	return cm
 }
 
-// Receive upgrade http to websocket and listen
+// Receive upgrade http to websocket and listen. Returns the connection, or the error when the upgrade failed
+// after the error response was written to w. Rejections are UpgradeRejection errors.
 func (cm *ConnectionManager) Receive(
-	w http.ResponseWriter, r *http.Request, onReceive func(*Message)) {
-	log.V("Receive\n")
-	socket, err := cm.upgrader.Upgrade(w, r, nil)
+	w http.ResponseWriter, r *http.Request, onReceive func(*Message)) (*Connection, error) {
+	return cm.ReceiveConn(w, r, func(_ *Connection, msg *Message) {
+		onReceive(msg)
+	})
+}
 
-	// TODO make this log.E
-	log.F(err, "Upgrade to websocket failed\n")
-	cm.operations <- &socketOperation{
-		opType: add,
-		socket: socket,
+// ReceiveConn is Receive with onReceive getting the connection each message was read from, to answer it with
+// Connection.Send
+func (cm *ConnectionManager) ReceiveConn(
+	w http.ResponseWriter, r *http.Request, onReceive func(*Connection, *Message)) (*Connection, error) {
+	cm.logV("Receive")
+	if err := cm.rejectShutdown(w, r); err != nil {
+		return nil, err
+	}
+	if err := cm.rejectMaintenance(w, r); err != nil {
+		return nil, err
+	}
+	cm.faults.handshakeDelay(cm.clock)
+	decision, err := cm.beforeUpgrade(w, r)
+	if err != nil {
+		cm.logV("Upgrade aborted by OnBeforeUpgrade")
+		return nil, err
+	}
+	r = decision.Request
+	identity, err := cm.authenticateRequest(w, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := cm.rejectQuota(w, r, decision, identity); err != nil {
+		return nil, err
+	}
+	if err := cm.admit(w, r); err != nil {
+		return nil, err
+	}
+	hw, err := hijackable(w)
+	if err != nil {
+		cm.unadmit()
+		cm.logE(err, "Cannot upgrade, wrap the response writer with Hijack or Unwrap support")
+		return nil, cm.reject(w, r, UpgradeRejection{
+			Status:  http.StatusInternalServerError,
+			Reason:  RejectNotHijacker,
+			Message: err.Error(),
+		})
+	}
+	hw, flushing := cm.withFlushing(hw)
+	id := cm.NewID()
+	socket, err := cm.upgrader.Upgrade(hw, r, cm.affinityHeader(r, decision.Header, id))
+	if err != nil {
+		cm.unadmit()
+	}
+	if err != nil && isTimeout(err) {
+		cm.handshakeTimeouts.Add(1)
+		cm.rejected(RejectHandshakeTimeout)
+		cm.logE(err, "Upgrade to websocket timed out")
+		return nil, err
+	}
+	if err != nil {
+		// The upgrader already answered through upgradeError
+		cm.logE(err, "Upgrade to websocket failed")
+		return nil, err
+	}
+	return cm.manage(r, decision, id, flushing.flushed(socket), identity, onReceive), nil
+}
+
+// manage starts managing the upgraded socket
+func (cm *ConnectionManager) manage(r *http.Request, decision UpgradeDecision, id string, socket Conn,
+	identity interface{}, onReceive func(*Connection, *Message)) *Connection {
+	cm.tuneSocket(socket)
+	if cm.opts.MaxMessageSize > 0 {
+		socket.SetReadLimit(cm.opts.MaxMessageSize)
+	}
+	conn := newConnection(cm, id, socket)
+	conn.ctx = context.WithoutCancel(r.Context())
+	conn.pool = decision.Pool
+	conn.codec = cm.codecFor(decision, socket.Subprotocol())
+	for key, value := range decision.Values {
+		conn.Set(key, value)
+	}
+	if identity != nil {
+		conn.setIdentity(identity)
+	}
+	if cm.opts.Authenticate == nil || cm.opts.AllowAnonymous || identity != nil {
+		conn.state = cm.activeState()
+	}
+	if info, received := cm.attachSession(conn, r); info != nil {
+		cm.enqueue(&socketOperation{
+			opType: call,
+			fn: func() {
+				cm.addSession(conn, info, received)
+			},
+		})
+	} else {
+		cm.enqueue(&socketOperation{
+			opType: add,
+			conn:   conn,
+		})
 	}
 
-	// TODO handle failures
-	go cm.receive(socket, onReceive)
+	if conn.queue != nil {
+		go cm.writeLoop(conn)
+	}
+	go cm.receive(conn, onReceive)
+	return conn
 }
 
 // Send messages on web socket
 func (cm *ConnectionManager) Send(msg *Message) {
-	cm.operations <- &socketOperation{
+	cm.toBroker("", msg)
+	cm.sendLocal(msg)
+}
+
+// sendLocal broadcasts msg to the connections of this manager only, skipping the Broker
+func (cm *ConnectionManager) sendLocal(msg *Message) {
+	cm.enqueue(&socketOperation{
 		opType: send,
-		socket: nil,
+		conn:   nil,
 		msg:    msg,
-	}
+		queued: cm.clock.Now(),
+	})
+}
+
+// SendTo sends msg to conn only, e.g. a response or a private notification. Messages to a closed connection
+// are discarded.
+func (cm *ConnectionManager) SendTo(conn *Connection, msg *Message) {
+	cm.enqueue(&socketOperation{
+		opType: sendTo,
+		conn:   conn,
+		msg:    msg,
+	})
+}
+
+// HandshakeTimeouts number of connections closed for not completing the handshake or sending the
+// first message in time
+func (cm *ConnectionManager) HandshakeTimeouts() int64 {
+	return cm.handshakeTimeouts.Load()
 }
 
 func (cm *ConnectionManager) receive(
-	socket *websocket.Conn, onReceive func(*Message)) {
+	conn *Connection, onReceive func(*Connection, *Message)) {
+	defer close(conn.readDone)
+	defer cm.dropScratch(conn)
+	socket := conn.socket
+	first := cm.opts.FirstMessageTimeout > 0
+	authPending := cm.opts.Authenticate != nil && !cm.opts.AllowAnonymous && conn.Anonymous()
+	var firstTimer Timer
+	if first {
+		// Expire the pending read through the clock so the timeout follows fake clocks in tests
+		firstTimer = cm.clock.AfterFunc(cm.opts.FirstMessageTimeout, func() {
+			cm.logE(socket.SetReadDeadline(time.Now()), "Failed to set read deadline")
+		})
+	}
+	if !first {
+		conn.deadlineArmed.Store(true)
+		cm.keepalive(conn)
+	}
+	if !authPending && !cm.runConnectPipeline(conn) {
+		return
+	}
 	for {
 		msg := Message{}
-		err := socket.ReadJSON(&msg)
+		size, err := cm.readMessage(conn, &msg)
 
 		if err != nil {
-			log.E(err, "Error reading message from the socket\n")
-			cm.operations <- &socketOperation{
+			if first && isTimeout(err) {
+				cm.handshakeTimeouts.Add(1)
+				cm.rejected(RejectHandshakeTimeout)
+			}
+			cm.logE(err, "Error reading message from the socket")
+			cm.publish(ErrorEvent{Conn: conn, Err: err, Time: cm.clock.Now()})
+			cm.enqueue(&socketOperation{
 				opType: remove,
-				socket: socket,
+				conn:   conn,
 				msg:    nil,
+				err:    err,
+			})
+			break
+		}
+
+		if first {
+			first = false
+			if !firstTimer.Stop() {
+				cm.logE(socket.SetReadDeadline(time.Time{}), "Failed to clear read deadline")
 			}
+			conn.deadlineArmed.Store(true)
+		}
+		cm.keepalive(conn)
+		cm.load.messages.Add(1)
+		conn.readProgress(cm.clock.Now(), false)
+		if cm.faults.dropInbound() {
+			continue
+		}
+		allowed, disconnect := cm.limitInbound(conn, &msg, size)
+		if disconnect {
 			break
 		}
+		if !allowed {
+			continue
+		}
+		if !cm.quotaAllows(conn, true) {
+			continue
+		}
+		cm.resolveAlias(&msg)
+		if authPending || cm.isAuthMessage(&msg) {
+			if !cm.authenticate(conn, &msg) {
+				break
+			}
+			if authPending && !cm.runConnectPipeline(conn) {
+				break
+			}
+			authPending = false
+			continue
+		}
+		if msg.binary {
+			cm.opts.OnBinary(conn, msg.Data.([]byte))
+			continue
+		}
+		if cm.opts.FlowControl && msg.Type == cm.opts.CreditMessageType {
+			cm.receiveCredits(conn, &msg)
+			continue
+		}
+		if cm.opts.InterestMessageType != "" && msg.Type == cm.opts.InterestMessageType {
+			cm.receiveInterests(conn, &msg)
+			continue
+		}
+		if cm.opts.JoinMessageType != "" && msg.Type == cm.opts.JoinMessageType {
+			cm.receiveJoin(conn, &msg, true)
+			continue
+		}
+		if cm.opts.LeaveMessageType != "" && msg.Type == cm.opts.LeaveMessageType {
+			cm.receiveJoin(conn, &msg, false)
+			continue
+		}
+		if cm.opts.CursorMessageType != "" && msg.Type == cm.opts.CursorMessageType {
+			cm.receiveCursors(conn, &msg)
+			continue
+		}
+		if cm.opts.ReadyMessageType != "" && msg.Type == cm.opts.ReadyMessageType {
+			conn.MarkReady()
+			continue
+		}
+		if cm.opts.TaskCancelMessageType != "" && msg.Type == cm.opts.TaskCancelMessageType {
+			cm.cancelTask(conn, &msg)
+			continue
+		}
+		if cm.isCall(&msg) {
+			cm.answerCall(conn, &msg)
+			continue
+		}
+		if cm.isJob(&msg) {
+			cm.pushJob(conn, &msg)
+			continue
+		}
+		cm.capturer.capture(conn, CaptureIn, &msg)
+		start := time.Now()
+		onReceive(conn, &msg)
+		cm.types.observe(msg.Type, time.Since(start))
+	}
+}
+
+// call runs fn on the operations goroutine and waits for it, fn can access manager state directly
+func (cm *ConnectionManager) call(fn func()) {
+	done := make(chan struct{})
+	cm.enqueue(&socketOperation{
+		opType: call,
+		fn: func() {
+			fn()
+			close(done)
+		},
+	})
+	select {
+	case <-done:
+	case <-cm.stopped:
+	}
+}
 
-		onReceive(&msg)
+// enqueue queues op for the operations goroutine, operations queued after Shutdown are discarded
+func (cm *ConnectionManager) enqueue(op *socketOperation) {
+	select {
+	case cm.operations <- op:
+	case <-cm.done:
 	}
 }
 
-func (cm *ConnectionManager) addSocket(socket *websocket.Conn) {
-	cm.sockets[socket] = true
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// write queues msg to the writer goroutine of conn, or to its fan-out shard with FanoutShards. Runs on the
+// operations goroutine.
+func (cm *ConnectionManager) write(conn *Connection, msg *Message) {
+	if cm.shards != nil {
+		cm.queueShard(conn, msg)
+		return
+	}
+	cm.queueWrite(conn, outbound{msg: msg})
 }
 
-func (cm *ConnectionManager) removeSocket(socket *websocket.Conn) {
-	log.E(socket.Close(), "Failed to close socket\n")
-	delete(cm.sockets, socket)
+func (cm *ConnectionManager) writeNow(conn *Connection, msg *Message) {
+	if cm.faults.dropOutbound() {
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "injected fault", Time: cm.clock.Now()})
+		return
+	}
+	if msg.Type != cm.opts.RateLimitMessageType && !cm.quotaAllows(conn, false) {
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "quota exceeded", Time: cm.clock.Now()})
+		return
+	}
+	if conn.writeFailed.Load() {
+		cm.sessionUnsent(conn, msg)
+		msg.report(ErrConnectionClosed)
+		return
+	}
+	cm.logV("Sending message on websocket")
+	err := cm.faults.writeError()
+	if err == nil {
+		err = cm.writeMessage(conn, msg)
+	}
+	var encodeErr *encodeError
+	if errors.As(err, &encodeErr) {
+		cm.logE(err, "Dropping message the codec cannot encode")
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "encode failed", Time: cm.clock.Now()})
+		return
+	}
+	if err != nil {
+		cm.logE(err, "Write was not successful, will remove the socket")
+		cm.sessionUnsent(conn, msg)
+		msg.report(err)
+		cm.types.failed(msg.Type)
+		cm.metrics.writeErrors.Add(1)
+		cm.publish(ErrorEvent{Conn: conn, Err: err, Time: cm.clock.Now()})
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "write failed", Time: cm.clock.Now()})
+		cm.removeFailed(conn, err)
+		return
+	}
+	cm.load.messages.Add(1)
+	cm.capturer.capture(conn, CaptureOut, msg)
+	msg.report(nil)
+}
+
+// removeFailed queues the removal of conn after a write error without blocking the writer, once. Writes of
+// later messages are skipped.
+func (cm *ConnectionManager) removeFailed(conn *Connection, err error) {
+	if !conn.writeFailed.CompareAndSwap(false, true) {
+		return
+	}
+	go cm.enqueue(&socketOperation{
+		opType: remove,
+		conn:   conn,
+		err:    err,
+	})
+}
+
+// activeState of a connection once authenticated
+func (cm *ConnectionManager) activeState() connectionState {
+	if cm.opts.RequireReady {
+		return stateActive
+	}
+	return stateReady
+}
+
+func (cm *ConnectionManager) addSocket(conn *Connection) {
+	conn.added = cm.clock.Now()
+	cm.registry.Add(conn)
+	cm.byID[conn.id] = conn
+	cm.load.connections.Add(1)
+	cm.publish(ConnectEvent{Conn: conn, Time: cm.clock.Now()})
+	if cm.opts.OnConnect != nil {
+		cm.opts.OnConnect(conn)
+	}
+	if conn.state != statePending {
+		cm.presence.track(conn)
+	}
+}
+
+func (cm *ConnectionManager) activateSocket(conn *Connection) {
+	if !cm.registry.Contains(conn) {
+		return
+	}
+	if conn.state == statePending {
+		conn.state = cm.activeState()
+	}
+	cm.presence.track(conn)
+}
+
+func (cm *ConnectionManager) removeSocket(conn *Connection, err error) {
+	if !cm.registry.Contains(conn) {
+		cm.logE(conn.socket.Close(), "Failed to close socket")
+		return
+	}
+	cm.handshakes.Add(1)
+	go func() {
+		defer cm.handshakes.Done()
+		cm.closeHandshake(conn, err)
+	}()
+	cm.registry.Remove(conn)
+	delete(cm.byID, conn.id)
+	cm.load.connections.Add(-1)
+	cm.unadmit()
+	cm.publish(DisconnectEvent{Conn: conn, Err: err, Time: cm.clock.Now()})
+	if cm.opts.OnDisconnect != nil {
+		cm.opts.OnDisconnect(conn, err)
+	}
+	cm.presence.untrack(conn)
+	cm.detachSession(conn)
+	cm.dropInterests(conn)
+	delete(cm.coalescing, conn)
+	if conn.stop != nil {
+		close(conn.stop)
+	}
+	cm.sessionUnsent(conn, conn.backlog...)
+	cm.sessionUnsent(conn, conn.held...)
+	cm.releaseAll(conn.backlog)
+	cm.releaseAll(conn.held)
+	abandon(conn.backlog)
+	abandon(conn.held)
+	conn.backlog, conn.held = nil, nil
 }
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendToAnswersOneConnection(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	dial := testServer(t, cm, func(conn *Connection, msg *Message) {
+		conn.Send(&Message{Type: "reply", Data: msg.Data})
+	})
+	asking, conn := dial()
+	other, _ := dial()
+
+	asking.WriteJSON(Message{Type: "question", Data: "ping"})
+	if msg := readType(t, asking, "reply"); msg.Data != "ping" {
+		t.Fatalf("reply %v", msg.Data)
+	}
+	cm.SendTo(conn, &Message{Type: "private"})
+	cm.Send(&Message{Type: "public"})
+	readType(t, asking, "private")
+	var msg Message
+	if err := other.ReadJSON(&msg); err != nil || msg.Type != "public" {
+		t.Fatalf("other connection got %+v, %v, want the broadcast only", msg, err)
+	}
+
+	conn.Terminate()
+	eventually(t, "the connection to be removed", func() bool { return cm.Stats().Connections == 1 })
+	cm.SendTo(conn, &Message{Type: "late"})
+	cm.Send(&Message{Type: "after"})
+	readType(t, other, "after")
+}
+
+func TestReceiveReturnsUpgradeErrors(t *testing.T) {
+	cm := NewConnectionManager()
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := cm.Receive(w, r, func(*Message) {})
+		errs <- err
+	}))
+	defer srv.Close()
+	get := func() error {
+		t.Helper()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return <-errs
+	}
+
+	if err := get(); err == nil || cm.Rejections()[RejectBadHandshake] != 1 {
+		t.Fatalf("err = %v, want the failed handshake", err)
+	}
+	var rejection UpgradeRejection
+	cm.EnterMaintenance("upgrading", time.Minute, false)
+	if err := get(); !errors.As(err, &rejection) || rejection.Reason != RejectMaintenance ||
+		rejection.Status != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want a %s rejection", err, RejectMaintenance)
+	}
+}
@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	defaultMaxCreditBacklog = 1024
+	defaultMaxCredits       = 1 << 16
+)
+
+var errInvalidCredits = errors.New("websocket: credit message data must be a positive integer")
+
+// deliver writes msg to conn when flow control is off or the connection has credits left, otherwise keeps it
+// until the client grants more. Messages to paused connections are held until resumed. Runs on the operations
+// goroutine.
+func (cm *ConnectionManager) deliver(conn *Connection, msg *Message) {
+	if conn.paused {
+		cm.hold(conn, msg)
+		return
+	}
+	if !cm.opts.FlowControl {
+		cm.write(conn, msg)
+		return
+	}
+	if conn.credits > 0 && len(conn.backlog) == 0 {
+		conn.credits--
+		cm.write(conn, msg)
+		return
+	}
+	if len(conn.backlog) >= cm.opts.MaxCreditBacklog {
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "credit backlog full", Time: cm.clock.Now()})
+		return
+	}
+	if !cm.reserve(conn, msg) {
+		return
+	}
+	conn.backlog = append(conn.backlog, msg)
+}
+
+// grantCredits adds credits to conn and writes the backlog they cover. Runs on the operations goroutine.
+func (cm *ConnectionManager) grantCredits(conn *Connection, credits int) {
+	if !cm.registry.Contains(conn) {
+		return
+	}
+	conn.credits = min(conn.credits+credits, cm.opts.MaxCredits)
+	for conn.credits > 0 && len(conn.backlog) > 0 {
+		msg := conn.backlog[0]
+		conn.backlog[0] = nil
+		conn.backlog = conn.backlog[1:]
+		conn.credits--
+		cm.release(msg)
+		cm.write(conn, msg)
+	}
+}
+
+// creditsOf parses the number of credits granted by a credit message, capped to max
+func creditsOf(msg *Message, max int) (int, error) {
+	credits, ok := msg.Data.(float64)
+	if !ok || credits < 1 || credits != math.Trunc(credits) {
+		return 0, errInvalidCredits
+	}
+	if credits > float64(max) {
+		return max, nil
+	}
+	return int(credits), nil
+}
+
+// receiveCredits queues a credit grant read from conn
+func (cm *ConnectionManager) receiveCredits(conn *Connection, msg *Message) {
+	credits, err := creditsOf(msg, cm.opts.MaxCredits)
+	if err != nil {
+		cm.logE(err, "Ignoring credit message")
+		return
+	}
+	cm.enqueue(&socketOperation{
+		opType:  grant,
+		conn:    conn,
+		credits: credits,
+	})
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCreditsOf(t *testing.T) {
+	tests := []struct {
+		data    interface{}
+		credits int
+		err     bool
+	}{
+		{data: float64(3), credits: 3},
+		{data: float64(1e18), credits: 10},
+		{data: math.Inf(1), credits: 10},
+		{data: 2.5, err: true},
+		{data: 0.5, err: true},
+		{data: float64(0), err: true},
+		{data: float64(-1), err: true},
+		{data: math.NaN(), err: true},
+		{data: "3", err: true},
+	}
+	for _, test := range tests {
+		credits, err := creditsOf(&Message{Data: test.data}, 10)
+		if (err != nil) != test.err || credits != test.credits {
+			t.Errorf("creditsOf(%v) = %d, %v, want %d", test.data, credits, err, test.credits)
+		}
+	}
+}
+
+func TestGrantedCreditsCappedToMaxCredits(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.FlowControl = true
+		o.MaxCredits = 2
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+	})
+	client, conn := testServer(t, cm, nil)()
+	client.WriteJSON(Message{Type: "credit", Data: 1e18})
+	client.WriteJSON(Message{Type: "credit", Data: 5})
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		conn.Send(&Message{Type: "data"})
+	}
+	readType(t, client, "data")
+	readType(t, client, "data")
+	expectNone(t, client, 100*time.Millisecond)
+}
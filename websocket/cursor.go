@@ -0,0 +1,160 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for cursors that do not parse, are not signed with Options.CursorKey or point past
+// the end of their topic, e.g. issued before the history was reset
+var ErrInvalidCursor = errors.New("websocket: invalid cursor")
+
+// ErrCursorNotAuthorized is returned by ResumeStream when Options.AnonymousTopics or Options.AuthorizeJoin deny
+// the topic of the cursor
+var ErrCursorNotAuthorized = errors.New("websocket: cursor topic not authorized")
+
+const cursorKeySize = 32
+
+// Cursor is a position in a topic stream, clients get it as the opaque Message.Cursor of published messages
+type Cursor struct {
+	Topic string
+	Seq   uint64
+}
+
+// String encodes the cursor as an unsigned token, the manager appends its signature to the cursors it issues
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(c.Seq, 10) + ":" + c.Topic))
+}
+
+// ParseCursor decodes the position of a token returned by Cursor.String or issued by the manager, without
+// verifying its signature
+func ParseCursor(token string) (Cursor, error) {
+	token, _, _ = strings.Cut(token, ".")
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	seq, topic, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Topic: topic, Seq: n}, nil
+}
+
+// cursorToken returns the token of c signed with Options.CursorKey
+func (cm *ConnectionManager) cursorToken(c Cursor) string {
+	payload := c.String()
+	return payload + "." + base64.RawURLEncoding.EncodeToString(cm.cursorMAC(payload))
+}
+
+// verifyCursor decodes a token returned by cursorToken, rejecting tokens not signed with Options.CursorKey
+func (cm *ConnectionManager) verifyCursor(token string) (Cursor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, cm.cursorMAC(payload)) {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return ParseCursor(payload)
+}
+
+func (cm *ConnectionManager) cursorMAC(payload string) []byte {
+	h := hmac.New(sha256.New, cm.opts.CursorKey)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func randomCursorKey() []byte {
+	key := make([]byte, cursorKeySize)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// StreamResume describes where a resumed topic stream continues
+type StreamResume struct {
+	Topic string
+	// From is the first replayed sequence number
+	From uint64
+	// Trimmed reports that messages after the cursor were evicted by retention before From, the client missed
+	// them and should reload its state
+	Trimmed bool
+}
+
+// ResumeStream replays the messages of the cursor topic after the cursor and subscribes conn to the topic like
+// SubscribeWithBackfill. Cursors older than the retained history resume from the oldest retained message. Only
+// cursors signed by a manager sharing Options.CursorKey are accepted, and their topic must pass
+// Options.AnonymousTopics and Options.AuthorizeJoin.
+func (cm *ConnectionManager) ResumeStream(conn *Connection, token string) (StreamResume, error) {
+	cursor, err := cm.verifyCursor(token)
+	if err != nil {
+		return StreamResume{}, err
+	}
+	if !cm.authorizeTopic(conn, cursor.Topic) {
+		return StreamResume{}, ErrCursorNotAuthorized
+	}
+	if cm.opts.History == nil {
+		return StreamResume{}, ErrNoHistory
+	}
+	var resume StreamResume
+	cm.call(func() {
+		resume, err = cm.resumeStream(conn, cursor)
+	})
+	return resume, err
+}
+
+// resumeStream runs on the operations goroutine, so no message is published between validation and backfill
+func (cm *ConnectionManager) resumeStream(conn *Connection, cursor Cursor) (StreamResume, error) {
+	first, last, err := cm.opts.History.Bounds(cursor.Topic)
+	if err != nil {
+		return StreamResume{}, err
+	}
+	if cursor.Seq > last {
+		return StreamResume{}, ErrInvalidCursor
+	}
+	resume := StreamResume{Topic: cursor.Topic, From: cursor.Seq + 1}
+	if resume.From < first {
+		resume.From = first
+		resume.Trimmed = true
+	}
+	return resume, cm.backfill(conn, cursor.Topic, resume.From-1)
+}
+
+// receiveCursors resumes the streams of the cursors in a client message
+func (cm *ConnectionManager) receiveCursors(conn *Connection, msg *Message) {
+	var tokens []string
+	switch data := msg.Data.(type) {
+	case string:
+		tokens = []string{data}
+	case []interface{}:
+		for _, item := range data {
+			token, ok := item.(string)
+			if !ok {
+				cm.logE(ErrInvalidCursor, "Ignoring cursor message")
+				return
+			}
+			tokens = append(tokens, token)
+		}
+	default:
+		cm.logE(ErrInvalidCursor, "Ignoring cursor message")
+		return
+	}
+	for _, token := range tokens {
+		_, err := cm.ResumeStream(conn, token)
+		if err != nil {
+			cm.logE(err, "Failed to resume stream")
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestResumeStreamFromCursor(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.History = NewMemoryHistory(2)
+	})
+	client, conn := testServer(t, cm, nil)()
+	cm.Join(conn, "news")
+	cm.PublishEntity("news", &Message{Type: "m1"})
+	cursor := readType(t, client, "m1").Cursor
+	cm.Leave(conn, "news")
+	for i := 2; i <= 4; i++ {
+		cm.PublishEntity("news", &Message{Type: fmt.Sprint("m", i)})
+	}
+
+	resume, err := cm.ResumeStream(conn, cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resume != (StreamResume{Topic: "news", From: 3, Trimmed: true}) {
+		t.Fatalf("resume %+v, want from the oldest retained message", resume)
+	}
+	for _, want := range []string{"m3", "m4"} {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil || msg.Type != want {
+			t.Fatalf("got %+v, %v, want %s", msg, err, want)
+		}
+	}
+	if p, err := ParseCursor(cursor); err != nil || p != (Cursor{Topic: "news", Seq: 1}) {
+		t.Fatalf("ParseCursor = %+v, %v", p, err)
+	}
+}
+
+func TestResumeStreamRejectsCursors(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.History = NewMemoryHistory(10)
+		o.AuthorizeJoin = func(conn *Connection, topic string) bool { return topic != "secret" }
+	})
+	_, conn := testServer(t, cm, nil)()
+	cm.PublishEntity("public", &Message{Type: "m"})
+	cm.PublishEntity("secret", &Message{Type: "m"})
+	other := NewConnectionManager()
+
+	for name, c := range map[string]struct {
+		token string
+		err   error
+	}{
+		"unsigned":     {Cursor{Topic: "public"}.String(), ErrInvalidCursor},
+		"foreign key":  {other.cursorToken(Cursor{Topic: "public"}), ErrInvalidCursor},
+		"past the end": {cm.cursorToken(Cursor{Topic: "public", Seq: 5}), ErrInvalidCursor},
+		"unauthorized": {cm.cursorToken(Cursor{Topic: "secret"}), ErrCursorNotAuthorized},
+	} {
+		if _, err := cm.ResumeStream(conn, c.token); !errors.Is(err, c.err) {
+			t.Errorf("%s cursor: err = %v, want %v", name, err, c.err)
+		}
+	}
+	if _, err := cm.ResumeStream(conn, cm.cursorToken(Cursor{Topic: "public"})); err != nil {
+		t.Fatal(err)
+	}
+}
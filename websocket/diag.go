@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BenchmarkMessageType of client messages asking BenchmarkHandler for a burst, with data {"count": n, "size": s}
+const BenchmarkMessageType = "benchmark"
+
+// BenchmarkResultMessageType of the message ending a burst, with BenchmarkResult data
+const BenchmarkResultMessageType = "benchmark_result"
+
+// Limits of a benchmark burst, at most 16MiB of data per request
+const (
+	maxBenchmarkCount = 1000
+	maxBenchmarkSize  = 16 << 10
+)
+
+// BenchmarkResult ends a benchmark burst
+type BenchmarkResult struct {
+	Count int `json:"count"`
+	Size  int `json:"size"`
+	// Seconds the server took to queue the burst
+	Seconds float64 `json:"seconds"`
+}
+
+// EchoHandler upgrades requests and sends every message back to the connection it came from, to smoke-test
+// deployments
+func (cm *ConnectionManager) EchoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(conn *Connection, msg *Message) {
+			conn.Send(msg)
+		})
+	})
+}
+
+// DiscardHandler upgrades requests and discards every message, to measure the raw inbound path
+func (cm *ConnectionManager) DiscardHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.Receive(w, r, func(*Message) {})
+	})
+}
+
+// BenchmarkHandler upgrades requests and answers BenchmarkMessageType messages with a burst of count messages
+// of size bytes of data, followed by a BenchmarkResultMessageType message. Other messages are echoed. Bursts are
+// client controlled, so only requests authorize accepts are upgraded, the others are answered with 403. A nil
+// authorize refuses all requests.
+func (cm *ConnectionManager) BenchmarkHandler(authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			cm.reject(w, r, UpgradeRejection{
+				Status: http.StatusForbidden,
+				Reason: RejectUnauthorized,
+			})
+			return
+		}
+		cm.ReceiveConn(w, r, func(conn *Connection, msg *Message) {
+			if msg.Type != BenchmarkMessageType {
+				conn.Send(msg)
+				return
+			}
+			count, size := benchmarkParams(msg)
+			payload := strings.Repeat("x", size)
+			start := time.Now()
+			for i := 0; i < count; i++ {
+				conn.Send(&Message{Type: BenchmarkMessageType, Data: payload})
+			}
+			conn.Send(&Message{Type: BenchmarkResultMessageType, Data: BenchmarkResult{
+				Count:   count,
+				Size:    size,
+				Seconds: time.Since(start).Seconds(),
+			}})
+		})
+	})
+}
+
+func benchmarkParams(msg *Message) (int, int) {
+	params, _ := msg.Data.(map[string]interface{})
+	count, _ := params["count"].(float64)
+	size, _ := params["size"].(float64)
+	if count < 1 {
+		count = 1
+	}
+	if count > maxBenchmarkCount {
+		count = maxBenchmarkCount
+	}
+	if size < 0 {
+		size = 0
+	}
+	if size > maxBenchmarkSize {
+		size = maxBenchmarkSize
+	}
+	return int(count), int(size)
+}
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestEchoHandler(t *testing.T) {
+	srv := httptest.NewServer(NewConnectionManager().EchoHandler())
+	defer srv.Close()
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.WriteJSON(Message{Type: "hello", Data: "there"})
+	if msg := readType(t, client, "hello"); msg.Data != "there" {
+		t.Fatalf("echoed %+v", msg)
+	}
+}
+
+func TestBenchmarkHandler(t *testing.T) {
+	cm := NewConnectionManager()
+	srv := httptest.NewServer(cm.BenchmarkHandler(func(r *http.Request) bool { return r.URL.Query().Has("key") }))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	if _, resp, err := gorilla.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatal("unauthorized benchmark upgraded")
+	}
+	client, _, err := gorilla.DefaultDialer.Dial(url+"/?key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.WriteJSON(Message{Type: BenchmarkMessageType, Data: map[string]int{"count": 3, "size": 10}})
+	for i := 0; i < 3; i++ {
+		if msg := readType(t, client, BenchmarkMessageType); msg.Data != strings.Repeat("x", 10) {
+			t.Fatalf("burst message %+v", msg)
+		}
+	}
+	result, _ := readType(t, client, BenchmarkResultMessageType).Data.(map[string]interface{})
+	if result["count"] != float64(3) || result["size"] != float64(10) {
+		t.Fatalf("result %v", result)
+	}
+
+	closed := httptest.NewServer(cm.BenchmarkHandler(nil))
+	defer closed.Close()
+	if _, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(closed.URL, "http"), nil); err == nil {
+		t.Fatal("benchmark without authorize upgraded")
+	}
+}
+
+func TestBenchmarkParamsLimits(t *testing.T) {
+	huge := &Message{Data: map[string]interface{}{"count": float64(1 << 30), "size": float64(1 << 30)}}
+	if count, size := benchmarkParams(huge); count != maxBenchmarkCount || size != maxBenchmarkSize {
+		t.Fatalf("burst of %d messages of %d bytes", count, size)
+	}
+	if count, size := benchmarkParams(&Message{}); count != 1 || size != 0 {
+		t.Fatalf("default burst of %d messages of %d bytes", count, size)
+	}
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	closeWriteTimeout   = time.Second
+	defaultCloseTimeout = time.Second
+)
+
+type closeFrame struct {
+	code   int
+	reason string
+}
+
+// err reports the close frame to OnDisconnect and DisconnectEvent
+func (f *closeFrame) err() error {
+	return &websocket.CloseError{Code: f.code, Text: f.reason}
+}
+
+// Disconnect closes conn gracefully with a close frame carrying code and reason, after the messages already
+// queued to it are written. Use it for drains and normal shutdown.
+func (cm *ConnectionManager) Disconnect(conn *Connection, code int, reason string) {
+	cm.enqueue(&socketOperation{
+		opType: disconnect,
+		conn:   conn,
+		close:  &closeFrame{code: code, reason: reason},
+	})
+}
+
+// Terminate closes the network connection of conn immediately, queued messages are dropped and no close frame
+// is sent. Use it for abusive clients.
+func (cm *ConnectionManager) Terminate(conn *Connection) {
+	conn.closeSent.Store(true)
+	cm.logE(conn.socket.Close(), "Failed to terminate socket")
+	cm.enqueue(&socketOperation{
+		opType: remove,
+		conn:   conn,
+	})
+}
+
+// disconnect closes conn with frame after the messages queued to it, runs on the operations goroutine
+func (cm *ConnectionManager) disconnect(conn *Connection, frame *closeFrame) {
+	if !cm.registry.Contains(conn) {
+		return
+	}
+	if conn.queue != nil {
+		// The writer closes the connection after the queued messages
+		cm.queueWrite(conn, outbound{close: frame})
+		return
+	}
+	if len(cm.shards) > 0 {
+		cm.queueShardClose(conn, frame)
+		return
+	}
+	cm.writeClose(conn, frame)
+	cm.removeSocket(conn, frame.err())
+}
+
+func (cm *ConnectionManager) writeClose(conn *Connection, frame *closeFrame) {
+	if conn.closeSent.Swap(true) {
+		return
+	}
+	err := conn.socket.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(frame.code, frame.reason), time.Now().Add(closeWriteTimeout))
+	cm.logE(err, "Failed to write close frame")
+}
+
+// closeHandshake completes the close handshake of a removed connection: it sends a close frame with CloseCode
+// and CloseReason unless a close frame was already sent or received, waits up to CloseTimeout for the close
+// frame of the peer to end the read loop, and then closes the network connection
+func (cm *ConnectionManager) closeHandshake(conn *Connection, cause error) {
+	var peerClose *websocket.CloseError
+	if !errors.As(cause, &peerClose) {
+		cm.writeClose(conn, &closeFrame{code: cm.opts.CloseCode, reason: cm.opts.CloseReason})
+	}
+	timer := time.NewTimer(cm.opts.CloseTimeout)
+	select {
+	case <-conn.readDone:
+	case <-timer.C:
+		cm.logV("Peer did not answer the close frame")
+	}
+	timer.Stop()
+	cm.logE(conn.socket.Close(), "Failed to close socket")
+}
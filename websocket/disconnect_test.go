@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestDisconnectAfterQueuedMessages(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, conn := testServer(t, cm, nil)()
+	conn.Send(&Message{Type: "last words"})
+	cm.Disconnect(conn, gorilla.CloseGoingAway, "draining")
+	readType(t, client, "last words")
+	_, _, err := client.ReadMessage()
+	var closeErr *gorilla.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != gorilla.CloseGoingAway || closeErr.Text != "draining" {
+		t.Fatalf("err = %v, want the close frame", err)
+	}
+}
+
+func TestTerminateSendsNoCloseFrame(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, conn := testServer(t, cm, nil)()
+	cm.Terminate(conn)
+	_, _, err := client.ReadMessage()
+	var closeErr *gorilla.CloseError
+	if err == nil || errors.As(err, &closeErr) && closeErr.Code != gorilla.CloseAbnormalClosure {
+		t.Fatalf("err = %v, want the connection dropped without close frame", err)
+	}
+	eventually(t, "the connection to be removed", func() bool { return cm.Stats().Connections == 0 })
+}
+
+func TestOnDisconnectReportsCloseFrames(t *testing.T) {
+	connected := make(chan *Connection, 2)
+	disconnected := make(chan error, 2)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.OnConnect = func(conn *Connection) { connected <- conn }
+		o.OnDisconnect = func(_ *Connection, err error) { disconnected <- err }
+	})
+	dial := testServer(t, cm, nil)
+	expectClose := func(code int, text string) {
+		t.Helper()
+		select {
+		case err := <-disconnected:
+			var closeErr *gorilla.CloseError
+			if !errors.As(err, &closeErr) || closeErr.Code != code || closeErr.Text != text {
+				t.Fatalf("OnDisconnect got %v, want close %d %q", err, code, text)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("OnDisconnect not called")
+		}
+	}
+
+	_, conn := dial()
+	if got := <-connected; got != conn {
+		t.Fatal("OnConnect called with another connection")
+	}
+	cm.Disconnect(conn, 4001, "server done")
+	expectClose(4001, "server done")
+
+	client, _ := dial()
+	<-connected
+	client.WriteMessage(gorilla.CloseMessage, gorilla.FormatCloseMessage(4002, "client done"))
+	expectClose(4002, "client done")
+}
+
+func TestRemovedConnectionsCompleteCloseHandshake(t *testing.T) {
+	// Connections are removed as slow consumers, without a close frame of their own
+	slowManager := func() *ConnectionManager {
+		return NewConnectionManager(func(o *Options) {
+			o.SetupTimeout = -1
+			o.PingInterval = 0
+			o.WriteQueueSize = 2
+			o.WriteQueuePolicy = DisconnectSlow
+			o.CloseCode = 4003
+			o.CloseReason = "gone"
+			o.CloseTimeout = time.Second
+		})
+	}
+
+	// A client answering the close frame is closed without waiting for CloseTimeout
+	cm := slowManager()
+	client, conn, _ := blockedServer(t, cm)
+	fillQueue(t, cm, conn)
+	_, _, err := client.ReadMessage()
+	var closeErr *gorilla.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != 4003 || closeErr.Text != "gone" {
+		t.Fatalf("err = %v, want the CloseCode and CloseReason", err)
+	}
+	answered := time.Now()
+	if _, err := client.UnderlyingConn().Read(make([]byte, 1)); err == nil {
+		t.Fatal("data after the close frame")
+	}
+	if waited := time.Since(answered); waited > 500*time.Millisecond {
+		t.Fatalf("closed %v after the answer", waited)
+	}
+
+	// A client ignoring it is closed after CloseTimeout
+	cm = slowManager()
+	client, conn, _ = blockedServer(t, cm)
+	fillQueue(t, cm, conn)
+	removed := time.Now()
+	var frame []byte
+	buf := make([]byte, 64)
+	for {
+		n, err := client.UnderlyingConn().Read(buf)
+		frame = append(frame, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	if waited := time.Since(removed); waited < 900*time.Millisecond || waited > 3*time.Second {
+		t.Fatalf("closed after %v, want CloseTimeout", waited)
+	}
+	if len(frame) < 4 || frame[0] != 0x88 || int(frame[2])<<8|int(frame[3]) != 4003 {
+		t.Fatalf("read %x, want a close frame", frame)
+	}
+}
@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const entityIndexShards = 64
+
+// entityIndex maps entity IDs to the connections interested in them. It is sharded by ID so that lookups from
+// other goroutines contend only on the shard they touch while the operations goroutine updates the index.
+type entityIndex struct {
+	shards [entityIndexShards]entityShard
+}
+
+type entityShard struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Connection]struct{}
+}
+
+func newEntityIndex() *entityIndex {
+	index := &entityIndex{}
+	for i := range index.shards {
+		index.shards[i].subscribers = make(map[string]map[*Connection]struct{})
+	}
+	return index
+}
+
+func (index *entityIndex) shard(id string) *entityShard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &index.shards[h.Sum32()%entityIndexShards]
+}
+
+func (index *entityIndex) add(id string, conn *Connection) {
+	shard := index.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	conns, ok := shard.subscribers[id]
+	if !ok {
+		conns = make(map[*Connection]struct{})
+		shard.subscribers[id] = conns
+	}
+	conns[conn] = struct{}{}
+}
+
+func (index *entityIndex) remove(id string, conn *Connection) {
+	shard := index.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	conns := shard.subscribers[id]
+	delete(conns, conn)
+	if len(conns) == 0 {
+		delete(shard.subscribers, id)
+	}
+}
+
+// each calls fn for every connection interested in id, fn must not modify the index
+func (index *entityIndex) each(id string, fn func(*Connection)) {
+	shard := index.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	for conn := range shard.subscribers[id] {
+		fn(conn)
+	}
+}
+
+func (index *entityIndex) count(id string) int {
+	shard := index.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return len(shard.subscribers[id])
+}
+
+// EntitySubscribers number of connections interested in entity id
+func (cm *ConnectionManager) EntitySubscribers(id string) int {
+	return cm.entities.count(id)
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import "testing"
+
+func TestEntityIndex(t *testing.T) {
+	index := newEntityIndex()
+	a, b := &Connection{}, &Connection{}
+	index.add("x", a)
+	index.add("x", b)
+	index.add("x", a)
+	index.add("y", b)
+	if n := index.count("x"); n != 2 {
+		t.Fatalf("%d subscribers of x", n)
+	}
+	index.remove("x", a)
+	var got []*Connection
+	index.each("x", func(conn *Connection) { got = append(got, conn) })
+	if len(got) != 1 || got[0] != b {
+		t.Fatalf("subscribers of x %v", got)
+	}
+	index.remove("x", b)
+	index.remove("z", b)
+	if n := index.count("x"); n != 0 || index.count("y") != 1 {
+		t.Fatalf("%d subscribers of x after removing both", n)
+	}
+}
+
+func TestEntitySubscribersFollowInterestsAndTopics(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	_, conn := testServer(t, cm, nil)()
+	subscribers := func(n int) {
+		t.Helper()
+		conn.Topics()
+		if got := cm.EntitySubscribers("x"); got != n {
+			t.Fatalf("%d subscribers of x, want %d", got, n)
+		}
+	}
+
+	cm.SetInterests(conn, []string{"x"})
+	cm.Join(conn, "x")
+	subscribers(1)
+	cm.SetInterests(conn, nil)
+	subscribers(1)
+	cm.Leave(conn, "x")
+	subscribers(0)
+	cm.SetInterests(conn, []string{"x"})
+	subscribers(1)
+	cm.Terminate(conn)
+	eventually(t, "the connection to leave the index", func() bool { return cm.EntitySubscribers("x") == 0 })
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Top level fields of Message
+var messageFields = map[string]bool{
+	"type": true, "data": true, "topic": true, "seq": true, "key": true, "cursor": true, "attachment": true,
+	"id": true,
+}
+
+type deprecationCounts struct {
+	mu     sync.Mutex
+	byType map[string]int64
+}
+
+// DecodeMessage decodes b keeping unknown top level fields in Extra
+func DecodeMessage(b []byte) (*Message, error) {
+	msg := &Message{}
+	err := decodeMessage(b, msg)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func decodeMessage(b []byte, msg *Message) error {
+	err := json.Unmarshal(b, msg)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return err
+	}
+	for name := range messageFields {
+		delete(fields, name)
+	}
+	if len(fields) > 0 {
+		msg.Extra = fields
+	}
+	return nil
+}
+
+// MarshalJSON writes the known fields and Extra
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	b, err := json.Marshal(plain(m))
+	if err != nil || len(m.Extra) == 0 {
+		return b, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range m.Extra {
+		if !messageFields[name] {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// DeprecatedTypes counts of client messages received per deprecated type of Options.TypeAliases, to tell
+// when an old type can be removed
+func (cm *ConnectionManager) DeprecatedTypes() map[string]int64 {
+	cm.deprecated.mu.Lock()
+	defer cm.deprecated.mu.Unlock()
+	counts := make(map[string]int64, len(cm.deprecated.byType))
+	for msgType, count := range cm.deprecated.byType {
+		counts[msgType] = count
+	}
+	return counts
+}
+
+// resolveAlias renames a deprecated message type to its current name
+func (cm *ConnectionManager) resolveAlias(msg *Message) {
+	current, ok := cm.opts.TypeAliases[msg.Type]
+	if !ok {
+		return
+	}
+	cm.deprecated.mu.Lock()
+	if cm.deprecated.byType == nil {
+		cm.deprecated.byType = make(map[string]int64)
+	}
+	cm.deprecated.byType[msg.Type]++
+	cm.deprecated.mu.Unlock()
+	msg.Type = current
+}
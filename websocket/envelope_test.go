@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestUnknownFieldsAndTypeAliases(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PreserveUnknownFields = true
+		o.TypeAliases = map[string]string{"old": "new"}
+	})
+	client, _ := testServer(t, cm, func(conn *Connection, msg *Message) { conn.Send(msg) })()
+
+	client.WriteMessage(gorilla.TextMessage, []byte(`{"type":"old","data":1,"traceId":"t1"}`))
+	_, raw, err := client.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var echoed map[string]interface{}
+	json.Unmarshal(raw, &echoed)
+	if echoed["type"] != "new" || echoed["data"] != float64(1) || echoed["traceId"] != "t1" {
+		t.Fatalf("echoed %s", raw)
+	}
+	if counts := cm.DeprecatedTypes(); len(counts) != 1 || counts["old"] != 1 {
+		t.Fatalf("deprecated types %v", counts)
+	}
+}
+
+func TestDecodeMessageKeepsExtraFields(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"type":"t","topic":"x","v":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "t" || msg.Topic != "x" || len(msg.Extra) != 1 || string(msg.Extra["v"]) != "2" {
+		t.Fatalf("decoded %+v", msg)
+	}
+	if _, err := DecodeMessage([]byte(`{"type":`)); err == nil {
+		t.Fatal("invalid JSON decoded")
+	}
+}
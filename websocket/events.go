@@ -0,0 +1,77 @@
+package websocket
+
+import "time"
+
+const defaultEventsBuffer = 256
+
+// Event is one of ConnectEvent, DisconnectEvent, ErrorEvent, DropEvent, JoinEvent or LeaveEvent
+type Event interface {
+	event()
+}
+
+// ConnectEvent a connection was added to the manager
+type ConnectEvent struct {
+	Conn *Connection
+	Time time.Time
+}
+
+// DisconnectEvent a connection was removed from the manager, Err is the error that caused it as for
+// Options.OnDisconnect
+type DisconnectEvent struct {
+	Conn *Connection
+	Err  error
+	Time time.Time
+}
+
+// ErrorEvent reading from or writing to a connection failed
+type ErrorEvent struct {
+	Conn *Connection
+	Err  error
+	Time time.Time
+}
+
+// DropEvent a message was not delivered to a connection
+type DropEvent struct {
+	Conn    *Connection
+	Message *Message
+	Reason  string
+	Time    time.Time
+}
+
+// JoinEvent a connection joined a topic with Join
+type JoinEvent struct {
+	Conn  *Connection
+	Topic string
+	Time  time.Time
+}
+
+// LeaveEvent a connection left a topic with Leave
+type LeaveEvent struct {
+	Conn  *Connection
+	Topic string
+	Time  time.Time
+}
+
+func (ConnectEvent) event()    {}
+func (DisconnectEvent) event() {}
+func (ErrorEvent) event()      {}
+func (DropEvent) event()       {}
+func (JoinEvent) event()       {}
+func (LeaveEvent) event()      {}
+
+// Events stream of connection lifecycle events. Events are dropped rather than blocking the manager when the
+// consumer falls behind by more than Options.EventsBuffer events.
+func (cm *ConnectionManager) Events() <-chan Event {
+	return cm.events
+}
+
+func (cm *ConnectionManager) publish(event Event) {
+	if drop, ok := event.(DropEvent); ok {
+		drop.Message.report(&DropError{Reason: drop.Reason})
+		cm.metrics.dropped.Add(1)
+	}
+	select {
+	case cm.events <- event:
+	default:
+	}
+}
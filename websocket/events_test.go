@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// nextEvent reads the next event of cm, failing the test when none arrives
+func nextEvent(t *testing.T, cm *ConnectionManager) Event {
+	t.Helper()
+	select {
+	case event := <-cm.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func TestEventsFollowConnectionLifecycle(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, conn := testServer(t, cm, nil)()
+	cm.Join(conn, "news")
+	cm.Leave(conn, "news")
+	client.Close()
+
+	var got []string
+	for len(got) < 4 {
+		switch e := nextEvent(t, cm).(type) {
+		case ConnectEvent:
+			got = append(got, fmt.Sprint("connect ", e.Conn == conn))
+		case JoinEvent:
+			got = append(got, "join "+e.Topic)
+		case LeaveEvent:
+			got = append(got, "leave "+e.Topic)
+		case DisconnectEvent:
+			got = append(got, fmt.Sprint("disconnect ", e.Conn == conn))
+		}
+	}
+	want := []string{"connect true", "join news", "leave news", "disconnect true"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events %v, want %v", got, want)
+	}
+}
+
+func TestEventsDroppedWhenNotConsumed(t *testing.T) {
+	cm := NewConnectionManager(WithEvents(1), func(o *Options) { o.SetupTimeout = -1 })
+	_, conn := testServer(t, cm, nil)()
+	for i := 0; i < 10; i++ {
+		cm.Join(conn, fmt.Sprint(i))
+	}
+	// The manager is not blocked by the full channel
+	if topics := conn.Topics(); len(topics) != 10 {
+		t.Fatalf("topics %v", topics)
+	}
+	if _, ok := nextEvent(t, cm).(ConnectEvent); !ok {
+		t.Fatal("first event is not the connect event")
+	}
+}
@@ -0,0 +1,190 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// skewTimeout after which a broadcast not written to all its connections, e.g. because a message was dropped,
+// is counted as incomplete
+const skewTimeout = 10 * time.Second
+
+// DeliverySkew reports the fairness of the fan-out: the skew of a broadcast is the time between the first and
+// the last connection write completing. Percentiles cover the last Options.DeliverySkewSamples broadcasts.
+type DeliverySkew struct {
+	// Broadcasts measured, sent with Send or published to entities and written to all their connections
+	Broadcasts int64 `json:"broadcasts"`
+	// Incomplete broadcasts not written to all their connections within 10s, e.g. dropped for a slow consumer
+	Incomplete int64         `json:"incomplete"`
+	P50        time.Duration `json:"p50"`
+	P90        time.Duration `json:"p90"`
+	P99        time.Duration `json:"p99"`
+	Max        time.Duration `json:"max"`
+}
+
+// skewTracker matches connection writes to the broadcast they belong to
+type skewTracker struct {
+	mu         sync.Mutex
+	pending    map[*Message]*skewSample
+	skews      []time.Duration // Ring of the last samples
+	next       int
+	measured   int64
+	incomplete int64
+	pruned     time.Time
+}
+
+type skewSample struct {
+	started  time.Time
+	expected int
+	written  int
+	sealed   bool
+	first    time.Time
+	last     time.Time
+	msgs     []*Message // Written by the fan-out, several with Options.Transform
+}
+
+// DeliverySkew of the measured broadcasts, zero without Options.DeliverySkewSamples
+func (cm *ConnectionManager) DeliverySkew() DeliverySkew {
+	t := &cm.skew
+	t.mu.Lock()
+	report := DeliverySkew{Broadcasts: t.measured, Incomplete: t.incomplete}
+	skews := make([]time.Duration, len(t.skews))
+	copy(skews, t.skews)
+	t.mu.Unlock()
+	if len(skews) == 0 {
+		return report
+	}
+	sort.Slice(skews, func(i, j int) bool { return skews[i] < skews[j] })
+	quantile := func(q float64) time.Duration {
+		return skews[int(q*float64(len(skews)-1))]
+	}
+	report.P50 = quantile(0.5)
+	report.P90 = quantile(0.9)
+	report.P99 = quantile(0.99)
+	report.Max = skews[len(skews)-1]
+	return report
+}
+
+// measure starts measuring the skew of f, runs on the operations goroutine
+func (cm *ConnectionManager) measure(f *fanout) {
+	if cm.opts.DeliverySkewSamples <= 0 {
+		return
+	}
+	now := cm.clock.Now()
+	t := &cm.skew
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending == nil {
+		t.pending = make(map[*Message]*skewSample)
+	}
+	if now.Sub(t.pruned) >= time.Second {
+		t.prune(now)
+	}
+	f.skew = &skewSample{started: now}
+}
+
+// expect counts msg written to one more connection of the sample, runs on the operations goroutine
+func (t *skewTracker) expect(sample *skewSample, msg *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending[msg] != sample {
+		t.pending[msg] = sample
+		sample.msgs = append(sample.msgs, msg)
+	}
+	sample.expected++
+}
+
+// seal ends the fan-out of sample, it completes once written to the expected connections
+func (cm *ConnectionManager) seal(sample *skewSample) {
+	if sample == nil {
+		return
+	}
+	t := &cm.skew
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sample.sealed = true
+	t.complete(sample, cm.opts.DeliverySkewSamples)
+}
+
+// skewWritten records a completed connection write of msg
+func (cm *ConnectionManager) skewWritten(msg *Message) {
+	if cm.opts.DeliverySkewSamples <= 0 {
+		return
+	}
+	now := cm.clock.Now()
+	t := &cm.skew
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sample, ok := t.pending[msg]
+	if !ok {
+		return
+	}
+	if sample.written == 0 {
+		sample.first = now
+	}
+	sample.last = now
+	sample.written++
+	t.complete(sample, cm.opts.DeliverySkewSamples)
+}
+
+func (t *skewTracker) complete(sample *skewSample, samples int) {
+	if !sample.sealed || sample.written < sample.expected {
+		return
+	}
+	t.forget(sample)
+	if sample.expected == 0 {
+		return
+	}
+	t.measured++
+	skew := sample.last.Sub(sample.first)
+	if len(t.skews) < samples {
+		t.skews = append(t.skews, skew)
+		return
+	}
+	t.skews[t.next] = skew
+	t.next = (t.next + 1) % samples
+}
+
+// prune counts the samples pending for longer than skewTimeout as incomplete
+func (t *skewTracker) prune(now time.Time) {
+	t.pruned = now
+	expired := make(map[*skewSample]bool)
+	for msg, sample := range t.pending {
+		if now.Sub(sample.started) >= skewTimeout {
+			expired[sample] = true
+			delete(t.pending, msg)
+		}
+	}
+	t.incomplete += int64(len(expired))
+}
+
+func (t *skewTracker) forget(sample *skewSample) {
+	for _, msg := range sample.msgs {
+		if t.pending[msg] == sample {
+			delete(t.pending, msg)
+		}
+	}
+}
+
+// writeDeliverySkew writes skew in the Prometheus text format once a broadcast was measured
+func writeDeliverySkew(w io.Writer, labels string, skew DeliverySkew) {
+	if skew.Broadcasts == 0 && skew.Incomplete == 0 {
+		return
+	}
+	quantileLabels := labels
+	if labels != "" {
+		quantileLabels += ","
+		labels = "{" + labels + "}"
+	}
+	for _, q := range []struct {
+		quantile string
+		value    time.Duration
+	}{{"0.5", skew.P50}, {"0.9", skew.P90}, {"0.99", skew.P99}, {"1", skew.Max}} {
+		fmt.Fprintf(w, "websocket_delivery_skew_seconds{%squantile=\"%s\"} %g\n", quantileLabels, q.quantile, q.value.Seconds())
+	}
+	fmt.Fprintf(w, "websocket_delivery_skew_seconds_count%s %d\n", labels, skew.Broadcasts)
+	fmt.Fprintf(w, "websocket_delivery_skew_incomplete_total%s %d\n", labels, skew.Incomplete)
+}
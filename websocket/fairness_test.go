@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliverySkewMeasuresBroadcasts(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.DeliverySkewSamples = 10
+	})
+	dial := testServer(t, cm, nil)
+	first, _ := dial()
+	second, _ := dial()
+	for _, msgType := range []string{"a", "b", "c"} {
+		cm.Send(&Message{Type: msgType})
+	}
+	readType(t, first, "c")
+	readType(t, second, "c")
+	eventually(t, "the broadcasts to be measured", func() bool { return cm.DeliverySkew().Broadcasts == 3 })
+
+	skew := cm.DeliverySkew()
+	if skew.Incomplete != 0 || skew.P50 < 0 || skew.P50 > skew.P90 || skew.P99 > skew.Max {
+		t.Fatalf("skew %+v", skew)
+	}
+	rec := httptest.NewRecorder()
+	cm.LoadHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		"websocket_delivery_skew_seconds{quantile=\"0.5\"} ",
+		"websocket_delivery_skew_seconds_count 3\n",
+		"websocket_delivery_skew_incomplete_total 0\n",
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Fatalf("metrics missing %q:\n%s", line, rec.Body.String())
+		}
+	}
+}
+
+func TestDeliverySkewCountsIncompleteBroadcasts(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.DeliverySkewSamples = 10
+	})
+	_, _, release := blockedServer(t, cm)
+	defer release()
+	cm.Send(&Message{Type: "stuck"})
+	eventually(t, "the stuck broadcast to be measured", func() bool {
+		cm.skew.mu.Lock()
+		defer cm.skew.mu.Unlock()
+		return len(cm.skew.pending) == 1
+	})
+	clock.Advance(skewTimeout)
+	// Pending broadcasts are pruned when the next one starts
+	cm.Send(&Message{Type: "next"})
+	eventually(t, "the stuck broadcast to be incomplete", func() bool { return cm.DeliverySkew().Incomplete == 1 })
+	if n := cm.DeliverySkew().Broadcasts; n != 0 {
+		t.Fatalf("%d broadcasts measured, want none written", n)
+	}
+}
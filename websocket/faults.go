@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by writes failed through Faults
+var ErrInjectedFault = errors.New("websocket: injected fault")
+
+// Faults injects failures into the manager to test the resilience of applications built on it. The same Seed
+// produces the same sequence of faults for the same sequence of operations. Not for production use.
+type Faults struct {
+	Seed int64
+	// WriteErrorRate fraction of writes failing with ErrInjectedFault, which removes the connection
+	WriteErrorRate float64
+	// DropRate fraction of outbound messages silently not written
+	DropRate float64
+	// InboundDropRate fraction of inbound messages discarded before onReceive
+	InboundDropRate float64
+	// HandshakeDelay added before upgrading a fraction HandshakeDelayRate of connections, all of them when zero
+	HandshakeDelay     time.Duration
+	HandshakeDelayRate float64
+}
+
+type faultInjector struct {
+	faults Faults
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newFaultInjector(faults *Faults) *faultInjector {
+	if faults == nil {
+		return nil
+	}
+	return &faultInjector{
+		faults: *faults,
+		rand:   rand.New(rand.NewSource(faults.Seed)),
+	}
+}
+
+func (f *faultInjector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Float64() < rate
+}
+
+func (f *faultInjector) writeError() error {
+	if f != nil && f.hit(f.faults.WriteErrorRate) {
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+func (f *faultInjector) dropOutbound() bool {
+	return f != nil && f.hit(f.faults.DropRate)
+}
+
+func (f *faultInjector) dropInbound() bool {
+	return f != nil && f.hit(f.faults.InboundDropRate)
+}
+
+// handshakeDelay blocks for the configured handshake delay on clock
+func (f *faultInjector) handshakeDelay(clock Clock) {
+	if f == nil || f.faults.HandshakeDelay <= 0 {
+		return
+	}
+	if f.faults.HandshakeDelayRate > 0 && !f.hit(f.faults.HandshakeDelayRate) {
+		return
+	}
+	done := make(chan struct{})
+	clock.AfterFunc(f.faults.HandshakeDelay, func() { close(done) })
+	<-done
+}
@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFaultsSameSeedSameSequence(t *testing.T) {
+	sequence := func() []bool {
+		f := newFaultInjector(&Faults{Seed: 42, DropRate: 0.5})
+		hits := make([]bool, 20)
+		for i := range hits {
+			hits[i] = f.dropOutbound()
+		}
+		return hits
+	}
+	first, second := sequence(), sequence()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("sequences differ at %d", i)
+		}
+	}
+	var nilInjector *faultInjector
+	if nilInjector.dropOutbound() || nilInjector.writeError() != nil {
+		t.Fatal("nil injector injected a fault")
+	}
+}
+
+func TestInjectedWriteErrorRemovesConnection(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.Faults = &Faults{WriteErrorRate: 1}
+	})
+	_, conn := testServer(t, cm, nil)()
+	conn.Send(&Message{Type: "lost"})
+	for {
+		if e, ok := nextEvent(t, cm).(DisconnectEvent); ok {
+			if !errors.Is(e.Err, ErrInjectedFault) {
+				t.Fatalf("disconnected with %v", e.Err)
+			}
+			return
+		}
+	}
+}
+
+func TestInjectedDropsSkipDelivery(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.Faults = &Faults{DropRate: 1}
+	})
+	client, conn := testServer(t, cm, nil)()
+	conn.Send(&Message{Type: "lost"})
+	expectNone(t, client, 100*time.Millisecond)
+	if dropped := cm.Stats().Dropped; dropped != 1 {
+		t.Fatalf("%d dropped", dropped)
+	}
+}
@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultFlushBufferSize = 4096
+
+// Flush writes the frames held back by Options.FlushInterval to the network now, e.g. after the last message of
+// a burst. It does nothing without FlushInterval.
+func (c *Connection) Flush() error {
+	if s, ok := c.socket.(*flushingSocket); ok {
+		return s.conn.Flush()
+	}
+	return nil
+}
+
+// flushingWriter hijacks a bufferedConn so the frames written by the upgraded socket are coalesced
+type flushingWriter struct {
+	http.ResponseWriter
+	cm   *ConnectionManager
+	conn *bufferedConn
+}
+
+func (w *flushingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	netConn, brw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = &bufferedConn{
+		Conn:     netConn,
+		cm:       w.cm,
+		interval: w.cm.opts.FlushInterval,
+		w:        bufio.NewWriterSize(netConn, w.cm.opts.FlushBufferSize),
+	}
+	return w.conn, brw, nil
+}
+
+// withFlushing wraps the hijackable writer hw when FlushInterval is set
+func (cm *ConnectionManager) withFlushing(hw http.ResponseWriter) (http.ResponseWriter, *flushingWriter) {
+	if cm.opts.FlushInterval <= 0 {
+		return hw, nil
+	}
+	fw := &flushingWriter{ResponseWriter: hw, cm: cm}
+	return fw, fw
+}
+
+// flushed sends the upgrade response held by the buffer and returns the socket that flushes its control frames
+func (fw *flushingWriter) flushed(socket *websocket.Conn) Conn {
+	if fw == nil {
+		return socket
+	}
+	fw.cm.logE(fw.conn.Flush(), "Failed to flush upgrade response")
+	return &flushingSocket{Conn: socket, conn: fw.conn}
+}
+
+// bufferedConn holds writes until the buffer fills, Flush is called or interval passed since the first write
+// held. A failed flush of the timer fails the next write, so the writer removes the connection.
+type bufferedConn struct {
+	net.Conn
+	cm       *ConnectionManager
+	interval time.Duration
+
+	mu       sync.Mutex
+	w        *bufio.Writer
+	timer    Timer
+	flushErr error // Of the last flush by the timer
+}
+
+func (c *bufferedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.flushErr != nil {
+		return 0, c.flushErr
+	}
+	n, err := c.w.Write(p)
+	if c.w.Buffered() > 0 && c.timer == nil {
+		c.timer = c.cm.clock.AfterFunc(c.interval, c.flushTimer)
+	}
+	return n, err
+}
+
+// flushTimer flushes the writes held for interval and keeps the error for the next Write
+func (c *bufferedConn) flushTimer() {
+	err := c.Flush()
+	if err == nil {
+		return
+	}
+	c.cm.logE(err, "Failed to flush socket")
+	c.mu.Lock()
+	c.flushErr = err
+	c.mu.Unlock()
+}
+
+func (c *bufferedConn) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	return c.w.Flush()
+}
+
+// Close drops the held writes, the close frame was flushed when it was written
+func (c *bufferedConn) Close() error {
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// flushingSocket sends close and ping frames right away
+type flushingSocket struct {
+	Conn
+	conn *bufferedConn
+}
+
+func (s *flushingSocket) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	err := s.Conn.WriteControl(messageType, data, deadline)
+	if err != nil {
+		return err
+	}
+	return s.conn.Flush()
+}
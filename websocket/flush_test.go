@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestFlushIntervalHoldsFrames(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), WithFlushInterval(time.Second), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+	})
+	client, conn := testServer(t, cm, nil)()
+	frames := make(chan string, 4)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, data, err := client.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			frames <- string(data)
+		}
+	}()
+	expectFrames := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case <-frames:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("read %d frames, want %d", i, n)
+			}
+		}
+	}
+
+	cm.Send(&Message{Type: "a"})
+	cm.Send(&Message{Type: "b"})
+	eventually(t, "the frames to be written", func() bool { return cm.Stats().MessagesSent == 2 })
+	select {
+	case frame := <-frames:
+		t.Fatalf("frame %s sent before the flush interval", frame)
+	case <-time.After(100 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	expectFrames(2)
+
+	conn.Send(&Message{Type: "c"})
+	eventually(t, "the frame to be written", func() bool { return cm.Stats().MessagesSent == 3 })
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expectFrames(1)
+
+	// Close frames are sent right away
+	cm.Disconnect(conn, 4000, "bye")
+	select {
+	case err := <-readErr:
+		var closeErr *gorilla.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != 4000 {
+			t.Fatalf("err = %v, want close 4000", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("close frame held")
+	}
+}
+
+func TestBufferedConnWriteFailsAfterTimerFlushFailed(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock))
+	local, remote := net.Pipe()
+	remote.Close()
+	conn := &bufferedConn{Conn: local, cm: cm, interval: time.Second, w: bufio.NewWriter(local)}
+	if _, err := conn.Write([]byte("held")); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if _, err := conn.Write([]byte("next")); err == nil {
+		t.Fatal("write after a failed flush succeeded")
+	}
+}
@@ -0,0 +1,42 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultSetupTimeout = time.Minute
+
+// reapHalfOpen disconnects connections that did not authenticate or become ready within SetupTimeout of their
+// upgrade, so clients stalling after the handshake do not hold connections forever
+func (cm *ConnectionManager) reapHalfOpen() {
+	ticker := cm.clock.NewTicker(cm.opts.SetupTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		cm.enqueue(&socketOperation{opType: call, fn: cm.disconnectHalfOpen})
+	}
+}
+
+// disconnectHalfOpen runs on the operations goroutine
+func (cm *ConnectionManager) disconnectHalfOpen() {
+	deadline := cm.clock.Now().Add(-cm.opts.SetupTimeout)
+	frame := &closeFrame{code: websocket.ClosePolicyViolation, reason: "setup timeout"}
+	var reaped []*Connection
+	cm.registry.Range(func(conn *Connection) {
+		if conn.state != stateReady && conn.added.Before(deadline) {
+			reaped = append(reaped, conn)
+		}
+	})
+	for _, conn := range reaped {
+		cm.logV("Disconnecting half-open connection")
+		cm.rejected(RejectSetupTimeout)
+		cm.writeClose(conn, frame)
+		cm.removeSocket(conn, frame.err())
+	}
+}
@@ -0,0 +1,39 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestSetupTimeoutReapsHalfOpenConnections(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.PingInterval = 0
+		o.SetupTimeout = time.Minute
+		o.RequireReady = true
+	})
+	dial := testServer(t, cm, nil)
+	stalling, _ := dial()
+	_, conn := dial()
+	conn.MarkReady()
+
+	clock.Advance(30 * time.Second)
+	if n := cm.Rejections()[RejectSetupTimeout]; n != 0 {
+		t.Fatalf("%d connections reaped before SetupTimeout", n)
+	}
+	eventually(t, "the stalling connection to be reaped", func() bool {
+		clock.Advance(30 * time.Second)
+		return cm.Rejections()[RejectSetupTimeout] == 1
+	})
+	_, _, err := stalling.ReadMessage()
+	var closeErr *gorilla.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != gorilla.ClosePolicyViolation {
+		t.Fatalf("err = %v, want close 1008", err)
+	}
+	if n := cm.Stats().Connections; n != 1 {
+		t.Fatalf("%d connections left, want the ready one", n)
+	}
+}
@@ -0,0 +1,25 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFirstMessageTimeoutClosesSilentConnections(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.FirstMessageTimeout = 100 * time.Millisecond
+		o.SetupTimeout = -1
+	})
+	dial := testServer(t, cm, nil)
+	silent, _ := dial()
+	talking, _ := dial()
+	talking.WriteJSON(Message{Type: "hello"})
+
+	if _, _, err := silent.ReadMessage(); err == nil {
+		t.Fatal("silent connection not closed")
+	}
+	if n := cm.HandshakeTimeouts(); n != 1 {
+		t.Fatalf("HandshakeTimeouts = %d, want 1", n)
+	}
+	eventually(t, "the silent connection to be removed", func() bool { return cm.Stats().Connections == 1 })
+}
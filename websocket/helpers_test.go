@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// testServer serves cm over HTTP and returns a function dialing a client, which it returns with the server side
+// connection. onReceive may be nil.
+func testServer(t *testing.T, cm *ConnectionManager,
+	onReceive func(*Connection, *Message)) func() (*gorilla.Conn, *Connection) {
+	t.Helper()
+	dial := testServerQuery(t, cm, onReceive)
+	return func() (*gorilla.Conn, *Connection) {
+		t.Helper()
+		return dial("")
+	}
+}
+
+// testServerQuery is testServer dialing with a query string, e.g. to resume a session
+func testServerQuery(t *testing.T, cm *ConnectionManager,
+	onReceive func(*Connection, *Message)) func(query string) (*gorilla.Conn, *Connection) {
+	t.Helper()
+	if onReceive == nil {
+		onReceive = func(*Connection, *Message) {}
+	}
+	conns := make(chan *Connection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := cm.ReceiveConn(w, r, onReceive)
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return func(query string) (*gorilla.Conn, *Connection) {
+		t.Helper()
+		client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return client, <-conns
+	}
+}
+
+// readType reads messages from client until one of msgType, failing the test when none arrives
+func readType(t *testing.T, client *gorilla.Conn, msgType string) *Message {
+	t.Helper()
+	for {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatalf("reading %s: %v", msgType, err)
+		}
+		if msg.Type == msgType {
+			return &msg
+		}
+	}
+}
+
+// expectNone fails the test when client reads a message within d
+func expectNone(t *testing.T, client *gorilla.Conn, d time.Duration) {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(d))
+	var msg Message
+	if err := client.ReadJSON(&msg); err == nil {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
+
+// eventually fails the test when cond does not hold within 5s
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,31 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNotHijacker is reported when neither the ResponseWriter passed to Receive nor any writer it wraps
+// implements http.Hijacker. Middlewares wrapping the ResponseWriter should either implement http.Hijacker
+// or expose the wrapped writer with an Unwrap() http.ResponseWriter method, the same convention used by
+// http.ResponseController.
+var ErrNotHijacker = errors.New("websocket: response writer and the writers it wraps do not implement http.Hijacker")
+
+type rwUnwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// hijackable walks the Unwrap chain of middleware wrappers and returns the first writer that can be hijacked
+func hijackable(w http.ResponseWriter) (http.ResponseWriter, error) {
+	for w != nil {
+		if _, ok := w.(http.Hijacker); ok {
+			return w, nil
+		}
+		u, ok := w.(rwUnwrapper)
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return nil, ErrNotHijacker
+}
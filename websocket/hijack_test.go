@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// loggingWriter is a middleware wrapper that hides the Hijacker of the writer it wraps
+type loggingWriter struct {
+	w http.ResponseWriter
+}
+
+func (l *loggingWriter) Header() http.Header         { return l.w.Header() }
+func (l *loggingWriter) Write(p []byte) (int, error) { return l.w.Write(p) }
+func (l *loggingWriter) WriteHeader(status int)      { l.w.WriteHeader(status) }
+
+type unwrappingWriter struct {
+	loggingWriter
+}
+
+func (u *unwrappingWriter) Unwrap() http.ResponseWriter { return u.w }
+
+func TestReceiveUnwrapsMiddlewareWriters(t *testing.T) {
+	cm := NewConnectionManager()
+	errs := make(chan error, 1)
+	wrap := func(w http.ResponseWriter) http.ResponseWriter { return &unwrappingWriter{loggingWriter{w: w}} }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := cm.ReceiveConn(wrap(w), r, func(*Connection, *Message) {})
+		errs <- err
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	client, _, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	wrap = func(w http.ResponseWriter) http.ResponseWriter { return &loggingWriter{w: w} }
+	_, resp, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("upgraded through a writer that cannot be hijacked")
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var rejection UpgradeRejection
+	if err := <-errs; !errors.As(err, &rejection) || rejection.Reason != RejectNotHijacker {
+		t.Fatalf("err = %v, want a %s rejection", err, RejectNotHijacker)
+	}
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"errors"
+)
+
+// ErrNoHistory is returned by SubscribeWithBackfill when Options.History is not set
+var ErrNoHistory = errors.New("websocket: no history store")
+
+// HistoryStore keeps the messages published to topics
+type HistoryStore interface {
+	// Append stores msg for topic and returns its sequence number, starting at 1 and increasing by one
+	Append(topic string, msg *Message) (uint64, error)
+	// Since returns the stored messages of topic with a sequence number greater than seq, in order
+	Since(topic string, seq uint64) ([]*Message, error)
+	// Bounds returns the first retained and the last appended sequence number of topic, first is last+1 when
+	// no message is retained
+	Bounds(topic string) (first, last uint64, err error)
+}
+
+// SubscribeWithBackfill sends conn the stored messages of topic after sinceSeq and then subscribes it to the
+// topic, in one step on the operations goroutine so no message is missed or delivered twice between replay and
+// live delivery. Topics are entity IDs, live messages are published with PublishEntity.
+func (cm *ConnectionManager) SubscribeWithBackfill(conn *Connection, topic string, sinceSeq uint64) error {
+	if cm.opts.History == nil {
+		return ErrNoHistory
+	}
+	var err error
+	cm.call(func() {
+		err = cm.backfill(conn, topic, sinceSeq)
+	})
+	return err
+}
+
+// backfill runs on the operations goroutine
+func (cm *ConnectionManager) backfill(conn *Connection, topic string, sinceSeq uint64) error {
+	if !cm.registry.Contains(conn) {
+		return nil
+	}
+	stored, err := cm.opts.History.Since(topic, sinceSeq)
+	if err != nil {
+		return err
+	}
+	for _, msg := range stored {
+		if msg.Cursor == "" {
+			replayed := *msg
+			replayed.Cursor = cm.cursorToken(Cursor{Topic: topic, Seq: msg.Seq})
+			msg = &replayed
+		}
+		cm.newFanout(msg).deliver(conn)
+	}
+	cm.subscribe(conn, topic)
+	return nil
+}
+
+// subscribe adds topic to the topics of conn, or renews its lease when it has it already. Runs on the operations
+// goroutine.
+func (cm *ConnectionManager) subscribe(conn *Connection, topic string) {
+	cm.renewLease(conn, topic)
+	if conn.topics[topic] {
+		return
+	}
+	if conn.topics == nil {
+		conn.topics = make(map[string]bool)
+	}
+	conn.topics[topic] = true
+	cm.entities.add(topic, conn)
+	if cm.opts.OnInterestChange != nil {
+		cm.opts.OnInterestChange(conn, []string{topic}, nil)
+	}
+}
+
+// record appends msg to the history of topic and returns the copy carrying its sequence number, runs on the
+// operations goroutine
+func (cm *ConnectionManager) record(topic string, msg *Message) *Message {
+	if cm.opts.History == nil {
+		return msg
+	}
+	stored := *msg
+	stored.Topic = topic
+	seq, err := cm.opts.History.Append(topic, &stored)
+	if err != nil {
+		cm.logE(err, "Failed to store published message")
+		return msg
+	}
+	stored.Seq = seq
+	stored.Cursor = cm.cursorToken(Cursor{Topic: topic, Seq: seq})
+	return &stored
+}
@@ -0,0 +1,35 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSubscribeWithBackfill(t *testing.T) {
+	if err := NewConnectionManager().SubscribeWithBackfill(nil, "t", 0); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("err = %v without history", err)
+	}
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.History = NewMemoryHistory(10)
+	})
+	client, conn := testServer(t, cm, nil)()
+	for i := 1; i <= 3; i++ {
+		cm.PublishEntity("t", &Message{Type: fmt.Sprint("m", i)})
+	}
+
+	if err := cm.SubscribeWithBackfill(conn, "t", 1); err != nil {
+		t.Fatal(err)
+	}
+	cm.PublishEntity("t", &Message{Type: "m4"})
+	for seq := uint64(2); seq <= 4; seq++ {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != fmt.Sprint("m", seq) || msg.Seq != seq || msg.Topic != "t" || msg.Cursor == "" {
+			t.Fatalf("got %+v, want m%d", msg, seq)
+		}
+	}
+}
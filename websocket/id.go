@@ -0,0 +1,15 @@
+package websocket
+
+// IDGenerator produces connection and session IDs, e.g. UUIDv7, ULID or snowflake IDs so they sort and match
+// the rest of the system. It is called concurrently.
+type IDGenerator func() string
+
+// NewID generates an ID with the configured IDGenerator
+func (cm *ConnectionManager) NewID() string {
+	return cm.opts.IDGenerator()
+}
+
+// ID of the connection
+func (c *Connection) ID() string {
+	return c.id
+}
@@ -0,0 +1,29 @@
+package websocket
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIDGenerator(t *testing.T) {
+	var next atomic.Int32
+	cm := NewConnectionManager(WithIDGenerator(func() string {
+		return fmt.Sprintf("id-%d", next.Add(1))
+	}), func(o *Options) { o.SetupTimeout = -1 })
+	_, conn := testServer(t, cm, nil)()
+	if conn.ID() != "id-1" {
+		t.Fatalf("connection ID %q", conn.ID())
+	}
+	if id, token := cm.NewSession(); id != "id-2" || token == "" || token == id {
+		t.Fatalf("session %q with token %q, want a generated ID and a random token", id, token)
+	}
+	if id := cm.NewID(); id != "id-3" {
+		t.Fatalf("NewID = %q", id)
+	}
+
+	defaults := NewConnectionManager()
+	if a, b := defaults.NewID(), defaults.NewID(); a == "" || a == b {
+		t.Fatalf("default IDs %q and %q", a, b)
+	}
+}
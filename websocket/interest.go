@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"errors"
+)
+
+var errInvalidInterests = errors.New("websocket: interest message data must be a list of entity IDs")
+
+// SetInterests replaces the entity IDs conn is subscribed to with ids in one step, so list driven UIs never
+// miss or double receive updates for entities present in both the old and the new set. Topics joined with Join
+// are not affected.
+func (cm *ConnectionManager) SetInterests(conn *Connection, ids []string) {
+	cm.enqueue(&socketOperation{
+		opType: interest,
+		conn:   conn,
+		ids:    ids,
+	})
+}
+
+// PublishEntity sends msg to the connections interested in entity id
+func (cm *ConnectionManager) PublishEntity(id string, msg *Message) {
+	cm.toBroker(id, msg)
+	cm.enqueue(&socketOperation{
+		opType: publishEntity,
+		msg:    msg,
+		ids:    []string{id},
+	})
+}
+
+// Interests entity IDs the connection is subscribed to with SetInterests
+func (c *Connection) Interests() []string {
+	var ids []string
+	c.manager.call(func() {
+		ids = make([]string, 0, len(c.interests))
+		for id := range c.interests {
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
+
+// updateInterests diffs ids against the current interests of conn, runs on the operations goroutine
+func (cm *ConnectionManager) updateInterests(conn *Connection, ids []string) {
+	if !cm.registry.Contains(conn) {
+		return
+	}
+	next := make(map[string]bool, len(ids))
+	var added, removed []string
+	for _, id := range ids {
+		id = conn.swappedTopic(id)
+		if next[id] {
+			continue
+		}
+		next[id] = true
+		if !conn.interests[id] {
+			added = append(added, id)
+			cm.entities.add(id, conn)
+		}
+	}
+	previous := conn.interests
+	conn.interests = next
+	for id := range previous {
+		if !next[id] {
+			removed = append(removed, id)
+			cm.unindex(conn, id)
+		}
+	}
+	if cm.opts.OnInterestChange != nil && (len(added) > 0 || len(removed) > 0) {
+		cm.opts.OnInterestChange(conn, added, removed)
+	}
+}
+
+// publishToEntity runs on the operations goroutine, its cost is proportional to the subscribers of id
+func (cm *ConnectionManager) publishToEntity(id string, msg *Message, filter func(*Connection) bool) {
+	msg = cm.record(id, msg)
+	if msg.Key != "" {
+		cm.compact(id, msg)
+	}
+	cm.keepDetached(id, msg)
+	var subscribers []*Connection
+	cm.entities.each(id, func(conn *Connection) {
+		if conn.state == stateReady && (filter == nil || filter(conn)) {
+			subscribers = append(subscribers, conn)
+		}
+	})
+	cm.orderByRTT(subscribers)
+	fanout := cm.newFanout(msg)
+	cm.measure(fanout)
+	for _, conn := range subscribers {
+		if msg.Key != "" && cm.opts.AdaptiveCoalesce {
+			cm.coalesceFor(conn, fanout.transform(conn))
+			continue
+		}
+		fanout.deliver(conn)
+	}
+	cm.seal(fanout.skew)
+}
+
+// dropInterests removes a departing connection from the entity index, runs on the operations goroutine
+func (cm *ConnectionManager) dropInterests(conn *Connection) {
+	for id := range conn.interests {
+		cm.entities.remove(id, conn)
+	}
+	for topic := range conn.topics {
+		cm.entities.remove(topic, conn)
+	}
+	conn.interests = nil
+	conn.topics = nil
+	conn.leases = nil
+}
+
+// unindex removes conn from the subscribers of id unless it is still subscribed through an interest or a topic
+func (cm *ConnectionManager) unindex(conn *Connection, id string) {
+	if !conn.interests[id] && !conn.topics[id] {
+		cm.entities.remove(id, conn)
+	}
+}
+
+// receiveInterests queues the interest set declared by a client message, keeping the IDs authorizeTopic allows
+func (cm *ConnectionManager) receiveInterests(conn *Connection, msg *Message) {
+	list, ok := msg.Data.([]interface{})
+	if !ok && msg.Data != nil {
+		cm.logE(errInvalidInterests, "Ignoring interest message")
+		return
+	}
+	ids := make([]string, 0, len(list))
+	for _, item := range list {
+		id, ok := item.(string)
+		if !ok {
+			cm.logE(errInvalidInterests, "Ignoring interest message")
+			return
+		}
+		if !cm.authorizeTopic(conn, id) {
+			cm.logV("Interest not authorized")
+			continue
+		}
+		ids = append(ids, id)
+	}
+	cm.SetInterests(conn, ids)
+}
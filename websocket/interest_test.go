@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestInterestsDiff(t *testing.T) {
+	type change struct{ added, removed []string }
+	changes := make(chan change, 2)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.InterestMessageType = "interests"
+		o.OnInterestChange = func(_ *Connection, added, removed []string) {
+			slices.Sort(added)
+			slices.Sort(removed)
+			changes <- change{added, removed}
+		}
+	})
+	client, _ := testServer(t, cm, nil)()
+	expect := func(added, removed []string) {
+		t.Helper()
+		select {
+		case c := <-changes:
+			if !slices.Equal(c.added, added) || !slices.Equal(c.removed, removed) {
+				t.Fatalf("added %v removed %v, want %v and %v", c.added, c.removed, added, removed)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("interests not changed")
+		}
+	}
+
+	client.WriteJSON(Message{Type: "interests", Data: []string{"a", "b"}})
+	expect([]string{"a", "b"}, nil)
+	client.WriteJSON(Message{Type: "interests", Data: []string{"b", "c", "c"}})
+	expect([]string{"c"}, []string{"a"})
+
+	cm.PublishEntity("a", &Message{Type: "a"})
+	cm.PublishEntity("b", &Message{Type: "b"})
+	var msg Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "b" {
+		t.Fatalf("first message %+v, %v, want b only", msg, err)
+	}
+	cm.PublishEntity("c", &Message{Type: "c"})
+	readType(t, client, "c")
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"context"
+)
+
+const defaultJobErrorMessageType = "job.error"
+
+// Job is a client message handed to external workers through Options.JobQueue
+type Job struct {
+	ID string `json:"id"`
+	// ConnectionID of the connection the message was read from, results are sent back to it
+	ConnectionID string `json:"connectionId"`
+	// ReplyTo addresses the results queue of the instance holding the connection, set by the JobQueue
+	ReplyTo string   `json:"replyTo,omitempty"`
+	Message *Message `json:"message"`
+}
+
+// JobResult is a message of a worker to the connection of a job
+type JobResult struct {
+	ConnectionID string   `json:"connectionId"`
+	Message      *Message `json:"message"`
+}
+
+// JobQueue hands client messages to workers outside the websocket tier and returns their results. See the
+// redisjobs and sqsjobs packages.
+type JobQueue interface {
+	// Push queues job for the workers
+	Push(ctx context.Context, job Job) error
+	// Results calls deliver with the results addressed to this instance until ctx is done. It is called again
+	// with a backoff when it returns before.
+	Results(ctx context.Context, deliver func(JobResult)) error
+}
+
+// jobRelay pushes the messages of the job types of a manager
+type jobRelay struct {
+	ctx   context.Context // Cancelled when the manager shuts down
+	types map[string]bool
+}
+
+// startJobs delivers the results of the JobQueue until the manager shuts down
+func (cm *ConnectionManager) startJobs() {
+	if cm.opts.JobQueue == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cm.jobs = &jobRelay{ctx: ctx, types: make(map[string]bool, len(cm.opts.JobTypes))}
+	for _, msgType := range cm.opts.JobTypes {
+		cm.jobs.types[msgType] = true
+	}
+	go func() {
+		<-cm.done
+		cancel()
+	}()
+	go cm.keepRunning(ctx, "Job results", func(ctx context.Context) error {
+		return cm.opts.JobQueue.Results(ctx, cm.jobResult)
+	})
+}
+
+// isJob reports whether msg is pushed to the JobQueue instead of passed to onReceive
+func (cm *ConnectionManager) isJob(msg *Message) bool {
+	return cm.jobs != nil && cm.jobs.types[msg.Type]
+}
+
+// pushJob pushes msg of conn to the JobQueue, runs on the reader goroutine of conn so a slow queue slows down
+// the reads of the connection. A job that cannot be pushed is dropped with a DropEvent and answered with a
+// JobErrorMessageType message carrying the ID of msg.
+func (cm *ConnectionManager) pushJob(conn *Connection, msg *Message) {
+	job := Job{ID: cm.NewID(), ConnectionID: conn.ID(), Message: msg}
+	err := cm.opts.JobQueue.Push(cm.jobs.ctx, job)
+	if err == nil {
+		return
+	}
+	cm.logE(err, "Failed to push job")
+	cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "job push failed", Time: cm.clock.Now()})
+	conn.Send(&Message{Type: cm.opts.JobErrorMessageType, ID: msg.ID, Data: CallResult{Error: err.Error()}})
+}
+
+// jobResult sends the result of a worker to its connection
+func (cm *ConnectionManager) jobResult(result JobResult) {
+	if result.Message == nil {
+		return
+	}
+	cm.SendToID(result.ConnectionID, result.Message)
+}
+
+// SendToID sends msg to the connection with id, e.g. a result computed elsewhere. Messages to an unknown or
+// closed connection are discarded.
+func (cm *ConnectionManager) SendToID(id string, msg *Message) {
+	cm.enqueue(&socketOperation{
+		opType: call,
+		fn: func() {
+			conn, ok := cm.byID[id]
+			if !ok {
+				cm.logV("No connection with ID " + id)
+				return
+			}
+			cm.deliver(conn, msg)
+		},
+	})
+}
@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryQueue hands jobs to the test, its first Results call fails
+type memoryQueue struct {
+	jobs     chan Job
+	results  chan JobResult
+	down     atomic.Bool
+	sessions atomic.Int32
+}
+
+func (q *memoryQueue) Push(_ context.Context, job Job) error {
+	if q.down.Load() {
+		return errors.New("queue down")
+	}
+	q.jobs <- job
+	return nil
+}
+
+func (q *memoryQueue) Results(ctx context.Context, deliver func(JobResult)) error {
+	if q.sessions.Add(1) == 1 {
+		return errors.New("results blip")
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case result := <-q.results:
+			deliver(result)
+		}
+	}
+}
+
+func TestJobQueue(t *testing.T) {
+	q := &memoryQueue{jobs: make(chan Job, 1), results: make(chan JobResult, 1)}
+	received := make(chan string, 1)
+	cm := NewConnectionManager(WithJobQueue(q, "render"), func(o *Options) { o.SetupTimeout = -1 })
+	client, conn := testServer(t, cm, func(_ *Connection, msg *Message) { received <- msg.Type })()
+
+	client.WriteJSON(Message{Type: "render", ID: "7"})
+	client.WriteJSON(Message{Type: "chat"})
+	select {
+	case job := <-q.jobs:
+		if job.ConnectionID != conn.ID() || job.Message.Type != "render" || job.Message.ID != "7" {
+			t.Fatalf("pushed %+v", job)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not pushed")
+	}
+	if msgType := <-received; msgType != "chat" {
+		t.Fatalf("onReceive got %s, want only the messages that are not jobs", msgType)
+	}
+
+	// Results are delivered once Results is called again after failing
+	q.results <- JobResult{ConnectionID: conn.ID(), Message: &Message{Type: "rendered"}}
+	readType(t, client, "rendered")
+	if n := q.sessions.Load(); n != 2 {
+		t.Fatalf("Results called %d times, want it retried once", n)
+	}
+
+	q.down.Store(true)
+	client.WriteJSON(Message{Type: "render", ID: "8"})
+	var answer struct {
+		Type string
+		ID   string
+		Data CallResult
+	}
+	if err := client.ReadJSON(&answer); err != nil || answer.Type != defaultJobErrorMessageType ||
+		answer.ID != "8" || answer.Data.Error != "queue down" {
+		t.Fatalf("read %+v, %v", answer, err)
+	}
+}
@@ -0,0 +1,173 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPingInterval = 15 * time.Second
+	defaultPongTimeout  = 10 * time.Second
+	pingWriteTimeout    = 5 * time.Second
+	loadHintPayloadSize = 9
+
+	defaultNoopMessageType = "noop"
+)
+
+// RTT smoothed round trip time of the connection measured with pings, zero before the first pong
+func (c *Connection) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
+// measureRTT updates the smoothed RTT from the timestamp payload of a ping sent by pingLoop
+func (c *Connection) measureRTT(now time.Time, payload string) {
+	sent, ok := pingTime(payload)
+	if !ok {
+		return
+	}
+	rtt := now.Sub(sent)
+	if rtt < 0 {
+		return
+	}
+	if prev := time.Duration(c.rtt.Load()); prev > 0 {
+		rtt = (7*prev + rtt) / 8
+	}
+	c.rtt.Store(int64(rtt))
+}
+
+// pingLoop pings all connections every PingInterval with the send time as payload
+func (cm *ConnectionManager) pingLoop() {
+	ticker := cm.clock.NewTicker(cm.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		var conns []*Connection
+		cm.call(func() {
+			cm.registry.Range(func(conn *Connection) {
+				conns = append(conns, conn)
+			})
+		})
+		now := cm.clock.Now()
+		payload := cm.pingPayload(now)
+		for _, conn := range conns {
+			if !canPing(conn.socket) || !conn.pinging.CompareAndSwap(false, true) {
+				continue
+			}
+			go cm.ping(conn, payload)
+		}
+	}
+}
+
+// ping writes a ping to conn on its own goroutine, so a connection that does not take writes delays neither the
+// pings of the others nor the next tick. The connection is skipped by pingLoop until the write returns.
+func (cm *ConnectionManager) ping(conn *Connection, payload []byte) {
+	defer conn.pinging.Store(false)
+	err := conn.socket.WriteControl(websocket.PingMessage, payload, time.Now().Add(pingWriteTimeout))
+	if err != nil {
+		cm.logV("Failed to ping connection")
+	}
+}
+
+// pingFrame is a ping queued behind the writes of a connection by QueuePing
+type pingFrame struct {
+	payload []byte
+}
+
+// QueuePing pings the client with payload after the messages already queued to the connection, so the client
+// seeing the ping has read them. Unlike a message the ping takes no credits, quota or rate budget and is not
+// counted in the stats. Messages held by flow control or Pause are not waited for, and the ping is skipped when
+// the write queue is full.
+func (c *Connection) QueuePing(payload []byte) {
+	cm := c.manager
+	cm.enqueue(&socketOperation{opType: call, fn: func() {
+		if c.closing || !cm.registry.Contains(c) {
+			return
+		}
+		ping := &pingFrame{payload: payload}
+		if cm.shards != nil {
+			select {
+			case cm.shards[c.shard].queue <- shardWrite{conn: c, ping: ping}:
+			default:
+			}
+			return
+		}
+		select {
+		case c.queue <- outbound{ping: ping}:
+		default:
+		}
+	}})
+}
+
+// writePing writes a ping queued with QueuePing, on the writer of conn or its fan-out shard
+func (cm *ConnectionManager) writePing(conn *Connection, ping *pingFrame) {
+	if conn.writeFailed.Load() {
+		return
+	}
+	err := conn.socket.WriteControl(websocket.PingMessage, ping.payload, time.Now().Add(pingWriteTimeout))
+	if err != nil {
+		cm.logV("Failed to ping connection")
+	}
+}
+
+// pingPayload is the send time in decimal, or with Options.LoadHint 8 bytes of send time in big endian followed
+// by a byte of load hint from 0 (idle) to 255 (overloaded)
+func (cm *ConnectionManager) pingPayload(now time.Time) []byte {
+	if cm.opts.LoadHint == nil {
+		return []byte(strconv.FormatInt(now.UnixNano(), 10))
+	}
+	load := cm.opts.LoadHint(cm.LoadSignals())
+	load = math.Max(0, math.Min(1, load))
+	payload := make([]byte, loadHintPayloadSize)
+	binary.BigEndian.PutUint64(payload, uint64(now.UnixNano()))
+	payload[8] = byte(math.Round(load * 255))
+	return payload
+}
+
+// pingTime parses the send time of both ping payload formats
+func pingTime(payload string) (time.Time, bool) {
+	if len(payload) == loadHintPayloadSize {
+		return time.Unix(0, int64(binary.BigEndian.Uint64([]byte(payload)))), true
+	}
+	sent, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, sent), true
+}
+
+// keepalive moves the read deadline of conn past the next ping and its pong timeout, so connections that stop
+// answering pings fail their read and are removed. Transports that cannot ping keep reading without deadline.
+func (cm *ConnectionManager) keepalive(conn *Connection) {
+	if cm.opts.PingInterval <= 0 || !conn.deadlineArmed.Load() || !canPing(conn.socket) {
+		return
+	}
+	deadline := time.Now().Add(cm.opts.PingInterval + cm.opts.PongTimeout)
+	cm.logE(conn.socket.SetReadDeadline(deadline), "Failed to set read deadline")
+}
+
+// noopLoop sends a NoopMessageType message to all connections every NoopInterval, unlike pings these are data
+// frames that proxies count as traffic
+func (cm *ConnectionManager) noopLoop() {
+	ticker := cm.clock.NewTicker(cm.opts.NoopInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		cm.enqueue(&socketOperation{opType: call, fn: func() {
+			cm.registry.Range(func(conn *Connection) {
+				cm.write(conn, &Message{Type: cm.opts.NoopMessageType})
+			})
+		}})
+	}
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// stalledPings is a socket whose pings block until release is closed
+type stalledPings struct {
+	Conn
+	release chan struct{}
+}
+
+func (s stalledPings) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType == gorilla.PingMessage {
+		<-s.release
+	}
+	return s.Conn.WriteControl(messageType, data, deadline)
+}
+
+func TestPingLoopNotDelayedByStalledConnection(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.PingInterval = time.Minute
+		o.SetupTimeout = -1
+	})
+	release := make(chan struct{})
+	defer close(release)
+	var accepted atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := (&gorilla.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		var conn Conn = socket
+		if accepted.Add(1) == 1 {
+			conn = stalledPings{Conn: socket, release: release}
+		}
+		cm.Accept(r, conn, func(*Connection, *Message) {})
+	}))
+	defer srv.Close()
+	dial := func() *gorilla.Conn {
+		client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+	dial()
+	client := dial()
+	pings := make(chan struct{}, 10)
+	client.SetPingHandler(func(string) error {
+		pings <- struct{}{}
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Minute)
+		select {
+		case <-pings:
+		case <-time.After(time.Second):
+			t.Fatalf("ping %d not received while another connection stalls", i+1)
+		}
+	}
+}
+
+func TestNoopMessagesKeepIdleConnectionsBusy(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.NoopInterval = 50 * time.Millisecond
+		o.NoopMessageType = "keep"
+	})
+	client, _ := testServer(t, cm, nil)()
+	for i := 0; i < 2; i++ {
+		if msg := readType(t, client, "keep"); msg.Data != nil {
+			t.Fatalf("noop message carries %v", msg.Data)
+		}
+	}
+}
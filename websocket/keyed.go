@@ -0,0 +1,125 @@
+package websocket
+
+import "sort"
+
+// PublishKeyed publishes msg for key to the subscribers of topic and keeps it as the latest value of key, so
+// SubscribeKeyed subscribers start from a compacted snapshot with one message per key. A msg with nil Data
+// deletes key. With Options.CoalesceInterval only the latest update of a key per interval is published.
+func (cm *ConnectionManager) PublishKeyed(topic string, key string, msg *Message) {
+	keyed := *msg
+	keyed.Topic = topic
+	keyed.Key = key
+	if cm.opts.CoalesceInterval <= 0 || cm.opts.AdaptiveCoalesce {
+		cm.PublishEntity(topic, &keyed)
+		return
+	}
+	cm.toBroker(topic, &keyed)
+	cm.enqueue(&socketOperation{
+		opType: coalesce,
+		msg:    &keyed,
+		ids:    []string{topic},
+	})
+}
+
+// SubscribeKeyed sends conn the latest message of every key of topic, then Options.SnapshotEndMessageType,
+// and subscribes it to the live updates of topic in one step
+func (cm *ConnectionManager) SubscribeKeyed(conn *Connection, topic string) {
+	cm.call(func() {
+		if !cm.registry.Contains(conn) {
+			return
+		}
+		latest := cm.compacted[topic]
+		keys := make([]string, 0, len(latest))
+		for key := range latest {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			cm.newFanout(latest[key]).deliver(conn)
+		}
+		if cm.opts.SnapshotEndMessageType != "" {
+			cm.deliver(conn, &Message{Type: cm.opts.SnapshotEndMessageType, Topic: topic})
+		}
+		cm.subscribe(conn, topic)
+	})
+}
+
+// compact keeps msg as the latest message of its key, runs on the operations goroutine
+func (cm *ConnectionManager) compact(topic string, msg *Message) {
+	latest := cm.compacted[topic]
+	if msg.Data == nil {
+		delete(latest, msg.Key)
+		if len(latest) == 0 {
+			delete(cm.compacted, topic)
+		}
+		return
+	}
+	if latest == nil {
+		if cm.compacted == nil {
+			cm.compacted = make(map[string]map[string]*Message)
+		}
+		latest = make(map[string]*Message)
+		cm.compacted[topic] = latest
+	}
+	latest[msg.Key] = msg
+}
+
+// coalescedKeys updates in the order their keys were first updated in the interval
+type coalescedKeys struct {
+	index    map[string]int
+	messages []*Message
+}
+
+// coalesceKeyed keeps msg until the next flush, replacing a pending update of the same key. Runs on the
+// operations goroutine.
+func (cm *ConnectionManager) coalesceKeyed(topic string, msg *Message) {
+	pending := cm.coalesced[topic]
+	if pending == nil {
+		if cm.coalesced == nil {
+			cm.coalesced = make(map[string]*coalescedKeys)
+		}
+		pending = &coalescedKeys{index: make(map[string]int)}
+		cm.coalesced[topic] = pending
+	}
+	pending.add(msg)
+}
+
+// add replaces the pending update of the key of msg or appends msg
+func (c *coalescedKeys) add(msg *Message) {
+	key := msg.Topic + "\x00" + msg.Key
+	if i, ok := c.index[key]; ok {
+		c.messages[i] = msg
+		return
+	}
+	c.index[key] = len(c.messages)
+	c.messages = append(c.messages, msg)
+}
+
+// flushKeyed publishes the coalesced updates, runs on the operations goroutine
+func (cm *ConnectionManager) flushKeyed() {
+	topics := make([]string, 0, len(cm.coalesced))
+	for topic := range cm.coalesced {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		for _, msg := range cm.coalesced[topic].messages {
+			cm.publishToEntity(topic, msg, nil)
+		}
+	}
+	cm.coalesced = nil
+	cm.flushAdaptive()
+}
+
+func (cm *ConnectionManager) flushCoalescedLoop() {
+	ticker := cm.clock.NewTicker(cm.opts.CoalesceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		cm.enqueue(&socketOperation{opType: flushCoalesced})
+	}
+}
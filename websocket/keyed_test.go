@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeKeyedStartsFromCompactedSnapshot(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.SnapshotEndMessageType = "snapshot-end"
+	})
+	client, conn := testServer(t, cm, nil)()
+	cm.PublishKeyed("prices", "a", &Message{Type: "price", Data: 1.0})
+	cm.PublishKeyed("prices", "b", &Message{Type: "price", Data: 1.0})
+	cm.PublishKeyed("prices", "a", &Message{Type: "price", Data: 2.0})
+	cm.PublishKeyed("prices", "b", &Message{Type: "price"})
+	cm.PublishKeyed("prices", "c", &Message{Type: "price", Data: 3.0})
+
+	cm.SubscribeKeyed(conn, "prices")
+	cm.PublishKeyed("prices", "a", &Message{Type: "price", Data: 4.0})
+	for _, want := range []Message{
+		{Type: "price", Topic: "prices", Key: "a", Data: 2.0},
+		{Type: "price", Topic: "prices", Key: "c", Data: 3.0},
+		{Type: "snapshot-end", Topic: "prices"},
+		{Type: "price", Topic: "prices", Key: "a", Data: 4.0},
+	} {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != want.Type || msg.Topic != want.Topic || msg.Key != want.Key || msg.Data != want.Data {
+			t.Fatalf("got %+v, want %+v", msg, want)
+		}
+	}
+}
+
+func TestPublishKeyedCoalescesPerInterval(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.CoalesceInterval = 100 * time.Millisecond
+	})
+	client, conn := testServer(t, cm, nil)()
+	cm.SubscribeKeyed(conn, "prices")
+	cm.PublishKeyed("prices", "a", &Message{Type: "price", Data: 1.0})
+	cm.PublishKeyed("prices", "b", &Message{Type: "price", Data: 1.0})
+	cm.PublishKeyed("prices", "a", &Message{Type: "price", Data: 2.0})
+	conn.Topics()
+	clock.Advance(100 * time.Millisecond)
+
+	for _, want := range []Message{{Key: "a", Data: 2.0}, {Key: "b", Data: 1.0}} {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Key != want.Key || msg.Data != want.Data {
+			t.Fatalf("got %+v, want the latest update of %s", msg, want.Key)
+		}
+	}
+}
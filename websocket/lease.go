@@ -0,0 +1,53 @@
+package websocket
+
+import "time"
+
+// expireLeasesLoop leaves the topics whose lease was not renewed within SubscriptionLease, so clients whose UI
+// moved on without leaving do not keep receiving them
+func (cm *ConnectionManager) expireLeasesLoop() {
+	ticker := cm.clock.NewTicker(cm.opts.SubscriptionLease / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		cm.enqueue(&socketOperation{opType: call, fn: cm.expireLeases})
+	}
+}
+
+// renewLease extends the lease of conn on topic, runs on the operations goroutine
+func (cm *ConnectionManager) renewLease(conn *Connection, topic string) {
+	if cm.opts.SubscriptionLease <= 0 {
+		return
+	}
+	if conn.leases == nil {
+		conn.leases = make(map[string]time.Time)
+	}
+	conn.leases[topic] = cm.clock.Now().Add(cm.opts.SubscriptionLease)
+}
+
+// expireLeases runs on the operations goroutine
+func (cm *ConnectionManager) expireLeases() {
+	now := cm.clock.Now()
+	type expiry struct {
+		conn  *Connection
+		topic string
+	}
+	var expired []expiry
+	cm.registry.Range(func(conn *Connection) {
+		for topic, until := range conn.leases {
+			if !now.Before(until) {
+				expired = append(expired, expiry{conn: conn, topic: topic})
+			}
+		}
+	})
+	for _, e := range expired {
+		cm.logV("Subscription lease expired: " + e.topic)
+		cm.leaveTopic(e.conn, e.topic)
+		if cm.opts.LeaseExpiredMessageType != "" {
+			cm.deliver(e.conn, &Message{Type: cm.opts.LeaseExpiredMessageType, Data: e.topic})
+		}
+	}
+}
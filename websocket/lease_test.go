@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func newLeaseManager(clock *FakeClock) *ConnectionManager {
+	return NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SubscriptionLease = time.Minute
+		o.LeaseExpiredMessageType = "expired"
+		o.JoinMessageType = "join"
+		o.History = NewMemoryHistory(10)
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+	})
+}
+
+// advance moves clock and waits for the lease expiry it triggers to run
+func advance(clock *FakeClock, conn *Connection, d time.Duration) {
+	clock.Advance(d)
+	time.Sleep(50 * time.Millisecond)
+	conn.Topics()
+}
+
+func TestSubscriptionLeaseExpiresUnlessJoinedAgain(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := newLeaseManager(clock)
+	client, conn := testServer(t, cm, nil)()
+	client.WriteJSON(Message{Type: "join", Data: "a"})
+	client.WriteJSON(Message{Type: "join", Data: "b"})
+	time.Sleep(50 * time.Millisecond)
+	advance(clock, conn, 45*time.Second)
+	client.WriteJSON(Message{Type: "join", Data: "b"})
+	time.Sleep(50 * time.Millisecond)
+	advance(clock, conn, 20*time.Second)
+	if len(cm.Members("a")) != 0 || len(cm.Members("b")) != 1 {
+		t.Fatalf("members of a = %d, b = %d", len(cm.Members("a")), len(cm.Members("b")))
+	}
+	if msg := readType(t, client, "expired"); msg.Data != "a" {
+		t.Fatalf("expired %v", msg.Data)
+	}
+	advance(clock, conn, 45*time.Second)
+	if len(cm.Members("b")) != 0 {
+		t.Fatal("b kept after its lease")
+	}
+}
+
+func TestSubscriptionLeaseCoversServerSubscriptions(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := newLeaseManager(clock)
+	_, conn := testServer(t, cm, nil)()
+	if err := cm.SubscribeWithBackfill(conn, "backfilled", 0); err != nil {
+		t.Fatal(err)
+	}
+	cm.SubscribeKeyed(conn, "keyed")
+	advance(clock, conn, 45*time.Second)
+	// Subscribing again renews the lease
+	cm.SubscribeKeyed(conn, "keyed")
+	advance(clock, conn, 20*time.Second)
+	if topics := conn.Topics(); len(topics) != 1 || topics[0] != "keyed" {
+		t.Fatalf("topics = %v, want [keyed]", topics)
+	}
+	advance(clock, conn, 45*time.Second)
+	if topics := conn.Topics(); len(topics) != 0 {
+		t.Fatalf("topics = %v after the lease", topics)
+	}
+}
@@ -0,0 +1,36 @@
+package websocket
+
+import (
+	"net/http"
+)
+
+// admit reserves a connection slot under MaxConnections, answering the upgrade with 503 when none is left. The
+// slot is given back by unadmit when the upgrade fails or the connection is removed.
+func (cm *ConnectionManager) admit(w http.ResponseWriter, r *http.Request) error {
+	if cm.tryAdmit() {
+		return nil
+	}
+	w.Header().Set("Retry-After", "1")
+	return cm.reject(w, r, UpgradeRejection{
+		Status: http.StatusServiceUnavailable,
+		Reason: RejectConnectionLimit,
+	})
+}
+
+// tryAdmit counts a connection unless the manager has MaxConnections connections
+func (cm *ConnectionManager) tryAdmit() bool {
+	if cm.opts.MaxConnections <= 0 {
+		return true
+	}
+	if cm.admitted.Add(1) <= int64(cm.opts.MaxConnections) {
+		return true
+	}
+	cm.admitted.Add(-1)
+	return false
+}
+
+func (cm *ConnectionManager) unadmit() {
+	if cm.opts.MaxConnections > 0 {
+		cm.admitted.Add(-1)
+	}
+}
@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestConnectionAndMessageLimits(t *testing.T) {
+	cm := NewConnectionManager(WithLimits(1, 32, 0), func(o *Options) { o.SetupTimeout = -1 })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	client, _, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	_, resp, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err == nil || resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "1" {
+		t.Fatalf("second connection not rejected with 503: %v", err)
+	}
+	if n := cm.Rejections()[RejectConnectionLimit]; n != 1 {
+		t.Fatalf("%d connection limit rejections", n)
+	}
+
+	client.WriteJSON(Message{Type: "this message is longer than the limit"})
+	if _, _, err := client.ReadMessage(); !gorilla.IsCloseError(err, gorilla.CloseMessageTooBig) {
+		t.Fatalf("err = %v, want close 1009", err)
+	}
+	eventually(t, "the connection slot to be released", func() bool { return cm.admitted.Load() == 0 })
+	client, _, err = gorilla.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("slot of the removed connection not released: %v", err)
+	}
+	client.Close()
+}
@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultLoadInterval = 10 * time.Second
+	defaultShedFraction = 0.1
+)
+
+// LoadSignals describes how loaded the manager is, for autoscalers and load shedding
+type LoadSignals struct {
+	Connections int64 `json:"connections"`
+	// MessageRate messages read and written per second over the last LoadInterval
+	MessageRate float64 `json:"messageRate"`
+	// QueueSaturation fill ratio of the operations queue, from 0 to 1
+	QueueSaturation float64 `json:"queueSaturation"`
+}
+
+type loadCounters struct {
+	connections atomic.Int64
+	messages    atomic.Int64
+
+	mu           sync.Mutex
+	rate         float64
+	lastMessages int64
+	lastSample   time.Time
+}
+
+// LoadSignals current load of the manager
+func (cm *ConnectionManager) LoadSignals() LoadSignals {
+	cm.load.mu.Lock()
+	rate := cm.load.rate
+	cm.load.mu.Unlock()
+	return LoadSignals{
+		Connections:     cm.load.connections.Load(),
+		MessageRate:     rate,
+		QueueSaturation: float64(len(cm.operations)) / float64(cap(cm.operations)),
+	}
+}
+
+// LoadHandler serves LoadSignals, Stats and TypeStats in the Prometheus text format, to feed Kubernetes HPA
+// custom metrics through a metrics adapter
+func (cm *ConnectionManager) LoadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeLoadSignals(w, "", cm.LoadSignals())
+		writeStats(w, "", cm.Stats())
+		writeTypeStats(w, "", cm.TypeStats())
+		writeDeliverySkew(w, "", cm.DeliverySkew())
+	})
+}
+
+// writeLoadSignals writes signals in the Prometheus text format, labels such as `endpoint="/chat"` are optional
+func writeLoadSignals(w io.Writer, labels string, signals LoadSignals) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "websocket_connections%s %d\n", labels, signals.Connections)
+	fmt.Fprintf(w, "websocket_message_rate%s %g\n", labels, signals.MessageRate)
+	fmt.Fprintf(w, "websocket_queue_saturation%s %g\n", labels, signals.QueueSaturation)
+}
+
+// ShedLoad gracefully disconnects fraction of the connections with close code 1013 (try again later), so the
+// clients reconnect to less loaded instances. Connections with the lowest ShedPriority are shed first.
+func (cm *ConnectionManager) ShedLoad(fraction float64) {
+	cm.enqueue(&socketOperation{
+		opType:   shed,
+		fraction: fraction,
+	})
+}
+
+// sampleLoad updates the message rate every LoadInterval, reports it to OnLoad and sheds load when Overloaded
+func (cm *ConnectionManager) sampleLoad() {
+	ticker := cm.clock.NewTicker(cm.opts.LoadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		now := cm.clock.Now()
+		messages := cm.load.messages.Load()
+		cm.load.mu.Lock()
+		if !cm.load.lastSample.IsZero() {
+			cm.load.rate = float64(messages-cm.load.lastMessages) / now.Sub(cm.load.lastSample).Seconds()
+		}
+		cm.load.lastMessages = messages
+		cm.load.lastSample = now
+		cm.load.mu.Unlock()
+
+		signals := cm.LoadSignals()
+		if cm.opts.OnLoad != nil {
+			cm.opts.OnLoad(signals)
+		}
+		if cm.opts.Overloaded != nil && cm.opts.Overloaded(signals) {
+			cm.logV("Overloaded, shedding connections")
+			cm.ShedLoad(cm.opts.ShedFraction)
+		}
+	}
+}
+
+// shedConnections runs on the operations goroutine
+func (cm *ConnectionManager) shedConnections(fraction float64) {
+	count := int(math.Ceil(float64(cm.registry.Len()) * fraction))
+	if count <= 0 {
+		return
+	}
+	priority := cm.opts.ShedPriority
+	if priority == nil {
+		priority = defaultShedPriority
+	}
+	type candidate struct {
+		conn     *Connection
+		priority int
+	}
+	candidates := make([]candidate, 0, cm.registry.Len())
+	cm.registry.Range(func(conn *Connection) {
+		candidates = append(candidates, candidate{conn: conn, priority: priority(conn)})
+	})
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].priority < candidates[j].priority })
+
+	frame := &closeFrame{code: websocket.CloseTryAgainLater, reason: "server overloaded"}
+	for _, c := range candidates[:count] {
+		cm.writeClose(c.conn, frame)
+		cm.removeSocket(c.conn, frame.err())
+	}
+}
+
+// defaultShedPriority sheds anonymous connections before authenticated ones
+func defaultShedPriority(conn *Connection) int {
+	if conn.Anonymous() {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestLoadSignals(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	samples := make(chan LoadSignals, 1)
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.LoadInterval = time.Second
+		o.OnLoad = func(signals LoadSignals) { samples <- signals }
+	})
+	client, conn := testServer(t, cm, nil)()
+	sample := func() LoadSignals {
+		t.Helper()
+		clock.Advance(time.Second)
+		select {
+		case signals := <-samples:
+			return signals
+		case <-time.After(5 * time.Second):
+			t.Fatal("no load sample")
+			return LoadSignals{}
+		}
+	}
+
+	if signals := sample(); signals.Connections != 1 || signals.MessageRate != 0 {
+		t.Fatalf("first sample %+v", signals)
+	}
+	conn.Send(&Message{Type: "a"})
+	conn.Send(&Message{Type: "b"})
+	readType(t, client, "b")
+	if signals := sample(); signals.MessageRate != 2 {
+		t.Fatalf("second sample %+v, want 2 messages per second", signals)
+	}
+
+	w := httptest.NewRecorder()
+	cm.LoadHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	if !strings.Contains(string(body), "websocket_connections 1\n") {
+		t.Fatalf("metrics:\n%s", body)
+	}
+}
+
+func TestShedLoadAnonymousFirst(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.AllowAnonymous = true
+		o.Authenticate = func(msg *Message) (interface{}, error) { return msg.Data, nil }
+	})
+	dial := testServer(t, cm, nil)
+	member, memberConn := dial()
+	member.WriteJSON(Message{Type: "auth", Data: "alice"})
+	eventually(t, "the member to authenticate", func() bool { return !memberConn.Anonymous() })
+	anonymous, _ := dial()
+
+	cm.ShedLoad(0.5)
+	_, _, err := anonymous.ReadMessage()
+	var closeErr *gorilla.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != gorilla.CloseTryAgainLater {
+		t.Fatalf("anonymous err = %v, want close code 1013", err)
+	}
+	eventually(t, "the shed connection to be removed", func() bool { return cm.Stats().Connections == 1 })
+	memberConn.Send(&Message{Type: "still here"})
+	readType(t, member, "still here")
+}
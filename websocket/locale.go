@@ -0,0 +1,66 @@
+package websocket
+
+import "strings"
+
+type localizedMessage struct {
+	variants map[string]*Message
+	fallback string
+}
+
+// SendLocalized broadcasts one of the pre-localized variants, keyed by locale such as "en" or "pt-BR", to each
+// connection by its locale. A connection without an exact match gets the variant of its base language, then the
+// fallback variant.
+func (cm *ConnectionManager) SendLocalized(variants map[string]*Message, fallback string) {
+	cm.toBrokerLocalized(variants, fallback)
+	cm.enqueue(&socketOperation{
+		opType:    sendLocalized,
+		localized: &localizedMessage{variants: variants, fallback: fallback},
+	})
+}
+
+// Locale of the connection, empty when not set
+func (c *Connection) Locale() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.locale
+}
+
+// SetLocale sets the locale used to pick localized variants for the connection
+func (c *Connection) SetLocale(locale string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.locale = locale
+}
+
+// variantFor picks the variant for locale
+func (l *localizedMessage) variantFor(locale string) *Message {
+	if msg, ok := l.variants[locale]; ok {
+		return msg
+	}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		if msg, ok := l.variants[locale[:i]]; ok {
+			return msg
+		}
+	}
+	return l.variants[l.fallback]
+}
+
+// sendLocalizedMessage runs on the operations goroutine
+func (cm *ConnectionManager) sendLocalizedMessage(localized *localizedMessage) {
+	fanouts := make(map[*Message]*fanout)
+	cm.registry.Range(func(conn *Connection) {
+		if conn.state != stateReady {
+			return
+		}
+		msg := localized.variantFor(conn.Locale())
+		if msg == nil {
+			return
+		}
+		f, ok := fanouts[msg]
+		if !ok {
+			f = cm.newFanout(msg)
+			fanouts[msg] = f
+		}
+		f.deliver(conn)
+	})
+}
@@ -0,0 +1,21 @@
+package websocket
+
+import "testing"
+
+func TestSendLocalizedPicksVariant(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	dial := testServer(t, cm, nil)
+	variants := map[string]*Message{
+		"en":    {Type: "greeting", Data: "hello"},
+		"pt":    {Type: "greeting", Data: "olá"},
+		"pt-BR": {Type: "greeting", Data: "oi"},
+	}
+	for locale, want := range map[string]string{"pt-BR": "oi", "pt_PT": "olá", "de": "hello", "": "hello"} {
+		client, conn := dial()
+		conn.SetLocale(locale)
+		cm.SendLocalized(variants, "en")
+		if msg := readType(t, client, "greeting"); msg.Data != want {
+			t.Fatalf("locale %q got %v, want %s", locale, msg.Data, want)
+		}
+	}
+}
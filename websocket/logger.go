@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger receives the logs of the manager, e.g. an adapter to zap. Error is only called with a non nil err.
+type Logger interface {
+	// Verbose logs progress such as accepted and removed connections
+	Verbose(msg string)
+	Error(err error, msg string)
+}
+
+// NopLogger discards all logs, the default Options.Logger
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Verbose(string)      {}
+func (nopLogger) Error(error, string) {}
+
+// SlogLogger logs verbose messages at debug level and errors at error level with the error as "err" attribute
+func SlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Verbose(msg string) {
+	l.logger.Log(context.Background(), slog.LevelDebug, msg)
+}
+
+func (l slogLogger) Error(err error, msg string) {
+	l.logger.Log(context.Background(), slog.LevelError, msg, slog.Any("err", err))
+}
+
+func (cm *ConnectionManager) logV(msg string) {
+	cm.opts.Logger.Verbose(msg)
+}
+
+// logE logs err unless it is nil
+func (cm *ConnectionManager) logE(err error, msg string) {
+	if err != nil {
+		cm.opts.Logger.Error(err, msg)
+	}
+}
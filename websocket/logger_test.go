@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe to log to from several goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSlogLogger(t *testing.T) {
+	var out syncBuffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cm := NewConnectionManager(WithLogger(SlogLogger(logger)))
+	cm.Route(nil, &Message{Type: "x"})
+	cm.logE(nil, "not logged")
+	cm.logE(errors.New("boom"), "Write failed")
+
+	logs := out.String()
+	for _, want := range []string{
+		"level=DEBUG msg=\"New connection manager\"",
+		"level=DEBUG msg=\"No handler for message type x\"",
+		"level=ERROR msg=\"Write failed\" err=boom",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("logs missing %q:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "not logged") {
+		t.Fatalf("nil error logged:\n%s", logs)
+	}
+}
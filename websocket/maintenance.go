@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultMaintenanceMessageType = "maintenance"
+
+// MaintenanceNotice is the data of the message broadcast by EnterMaintenance
+type MaintenanceNotice struct {
+	Message string `json:"message"`
+	// RetryAfter seconds after which clients should reconnect
+	RetryAfter int `json:"retryAfter"`
+}
+
+type maintenance struct {
+	notice MaintenanceNotice
+}
+
+// EnterMaintenance broadcasts message to the clients connected to this manager, not through the Broker, and
+// rejects new upgrades with 503 and a Retry-After header until ExitMaintenance. With drain the connected clients
+// are then closed with 1013 (try again later) and the reason "maintenance", once the messages queued to them
+// before, including the notice, are written.
+func (cm *ConnectionManager) EnterMaintenance(message string, retryAfter time.Duration, drain bool) {
+	cm.logV("Entering maintenance")
+	notice := MaintenanceNotice{Message: message, RetryAfter: int(math.Ceil(retryAfter.Seconds()))}
+	cm.maintenance.Store(&maintenance{notice: notice})
+	// Only the clients of this instance are told to reconnect elsewhere
+	cm.sendLocal(&Message{Type: cm.opts.MaintenanceMessageType, Data: notice})
+	if drain {
+		// Queued behind the broadcast of the notice
+		cm.enqueue(&socketOperation{opType: call, fn: cm.drainMaintenance})
+	}
+}
+
+// drainMaintenance closes all connections behind their queued messages, runs on the operations goroutine
+func (cm *ConnectionManager) drainMaintenance() {
+	var conns []*Connection
+	cm.registry.Range(func(conn *Connection) {
+		conns = append(conns, conn)
+	})
+	frame := &closeFrame{code: websocket.CloseTryAgainLater, reason: "maintenance"}
+	for _, conn := range conns {
+		cm.disconnect(conn, frame)
+	}
+}
+
+// ExitMaintenance accepts upgrades again
+func (cm *ConnectionManager) ExitMaintenance() {
+	cm.logV("Exiting maintenance")
+	cm.maintenance.Store(nil)
+}
+
+// InMaintenance reports whether the manager is in maintenance mode
+func (cm *ConnectionManager) InMaintenance() bool {
+	return cm.maintenance.Load() != nil
+}
+
+// rejectMaintenance answers the upgrade with 503 in maintenance mode
+func (cm *ConnectionManager) rejectMaintenance(w http.ResponseWriter, r *http.Request) error {
+	m := cm.maintenance.Load()
+	if m == nil {
+		return nil
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(m.notice.RetryAfter))
+	return cm.reject(w, r, UpgradeRejection{
+		Status:  http.StatusServiceUnavailable,
+		Reason:  RejectMaintenance,
+		Message: m.notice.Message,
+	})
+}
@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestEnterMaintenanceNotifiesLocalClientsOnly(t *testing.T) {
+	broker := NewMemoryBroker()
+	local := NewConnectionManager(WithBroker(broker))
+	remote := NewConnectionManager(WithBroker(broker))
+	localClient, _ := testServer(t, local, nil)()
+	remoteClient, _ := testServer(t, remote, nil)()
+	waitSubscribed(t, broker, 2)
+
+	local.EnterMaintenance("upgrading", 30*time.Second, false)
+
+	msg := readType(t, localClient, local.opts.MaintenanceMessageType)
+	notice, _ := msg.Data.(map[string]interface{})
+	if notice["message"] != "upgrading" || notice["retryAfter"] != float64(30) {
+		t.Fatalf("notice = %v", msg.Data)
+	}
+	expectNone(t, remoteClient, 200*time.Millisecond)
+	if remote.InMaintenance() {
+		t.Fatal("remote instance entered maintenance")
+	}
+}
+
+func TestMaintenanceDrainClosesAfterNotice(t *testing.T) {
+	for _, shards := range []int{0, 2} {
+		cm := NewConnectionManager(func(o *Options) {
+			o.SetupTimeout = -1
+			o.FanoutShards = shards
+		})
+		client, _ := testServer(t, cm, nil)()
+		cm.EnterMaintenance("upgrading", time.Minute, true)
+		readType(t, client, cm.opts.MaintenanceMessageType)
+		_, _, err := client.ReadMessage()
+		var closeErr *gorilla.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != gorilla.CloseTryAgainLater || closeErr.Text != "maintenance" {
+			t.Fatalf("err = %v with %d shards, want close 1013 after the notice", err, shards)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+)
+
+// Approximate bytes of a message besides its data
+const messageOverhead = 64
+
+// MemoryStats approximate memory held by messages
+type MemoryStats struct {
+	// Queued bytes of messages in credit backlogs, pause buffers and fan-out shard queues, a broadcast counted once
+	// however many connections it is queued to
+	Queued int64
+	// History bytes of the History, zero when it does not report them
+	History int64
+	// Cap is Options.MemoryCap
+	Cap int64
+	// Dropped messages that were not queued because of the cap
+	Dropped int64
+}
+
+type memoryAccount struct {
+	queued  atomic.Int64
+	dropped atomic.Int64
+
+	mu   sync.Mutex
+	held map[*Message]*queuedMessage // Messages in queues, a broadcast queued to many connections counts once
+}
+
+type queuedMessage struct {
+	size   int64
+	queues int // Queues holding the message
+}
+
+// MemoryStats of the manager
+func (cm *ConnectionManager) MemoryStats() MemoryStats {
+	return MemoryStats{
+		Queued:  cm.memory.queued.Load(),
+		History: cm.historyBytes(),
+		Cap:     cm.opts.MemoryCap,
+		Dropped: cm.memory.dropped.Load(),
+	}
+}
+
+func (cm *ConnectionManager) historyBytes() int64 {
+	if sizer, ok := cm.opts.History.(interface{ Bytes() int64 }); ok {
+		return sizer.Bytes()
+	}
+	return 0
+}
+
+// reserve accounts msg queued to conn, or drops it when that would exceed MemoryCap
+func (cm *ConnectionManager) reserve(conn *Connection, msg *Message) bool {
+	m := &cm.memory
+	m.mu.Lock()
+	if queued, ok := m.held[msg]; ok {
+		queued.queues++
+		m.mu.Unlock()
+		return true
+	}
+	m.mu.Unlock()
+	size := approxSize(msg)
+	if cm.opts.MemoryCap > 0 && m.queued.Load()+cm.historyBytes()+size > cm.opts.MemoryCap {
+		m.dropped.Add(1)
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "memory cap", Time: cm.clock.Now()})
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if queued, ok := m.held[msg]; ok {
+		queued.queues++
+		return true
+	}
+	if m.held == nil {
+		m.held = make(map[*Message]*queuedMessage)
+	}
+	m.held[msg] = &queuedMessage{size: size, queues: 1}
+	m.queued.Add(size)
+	return true
+}
+
+// release accounts a reserved msg leaving its queue
+func (cm *ConnectionManager) release(msg *Message) {
+	m := &cm.memory
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queued, ok := m.held[msg]
+	if !ok {
+		return
+	}
+	queued.queues--
+	if queued.queues == 0 {
+		delete(m.held, msg)
+		m.queued.Add(-queued.size)
+	}
+}
+
+func (cm *ConnectionManager) releaseAll(msgs []*Message) {
+	for _, msg := range msgs {
+		cm.release(msg)
+	}
+}
+
+// approxSize estimates the memory held by msg, encoding its data only when the size is not known from its type
+func approxSize(msg *Message) int64 {
+	size := messageOverhead + len(msg.Type) + len(msg.Topic) + len(msg.Key) + len(msg.Cursor) + len(msg.Attachment)
+	switch data := msg.Data.(type) {
+	case nil:
+	case string:
+		size += len(data)
+	case []byte:
+		size += len(data)
+	case json.RawMessage:
+		size += len(data)
+	default:
+		b, err := json.Marshal(data)
+		if err == nil {
+			size += len(b)
+		}
+	}
+	return int64(size)
+}
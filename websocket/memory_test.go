@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStatsCountSharedBroadcastOnce(t *testing.T) {
+	for _, memoryCap := range []int64{0, 1 << 20} {
+		cm := NewConnectionManager(func(o *Options) {
+			o.MemoryCap = memoryCap
+			o.PauseBuffer = 10
+		})
+		dial := testServer(t, cm, nil)
+		var conns []*Connection
+		for i := 0; i < 3; i++ {
+			_, conn := dial()
+			conn.Pause()
+			conns = append(conns, conn)
+		}
+		msg := &Message{Type: "big", Data: strings.Repeat("x", 1000)}
+		cm.Send(msg)
+		conns[0].Topics()
+		if queued := cm.MemoryStats().Queued; queued != approxSize(msg) {
+			t.Fatalf("cap %d: queued = %d, want %d", memoryCap, queued, approxSize(msg))
+		}
+		for _, conn := range conns {
+			conn.Resume()
+		}
+		conns[0].Topics()
+		deadline := time.Now().Add(2 * time.Second)
+		for cm.MemoryStats().Queued != 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if queued := cm.MemoryStats().Queued; queued != 0 {
+			t.Fatalf("cap %d: queued = %d after resume", memoryCap, queued)
+		}
+	}
+}
+
+func TestMemoryCapDropsAboveCap(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.MemoryCap = 512 })
+	small := &Message{Type: "small"}
+	if !cm.reserve(nil, small) {
+		t.Fatal("small message dropped")
+	}
+	if cm.reserve(nil, &Message{Type: "big", Data: strings.Repeat("x", 1000)}) {
+		t.Fatal("message above the cap reserved")
+	}
+	if stats := cm.MemoryStats(); stats.Dropped != 1 || stats.Queued != approxSize(small) {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestMemoryHistoryBytesFollowEvictions(t *testing.T) {
+	h := NewMemoryHistory(2)
+	var sizes []int64
+	for _, data := range []string{"a", "bb", "ccc"} {
+		msg := &Message{Type: "m", Data: data}
+		size, _ := messageSize(msg)
+		sizes = append(sizes, int64(size))
+		h.Append("topic", msg)
+		h.Append("other", msg)
+	}
+	// The third message of each topic evicted its first
+	if want := 2 * (sizes[1] + sizes[2]); h.Bytes() != want {
+		t.Fatalf("Bytes = %d, want %d", h.Bytes(), want)
+	}
+}
@@ -0,0 +1,213 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Eviction reasons counted by MemoryHistory.Evictions
+const (
+	EvictedByCount = "count"
+	EvictedByBytes = "bytes"
+	EvictedByAge   = "age"
+)
+
+const defaultTrimInterval = time.Minute
+
+// Retention limits the history kept for a topic, zero fields are unlimited
+type Retention struct {
+	MaxMessages int
+	// MaxBytes of the JSON encoded messages
+	MaxBytes int
+	MaxAge   time.Duration
+}
+
+// MemoryHistoryOptions configures a MemoryHistory
+type MemoryHistoryOptions struct {
+	// Retention of topics without a TopicRetention
+	Retention Retention
+	// TopicRetention overrides Retention for a topic when it returns true, optional
+	TopicRetention func(topic string) (Retention, bool)
+	// TrimInterval between trims of expired messages by Run, defaults to 1m
+	TrimInterval time.Duration
+	// Clock used for message ages, defaults to SystemClock
+	Clock Clock
+}
+
+// MemoryHistory keeps the recent messages of each topic in memory
+type MemoryHistory struct {
+	opts MemoryHistoryOptions
+
+	bytes atomic.Int64 // Of all topics, so Bytes does not take mu
+
+	mu        sync.Mutex
+	topics    map[string]*topicHistory
+	evictions map[string]int64
+}
+
+type topicHistory struct {
+	retention Retention
+	last      uint64
+	bytes     int
+	entries   []historyEntry
+}
+
+type historyEntry struct {
+	msg  *Message
+	at   time.Time
+	size int
+}
+
+// NewMemoryHistory history keeping up to retain messages per topic
+func NewMemoryHistory(retain int) *MemoryHistory {
+	return NewMemoryHistoryWithOptions(MemoryHistoryOptions{Retention: Retention{MaxMessages: retain}})
+}
+
+// NewMemoryHistoryWithOptions history with per topic retention, start Run to trim messages by age
+func NewMemoryHistoryWithOptions(opts MemoryHistoryOptions) *MemoryHistory {
+	if opts.TrimInterval <= 0 {
+		opts.TrimInterval = defaultTrimInterval
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock()
+	}
+	return &MemoryHistory{
+		opts:      opts,
+		topics:    make(map[string]*topicHistory),
+		evictions: make(map[string]int64),
+	}
+}
+
+// Append implements HistoryStore
+func (h *MemoryHistory) Append(topic string, msg *Message) (uint64, error) {
+	size, err := messageSize(msg)
+	if err != nil {
+		return 0, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.topics[topic]
+	if !ok {
+		t = &topicHistory{retention: h.opts.Retention}
+		if h.opts.TopicRetention != nil {
+			if retention, ok := h.opts.TopicRetention(topic); ok {
+				t.retention = retention
+			}
+		}
+		h.topics[topic] = t
+	}
+	t.last++
+	stored := *msg
+	stored.Seq = t.last
+	t.entries = append(t.entries, historyEntry{msg: &stored, at: h.opts.Clock.Now(), size: size})
+	t.bytes += size
+	h.bytes.Add(int64(size))
+	h.trim(t, h.opts.Clock.Now())
+	return t.last, nil
+}
+
+// Since implements HistoryStore
+func (h *MemoryHistory) Since(topic string, seq uint64) ([]*Message, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.topics[topic]
+	if !ok {
+		return nil, nil
+	}
+	h.trim(t, h.opts.Clock.Now())
+	var messages []*Message
+	for _, entry := range t.entries {
+		if entry.msg.Seq > seq {
+			messages = append(messages, entry.msg)
+		}
+	}
+	return messages, nil
+}
+
+// Bounds implements HistoryStore
+func (h *MemoryHistory) Bounds(topic string) (uint64, uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.topics[topic]
+	if !ok {
+		return 1, 0, nil
+	}
+	h.trim(t, h.opts.Clock.Now())
+	if len(t.entries) == 0 {
+		return t.last + 1, t.last, nil
+	}
+	return t.entries[0].msg.Seq, t.last, nil
+}
+
+// Bytes of the JSON encoded messages retained for all topics
+func (h *MemoryHistory) Bytes() int64 {
+	return h.bytes.Load()
+}
+
+// Evictions counts of messages evicted by retention, keyed by EvictedByCount, EvictedByBytes and EvictedByAge
+func (h *MemoryHistory) Evictions() map[string]int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := make(map[string]int64, len(h.evictions))
+	for reason, count := range h.evictions {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// Run trims messages older than their MaxAge every TrimInterval until ctx is done
+func (h *MemoryHistory) Run(ctx context.Context) error {
+	ticker := h.opts.Clock.NewTicker(h.opts.TrimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+			h.Trim()
+		}
+	}
+}
+
+// Trim evicts the messages of all topics exceeding their retention
+func (h *MemoryHistory) Trim() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.opts.Clock.Now()
+	for _, t := range h.topics {
+		h.trim(t, now)
+	}
+}
+
+// trim evicts the oldest messages of t until it is within its retention, called with mu held
+func (h *MemoryHistory) trim(t *topicHistory, now time.Time) {
+	for len(t.entries) > 0 {
+		var reason string
+		switch r := t.retention; {
+		case r.MaxMessages > 0 && len(t.entries) > r.MaxMessages:
+			reason = EvictedByCount
+		case r.MaxBytes > 0 && t.bytes > r.MaxBytes:
+			reason = EvictedByBytes
+		case r.MaxAge > 0 && now.Sub(t.entries[0].at) > r.MaxAge:
+			reason = EvictedByAge
+		default:
+			return
+		}
+		t.bytes -= t.entries[0].size
+		h.bytes.Add(-int64(t.entries[0].size))
+		t.entries[0] = historyEntry{}
+		t.entries = t.entries[1:]
+		h.evictions[reason]++
+	}
+}
+
+func messageSize(msg *Message) (int, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,53 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryHistoryRetention(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	msg := &Message{Type: "m", Data: "payload"}
+	size, _ := messageSize(msg)
+	h := NewMemoryHistoryWithOptions(MemoryHistoryOptions{
+		Retention: Retention{MaxMessages: 2},
+		TopicRetention: func(topic string) (Retention, bool) {
+			switch topic {
+			case "bytes":
+				return Retention{MaxBytes: 2*size + 1}, true
+			case "age":
+				return Retention{MaxAge: time.Minute}, true
+			}
+			return Retention{}, false
+		},
+		Clock: clock,
+	})
+	for _, topic := range []string{"count", "bytes", "age"} {
+		for i := 0; i < 3; i++ {
+			h.Append(topic, msg)
+		}
+	}
+	bounds := func(topic string, first, last uint64) {
+		t.Helper()
+		if f, l, _ := h.Bounds(topic); f != first || l != last {
+			t.Fatalf("%s bounds %d-%d, want %d-%d", topic, f, l, first, last)
+		}
+	}
+	bounds("count", 2, 3)
+	bounds("bytes", 2, 3)
+	bounds("age", 1, 3)
+	bounds("unknown", 1, 0)
+	if since, _ := h.Since("count", 2); len(since) != 1 || since[0].Seq != 3 {
+		t.Fatalf("Since = %v", since)
+	}
+
+	clock.Advance(2 * time.Minute)
+	h.Trim()
+	bounds("age", 4, 3)
+	if got := h.Evictions(); got[EvictedByCount] != 1 || got[EvictedByBytes] != 1 || got[EvictedByAge] != 3 {
+		t.Fatalf("evictions %v", got)
+	}
+	if h.Bytes() != 4*int64(size) {
+		t.Fatalf("%d bytes retained, want %d", h.Bytes(), 4*size)
+	}
+}
@@ -1,7 +1,30 @@
 package websocket
 
+import "encoding/json"
+
 // Message between web app and client
 type Message struct {
 	Type string      `json:"type"`
 	Data interface{} `json:"data"`
+	// Topic and Seq of messages published to a topic with history, Seq increases by one per message
+	Topic string `json:"topic,omitempty"`
+	Seq   uint64 `json:"seq,omitempty"`
+	// Key of messages published with PublishKeyed
+	Key string `json:"key,omitempty"`
+	// Cursor resumes the topic after this message with ResumeStream
+	Cursor string `json:"cursor,omitempty"`
+	// ID correlates a call message and its result, or is the row ID of a message broadcast by an Outbox
+	ID string `json:"id,omitempty"`
+	// Extra top level fields this version does not know, kept with Options.PreserveUnknownFields or
+	// DecodeMessage and written back by MarshalJSON
+	Extra map[string]json.RawMessage `json:"-"`
+	// Attachment sent as a binary frame right after the message, so blobs travel without base64. It is read
+	// back into the message it follows.
+	Attachment []byte `json:"-"`
+	// AttachmentSize announces the attachment in the encoded message, it is set when writing
+	AttachmentSize int `json:"attachment,omitempty"`
+
+	delivered func(error)   // Set by SendToContext
+	binary    bool          // Data is a []byte written as is in a binary frame
+	stream    *binaryStream // Of NextBinaryWriter
 }
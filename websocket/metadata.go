@@ -0,0 +1,61 @@
+package websocket
+
+// Set stores value under key on the connection, e.g. session data looked up when it authenticates
+func (c *Connection) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+// Get returns the value stored under key and whether it is set
+func (c *Connection) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// metadata copies the values of the connection, nil when there are none
+func (c *Connection) metadata() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.values) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(c.values))
+	for key, value := range c.values {
+		values[key] = value
+	}
+	return values
+}
+
+// Delete removes the value stored under key
+func (c *Connection) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+}
+
+// SendWhere sends msg to the connections receiving broadcasts for which filter returns true, e.g. all
+// connections of a user. filter runs on the operations goroutine and must not call blocking manager methods. Only
+// the connections of this manager are filtered, msg is not relayed through Options.Broker.
+func (cm *ConnectionManager) SendWhere(filter func(conn *Connection) bool, msg *Message) {
+	cm.enqueue(&socketOperation{
+		opType: sendWhere,
+		msg:    msg,
+		filter: filter,
+	})
+}
+
+// sendWhere runs on the operations goroutine
+func (cm *ConnectionManager) sendWhere(filter func(*Connection) bool, msg *Message) {
+	fanout := cm.newFanout(msg)
+	cm.registry.Range(func(conn *Connection) {
+		if conn.state == stateReady && filter(conn) {
+			fanout.deliver(conn)
+		}
+	})
+}
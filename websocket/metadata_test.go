@@ -0,0 +1,44 @@
+package websocket
+
+import "testing"
+
+func TestConnectionValues(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	_, conn := testServer(t, cm, nil)()
+	if _, ok := conn.Get("user"); ok {
+		t.Fatal("value set on a new connection")
+	}
+	conn.Set("user", "alice")
+	if user, ok := conn.Get("user"); !ok || user != "alice" {
+		t.Fatalf("Get returned %v, %v", user, ok)
+	}
+	conn.Delete("user")
+	if _, ok := conn.Get("user"); ok {
+		t.Fatal("value kept after Delete")
+	}
+}
+
+func TestSendWhereFiltersConnections(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	dial := testServer(t, cm, nil)
+	alice, aliceConn := dial()
+	bob, bobConn := dial()
+	aliceConn.Set("user", "alice")
+	bobConn.Set("user", "bob")
+
+	cm.SendWhere(func(conn *Connection) bool {
+		user, _ := conn.Get("user")
+		return user == "alice"
+	}, &Message{Type: "for alice"})
+	cm.Send(&Message{Type: "for all"})
+	for _, want := range []string{"for alice", "for all"} {
+		var msg Message
+		if err := alice.ReadJSON(&msg); err != nil || msg.Type != want {
+			t.Fatalf("alice read %+v, %v, want %s", msg, err, want)
+		}
+	}
+	var msg Message
+	if err := bob.ReadJSON(&msg); err != nil || msg.Type != "for all" {
+		t.Fatalf("bob read %+v, %v, want only the broadcast", msg, err)
+	}
+}
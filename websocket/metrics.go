@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Stats snapshot of the manager counters. It marshals to JSON, so it can be published with expvar, e.g.
+//
+//	expvar.Publish("websocket", expvar.Func(func() any { return cm.Stats() }))
+type Stats struct {
+	Connections      int64 `json:"connections"`
+	MessagesSent     int64 `json:"messagesSent"`
+	MessagesReceived int64 `json:"messagesReceived"`
+	WriteErrors      int64 `json:"writeErrors"`
+	// Dropped messages, each reported with a DropEvent
+	Dropped int64 `json:"dropped"`
+	// RateLimited client messages above Options.InboundLimit
+	RateLimited int64 `json:"rateLimited"`
+	// Broadcasts sent with Send, BroadcastTime their total time from Send until handed to the writers of all
+	// ready connections, or to the pacer
+	Broadcasts    int64         `json:"broadcasts"`
+	BroadcastTime time.Duration `json:"broadcastTime"`
+	// BroadcastLatency cumulative counts of broadcasts per bound of 1ms, 5ms, 10ms, 50ms, 100ms, 500ms, 1s, 5s and +Inf
+	BroadcastLatency []int64 `json:"broadcastLatency"`
+}
+
+type managerMetrics struct {
+	sent          atomic.Int64
+	received      atomic.Int64
+	writeErrors   atomic.Int64
+	dropped       atomic.Int64
+	rateLimited   atomic.Int64
+	broadcasts    atomic.Int64
+	broadcastTime atomic.Int64
+	latency       [9]atomic.Int64 // Per bucket bound and +Inf, not cumulative
+}
+
+// Stats of the manager
+func (cm *ConnectionManager) Stats() Stats {
+	m := &cm.metrics
+	s := Stats{
+		Connections:      cm.load.connections.Load(),
+		MessagesSent:     m.sent.Load(),
+		MessagesReceived: m.received.Load(),
+		WriteErrors:      m.writeErrors.Load(),
+		Dropped:          m.dropped.Load(),
+		RateLimited:      m.rateLimited.Load(),
+		Broadcasts:       m.broadcasts.Load(),
+		BroadcastTime:    time.Duration(m.broadcastTime.Load()),
+		BroadcastLatency: make([]int64, len(m.latency)),
+	}
+	var total int64
+	for i := range m.latency {
+		total += m.latency[i].Load()
+		s.BroadcastLatency[i] = total
+	}
+	return s
+}
+
+func (m *managerMetrics) observeBroadcast(latency time.Duration) {
+	m.broadcasts.Add(1)
+	m.broadcastTime.Add(int64(latency))
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return latency <= latencyBuckets[i] })
+	m.latency[i].Add(1)
+}
+
+// writeStats writes stats in the Prometheus text format, connections are written with the LoadSignals
+func writeStats(w io.Writer, labels string, s Stats) {
+	bucketLabels := labels
+	if labels != "" {
+		bucketLabels += ","
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "websocket_sent_total%s %d\n", labels, s.MessagesSent)
+	fmt.Fprintf(w, "websocket_received_total%s %d\n", labels, s.MessagesReceived)
+	fmt.Fprintf(w, "websocket_dropped_total%s %d\n", labels, s.Dropped)
+	fmt.Fprintf(w, "websocket_rate_limited_total%s %d\n", labels, s.RateLimited)
+	for i, bound := range latencyBuckets {
+		fmt.Fprintf(w, "websocket_broadcast_seconds_bucket{%sle=\"%g\"} %d\n", bucketLabels, bound.Seconds(), s.BroadcastLatency[i])
+	}
+	fmt.Fprintf(w, "websocket_broadcast_seconds_bucket{%sle=\"+Inf\"} %d\n", bucketLabels, s.Broadcasts)
+	fmt.Fprintf(w, "websocket_broadcast_seconds_sum%s %g\n", labels, s.BroadcastTime.Seconds())
+	fmt.Fprintf(w, "websocket_broadcast_seconds_count%s %d\n", labels, s.Broadcasts)
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatsCountMessagesAndBroadcasts(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, _ := testServer(t, cm, nil)()
+	client.WriteJSON(Message{Type: "hello"})
+	eventually(t, "the message to be received", func() bool { return cm.Stats().MessagesReceived == 1 })
+	cm.Send(&Message{Type: "news"})
+	cm.Send(&Message{Type: "more news"})
+	readType(t, client, "more news")
+	eventually(t, "the messages to be counted", func() bool { return cm.Stats().MessagesSent == 2 })
+
+	s := cm.Stats()
+	if s.Connections != 1 || s.Broadcasts != 2 || s.Dropped != 0 || s.WriteErrors != 0 {
+		t.Fatalf("stats %+v", s)
+	}
+	// Latency buckets are cumulative, the last one counts every broadcast
+	for i := 1; i < len(s.BroadcastLatency); i++ {
+		if s.BroadcastLatency[i] < s.BroadcastLatency[i-1] {
+			t.Fatalf("latency buckets %v not cumulative", s.BroadcastLatency)
+		}
+	}
+	if last := s.BroadcastLatency[len(s.BroadcastLatency)-1]; last != 2 {
+		t.Fatalf("last latency bucket %d, want 2", last)
+	}
+
+	encoded, err := json.Marshal(s)
+	if err != nil || !strings.Contains(string(encoded), `"messagesSent":2`) {
+		t.Fatalf("marshaled %s, %v", encoded, err)
+	}
+	rec := httptest.NewRecorder()
+	cm.LoadHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		"websocket_sent_total 2\n",
+		"websocket_received_total 1\n",
+		"websocket_broadcast_seconds_bucket{le=\"+Inf\"} 2\n",
+		"websocket_broadcast_seconds_count 2\n",
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Fatalf("metrics missing %q:\n%s", line, rec.Body.String())
+		}
+	}
+}
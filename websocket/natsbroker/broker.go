@@ -0,0 +1,42 @@
+// Package natsbroker relays broadcasts between ConnectionManagers of several instances through NATS, e.g.
+//
+//	nc, err := nats.Connect(nats.DefaultURL)
+//	if err != nil {
+//		return err
+//	}
+//	cm := websocket.NewConnectionManager(websocket.WithBroker(natsbroker.New(nc, "broadcasts")))
+package natsbroker
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Broker publishes to and subscribes to one NATS subject
+type Broker struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// New broker on subject of conn, instances relaying to each other use the same subject
+func New(conn *nats.Conn, subject string) *Broker {
+	return &Broker{conn: conn, subject: subject}
+}
+
+// Publish sends data to the subject, NATS buffers it so ctx is not used
+func (b *Broker) Publish(_ context.Context, data []byte) error {
+	return b.conn.Publish(b.subject, data)
+}
+
+// Subscribe calls deliver with the messages of the subject, one at a time, until ctx is done
+func (b *Broker) Subscribe(ctx context.Context, deliver func(data []byte)) error {
+	sub, err := b.conn.Subscribe(b.subject, func(msg *nats.Msg) {
+		deliver(msg.Data)
+	})
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return sub.Unsubscribe()
+}
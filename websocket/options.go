@@ -0,0 +1,697 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Options configures a ConnectionManager created with NewConnectionManagerWithOptions
+type Options struct {
+	// Upgrader used for the websocket handshake, defaults to 1024 byte buffers and the same origin check. Its
+	// HandshakeTimeout and Error are set from these options when zero.
+	Upgrader *websocket.Upgrader
+	// ReadBufferSize and WriteBufferSize of the upgrader in bytes, zero keeps the Upgrader value or 1024
+	ReadBufferSize  int
+	WriteBufferSize int
+	// FlushInterval coalesces the frames written to a connection into fewer TCP segments: they are held in a
+	// buffer of FlushBufferSize bytes, defaults to 4096, until it fills, Connection.Flush is called or
+	// FlushInterval passed since the first held frame. Close and ping frames are sent right away. Zero writes
+	// each frame to the network directly.
+	FlushInterval   time.Duration
+	FlushBufferSize int
+	// TCP tunes TCP_NODELAY, keepalive probes and kernel buffer sizes of the TCP connection under each upgraded
+	// socket
+	TCP TCPOptions
+	// OnNetConn is called with the network connection of each upgraded socket after TCP is applied, e.g. to set
+	// other socket options through SyscallConn
+	OnNetConn func(conn net.Conn)
+	// CheckOrigin of the upgrader, nil keeps the Upgrader check or the same origin check
+	CheckOrigin func(r *http.Request) bool
+	// EnableCompression negotiates per message compression
+	EnableCompression bool
+	// Subprotocols supported by the server in order of preference
+	Subprotocols []string
+	// OperationsBuffer capacity of the operations channel, defaults to 1
+	OperationsBuffer int
+
+	// HandshakeTimeout bounds writing the upgrade response to the client
+	HandshakeTimeout time.Duration
+	// FirstMessageTimeout bounds the wait for the first message after the upgrade, zero disables it.
+	// Connections that stall are closed and counted in HandshakeTimeouts.
+	FirstMessageTimeout time.Duration
+
+	// Authenticate enables first message authentication. The first message of each connection is passed to it
+	// instead of onReceive, and the connection receives no broadcasts until it returns a nil error. The returned
+	// identity is available from Connection.Identity. Defaults FirstMessageTimeout to 10s when not set.
+	Authenticate func(msg *Message) (identity interface{}, err error)
+	// AuthenticateRequest validates the upgrade request before the socket is created, e.g. a JWT or a session
+	// cookie. An error rejects the upgrade with 401, the returned identity is available from Connection.Identity.
+	// Connections with a non nil identity skip first message authentication.
+	AuthenticateRequest func(r *http.Request) (identity interface{}, err error)
+	// AllowAnonymous lets connections receive broadcasts before authenticating, Connection.Anonymous reports true
+	// until an authentication message succeeds
+	AllowAnonymous bool
+	// AnonymousTopics with AllowAnonymous are the only topics anonymous connections may join by a join message,
+	// declare in an interest message or resume with a cursor, nil leaves them to AuthorizeJoin
+	AnonymousTopics []string
+	// AnonymousInboundLimit with AllowAnonymous replaces InboundLimit while the connection is anonymous, e.g. a
+	// lower rate for visitors. InboundLimit applies from the successful authentication on. Zero rates keep
+	// InboundLimit.
+	AnonymousInboundLimit InboundLimit
+	// AuthMessageType marks messages that are passed to Authenticate at any point of the session, so an anonymous
+	// connection can upgrade or an authenticated one can refresh its identity without reconnecting. A failed
+	// authentication removes the connection.
+	AuthMessageType string
+	// OnAuthenticated is called after a successful authentication with the identity the connection had before,
+	// so the application can re-evaluate permissions and subscriptions of the connection. The topics and
+	// interests AnonymousTopics and AuthorizeJoin no longer allow for the new identity are left before.
+	OnAuthenticated func(conn *Connection, previous interface{})
+
+	// PresenceKey maps a connection identity to a user key, empty keys are not tracked. Presence is enabled
+	// when both PresenceKey and OnPresence are set.
+	PresenceKey func(identity interface{}) string
+	// OnPresence is called when a user key gets its first active connection or loses its last one
+	OnPresence func(key string, online bool)
+	// PresenceDebounce delays presence events, transitions that cancel out within the window are not emitted
+	PresenceDebounce time.Duration
+
+	// AffinityCookie name of a cookie set on the upgrade response, and AffinityHeader of a header, carrying the
+	// connection ID so L7 load balancers can route the reconnects of a client to the same instance. A cookie sent
+	// back by the client keeps its value. Both optional.
+	AffinityCookie string
+	AffinityHeader string
+	// AffinityMaxAge of the affinity cookie, zero makes it a session cookie
+	AffinityMaxAge time.Duration
+	// CloseCode and CloseReason of the close frame sent to connections removed without one, e.g. after write
+	// errors or with DisconnectSlow. CloseCode defaults to 1000 (normal closure).
+	CloseCode   int
+	CloseReason string
+	// CloseTimeout to wait for the close frame of the peer after sending one before closing the network
+	// connection, defaults to 1s
+	CloseTimeout time.Duration
+	// OnConnect is called when a connection is added and OnDisconnect when it is removed, with the error that
+	// caused it: a *websocket.CloseError with the code and reason of the close frame sent or received, or the
+	// read or write error. Unlike the events they are never dropped. They run on the operations goroutine so they
+	// must not block or call back into the manager.
+	OnConnect    func(conn *Connection)
+	OnDisconnect func(conn *Connection, err error)
+	// OnConnectPipeline steps run in order for each new connection once it is active, before its messages are
+	// read, e.g. send welcome, send config, replay history
+	OnConnectPipeline []ConnectStep
+	// OnConnectError is called when a connect step fails
+	OnConnectError func(conn *Connection, err error)
+
+	// RequireReady holds back broadcasts from active connections until they are marked ready, either by
+	// ReadyStep at the end of the connect pipeline, a ReadyMessageType message or Connection.MarkReady
+	RequireReady bool
+	// ReadyMessageType marks client messages that mark the connection ready, they are not passed to onReceive
+	ReadyMessageType string
+
+	// SessionTokenTTL how long a session resume token stays valid, defaults to 1h
+	SessionTokenTTL time.Duration
+	// SessionReplayWindow enables resuming sessions with at-least-once delivery: each connection starts with a
+	// SessionMessageType message carrying a SessionInfo, defaults to "session", and a client upgrading with its
+	// token as SessionParam query parameter, defaults to "session", and its count of received messages as
+	// "received" gets the messages written after that count and those that could not be written, followed by
+	// those sent with SendSession in between. Messages are kept for SessionReplayWindow, at most
+	// SessionReplaySize of them, defaults to 1000. While no connection of the session is open its broadcasts and
+	// the publishes to its topics and interests are kept, and those subscriptions are restored on resume. Zero
+	// disables sessions.
+	SessionReplayWindow time.Duration
+	SessionReplaySize   int
+	SessionMessageType  string
+	SessionParam        string
+
+	// EventsBuffer capacity of the Events channel, defaults to 256
+	EventsBuffer int
+
+	// Clock time source for timeouts, TTLs and schedulers, defaults to SystemClock
+	Clock Clock
+	// Logger receives verbose and error logs, defaults to NopLogger. SlogLogger adapts a *slog.Logger.
+	Logger Logger
+
+	// Faults injects write errors, dropped frames and handshake delays, nil disables fault injection
+	Faults *Faults
+
+	// LoadInterval between load samples, defaults to 10s
+	LoadInterval time.Duration
+	// OnLoad receives load signals every LoadInterval, e.g. to push them to an autoscaler
+	OnLoad func(LoadSignals)
+	// Overloaded enables automatic load shedding, when it returns true ShedFraction of the connections are shed
+	Overloaded func(LoadSignals) bool
+	// ShedFraction of connections disconnected per overloaded sample, defaults to 0.1
+	ShedFraction float64
+	// ShedPriority ranks connections for shedding, lowest first, e.g. free tier below paying users. Defaults
+	// to shedding anonymous connections before authenticated ones.
+	ShedPriority func(conn *Connection) int
+
+	// StallThreshold after which a connection that read no message or pong is reported by StalledConnections,
+	// defaults to 2m
+	StallThreshold time.Duration
+	// CloseStalled terminates stalled connections automatically
+	CloseStalled bool
+	// SetupTimeout after the upgrade by which a connection must be authenticated and, with RequireReady, ready,
+	// otherwise it is closed with 1008 and counted as RejectSetupTimeout. Defaults to 1m, negative disables it.
+	SetupTimeout time.Duration
+
+	// FlowControl enables credit based flow control: a connection is only written to while it has credits, and
+	// messages beyond its credits are kept until the client grants more with a CreditMessageType message whose
+	// data is the number of credits
+	FlowControl bool
+	// InitialCredits of a new connection
+	InitialCredits int
+	// CreditMessageType of client messages granting credits, they are not passed to onReceive
+	CreditMessageType string
+	// MaxCreditBacklog messages kept per connection waiting for credits, further messages are dropped.
+	// Defaults to 1024.
+	MaxCreditBacklog int
+	// MaxCredits a connection can hold, larger grants and totals are capped to it. Defaults to 65536.
+	MaxCredits int
+
+	// PauseBuffer messages kept per paused connection, zero drops all messages while paused
+	PauseBuffer int
+
+	// InterestMessageType of client messages declaring the full list of entity IDs the client is interested in,
+	// they replace the previous interests of the connection and are not passed to onReceive. Empty disables it.
+	InterestMessageType string
+	// OnInterestChange is called with the entity IDs added and removed when interests of a connection change,
+	// on the operations goroutine so it must not block or call back into the manager
+	OnInterestChange func(conn *Connection, added, removed []string)
+	// JoinMessageType and LeaveMessageType of client messages joining or leaving the topic in their data, they
+	// are not passed to onReceive. Empty disables them.
+	JoinMessageType  string
+	LeaveMessageType string
+	// AuthorizeJoin reports whether conn may join topic by a join message, declare it in an interest message or
+	// resume its stream with a cursor, all are allowed when nil. It is called from the reader goroutine of conn.
+	AuthorizeJoin func(conn *Connection, topic string) bool
+	// SubscriptionLease makes subscribed topics expire unless joined again within the lease, e.g. every 5
+	// minutes, so clients whose UI navigated away without leaving stop receiving them. It applies to topics joined,
+	// subscribed with backfill, SubscribeKeyed or ResumeStream and restored with a session, and subscribing again
+	// renews it. Zero keeps subscriptions until left.
+	SubscriptionLease time.Duration
+	// LeaseExpiredMessageType of the message with the topic as data sent when a lease expires, empty sends none
+	LeaseExpiredMessageType string
+	// TopicSwapMessageType of the message with TopicSwap data sent to the subscribers moved by SwapTopic,
+	// defaults to "topic.swap"
+	TopicSwapMessageType string
+	// History stores messages published with PublishEntity, making the entity IDs replayable topics for
+	// SubscribeWithBackfill. Its methods run on the operations goroutine. Optional.
+	History HistoryStore
+	// Broker relays Send, SendContext, Publish and PublishEntity to the managers of other instances, so they
+	// reach every connection behind a load balancer. Broadcasts with a filter, e.g. SendWhere, stay local.
+	Broker Broker
+	// BrokerBuffer broadcasts waiting to be published to the Broker, more are dropped, defaults to 256
+	BrokerBuffer int
+	// CursorMessageType of client messages carrying a cursor or a list of cursors to resume topic streams after
+	// a reconnect, they are not passed to onReceive. Empty disables it.
+	CursorMessageType string
+	// CursorKey signs the cursors of published messages with HMAC-SHA256 so clients cannot forge them, the
+	// instances behind a load balancer must share it. Defaults to a random key, cursors then do not outlive the
+	// manager.
+	CursorKey []byte
+	// SnapshotEndMessageType of the message sent after the snapshot of a keyed topic to SubscribeKeyed
+	// subscribers, with the topic in Message.Topic. Empty sends no marker.
+	SnapshotEndMessageType string
+	// CoalesceInterval between flushes of PublishKeyed updates, updates of the same key within an interval are
+	// coalesced into the latest one, e.g. for order book levels. Zero publishes every update immediately.
+	CoalesceInterval time.Duration
+	// AdaptiveCoalesce coalesces keyed updates per connection instead of per topic, flushing every connection
+	// at an interval between CoalesceInterval and MaxCoalesceInterval grown with its RTT and queue depth, so
+	// nearby fast clients get low latency and distant slow clients get bigger batches. RTT is measured with
+	// pings. CoalesceInterval defaults to 10ms and PingInterval to 15s with it.
+	AdaptiveCoalesce bool
+	// MaxCoalesceInterval caps the adaptive flush interval, defaults to 1s
+	MaxCoalesceInterval time.Duration
+	// PaceWindow spreads the writes of broadcasts to at least PaceThreshold connections over the window, e.g.
+	// 50ms, to avoid microbursts when fanning out to many connections. Broadcasts stay in order, but messages
+	// sent with SendTo or published to entities may overtake a paced broadcast. Zero writes at once.
+	PaceWindow time.Duration
+	// PaceThreshold connections from which broadcasts are paced, defaults to 1000
+	PaceThreshold int
+	// BroadcastOrder orders the writes of broadcasts and entity publishes by the RTT of the connections. The order
+	// only holds within a shard writer with FanoutShards, which writes its connections in turn. Otherwise each
+	// connection is written by its own goroutine, so it sets the order messages are queued in, not written, and
+	// does not reduce the delivery skew between clients. RTT is measured with pings. Defaults to RegistryOrder.
+	BroadcastOrder BroadcastOrder
+	// DeliverySkewSamples broadcasts kept to report the delivery skew between their connections with
+	// DeliverySkew and the metrics handlers. Zero disables measuring it.
+	DeliverySkewSamples int
+	// MaxConnections accepted at a time, further upgrades are rejected with 503 and RejectConnectionLimit. Zero
+	// is unlimited.
+	MaxConnections int
+	// MaxMessageSize in bytes of client messages, larger messages close the connection with 1009 (message too
+	// big). Zero is unlimited.
+	MaxMessageSize int64
+	// InboundLimit limits the messages and bytes per second each connection may send, see InboundLimit
+	InboundLimit InboundLimit
+	// MaxMessageTypes counted separately by TypeStats and the metrics, defaults to 256. Clients choose the types
+	// of their messages, so types seen once the limit is reached are counted as "other".
+	MaxMessageTypes int
+	// WriteQueueSize messages queued per connection for its writer goroutine, so a slow client does not hold up
+	// writes to the others. Defaults to 256.
+	WriteQueueSize int
+	// WriteQueuePolicy when the queue of a connection, or with FanoutShards the queue of its shard, is full,
+	// defaults to DropOldest
+	WriteQueuePolicy QueuePolicy
+	// FanoutShards writer goroutines sharing the writes to connections, each connection is assigned to one shard
+	// by its ID. Set it to about the number of cores available for fan-out on high throughput deployments. Zero
+	// uses a writer goroutine per connection.
+	FanoutShards int
+	// FanoutShardBuffer writes queued per shard before WriteQueuePolicy applies, defaults to 1024
+	FanoutShardBuffer int
+	// MemoryCap in bytes of messages held in outbound queues and the History, when it supports Bytes() int64.
+	// Messages that would be queued above the cap are dropped with a DropEvent. Zero is unlimited.
+	MemoryCap int64
+	// UsageKey of the user whose usage a connection counts towards, e.g. an account ID from its Identity.
+	// Defaults to the Identity when it is a string or a fmt.Stringer, connections with an empty key are only
+	// counted per connection.
+	UsageKey func(conn *Connection) string
+	// OnUsageExport is called with the cumulative usage per user every UsageExportInterval, e.g. to feed
+	// usage based billing. Use UsageFileExporter to append reports to a file. Optional.
+	OnUsageExport func(report UsageReport)
+	// UsageExportInterval defaults to 1m
+	UsageExportInterval time.Duration
+	// Quota of the user key of a connection, see UsageKey. Users without a quota are not limited.
+	Quota func(key string) (Quota, bool)
+	// QuotaWarningMessageType of the message sent when a user reaches Quota.WarnAt, defaults to "quota"
+	QuotaWarningMessageType string
+	// RateLimitMessageType of the RateLimit message sent when QuotaThrottle starts throttling a connection and
+	// again, at most once per second, while it sends faster than allowed, and when RateLimitDrop starts dropping
+	// the messages of a connection above InboundLimit, with Rate its MessagesPerSecond. Defaults to "ratelimit".
+	RateLimitMessageType string
+	// MaintenanceMessageType of the notice broadcast by EnterMaintenance, defaults to "maintenance"
+	MaintenanceMessageType string
+	// PreserveUnknownFields keeps unknown top level fields of client messages in Message.Extra, so middleware
+	// and Transform pass fields added by newer clients through
+	PreserveUnknownFields bool
+	// Codec encoding the messages of connections, defaults to JSONCodec with PreserveUnknownFields
+	Codec Codec
+	// OnBinary receives the binary frames of connections whose codec uses text frames, e.g. file chunks or audio
+	// sent next to JSON messages. It is called from the reader goroutine of conn instead of onReceive. Without
+	// it binary frames are decoded by the codec.
+	OnBinary func(conn *Connection, data []byte)
+	// StreamTimeout longest a Connection.NextBinaryWriter stream may hold the writer of its connection before the
+	// connection is removed, defaults to 1 minute
+	StreamTimeout time.Duration
+	// JobQueue receives the client messages of JobTypes instead of onReceive, offloading their processing to
+	// workers. Worker results are sent back to the connection of the job.
+	JobQueue JobQueue
+	JobTypes []string
+	// JobErrorMessageType of the message answering a client message that could not be pushed to the JobQueue,
+	// with the ID of the message and CallResult data, defaults to "job.error"
+	JobErrorMessageType string
+	// CallMessageType and ResultMessageType of the call messages answered by HandleCall and of their results,
+	// default to "call" and "result"
+	CallMessageType   string
+	ResultMessageType string
+	// CallTimeout after which the context of a HandleCall handler is cancelled and the call answered with the
+	// error, defaults to 10s
+	CallTimeout time.Duration
+	// MaxConcurrentCalls of HandleCall running per connection, further calls are answered with ErrTooManyCalls,
+	// defaults to 16
+	MaxConcurrentCalls int
+	// TaskProgressMessageType and TaskResultMessageType of the messages of StartTask, default to "task.progress"
+	// and "task.result". TaskCancelMessageType marks client messages cancelling the task with their ID, they are
+	// not passed to onReceive. Empty disables it.
+	TaskProgressMessageType string
+	TaskResultMessageType   string
+	TaskCancelMessageType   string
+	// ScratchDir directory holding the Connection.Scratch files of connections, defaults to the temporary
+	// directory of the OS
+	ScratchDir string
+	// ScratchBudget maximum bytes of the scratch files of a connection, zero is unlimited
+	ScratchBudget int64
+	// SubprotocolCodecs codecs of connections by negotiated subprotocol, e.g. a protobuf codec for "proto".
+	// List the names in Subprotocols too. UpgradeDecision.Codec takes precedence.
+	SubprotocolCodecs map[string]Codec
+	// TypeAliases maps deprecated message types of client messages to their current names before they are
+	// handled, each use is counted by DeprecatedTypes
+	TypeAliases map[string]string
+	// CaptureRate fraction of outbound messages and inbound messages passed to onReceive sent to CaptureSink for
+	// offline analysis
+	CaptureRate float64
+	// CaptureSink receives sampled messages on its own goroutine, samples are dropped while it falls behind
+	CaptureSink CaptureSink
+	// CaptureRedact removes sensitive fields from a sampled message before it is captured, returning nil skips
+	// it. It must not modify msg. Outbound messages are captured after Transform. Optional.
+	CaptureRedact func(msg *Message) *Message
+	// PingInterval between pings measuring the RTT of connections and detecting dead connections, zero sends no
+	// pings. Transports implementing Pinger may opt out, e.g. xnetws.
+	PingInterval time.Duration
+	// PongTimeout after a ping without any message or pong read after which the connection is removed, so
+	// half-open connections do not linger. Defaults to 10s when PingInterval is set.
+	PongTimeout time.Duration
+	// LoadHint returns the load of the server from 0 (idle) to 1 (overloaded), sent in every ping as a byte after
+	// the send time so clients can slow down or prefer another endpoint, e.g. from QueueSaturation. Optional.
+	LoadHint func(signals LoadSignals) float64
+	// NoopInterval between tiny application level messages sent to every connection only to keep proxies that
+	// ignore pings from closing idle connections, zero sends none. Clients should ignore them.
+	NoopInterval time.Duration
+	// NoopMessageType of the NoopInterval messages, defaults to "noop"
+	NoopMessageType string
+
+	// Transform rewrites broadcast and published messages per subscriber, e.g. field filtering, localization or
+	// permission based redaction. Returning nil skips the subscriber. It runs on the operations goroutine and
+	// must not modify msg.
+	Transform func(conn *Connection, msg *Message) *Message
+	// TransformKey groups subscribers that get the same Transform output, e.g. role and locale, so Transform
+	// runs once per group and fan-out instead of once per subscriber
+	TransformKey func(conn *Connection) string
+
+	// TemplateVars per connection variables available to templates as .Vars, e.g. the user name
+	TemplateVars func(conn *Connection) map[string]interface{}
+
+	// OnBeforeUpgrade runs before each upgrade and can annotate the request, choose a pool for the connection or
+	// abort the upgrade with a custom status and body
+	OnBeforeUpgrade func(r *http.Request) UpgradeDecision
+
+	// RejectResponse writes the response of rejected upgrades, defaults to WriteJSONRejection
+	RejectResponse func(w http.ResponseWriter, r *http.Request, rejection UpgradeRejection)
+
+	// Registry stores the connections, defaults to NewMapRegistry
+	Registry Registry
+
+	// IDGenerator for connection and session IDs, defaults to random URL safe IDs. Session resume tokens are
+	// always random.
+	IDGenerator IDGenerator
+}
+
+// DefaultOptions used by NewConnectionManager
+func DefaultOptions() Options {
+	return Options{
+		HandshakeTimeout:    10 * time.Second,
+		FirstMessageTimeout: 0,
+		AuthMessageType:     "auth",
+		CreditMessageType:   "credit",
+		PingInterval:        defaultPingInterval,
+	}
+}
+
+func (opts *Options) setDefaults() {
+	if opts.Authenticate != nil && opts.FirstMessageTimeout == 0 {
+		opts.FirstMessageTimeout = defaultAuthTimeout
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock()
+	}
+	if opts.SessionReplaySize <= 0 {
+		opts.SessionReplaySize = defaultSessionReplaySize
+	}
+	if opts.MaxMessageTypes <= 0 {
+		opts.MaxMessageTypes = defaultMaxMessageTypes
+	}
+	if len(opts.CursorKey) == 0 {
+		opts.CursorKey = randomCursorKey()
+	}
+	if opts.TopicSwapMessageType == "" {
+		opts.TopicSwapMessageType = defaultTopicSwapMessageType
+	}
+	if opts.SessionMessageType == "" {
+		opts.SessionMessageType = defaultSessionMessageType
+	}
+	if opts.SessionParam == "" {
+		opts.SessionParam = defaultSessionParam
+	}
+	if opts.Logger == nil {
+		opts.Logger = NopLogger()
+	}
+	if opts.FlushBufferSize <= 0 {
+		opts.FlushBufferSize = defaultFlushBufferSize
+	}
+	if opts.CallMessageType == "" {
+		opts.CallMessageType = defaultCallMessageType
+	}
+	if opts.ResultMessageType == "" {
+		opts.ResultMessageType = defaultResultMessageType
+	}
+	if opts.JobErrorMessageType == "" {
+		opts.JobErrorMessageType = defaultJobErrorMessageType
+	}
+	if opts.TaskProgressMessageType == "" {
+		opts.TaskProgressMessageType = defaultTaskProgressMessageType
+	}
+	if opts.TaskResultMessageType == "" {
+		opts.TaskResultMessageType = defaultTaskResultMessageType
+	}
+	if opts.BrokerBuffer <= 0 {
+		opts.BrokerBuffer = defaultBrokerBuffer
+	}
+	if opts.EventsBuffer <= 0 {
+		opts.EventsBuffer = defaultEventsBuffer
+	}
+	if opts.LoadInterval <= 0 {
+		opts.LoadInterval = defaultLoadInterval
+	}
+	if opts.ShedFraction <= 0 {
+		opts.ShedFraction = defaultShedFraction
+	}
+	if opts.MaxCreditBacklog <= 0 {
+		opts.MaxCreditBacklog = defaultMaxCreditBacklog
+	}
+	if opts.MaxCredits <= 0 {
+		opts.MaxCredits = defaultMaxCredits
+	}
+	if opts.IDGenerator == nil {
+		opts.IDGenerator = randomID
+	}
+	if opts.RejectResponse == nil {
+		opts.RejectResponse = WriteJSONRejection
+	}
+	if opts.AdaptiveCoalesce && opts.CoalesceInterval <= 0 {
+		opts.CoalesceInterval = defaultCoalesceInterval
+	}
+	if opts.AdaptiveCoalesce && opts.MaxCoalesceInterval <= 0 {
+		opts.MaxCoalesceInterval = defaultMaxCoalesceInterval
+	}
+	if opts.AdaptiveCoalesce && opts.PingInterval <= 0 {
+		opts.PingInterval = defaultPingInterval
+	}
+	if opts.PaceThreshold <= 0 {
+		opts.PaceThreshold = defaultPaceThreshold
+	}
+	if opts.FanoutShards > 0 && opts.FanoutShardBuffer <= 0 {
+		opts.FanoutShardBuffer = defaultFanoutShardBuffer
+	}
+	if opts.UsageKey == nil {
+		opts.UsageKey = defaultUsageKey
+	}
+	if opts.UsageExportInterval <= 0 {
+		opts.UsageExportInterval = defaultUsageExportInterval
+	}
+	if opts.QuotaWarningMessageType == "" {
+		opts.QuotaWarningMessageType = defaultQuotaWarningMessageType
+	}
+	if opts.RateLimitMessageType == "" {
+		opts.RateLimitMessageType = defaultRateLimitMessageType
+	}
+	if opts.MaintenanceMessageType == "" {
+		opts.MaintenanceMessageType = defaultMaintenanceMessageType
+	}
+	if opts.PingInterval > 0 && opts.PongTimeout <= 0 {
+		opts.PongTimeout = defaultPongTimeout
+	}
+	if opts.OperationsBuffer <= 0 {
+		opts.OperationsBuffer = 1
+	}
+	if opts.CloseCode == 0 {
+		opts.CloseCode = websocket.CloseNormalClosure
+	}
+	if opts.StreamTimeout <= 0 {
+		opts.StreamTimeout = defaultStreamTimeout
+	}
+	if opts.CallTimeout <= 0 {
+		opts.CallTimeout = defaultCallTimeout
+	}
+	if opts.MaxConcurrentCalls <= 0 {
+		opts.MaxConcurrentCalls = defaultMaxConcurrentCalls
+	}
+	if opts.CloseTimeout <= 0 {
+		opts.CloseTimeout = defaultCloseTimeout
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec{PreserveUnknownFields: opts.PreserveUnknownFields}
+	}
+	if opts.NoopMessageType == "" {
+		opts.NoopMessageType = defaultNoopMessageType
+	}
+	if opts.WriteQueueSize <= 0 {
+		opts.WriteQueueSize = defaultWriteQueueSize
+	}
+	if opts.SetupTimeout == 0 {
+		opts.SetupTimeout = defaultSetupTimeout
+	}
+	if opts.StallThreshold <= 0 {
+		opts.StallThreshold = defaultStallThreshold
+	}
+	if opts.InboundLimit.Burst <= 0 {
+		opts.InboundLimit.Burst = defaultRateLimitBurst
+	}
+	if opts.AnonymousInboundLimit.Burst <= 0 {
+		opts.AnonymousInboundLimit.Burst = defaultRateLimitBurst
+	}
+}
+
+// Option changes the Options of NewConnectionManager
+type Option func(*Options)
+
+// WithOptions replaces all options with opts
+func WithOptions(opts Options) Option {
+	return func(o *Options) {
+		*o = opts
+	}
+}
+
+// WithUpgrader sets the websocket upgrader
+func WithUpgrader(upgrader websocket.Upgrader) Option {
+	return func(o *Options) {
+		o.Upgrader = &upgrader
+	}
+}
+
+// WithBufferSizes sets the read and write buffer sizes of the upgrader
+func WithBufferSizes(read, write int) Option {
+	return func(o *Options) {
+		o.ReadBufferSize = read
+		o.WriteBufferSize = write
+	}
+}
+
+// WithFlushInterval coalesces the frames written within interval, see Options.FlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.FlushInterval = interval
+	}
+}
+
+// WithTCP tunes the TCP connection under each upgraded socket, see TCPOptions
+func WithTCP(tcp TCPOptions) Option {
+	return func(o *Options) {
+		o.TCP = tcp
+	}
+}
+
+// WithCheckOrigin sets the origin check of the upgrader
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) Option {
+	return func(o *Options) {
+		o.CheckOrigin = checkOrigin
+	}
+}
+
+// WithCompression enables per message compression
+func WithCompression() Option {
+	return func(o *Options) {
+		o.EnableCompression = true
+	}
+}
+
+// WithSubprotocols sets the supported subprotocols
+func WithSubprotocols(subprotocols ...string) Option {
+	return func(o *Options) {
+		o.Subprotocols = subprotocols
+	}
+}
+
+// WithOperationsBuffer sets the capacity of the operations channel
+func WithOperationsBuffer(capacity int) Option {
+	return func(o *Options) {
+		o.OperationsBuffer = capacity
+	}
+}
+
+// WithInboundLimit limits the traffic each connection may send, see InboundLimit
+func WithInboundLimit(limit InboundLimit) Option {
+	return func(o *Options) {
+		o.InboundLimit = limit
+	}
+}
+
+// WithJobQueue pushes client messages of types to queue, see Options.JobQueue
+func WithJobQueue(queue JobQueue, types ...string) Option {
+	return func(o *Options) {
+		o.JobQueue = queue
+		o.JobTypes = types
+	}
+}
+
+// WithLimits bounds the connections, the size of client messages and the writes pending per connection, zero
+// leaves a limit unchanged
+func WithLimits(maxConnections int, maxMessageSize int64, maxPendingWrites int) Option {
+	return func(o *Options) {
+		if maxConnections > 0 {
+			o.MaxConnections = maxConnections
+		}
+		if maxMessageSize > 0 {
+			o.MaxMessageSize = maxMessageSize
+		}
+		if maxPendingWrites > 0 {
+			o.WriteQueueSize = maxPendingWrites
+		}
+	}
+}
+
+// WithHandshakeTimeout sets HandshakeTimeout and FirstMessageTimeout
+func WithHandshakeTimeout(handshake, firstMessage time.Duration) Option {
+	return func(o *Options) {
+		o.HandshakeTimeout = handshake
+		o.FirstMessageTimeout = firstMessage
+	}
+}
+
+// WithAuthenticator enables first message authentication with authenticate
+func WithAuthenticator(authenticate func(msg *Message) (interface{}, error)) Option {
+	return func(o *Options) {
+		o.Authenticate = authenticate
+	}
+}
+
+// WithRequestAuthenticator authenticates upgrade requests with authenticate
+func WithRequestAuthenticator(authenticate func(r *http.Request) (interface{}, error)) Option {
+	return func(o *Options) {
+		o.AuthenticateRequest = authenticate
+	}
+}
+
+// WithLogger routes the logs of the manager to logger
+func WithLogger(logger Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithClock sets the time source
+func WithClock(clock Clock) Option {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+// WithRegistry sets the connection registry
+func WithRegistry(registry Registry) Option {
+	return func(o *Options) {
+		o.Registry = registry
+	}
+}
+
+// WithIDGenerator sets the connection and session ID generator
+func WithIDGenerator(generator IDGenerator) Option {
+	return func(o *Options) {
+		o.IDGenerator = generator
+	}
+}
+
+// WithBroker relays broadcasts to the managers of other instances through broker, see Options.Broker
+func WithBroker(broker Broker) Option {
+	return func(o *Options) {
+		o.Broker = broker
+	}
+}
+
+// WithEvents sets the capacity of the Events channel
+func WithEvents(buffer int) Option {
+	return func(o *Options) {
+		o.EventsBuffer = buffer
+	}
+}
@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestFunctionalOptions(t *testing.T) {
+	cm := NewConnectionManager()
+	if cm.opts.AuthMessageType != "auth" || cm.opts.HandshakeTimeout != 10*time.Second ||
+		cm.opts.PingInterval != defaultPingInterval {
+		t.Fatalf("defaults not applied: %+v", cm.opts)
+	}
+
+	cm = NewConnectionManager(
+		WithLimits(100, 4096, 8),
+		WithLimits(0, 0, 16),
+		WithAuthenticator(func(*Message) (interface{}, error) { return "user", nil }),
+		func(o *Options) { o.AuthMessageType = "login" },
+	)
+	if cm.opts.MaxConnections != 100 || cm.opts.MaxMessageSize != 4096 || cm.opts.WriteQueueSize != 16 {
+		t.Fatalf("limits %d, %d, %d", cm.opts.MaxConnections, cm.opts.MaxMessageSize, cm.opts.WriteQueueSize)
+	}
+	if cm.opts.AuthMessageType != "login" || cm.opts.FirstMessageTimeout != defaultAuthTimeout {
+		t.Fatalf("auth message type %q, first message timeout %v", cm.opts.AuthMessageType,
+			cm.opts.FirstMessageTimeout)
+	}
+
+	cm = NewConnectionManager(
+		WithAuthenticator(func(*Message) (interface{}, error) { return "user", nil }),
+		WithHandshakeTimeout(time.Second, 2*time.Second),
+	)
+	if cm.opts.HandshakeTimeout != time.Second || cm.opts.FirstMessageTimeout != 2*time.Second {
+		t.Fatalf("handshake timeout %v, first message timeout %v", cm.opts.HandshakeTimeout,
+			cm.opts.FirstMessageTimeout)
+	}
+}
+
+func TestUpgraderOptions(t *testing.T) {
+	cm := NewConnectionManager(
+		WithCheckOrigin(func(r *http.Request) bool { return r.Header.Get("Origin") == "https://app.example" }),
+		WithSubprotocols("v2", "v1"),
+		WithCompression(),
+		WithBufferSizes(4096, 8192),
+		WithOperationsBuffer(64),
+	)
+	if cm.upgrader.ReadBufferSize != 4096 || cm.upgrader.WriteBufferSize != 8192 || cap(cm.operations) != 64 {
+		t.Fatalf("buffers %d, %d and %d operations", cm.upgrader.ReadBufferSize, cm.upgrader.WriteBufferSize,
+			cap(cm.operations))
+	}
+	srv := httptest.NewServer(cm.EchoHandler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	dialer := gorilla.Dialer{Subprotocols: []string{"v1"}, EnableCompression: true}
+
+	_, resp, err := dialer.Dial(url, http.Header{"Origin": {"https://evil.example"}})
+	if err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatal("upgrade from another origin accepted")
+	}
+	client, resp, err := dialer.Dial(url, http.Header{"Origin": {"https://app.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if client.Subprotocol() != "v1" || !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Fatalf("subprotocol %q, extensions %q", client.Subprotocol(), resp.Header.Get("Sec-WebSocket-Extensions"))
+	}
+	if n := cm.Rejections()[RejectBadOrigin]; n != 1 {
+		t.Fatalf("%d bad origin rejections", n)
+	}
+}
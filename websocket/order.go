@@ -0,0 +1,41 @@
+package websocket
+
+import "sort"
+
+// BroadcastOrder in which the connections of a broadcast are queued, which is the order they are written in only
+// within a shard writer with Options.FanoutShards
+type BroadcastOrder int
+
+// Broadcast orders
+const (
+	// RegistryOrder writes in the order of the Registry
+	RegistryOrder BroadcastOrder = iota
+	// SlowestFirst writes to the connections with the highest RTT first, so with FanoutShards distant clients of
+	// a shard get time sensitive data, e.g. auction ticks, at about the same time as nearby ones
+	SlowestFirst
+	// FastestFirst writes to the connections with the lowest RTT first
+	FastestFirst
+)
+
+// orderByRTT sorts conns by the measured RTT for Options.BroadcastOrder, connections without RTT come last. The
+// shard writers keep the order, the per connection write queues do not.
+func (cm *ConnectionManager) orderByRTT(conns []*Connection) {
+	if cm.opts.BroadcastOrder == RegistryOrder || len(conns) < 2 {
+		return
+	}
+	rtts := make(map[*Connection]int64, len(conns))
+	for _, conn := range conns {
+		rtts[conn] = conn.rtt.Load()
+	}
+	slowest := cm.opts.BroadcastOrder == SlowestFirst
+	sort.SliceStable(conns, func(i, j int) bool {
+		a, b := rtts[conns[i]], rtts[conns[j]]
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		if slowest {
+			return a > b
+		}
+		return a < b
+	})
+}
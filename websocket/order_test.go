@@ -0,0 +1,30 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrderByRTT(t *testing.T) {
+	for order, want := range map[BroadcastOrder][]int64{
+		RegistryOrder: {10, 0, 30, 20},
+		SlowestFirst:  {30, 20, 10, 0},
+		FastestFirst:  {10, 20, 30, 0},
+	} {
+		cm := NewConnectionManager(func(o *Options) { o.BroadcastOrder = order })
+		var conns []*Connection
+		for _, rtt := range []int64{10, 0, 30, 20} {
+			conn := &Connection{}
+			conn.rtt.Store(rtt)
+			conns = append(conns, conn)
+		}
+		cm.orderByRTT(conns)
+		var got []int64
+		for _, conn := range conns {
+			got = append(got, conn.rtt.Load())
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("order %d wrote RTTs %v, want %v", order, got, want)
+		}
+	}
+}
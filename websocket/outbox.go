@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxRow is a message written to the application store, usually in the same transaction as the data change
+// it announces
+type OutboxRow struct {
+	ID      string
+	Message *Message
+}
+
+// OutboxStore is implemented by the application on top of its database
+type OutboxStore interface {
+	// Pending returns up to limit undelivered rows in delivery order
+	Pending(ctx context.Context, limit int) ([]OutboxRow, error)
+	// MarkDelivered marks rows so Pending does not return them again
+	MarkDelivered(ctx context.Context, ids []string) error
+}
+
+// OutboxOptions configures an Outbox
+type OutboxOptions struct {
+	// PollInterval between polls of the store, defaults to 1s
+	PollInterval time.Duration
+	// BatchSize rows read per poll, defaults to 100
+	BatchSize int
+	// Notify triggers a poll immediately, e.g. fed by a database LISTEN/NOTIFY, optional
+	Notify <-chan struct{}
+}
+
+// Outbox broadcasts rows of an OutboxStore at least once. A row is broadcast again when MarkDelivered failed
+// before a restart, or when outboxes of several instances read it, so messages without ID carry the row ID as
+// Message.ID for clients to skip duplicates.
+type Outbox struct {
+	cm    *ConnectionManager
+	store OutboxStore
+	opts  OutboxOptions
+
+	// Rows already broadcast whose MarkDelivered failed, skipped until marking succeeds
+	unmarked map[string]bool
+}
+
+// NewOutbox outbox broadcasting rows of store on cm, start it with Run
+func (cm *ConnectionManager) NewOutbox(store OutboxStore, opts OutboxOptions) *Outbox {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &Outbox{
+		cm:       cm,
+		store:    store,
+		opts:     opts,
+		unmarked: make(map[string]bool),
+	}
+}
+
+// Run polls the store until ctx is done
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := o.cm.clock.NewTicker(o.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		for o.poll(ctx) {
+			// Full batch, more rows are likely pending
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		case <-o.opts.Notify:
+		}
+	}
+}
+
+// poll delivers one batch and reports whether the batch was full
+func (o *Outbox) poll(ctx context.Context) bool {
+	rows, err := o.store.Pending(ctx, o.opts.BatchSize)
+	if err != nil {
+		o.cm.logE(err, "Failed to read outbox")
+		return false
+	}
+	if len(rows) == 0 {
+		return false
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+		if o.unmarked[row.ID] {
+			continue
+		}
+		msg := *row.Message
+		if msg.ID == "" {
+			msg.ID = row.ID
+		}
+		o.cm.Send(&msg)
+		o.unmarked[row.ID] = true
+	}
+
+	err = o.store.MarkDelivered(ctx, ids)
+	if err != nil {
+		o.cm.logE(err, "Failed to mark outbox rows delivered, will retry")
+		return false
+	}
+	for _, id := range ids {
+		delete(o.unmarked, id)
+	}
+	return len(rows) == o.opts.BatchSize
+}
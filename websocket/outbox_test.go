@@ -0,0 +1,37 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingOutbox keeps its rows pending, MarkDelivered fails
+type failingOutbox struct {
+	rows []OutboxRow
+}
+
+func (f *failingOutbox) Pending(context.Context, int) ([]OutboxRow, error) {
+	return f.rows, nil
+}
+
+func (f *failingOutbox) MarkDelivered(context.Context, []string) error {
+	return errors.New("unavailable")
+}
+
+func TestOutboxRedeliversWithRowID(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, _ := testServer(t, cm, nil)()
+	store := &failingOutbox{rows: []OutboxRow{{ID: "row-1", Message: &Message{Type: "changed"}}}}
+	cm.NewOutbox(store, OutboxOptions{}).poll(context.Background())
+	// A restarted outbox no longer knows the row was broadcast
+	cm.NewOutbox(store, OutboxOptions{}).poll(context.Background())
+	for i := 0; i < 2; i++ {
+		if msg := readType(t, client, "changed"); msg.ID != "row-1" {
+			t.Fatalf("ID = %q, want the row ID", msg.ID)
+		}
+	}
+	if store.rows[0].Message.ID != "" {
+		t.Fatal("row message modified")
+	}
+}
@@ -0,0 +1,99 @@
+package websocket
+
+import "time"
+
+const (
+	defaultPaceThreshold = 1000
+	// paceStep between the write batches of a paced broadcast
+	paceStep = 5 * time.Millisecond
+)
+
+// pacer queues broadcasts while one is being paced, so they reach each connection in order
+type pacer struct {
+	active bool
+	queue  []*pacedBroadcast
+}
+
+type pacedBroadcast struct {
+	fanout *fanout
+	conns  []*Connection
+	batch  int
+}
+
+// broadcast delivers msg to all ready connections in BroadcastOrder, pacing it when it reaches PaceThreshold
+// connections or another broadcast is being paced. Runs on the operations goroutine.
+func (cm *ConnectionManager) broadcast(msg *Message) {
+	cm.keepDetached("", msg)
+	fanout := cm.newFanout(msg)
+	cm.measure(fanout)
+	if cm.opts.PaceWindow <= 0 && cm.opts.BroadcastOrder == RegistryOrder {
+		cm.registry.Range(func(conn *Connection) {
+			if conn.state == stateReady {
+				fanout.deliver(conn)
+			}
+		})
+		cm.seal(fanout.skew)
+		return
+	}
+	var conns []*Connection
+	cm.registry.Range(func(conn *Connection) {
+		if conn.state == stateReady {
+			conns = append(conns, conn)
+		}
+	})
+	cm.orderByRTT(conns)
+	if cm.opts.PaceWindow <= 0 || len(conns) < cm.opts.PaceThreshold && !cm.pacer.active {
+		for _, conn := range conns {
+			fanout.deliver(conn)
+		}
+		cm.seal(fanout.skew)
+		return
+	}
+	batches := int(cm.opts.PaceWindow / paceStep)
+	if batches < 1 {
+		batches = 1
+	}
+	cm.pacer.queue = append(cm.pacer.queue, &pacedBroadcast{
+		fanout: fanout,
+		conns:  conns,
+		batch:  (len(conns) + batches - 1) / batches,
+	})
+	if !cm.pacer.active {
+		cm.pacer.active = true
+		cm.paceNext()
+	}
+}
+
+// paceNext writes the next batch of the oldest paced broadcast and schedules the following one, runs on the
+// operations goroutine
+func (cm *ConnectionManager) paceNext() {
+	for len(cm.pacer.queue) > 0 {
+		paced := cm.pacer.queue[0]
+		n := paced.batch
+		if n > len(paced.conns) {
+			n = len(paced.conns)
+		}
+		for _, conn := range paced.conns[:n] {
+			if cm.registry.Contains(conn) {
+				paced.fanout.deliver(conn)
+			}
+		}
+		paced.conns = paced.conns[n:]
+		if len(paced.conns) == 0 {
+			cm.seal(paced.fanout.skew)
+			cm.pacer.queue[0] = nil
+			cm.pacer.queue = cm.pacer.queue[1:]
+			if n == 0 {
+				continue
+			}
+		}
+		if len(cm.pacer.queue) == 0 {
+			break
+		}
+		cm.clock.AfterFunc(paceStep, func() {
+			cm.enqueue(&socketOperation{opType: call, fn: cm.paceNext})
+		})
+		return
+	}
+	cm.pacer.active = false
+}
@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestPacedBroadcastsStayInOrder(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.PaceWindow = 2 * paceStep
+		o.PaceThreshold = 2
+	})
+	dial := testServer(t, cm, nil)
+	first, _ := dial()
+	second, _ := dial()
+	pacing := func() (active bool, queued int) {
+		cm.call(func() { active, queued = cm.pacer.active, len(cm.pacer.queue) })
+		return active, queued
+	}
+
+	cm.Send(&Message{Type: "a"})
+	cm.Send(&Message{Type: "b"})
+	if active, queued := pacing(); !active || queued != 2 {
+		t.Fatalf("pacing %v with %d broadcasts queued, want both queued behind the first batch", active, queued)
+	}
+	eventually(t, "the paced broadcasts to complete", func() bool {
+		clock.Advance(paceStep)
+		active, _ := pacing()
+		return !active
+	})
+	for _, client := range []*gorilla.Conn{first, second} {
+		for _, want := range []string{"a", "b"} {
+			var msg Message
+			if err := client.ReadJSON(&msg); err != nil || msg.Type != want {
+				t.Fatalf("got %+v, %v, want %s", msg, err, want)
+			}
+		}
+	}
+}
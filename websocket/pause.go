@@ -0,0 +1,47 @@
+package websocket
+
+// Pause holds back messages to the connection, e.g. while the client is backgrounded. Up to
+// Options.PauseBuffer messages are kept and delivered on Resume, later ones are dropped.
+func (c *Connection) Pause() {
+	c.manager.enqueue(&socketOperation{
+		opType: pause,
+		conn:   c,
+	})
+}
+
+// Resume delivers the messages kept while paused and resumes normal delivery
+func (c *Connection) Resume() {
+	c.manager.enqueue(&socketOperation{
+		opType: resume,
+		conn:   c,
+	})
+}
+
+// hold keeps msg for a paused connection, runs on the operations goroutine
+func (cm *ConnectionManager) hold(conn *Connection, msg *Message) {
+	if len(conn.held) >= cm.opts.PauseBuffer {
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "paused", Time: cm.clock.Now()})
+		return
+	}
+	if !cm.reserve(conn, msg) {
+		return
+	}
+	conn.held = append(conn.held, msg)
+}
+
+// resumeConnection runs on the operations goroutine. Delivering may remove the connection, e.g. with
+// DisconnectSlow, the messages left are then abandoned as removeSocket does with the held ones.
+func (cm *ConnectionManager) resumeConnection(conn *Connection) {
+	conn.paused = false
+	held := conn.held
+	conn.held = nil
+	cm.releaseAll(held)
+	for i, msg := range held {
+		if !cm.registry.Contains(conn) {
+			cm.sessionUnsent(conn, held[i:]...)
+			abandon(held[i:])
+			return
+		}
+		cm.deliver(conn, msg)
+	}
+}
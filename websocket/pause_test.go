@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// blockedWrites is a socket whose message writes block until it is closed
+type blockedWrites struct {
+	Conn
+	once    sync.Once
+	release chan struct{}
+}
+
+func (b *blockedWrites) WriteMessage(messageType int, data []byte) error {
+	<-b.release
+	return b.Conn.WriteMessage(messageType, data)
+}
+
+func (b *blockedWrites) Close() error {
+	b.once.Do(func() { close(b.release) })
+	return b.Conn.Close()
+}
+
+func TestResumeAbandonsHeldMessagesWhenRemoved(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.PauseBuffer = 10
+		o.WriteQueueSize = 1
+		o.WriteQueuePolicy = DisconnectSlow
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+	})
+	conns := make(chan *Connection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := (&gorilla.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		blocked := &blockedWrites{Conn: socket, release: make(chan struct{})}
+		t.Cleanup(func() { blocked.Close() })
+		conn, err := cm.Accept(r, blocked, func(*Connection, *Message) {})
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	defer srv.Close()
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn := <-conns
+	conn.Pause()
+	// One message is written, one queued and the next removes the connection as a slow consumer
+	for i := 0; i < 3; i++ {
+		cm.SendTo(conn, &Message{Type: "held"})
+	}
+	result := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		result <- cm.SendToContext(ctx, conn, &Message{Type: "last"})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	conn.Resume()
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Fatalf("err = %v, want ErrConnectionClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message left after the removal was not abandoned")
+	}
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"fmt"
+)
+
+// ConnectStep is one step of the connect pipeline run for each new connection
+type ConnectStep struct {
+	// Name identifies the step in errors
+	Name string
+	// Run performs the step, send queues a message to this connection only
+	Run func(conn *Connection, send func(*Message)) error
+	// Optional steps log their failure and the pipeline continues, otherwise the connection is removed
+	Optional bool
+}
+
+// SendStep connect step sending msg to the new connection, e.g. a welcome or config message
+func SendStep(name string, msg *Message) ConnectStep {
+	return ConnectStep{
+		Name: name,
+		Run: func(conn *Connection, send func(*Message)) error {
+			send(msg)
+			return nil
+		},
+	}
+}
+
+// ReadyStep connect step marking the connection ready, used last in the pipeline with RequireReady so broadcasts
+// are only delivered after the initial snapshot
+func ReadyStep() ConnectStep {
+	return ConnectStep{
+		Name: "ready",
+		Run: func(conn *Connection, send func(*Message)) error {
+			conn.MarkReady()
+			return nil
+		},
+	}
+}
+
+// runConnectPipeline runs OnConnectPipeline steps in order once the connection is active, returns false when
+// the connection was removed because a required step failed
+func (cm *ConnectionManager) runConnectPipeline(conn *Connection) bool {
+	if len(cm.opts.OnConnectPipeline) == 0 {
+		return true
+	}
+	for _, step := range cm.opts.OnConnectPipeline {
+		err := step.Run(conn, conn.Send)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("connect step %q: %w", step.Name, err)
+		if cm.opts.OnConnectError != nil {
+			cm.opts.OnConnectError(conn, err)
+		}
+		if step.Optional {
+			cm.logE(err, "Optional connect step failed, continuing")
+			continue
+		}
+		cm.logE(err, "Connect step failed, will remove the socket")
+		cm.enqueue(&socketOperation{
+			opType: remove,
+			conn:   conn,
+			err:    err,
+		})
+		return false
+	}
+	return true
+}
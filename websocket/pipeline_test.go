@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectPipelineRunsStepsInOrder(t *testing.T) {
+	failures := make(chan error, 2)
+	fail := func(conn *Connection, send func(*Message)) error { return errors.New("unavailable") }
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.OnConnectPipeline = []ConnectStep{
+			SendStep("welcome", &Message{Type: "welcome"}),
+			{Name: "recommendations", Run: fail, Optional: true},
+			SendStep("config", &Message{Type: "config"}),
+		}
+		o.OnConnectError = func(_ *Connection, err error) { failures <- err }
+	})
+	client, _ := testServer(t, cm, nil)()
+	for _, want := range []string{"welcome", "config"} {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil || msg.Type != want {
+			t.Fatalf("read %+v, %v, want %s", msg, err, want)
+		}
+	}
+	if n := len(failures); n != 1 {
+		t.Fatalf("OnConnectError called %d times, want once for the optional step", n)
+	}
+}
+
+func TestConnectPipelineRemovesConnectionOnRequiredFailure(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.OnConnectPipeline = []ConnectStep{{
+			Name: "load profile",
+			Run:  func(*Connection, func(*Message)) error { return errors.New("unavailable") },
+		}}
+	})
+	client, _ := testServer(t, cm, nil)()
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatal("connection kept after a required step failed")
+	}
+}
+
+func TestRequireReadyHoldsBroadcastsUntilReady(t *testing.T) {
+	received := make(chan string, 1)
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.RequireReady = true
+		o.ReadyMessageType = "ready"
+	})
+	client, _ := testServer(t, cm, func(_ *Connection, msg *Message) { received <- msg.Type })()
+	cm.Send(&Message{Type: "early"})
+	client.WriteJSON(Message{Type: "ready"})
+	client.WriteJSON(Message{Type: "after ready"})
+	select {
+	case msgType := <-received:
+		if msgType != "after ready" {
+			t.Fatalf("onReceive got %s", msgType)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+	cm.Send(&Message{Type: "late"})
+	var msg Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "late" {
+		t.Fatalf("first message %+v, %v, want the broadcast after ready", msg, err)
+	}
+}
@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// presence tracks live connections per user key and emits debounced online/offline transitions, so a user
+// whose connections flap within the debounce window produces no events
+type presence struct {
+	keyOf    func(identity interface{}) string
+	onChange func(key string, online bool)
+	debounce time.Duration
+	clock    Clock
+
+	mu      sync.Mutex
+	counts  map[string]int
+	online  map[string]bool // Last emitted state
+	pending map[string]Timer
+}
+
+func newPresence(opts Options) *presence {
+	if opts.PresenceKey == nil || opts.OnPresence == nil {
+		return nil
+	}
+	return &presence{
+		keyOf:    opts.PresenceKey,
+		onChange: opts.OnPresence,
+		debounce: opts.PresenceDebounce,
+		clock:    opts.Clock,
+		counts:   make(map[string]int),
+		online:   make(map[string]bool),
+		pending:  make(map[string]Timer),
+	}
+}
+
+// track moves conn to the key of its current identity, called from the operations goroutine
+func (p *presence) track(conn *Connection) {
+	if p == nil {
+		return
+	}
+	key := p.keyOf(conn.Identity())
+	if key == conn.presenceKey {
+		return
+	}
+	p.update(conn.presenceKey, -1)
+	p.update(key, 1)
+	conn.presenceKey = key
+}
+
+// untrack removes conn from its key, called from the operations goroutine
+func (p *presence) untrack(conn *Connection) {
+	if p == nil {
+		return
+	}
+	p.update(conn.presenceKey, -1)
+	conn.presenceKey = ""
+}
+
+func (p *presence) update(key string, delta int) {
+	if key == "" {
+		return
+	}
+	p.mu.Lock()
+	p.counts[key] += delta
+	if p.counts[key] <= 0 {
+		delete(p.counts, key)
+	}
+	if p.debounce <= 0 {
+		online, changed := p.settle(key)
+		p.mu.Unlock()
+		if changed {
+			p.onChange(key, online)
+		}
+		return
+	}
+	if _, ok := p.pending[key]; !ok {
+		p.pending[key] = p.clock.AfterFunc(p.debounce, func() { p.flush(key) })
+	}
+	p.mu.Unlock()
+}
+
+func (p *presence) flush(key string) {
+	p.mu.Lock()
+	delete(p.pending, key)
+	online, changed := p.settle(key)
+	p.mu.Unlock()
+	if changed {
+		p.onChange(key, online)
+	}
+}
+
+// settle records the current state of key and reports whether it differs from the last emitted one
+func (p *presence) settle(key string) (online bool, changed bool) {
+	online = p.counts[key] > 0
+	if online == p.online[key] {
+		return online, false
+	}
+	if online {
+		p.online[key] = true
+	} else {
+		delete(p.online, key)
+	}
+	return online, true
+}
@@ -0,0 +1,37 @@
+package websocket
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPresenceDebouncesFlaps(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	var events []string
+	p := newPresence(Options{
+		PresenceKey:      func(identity interface{}) string { return fmt.Sprint(identity) },
+		OnPresence:       func(key string, online bool) { events = append(events, fmt.Sprintf("%s %v", key, online)) },
+		PresenceDebounce: time.Second,
+		Clock:            clock,
+	})
+	first, second := &Connection{}, &Connection{}
+	first.setIdentity("alice")
+	second.setIdentity("alice")
+
+	p.track(first)
+	p.track(second)
+	clock.Advance(time.Second)
+	// Reconnecting within the window is no transition
+	p.untrack(first)
+	p.untrack(second)
+	p.track(first)
+	clock.Advance(time.Second)
+	p.untrack(first)
+	clock.Advance(time.Second)
+
+	if want := []string{"alice true", "alice false"}; !slices.Equal(events, want) {
+		t.Fatalf("events %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader carries the client chosen key of a push request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyStore remembers the idempotency keys of PushHandler, e.g. in Redis with SET NX and an expiry.
+// Instances behind a load balancer share one so retries reaching another instance are not broadcast again.
+type IdempotencyStore interface {
+	// Claim records key for ttl and reports whether it was not recorded already
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// PushOptions configures PushHandler
+type PushOptions struct {
+	// IdempotencyTTL how long idempotency keys are remembered, defaults to 24h
+	IdempotencyTTL time.Duration
+	// IdempotencyStore remembers the keys, defaults to memory of this handler only, which deduplicates retries
+	// across instances only with sticky routing
+	IdempotencyStore IdempotencyStore
+	// MaxBodyBytes limits the request body, defaults to 1MB
+	MaxBodyBytes int64
+}
+
+// PushHandler http handler broadcasting the JSON Message in the body of POST requests. Requests repeating an
+// Idempotency-Key seen within IdempotencyTTL are acknowledged without broadcasting again, so upstream retries
+// don't reach clients twice. Requests are rejected with 503 while the IdempotencyStore fails.
+func (cm *ConnectionManager) PushHandler(opts PushOptions) http.Handler {
+	if opts.IdempotencyTTL <= 0 {
+		opts.IdempotencyTTL = 24 * time.Hour
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+	if opts.IdempotencyStore == nil {
+		opts.IdempotencyStore = newIdempotencyKeys(cm.clock)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		msg := Message{}
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, opts.MaxBodyBytes)).Decode(&msg)
+		if err != nil {
+			cm.logE(err, "Invalid push request")
+			http.Error(w, "invalid message", http.StatusBadRequest)
+			return
+		}
+
+		if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+			claimed, err := opts.IdempotencyStore.Claim(r.Context(), key, opts.IdempotencyTTL)
+			if err != nil {
+				cm.logE(err, "Failed to claim idempotency key")
+				http.Error(w, "idempotency store unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if !claimed {
+				cm.logV("Duplicate push request")
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		}
+		cm.Send(&msg)
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// idempotencyKeys is the IdempotencyStore kept in memory by default
+type idempotencyKeys struct {
+	clock Clock
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	nextPrune time.Time
+}
+
+func newIdempotencyKeys(clock Clock) *idempotencyKeys {
+	return &idempotencyKeys{
+		clock: clock,
+		seen:  make(map[string]time.Time),
+	}
+}
+
+func (k *idempotencyKeys) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	now := k.clock.Now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if now.After(k.nextPrune) {
+		for seenKey, expires := range k.seen {
+			if now.After(expires) {
+				delete(k.seen, seenKey)
+			}
+		}
+		k.nextPrune = now.Add(ttl / 10)
+	}
+	if expires, ok := k.seen[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	k.seen[key] = now.Add(ttl)
+	return true, nil
+}
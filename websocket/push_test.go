@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sharedKeys is an IdempotencyStore shared by the handlers of several managers
+type sharedKeys struct {
+	mu   sync.Mutex
+	keys map[string]bool
+	err  error
+}
+
+func (s *sharedKeys) Claim(_ context.Context, key string, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if s.keys[key] {
+		return false, nil
+	}
+	s.keys[key] = true
+	return true, nil
+}
+
+func push(handler http.Handler, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/push", strings.NewReader(`{"type":"pushed"}`))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestPushHandlerDeduplicatesPerHandler(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, _ := testServer(t, cm, nil)()
+	handler := cm.PushHandler(PushOptions{})
+	push(handler, "k")
+	readType(t, client, "pushed")
+	if w := push(handler, "k"); w.Code != http.StatusAccepted || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry answered %d %v", w.Code, w.Header())
+	}
+	expectNone(t, client, 100*time.Millisecond)
+}
+
+func TestPushHandlerSharedIdempotencyStore(t *testing.T) {
+	store := &sharedKeys{keys: make(map[string]bool)}
+	first := NewConnectionManager()
+	second := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, _ := testServer(t, second, nil)()
+	push(first.PushHandler(PushOptions{IdempotencyStore: store}), "k")
+	handler := second.PushHandler(PushOptions{IdempotencyStore: store})
+	if w := push(handler, "k"); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("retry on another instance not deduplicated")
+	}
+	expectNone(t, client, 100*time.Millisecond)
+
+	store.err = errors.New("unavailable")
+	if w := push(handler, "other"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("push with failing store answered %d", w.Code)
+	}
+}
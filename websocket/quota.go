@@ -0,0 +1,268 @@
+package websocket
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQuotaWarningMessageType = "quota"
+	defaultQuotaWarnAt             = 0.8
+	defaultRateLimitMessageType    = "ratelimit"
+	rateLimitNoticeInterval        = time.Second
+	// CloseQuotaExceeded close code of connections disconnected by QuotaDisconnect
+	CloseQuotaExceeded = 4429
+)
+
+// QuotaPeriod over which quota usage is counted, periods start at UTC midnight
+type QuotaPeriod int
+
+// Quota periods
+const (
+	QuotaDaily QuotaPeriod = iota
+	QuotaMonthly
+)
+
+// QuotaAction taken when a user exceeds its quota
+type QuotaAction int
+
+// Quota actions
+const (
+	// QuotaThrottle limits the messages of the user in both directions to Quota.ThrottleRate, dropping the
+	// rest, until the period ends
+	QuotaThrottle QuotaAction = iota
+	// QuotaDisconnect closes the connections of the user exceeding the quota with CloseQuotaExceeded and rejects
+	// its upgrades until the period ends
+	QuotaDisconnect
+)
+
+// Quota limits the traffic of a user per period, zero limits are unlimited
+type Quota struct {
+	Period QuotaPeriod
+	// MaxBytes read and written
+	MaxBytes int64
+	// MaxMessages read and written
+	MaxMessages int64
+	// WarnAt fraction of a limit at which the connection is sent a quota warning message, defaults to 0.8
+	WarnAt float64
+	Action QuotaAction
+	// ThrottleRate messages per second allowed by QuotaThrottle, zero drops all messages
+	ThrottleRate float64
+}
+
+// QuotaWarning is the data of the quota warning message
+type QuotaWarning struct {
+	Bytes       int64 `json:"bytes"`
+	MaxBytes    int64 `json:"maxBytes,omitempty"`
+	Messages    int64 `json:"messages"`
+	MaxMessages int64 `json:"maxMessages,omitempty"`
+	// Reset time of the quota period
+	Reset time.Time `json:"reset"`
+}
+
+// RateLimit is the data of the rate limit message, so clients can pace themselves to Rate until Reset
+type RateLimit struct {
+	// Rate messages per second allowed in each direction, zero allows none
+	Rate float64 `json:"rate"`
+	// Remaining messages allowed right now
+	Remaining int64 `json:"remaining"`
+	// Next time a message is allowed
+	Next time.Time `json:"next"`
+	// Reset time throttling ends with the quota period
+	Reset time.Time `json:"reset"`
+}
+
+type quotaAccounts struct {
+	mu     sync.Mutex
+	byUser map[string]*quotaState
+}
+
+type quotaState struct {
+	quota    Quota
+	start    time.Time
+	bytes    int64
+	messages int64
+	warned   bool
+	exceeded bool
+	// Next time a message is allowed while throttled
+	next time.Time
+	// Last time a rate limit message was sent
+	notified time.Time
+}
+
+// checkQuota counts a message of bytes towards the quota of the user key and warns or acts on the connection
+// when a threshold is crossed
+func (cm *ConnectionManager) checkQuota(conn *Connection, key string, bytes int64) {
+	if cm.opts.Quota == nil {
+		return
+	}
+	now := cm.clock.Now()
+	cm.quotas.mu.Lock()
+	state := cm.quotaState(key, now)
+	if state == nil {
+		cm.quotas.mu.Unlock()
+		return
+	}
+	state.bytes += bytes
+	state.messages++
+	quota := state.quota
+	warn := !state.warned && (over(state.bytes, quota.MaxBytes, quota.WarnAt) ||
+		over(state.messages, quota.MaxMessages, quota.WarnAt))
+	exceed := !state.exceeded && (over(state.bytes, quota.MaxBytes, 1) || over(state.messages, quota.MaxMessages, 1))
+	state.warned = state.warned || warn
+	state.exceeded = state.exceeded || exceed
+	disconnect := state.exceeded && quota.Action == QuotaDisconnect
+	warning := QuotaWarning{
+		Bytes:       state.bytes,
+		MaxBytes:    quota.MaxBytes,
+		Messages:    state.messages,
+		MaxMessages: quota.MaxMessages,
+		Reset:       periodEnd(quota.Period, state.start),
+	}
+	cm.quotas.mu.Unlock()
+
+	// Usage is counted on the operations goroutine too, so act through new operations without waiting
+	if warn {
+		go cm.SendTo(conn, &Message{Type: cm.opts.QuotaWarningMessageType, Data: warning})
+	}
+	if disconnect {
+		cm.quotaDisconnect(conn)
+	}
+	if exceed && quota.Action == QuotaThrottle {
+		limit := RateLimit{Rate: quota.ThrottleRate, Next: now, Reset: warning.Reset}
+		if quota.ThrottleRate > 0 {
+			limit.Remaining = 1
+		}
+		go cm.SendTo(conn, &Message{Type: cm.opts.RateLimitMessageType, Data: limit})
+	}
+}
+
+// quotaDisconnect closes conn of a user above its QuotaDisconnect quota, once per connection
+func (cm *ConnectionManager) quotaDisconnect(conn *Connection) {
+	if conn.quotaClosed.Swap(true) {
+		return
+	}
+	go cm.Disconnect(conn, CloseQuotaExceeded, "quota exceeded")
+}
+
+// quotaBlocked reports whether the user key exceeded its QuotaDisconnect quota and until when
+func (cm *ConnectionManager) quotaBlocked(key string) (time.Time, bool) {
+	if cm.opts.Quota == nil || key == "" {
+		return time.Time{}, false
+	}
+	cm.quotas.mu.Lock()
+	defer cm.quotas.mu.Unlock()
+	state := cm.quotaState(key, cm.clock.Now())
+	if state == nil || !state.exceeded || state.quota.Action != QuotaDisconnect {
+		return time.Time{}, false
+	}
+	return periodEnd(state.quota.Period, state.start), true
+}
+
+// rejectQuota answers the upgrade of a user that exceeded its QuotaDisconnect quota with 429. UsageKey is called
+// with a connection that is not managed yet, carrying only the identity and the values of the upgrade.
+func (cm *ConnectionManager) rejectQuota(w http.ResponseWriter, r *http.Request, decision UpgradeDecision,
+	identity interface{}) error {
+	if cm.opts.Quota == nil {
+		return nil
+	}
+	probe := &Connection{}
+	for key, value := range decision.Values {
+		probe.Set(key, value)
+	}
+	probe.setIdentity(identity)
+	reset, blocked := cm.quotaBlocked(cm.opts.UsageKey(probe))
+	if !blocked {
+		return nil
+	}
+	retryAfter := int(reset.Sub(cm.clock.Now()).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	return cm.reject(w, r, UpgradeRejection{
+		Status: http.StatusTooManyRequests,
+		Reason: RejectQuotaExceeded,
+	})
+}
+
+// quotaAllows reports whether a message of conn may pass, false for throttled users above their rate. Inbound
+// messages dropped this way are answered with a rate limit message.
+func (cm *ConnectionManager) quotaAllows(conn *Connection, inbound bool) bool {
+	if cm.opts.Quota == nil {
+		return true
+	}
+	key := cm.opts.UsageKey(conn)
+	if key == "" {
+		return true
+	}
+	now := cm.clock.Now()
+	cm.quotas.mu.Lock()
+	state := cm.quotaState(key, now)
+	if state == nil || !state.exceeded || state.quota.Action != QuotaThrottle {
+		cm.quotas.mu.Unlock()
+		return true
+	}
+	if state.quota.ThrottleRate > 0 && !now.Before(state.next) {
+		state.next = now.Add(time.Duration(float64(time.Second) / state.quota.ThrottleRate))
+		cm.quotas.mu.Unlock()
+		return true
+	}
+	notify := inbound && now.Sub(state.notified) >= rateLimitNoticeInterval
+	if notify {
+		state.notified = now
+	}
+	limit := RateLimit{
+		Rate:  state.quota.ThrottleRate,
+		Next:  state.next,
+		Reset: periodEnd(state.quota.Period, state.start),
+	}
+	if state.quota.ThrottleRate <= 0 {
+		limit.Next = limit.Reset
+	}
+	cm.quotas.mu.Unlock()
+	if notify {
+		cm.SendTo(conn, &Message{Type: cm.opts.RateLimitMessageType, Data: limit})
+	}
+	return false
+}
+
+// quotaState of the current period of key, nil when the user has no quota. Called with mu held.
+func (cm *ConnectionManager) quotaState(key string, now time.Time) *quotaState {
+	state, ok := cm.quotas.byUser[key]
+	if ok && now.Before(periodEnd(state.quota.Period, state.start)) {
+		return state
+	}
+	quota, ok := cm.opts.Quota(key)
+	if !ok {
+		delete(cm.quotas.byUser, key)
+		return nil
+	}
+	if quota.WarnAt <= 0 {
+		quota.WarnAt = defaultQuotaWarnAt
+	}
+	state = &quotaState{quota: quota, start: periodStart(quota.Period, now)}
+	if cm.quotas.byUser == nil {
+		cm.quotas.byUser = make(map[string]*quotaState)
+	}
+	cm.quotas.byUser[key] = state
+	return state
+}
+
+func over(used, limit int64, fraction float64) bool {
+	return limit > 0 && float64(used) >= fraction*float64(limit)
+}
+
+func periodStart(period QuotaPeriod, now time.Time) time.Time {
+	now = now.UTC()
+	if period == QuotaMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func periodEnd(period QuotaPeriod, start time.Time) time.Time {
+	if period == QuotaMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestQuotaWarnsThenThrottles(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.UsageKey = func(*Connection) string { return "acme" }
+		o.Quota = func(string) (Quota, bool) { return Quota{MaxMessages: 5, Action: QuotaThrottle}, true }
+	})
+	received := make(chan string, 8)
+	client, _ := testServer(t, cm, func(_ *Connection, msg *Message) { received <- msg.Type })()
+
+	// The fourth message reaches 80% of the quota, the warning written back exceeds it
+	for i := 0; i < 4; i++ {
+		client.WriteJSON(Message{Type: "x"})
+	}
+	msg := readType(t, client, defaultQuotaWarningMessageType)
+	if warning, _ := msg.Data.(map[string]interface{}); warning["messages"] != float64(4) {
+		t.Fatalf("warning %v", msg.Data)
+	}
+	readType(t, client, defaultRateLimitMessageType)
+
+	client.WriteJSON(Message{Type: "throttled"})
+	readType(t, client, defaultRateLimitMessageType)
+	cm.Send(&Message{Type: "news"})
+	eventually(t, "the broadcast to be dropped", func() bool { return cm.Stats().Dropped == 1 })
+	for i := 0; i < 4; i++ {
+		<-received
+	}
+	select {
+	case msg := <-received:
+		t.Fatalf("throttled message %s received", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestQuotaDisconnectRejectsUpgrades(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.AuthenticateRequest = func(*http.Request) (interface{}, error) { return "acme", nil }
+		o.Quota = func(string) (Quota, bool) { return Quota{MaxMessages: 2, Action: QuotaDisconnect}, true }
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	client, _, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		client.WriteJSON(Message{Type: "x"})
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := client.ReadMessage()
+		var closeErr *gorilla.CloseError
+		if errors.As(err, &closeErr) && closeErr.Code == CloseQuotaExceeded {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err = %v, want the quota close code", err)
+		}
+	}
+	_, resp, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err == nil || resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("upgrade after exceeding the quota: %v", err)
+	}
+	if n := cm.Rejections()[RejectQuotaExceeded]; n != 1 {
+		t.Fatalf("%d quota rejections", n)
+	}
+}
@@ -0,0 +1,155 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultRateLimitBurst = time.Second
+
+// RateLimitPolicy decides what happens to a client message above Options.InboundLimit
+type RateLimitPolicy int
+
+// Rate limit policies
+const (
+	// RateLimitDrop discards the message with a DropEvent. The first message dropped after the connection was
+	// within the limit is answered with a RateLimitMessageType message telling when the next one is allowed.
+	RateLimitDrop RateLimitPolicy = iota
+	// RateLimitDelay holds the reader of the connection until the message is within the limit, so the client
+	// is slowed down by TCP backpressure. Long delays may exceed the PongTimeout of the connection.
+	RateLimitDelay
+	// RateLimitDisconnect closes the connection with 1008 (policy violation)
+	RateLimitDisconnect
+)
+
+// InboundLimit limits the messages a connection may send, so one client cannot saturate onReceive and the
+// operations of the manager. Zero rates are unlimited.
+type InboundLimit struct {
+	MessagesPerSecond float64
+	BytesPerSecond    float64
+	// Burst of traffic at the rates allowed at once, defaults to 1s. A message larger than the byte burst
+	// passes when no bytes were used during the burst.
+	Burst  time.Duration
+	Policy RateLimitPolicy
+}
+
+func (l InboundLimit) enabled() bool {
+	return l.MessagesPerSecond > 0 || l.BytesPerSecond > 0
+}
+
+// inboundLimiter token buckets of a connection, only accessed from its reader goroutine
+type inboundLimiter struct {
+	messages tokenBucket
+	bytes    tokenBucket
+	notified time.Time // Next time a message is allowed as sent with the last rate limit message
+}
+
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, burst time.Duration, now time.Time) tokenBucket {
+	capacity := rate * burst.Seconds()
+	return tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate <= 0 {
+		return
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// wait until n tokens are available, zero when they are or the bucket is full
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.rate <= 0 || b.tokens >= n || b.tokens >= b.capacity {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate > 0 {
+		b.tokens -= n
+	}
+}
+
+// limitInbound applies Options.InboundLimit to msg of size bytes read from conn. It reports whether the
+// message may pass and whether the connection is being disconnected. Runs on the reader goroutine of conn.
+func (cm *ConnectionManager) limitInbound(conn *Connection, msg *Message, size int64) (allowed bool, disconnect bool) {
+	limit := cm.inboundLimit(conn)
+	if !limit.enabled() {
+		return true, false
+	}
+	now := cm.clock.Now()
+	l := conn.inbound
+	if l == nil {
+		l = &inboundLimiter{
+			messages: newTokenBucket(limit.MessagesPerSecond, limit.Burst, now),
+			bytes:    newTokenBucket(limit.BytesPerSecond, limit.Burst, now),
+		}
+		conn.inbound = l
+	}
+	l.messages.refill(now)
+	l.bytes.refill(now)
+	wait := max(l.messages.wait(1), l.bytes.wait(float64(size)))
+	if wait == 0 {
+		l.messages.take(1)
+		l.bytes.take(float64(size))
+		return true, false
+	}
+	cm.metrics.rateLimited.Add(1)
+	switch limit.Policy {
+	case RateLimitDelay:
+		l.messages.take(1)
+		l.bytes.take(float64(size))
+		cm.sleep(wait)
+		return true, false
+	case RateLimitDisconnect:
+		cm.logV("Client exceeded the inbound rate limit, disconnecting")
+		cm.Disconnect(conn, websocket.ClosePolicyViolation, "rate limit exceeded")
+		return false, true
+	}
+	cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "rate limited", Time: now})
+	if now.Before(l.notified) {
+		// Already told when the next message is allowed
+		return false, false
+	}
+	next := now.Add(wait)
+	l.notified = next
+	cm.SendTo(conn, &Message{Type: cm.opts.RateLimitMessageType, Data: RateLimit{
+		Rate:  limit.MessagesPerSecond,
+		Next:  next,
+		Reset: next,
+	}})
+	return false, false
+}
+
+// inboundLimit of conn, Options.AnonymousInboundLimit while it is anonymous
+func (cm *ConnectionManager) inboundLimit(conn *Connection) InboundLimit {
+	if cm.opts.AllowAnonymous && cm.opts.AnonymousInboundLimit.enabled() && conn.Anonymous() {
+		return cm.opts.AnonymousInboundLimit
+	}
+	return cm.opts.InboundLimit
+}
+
+// sleep waits for d on the clock of the manager, or until it shuts down
+func (cm *ConnectionManager) sleep(d time.Duration) {
+	woken := make(chan struct{})
+	timer := cm.clock.AfterFunc(d, func() {
+		close(woken)
+	})
+	select {
+	case <-woken:
+	case <-cm.done:
+		timer.Stop()
+	}
+}
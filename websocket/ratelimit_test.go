@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// limitedServer dials a connection of a manager with limit on a fake clock, counting the received messages
+func limitedServer(t *testing.T, limit InboundLimit) (*ConnectionManager, *FakeClock, *gorilla.Conn, *atomic.Int64) {
+	t.Helper()
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), WithInboundLimit(limit), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+	})
+	received := new(atomic.Int64)
+	client, _ := testServer(t, cm, func(*Connection, *Message) { received.Add(1) })()
+	return cm, clock, client, received
+}
+
+func TestInboundLimitDrops(t *testing.T) {
+	cm, _, client, received := limitedServer(t, InboundLimit{MessagesPerSecond: 10})
+	for i := 0; i < 30; i++ {
+		client.WriteJSON(Message{Type: "x"})
+	}
+	eventually(t, "the messages over the limit to be dropped", func() bool { return cm.Stats().RateLimited == 20 })
+	if n := received.Load(); n != 10 {
+		t.Fatalf("received %d messages, want the burst of 10", n)
+	}
+	if n := cm.Stats().Dropped; n != 20 {
+		t.Fatalf("%d messages dropped, want 20", n)
+	}
+	// The client is told once when it may send again
+	readType(t, client, defaultRateLimitMessageType)
+	expectNone(t, client, 200*time.Millisecond)
+}
+
+func TestInboundLimitDelays(t *testing.T) {
+	_, clock, client, received := limitedServer(t, InboundLimit{MessagesPerSecond: 1, Policy: RateLimitDelay})
+	for i := 0; i < 3; i++ {
+		client.WriteJSON(Message{Type: "x"})
+	}
+	eventually(t, "the burst to be received", func() bool { return received.Load() == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if n := received.Load(); n != 1 {
+		t.Fatalf("received %d messages before the limit allowed them", n)
+	}
+	eventually(t, "the delayed messages", func() bool {
+		clock.Advance(time.Second)
+		return received.Load() == 3
+	})
+}
+
+func TestInboundLimitDisconnects(t *testing.T) {
+	_, _, client, received := limitedServer(t, InboundLimit{MessagesPerSecond: 2, Policy: RateLimitDisconnect})
+	for i := 0; i < 5; i++ {
+		client.WriteJSON(Message{Type: "x"})
+	}
+	_, _, err := client.ReadMessage()
+	var closeErr *gorilla.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != gorilla.ClosePolicyViolation {
+		t.Fatalf("err = %v, want close 1008", err)
+	}
+	if n := received.Load(); n != 2 {
+		t.Fatalf("received %d messages, want the burst of 2", n)
+	}
+}
@@ -0,0 +1,51 @@
+// Package redisbroker relays broadcasts between ConnectionManagers of several instances through Redis pub/sub,
+// e.g.
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	cm := websocket.NewConnectionManager(websocket.WithBroker(redisbroker.New(client, "broadcasts")))
+package redisbroker
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Broker publishes to and subscribes to one Redis channel
+type Broker struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// New broker on channel of client, instances relaying to each other use the same channel
+func New(client redis.UniversalClient, channel string) *Broker {
+	return &Broker{client: client, channel: channel}
+}
+
+// Publish sends data to the channel
+func (b *Broker) Publish(ctx context.Context, data []byte) error {
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe calls deliver with the messages of the channel until ctx is done. The subscription is restored by
+// the client after connection errors, messages published meanwhile are lost.
+func (b *Broker) Subscribe(ctx context.Context, deliver func(data []byte)) error {
+	sub := b.client.Subscribe(ctx, b.channel)
+	defer sub.Close()
+	// Wait for the confirmation so a bad address or channel is reported
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			deliver([]byte(msg.Payload))
+		}
+	}
+}
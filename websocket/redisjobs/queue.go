@@ -0,0 +1,104 @@
+// Package redisjobs is a websocket.JobQueue on Redis lists. Instances push jobs to one list shared with the
+// workers and receive results on a list of their own, e.g.
+//
+//	queue := redisjobs.New(client, "jobs", "results:"+hostname)
+//	cm := websocket.NewConnectionManager(websocket.WithJobQueue(queue, "render"))
+//
+// Workers take jobs with Next and answer with Reply.
+package redisjobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+	"github.com/redis/go-redis/v9"
+)
+
+// pollTimeout bounds each blocking pop so cancellation of ctx is noticed
+const pollTimeout = time.Second
+
+// Queue of jobs and of the results of one instance
+type Queue struct {
+	client  redis.UniversalClient
+	jobs    string
+	results string
+}
+
+// New queue pushing to the jobs list and receiving from the results list, which must differ per instance.
+// Workers only use the jobs list.
+func New(client redis.UniversalClient, jobs, results string) *Queue {
+	return &Queue{client: client, jobs: jobs, results: results}
+}
+
+// Push adds job to the jobs list with the results list of the instance as ReplyTo
+func (q *Queue) Push(ctx context.Context, job websocket.Job) error {
+	job.ReplyTo = q.results
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.client.LPush(ctx, q.jobs, data).Err()
+}
+
+// Results calls deliver with the results pushed to the results list until ctx is done
+func (q *Queue) Results(ctx context.Context, deliver func(websocket.JobResult)) error {
+	for {
+		data, err := q.pop(ctx, q.results)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		var result websocket.JobResult
+		if json.Unmarshal(data, &result) == nil {
+			deliver(result)
+		}
+	}
+}
+
+// Next takes the oldest job, waiting until one is pushed or ctx is done
+func (q *Queue) Next(ctx context.Context) (websocket.Job, error) {
+	for {
+		data, err := q.pop(ctx, q.jobs)
+		if err != nil {
+			return websocket.Job{}, err
+		}
+		if data == nil {
+			continue
+		}
+		var job websocket.Job
+		err = json.Unmarshal(data, &job)
+		return job, err
+	}
+}
+
+// Reply sends msg to the connection of job
+func (q *Queue) Reply(ctx context.Context, job websocket.Job, msg *websocket.Message) error {
+	data, err := json.Marshal(websocket.JobResult{ConnectionID: job.ConnectionID, Message: msg})
+	if err != nil {
+		return err
+	}
+	return q.client.LPush(ctx, job.ReplyTo, data).Err()
+}
+
+// pop the oldest entry of list, nil when none was pushed within pollTimeout
+func (q *Queue) pop(ctx context.Context, list string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	popped, err := q.client.BRPop(ctx, pollTimeout, list).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(popped[1]), nil
+}
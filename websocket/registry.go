@@ -0,0 +1,41 @@
+package websocket
+
+// Registry stores the connections of a manager. Provide a custom implementation through Options.Registry, e.g.
+// sharded or weighted. It is only used from the operations goroutine, so implementations need no locking.
+type Registry interface {
+	Add(conn *Connection)
+	Remove(conn *Connection)
+	Contains(conn *Connection) bool
+	Len() int
+	// Range calls fn for each connection, Add and Remove may be called from fn
+	Range(fn func(conn *Connection))
+}
+
+// NewMapRegistry default Registry backed by a map
+func NewMapRegistry() Registry {
+	return mapRegistry{}
+}
+
+type mapRegistry map[*Connection]bool // Using map for faster removal and access
+
+func (r mapRegistry) Add(conn *Connection) {
+	r[conn] = true
+}
+
+func (r mapRegistry) Remove(conn *Connection) {
+	delete(r, conn)
+}
+
+func (r mapRegistry) Contains(conn *Connection) bool {
+	return r[conn]
+}
+
+func (r mapRegistry) Len() int {
+	return len(r)
+}
+
+func (r mapRegistry) Range(fn func(conn *Connection)) {
+	for conn := range r {
+		fn(conn)
+	}
+}
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+// sliceRegistry keeps connections in arrival order
+type sliceRegistry struct {
+	conns   []*Connection
+	removed atomic.Int32
+}
+
+func (r *sliceRegistry) Add(conn *Connection) { r.conns = append(r.conns, conn) }
+func (r *sliceRegistry) Remove(conn *Connection) {
+	if i := slices.Index(r.conns, conn); i >= 0 {
+		r.conns = slices.Delete(r.conns, i, i+1)
+		r.removed.Add(1)
+	}
+}
+func (r *sliceRegistry) Contains(conn *Connection) bool { return slices.Contains(r.conns, conn) }
+func (r *sliceRegistry) Len() int                       { return len(r.conns) }
+func (r *sliceRegistry) Range(fn func(conn *Connection)) {
+	for _, conn := range slices.Clone(r.conns) {
+		fn(conn)
+	}
+}
+
+func TestCustomRegistry(t *testing.T) {
+	registry := &sliceRegistry{}
+	cm := NewConnectionManager(WithRegistry(registry), func(o *Options) { o.SetupTimeout = -1 })
+	dial := testServer(t, cm, nil)
+	first, firstConn := dial()
+	second, _ := dial()
+	if n := cm.Stats().Connections; n != 2 {
+		t.Fatalf("%d connections", n)
+	}
+
+	cm.Send(&Message{Type: "hello"})
+	readType(t, first, "hello")
+	readType(t, second, "hello")
+	firstConn.Terminate()
+	eventually(t, "the connection to leave the registry", func() bool { return registry.removed.Load() == 1 })
+	if n := cm.Stats().Connections; n != 1 {
+		t.Fatalf("%d connections after terminating one", n)
+	}
+}
+
+func TestMapRegistryRemoveWhileRanging(t *testing.T) {
+	registry := NewMapRegistry()
+	conns := []*Connection{{}, {}, {}}
+	for _, conn := range conns {
+		registry.Add(conn)
+	}
+	registry.Range(func(conn *Connection) { registry.Remove(conn) })
+	if registry.Len() != 0 || registry.Contains(conns[0]) {
+		t.Fatalf("%d connections left", registry.Len())
+	}
+}
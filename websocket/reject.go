@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Reasons of rejected upgrades
+const (
+	RejectBadOrigin    = "bad_origin"
+	RejectBadHandshake = "bad_handshake"
+	RejectAborted      = "aborted"
+	RejectNotHijacker  = "not_hijacker"
+	// RejectHandshakeTimeout the handshake or the first message did not complete in time
+	RejectHandshakeTimeout = "handshake_timeout"
+	// RejectAuthFailed first message authentication failed
+	RejectAuthFailed = "auth_failed"
+	// RejectUnauthorized AuthenticateRequest, or the authorize function of BenchmarkHandler, refused the upgrade
+	// request
+	RejectUnauthorized = "unauthorized"
+	// RejectMaintenance the manager is in maintenance mode
+	RejectMaintenance = "maintenance"
+	// RejectShutdown the manager is shutting down
+	RejectShutdown = "shutdown"
+	// RejectSetupTimeout the connection was not authenticated or ready within Options.SetupTimeout
+	RejectSetupTimeout = "setup_timeout"
+	// RejectConnectionLimit the manager has Options.MaxConnections connections
+	RejectConnectionLimit = "connection_limit"
+	// RejectQuotaExceeded the user exceeded its quota with QuotaDisconnect
+	RejectQuotaExceeded = "quota_exceeded"
+)
+
+// UpgradeRejection describes an upgrade that was refused
+type UpgradeRejection struct {
+	Status  int    `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// WriteJSONRejection writes rejection as a JSON body with its status, the default Options.RejectResponse
+func WriteJSONRejection(w http.ResponseWriter, r *http.Request, rejection UpgradeRejection) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rejection.Status)
+	json.NewEncoder(w).Encode(rejection)
+}
+
+type rejectionCounts struct {
+	mu       sync.Mutex
+	byReason map[string]int64
+}
+
+func (c *rejectionCounts) add(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byReason == nil {
+		c.byReason = make(map[string]int64)
+	}
+	c.byReason[reason]++
+}
+
+// Rejections counts of rejected connection attempts by reason, e.g. RejectBadOrigin or RejectAuthFailed, to
+// tell attacks from client bugs
+func (cm *ConnectionManager) Rejections() map[string]int64 {
+	cm.rejections.mu.Lock()
+	defer cm.rejections.mu.Unlock()
+	counts := make(map[string]int64, len(cm.rejections.byReason))
+	for reason, count := range cm.rejections.byReason {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// rejected counts a rejected connection attempt
+func (cm *ConnectionManager) rejected(reason string) {
+	cm.logV("Connection attempt rejected: " + reason)
+	cm.rejections.add(reason)
+}
+
+// Error implements error, rejected upgrades are returned by Receive
+func (rejection UpgradeRejection) Error() string {
+	return "websocket: upgrade rejected: " + rejection.Reason + ": " + rejection.Message
+}
+
+// reject counts the rejection, writes its response and returns it
+func (cm *ConnectionManager) reject(w http.ResponseWriter, r *http.Request, rejection UpgradeRejection) error {
+	cm.rejected(rejection.Reason)
+	if rejection.Message == "" {
+		rejection.Message = http.StatusText(rejection.Status)
+	}
+	cm.opts.RejectResponse(w, r, rejection)
+	return rejection
+}
+
+// upgradeError is installed as the upgrader Error hook so handshake failures detected by the upgrader are
+// answered by RejectResponse too
+func (cm *ConnectionManager) upgradeError(w http.ResponseWriter, r *http.Request, status int, reason error) {
+	rejection := UpgradeRejection{
+		Status:  status,
+		Reason:  RejectBadHandshake,
+		Message: reason.Error(),
+	}
+	if status == http.StatusForbidden {
+		rejection.Reason = RejectBadOrigin
+	}
+	cm.reject(w, r, rejection)
+}
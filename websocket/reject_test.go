@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejectedUpgradesAnswerJSON(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.OnBeforeUpgrade = func(r *http.Request) UpgradeDecision {
+			if r.URL.Query().Has("full") {
+				return UpgradeDecision{Status: http.StatusServiceUnavailable, Reason: "full"}
+			}
+			return UpgradeDecision{}
+		}
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+	}))
+	defer srv.Close()
+
+	for query, want := range map[string]UpgradeRejection{
+		"":      {Status: http.StatusBadRequest, Reason: RejectBadHandshake},
+		"?full": {Status: http.StatusServiceUnavailable, Reason: "full", Message: "Service Unavailable"},
+	} {
+		resp, err := http.Get(srv.URL + "/" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got UpgradeRejection
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil || resp.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("GET %q: %v, content type %q", query, err, resp.Header.Get("Content-Type"))
+		}
+		if resp.StatusCode != want.Status || got.Status != want.Status || got.Reason != want.Reason ||
+			want.Message != "" && got.Message != want.Message {
+			t.Fatalf("GET %q answered %d %+v, want %+v", query, resp.StatusCode, got, want)
+		}
+	}
+}
+
+func TestCustomRejectResponse(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.RejectResponse = func(w http.ResponseWriter, r *http.Request, rejection UpgradeRejection) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte(rejection.Reason))
+		}
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot || string(body) != RejectBadHandshake {
+		t.Fatalf("answered %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestRejectionsByReason(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.Authenticate = func(msg *Message) (interface{}, error) { return nil, errors.New("bad token") }
+	})
+	dial := testServerQuery(t, cm, nil)
+	client, _ := dial("")
+	client.WriteJSON(Message{Type: "auth", Data: "wrong"})
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatal("connection failing authentication not closed")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+	}))
+	defer srv.Close()
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	eventually(t, "the rejections to be counted", func() bool {
+		counts := cm.Rejections()
+		return counts[RejectBadHandshake] == 2 && counts[RejectAuthFailed] == 1
+	})
+	if counts := cm.Rejections(); len(counts) != 2 {
+		t.Fatalf("rejections %v", counts)
+	}
+}
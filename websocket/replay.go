@@ -0,0 +1,423 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionMessageType = "session"
+	defaultSessionParam       = "session"
+	defaultSessionReplaySize  = 1000
+	// sessionReceivedParam query parameter of a resuming upgrade with the number of messages the client received
+	sessionReceivedParam = "received"
+)
+
+// errSessionResumed removes the previous connection of a session resumed by a new one
+var errSessionResumed = errors.New("websocket: session resumed by another connection")
+
+// SessionInfo is the data of the SessionMessageType message that starts each connection with SessionReplayWindow
+type SessionInfo struct {
+	ID string `json:"id"`
+	// Token resumes the session as the SessionParam query parameter of the next upgrade, together with the
+	// number of messages received in the session so far, not counting session messages, as "received"
+	Token string `json:"token"`
+	// Resumed is false for a new session, its count of received messages starts at zero
+	Resumed bool `json:"resumed"`
+}
+
+// SessionID of the connection with SessionReplayWindow, empty otherwise
+func (c *Connection) SessionID() string {
+	if c.session == nil {
+		return ""
+	}
+	return c.session.id
+}
+
+// SendSession sends msg to the connection of the session, or keeps it for the connection resuming the session
+// within SessionReplayWindow. Messages to unknown or expired sessions are dropped.
+func (cm *ConnectionManager) SendSession(sessionID string, msg *Message) {
+	cm.enqueue(&socketOperation{
+		opType: call,
+		fn: func() {
+			cm.sendSession(sessionID, msg)
+		},
+	})
+}
+
+// replaySession keeps the messages written to the connection of a session, until the client reports it received
+// them when resuming, and the messages that could not be written or were sent while it was detached
+type replaySession struct {
+	id string
+
+	mu       sync.Mutex
+	written  []replayed
+	base     uint64 // Messages of the session before written[0]
+	unsent   []pending
+	conn     *Connection // Nil while detached
+	detached time.Time
+
+	// Subscriptions and metadata of the detached connection, publishes to the subscriptions are kept and they
+	// are restored on resume. Only accessed from the operations goroutine.
+	topics    []string
+	interests []string
+	values    map[string]interface{}
+}
+
+type replayed struct {
+	msg     *Message
+	written time.Time
+}
+
+// pending is a message kept for the next connection of a session
+type pending struct {
+	msg *Message
+	// fanout marks broadcasts and publishes kept while detached, Transform still has to be applied to them
+	fanout bool
+}
+
+type replaySessions struct {
+	mu        sync.Mutex
+	byID      map[string]*replaySession
+	detached  map[*replaySession]struct{} // Sessions keeping broadcasts for their next connection
+	nextPrune time.Time
+}
+
+// attachSession finds or starts the session of conn from the query of r, and returns the session message and
+// the count of messages the client received. The connection takes over the session in addSession.
+func (cm *ConnectionManager) attachSession(conn *Connection, r *http.Request) (*Message, uint64) {
+	if cm.opts.SessionReplayWindow <= 0 {
+		return nil, 0
+	}
+	query := r.URL.Query()
+	var id, token string
+	resumed := false
+	if presented := query.Get(cm.opts.SessionParam); presented != "" {
+		var err error
+		id, token, err = cm.ResumeSession(presented)
+		resumed = err == nil
+	}
+	if !resumed {
+		id, token = cm.NewSession()
+	}
+	received, _ := strconv.ParseUint(query.Get(sessionReceivedParam), 10, 64)
+	if !resumed {
+		received = 0
+	}
+	conn.session = cm.replay.session(id, resumed, received, cm.clock.Now(), cm.opts.SessionReplayWindow)
+	info := &Message{
+		Type: cm.opts.SessionMessageType,
+		Data: SessionInfo{ID: id, Token: token, Resumed: resumed},
+	}
+	return info, received
+}
+
+// addSession hands the session over to conn and adds it in one step, so no message falls between the previous
+// connection and conn. It removes the previous connection, restores its subscriptions, writes the session
+// message and replays the messages the client missed. Runs on the operations goroutine.
+func (cm *ConnectionManager) addSession(conn *Connection, info *Message, received uint64) {
+	session := conn.session
+	previous, replay := cm.replay.attach(session, conn, received, cm.clock.Now(), cm.opts)
+	if previous != nil {
+		cm.removeSocket(previous, errSessionResumed)
+	}
+	cm.addSocket(conn)
+	for _, topic := range session.topics {
+		cm.subscribe(conn, topic)
+	}
+	if len(session.interests) > 0 {
+		cm.updateInterests(conn, session.interests)
+	}
+	for key, value := range session.values {
+		// Values of the upgrade take precedence
+		if _, ok := conn.Get(key); !ok {
+			conn.Set(key, value)
+		}
+	}
+	session.topics, session.interests, session.values = nil, nil, nil
+	cm.write(conn, info)
+	for _, p := range replay {
+		if p.fanout {
+			cm.newFanout(p.msg).deliver(conn)
+		} else {
+			cm.deliver(conn, p.msg)
+		}
+	}
+}
+
+// keepDetached keeps msg for the detached sessions, those subscribed to topic unless it is empty. Runs on the
+// operations goroutine.
+func (cm *ConnectionManager) keepDetached(topic string, msg *Message) {
+	if len(cm.replay.detached) == 0 {
+		return
+	}
+	now := cm.clock.Now()
+	cm.replay.mu.Lock()
+	defer cm.replay.mu.Unlock()
+	for session := range cm.replay.detached {
+		session.mu.Lock()
+		expired := now.Sub(session.detached) > cm.opts.SessionReplayWindow
+		if !expired && (topic == "" || session.subscribed(topic)) {
+			session.keep(pending{msg: msg, fanout: true}, cm.opts.SessionReplaySize)
+		}
+		session.mu.Unlock()
+		if expired {
+			delete(cm.replay.detached, session)
+		}
+	}
+}
+
+// subscribed reports whether the detached connection of the session was subscribed to topic
+func (s *replaySession) subscribed(topic string) bool {
+	for _, t := range s.topics {
+		if t == topic {
+			return true
+		}
+	}
+	for _, id := range s.interests {
+		if id == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// sendSession runs on the operations goroutine
+func (cm *ConnectionManager) sendSession(sessionID string, msg *Message) {
+	session := cm.replay.get(sessionID)
+	if session == nil {
+		cm.publish(DropEvent{Message: msg, Reason: "unknown session", Time: cm.clock.Now()})
+		return
+	}
+	session.mu.Lock()
+	conn := session.conn
+	if conn == nil || !cm.registry.Contains(conn) {
+		session.keep(pending{msg: msg}, cm.opts.SessionReplaySize)
+		session.mu.Unlock()
+		return
+	}
+	session.mu.Unlock()
+	cm.deliver(conn, msg)
+}
+
+// sessionWritten records msg written to conn for the replay
+func (cm *ConnectionManager) sessionWritten(conn *Connection, msg *Message) {
+	if conn.session == nil || msg.Type == cm.opts.SessionMessageType {
+		return
+	}
+	session := conn.session
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.conn != conn {
+		// Written to a connection replaced by a resume, the client did not count it
+		cm.forwardSession(session, msg)
+		return
+	}
+	session.written = append(session.written, replayed{msg: msg, written: cm.clock.Now()})
+	session.trim(cm.clock.Now().Add(-cm.opts.SessionReplayWindow), cm.opts.SessionReplaySize)
+}
+
+// sessionUnsent keeps msgs that were queued to conn but not written for the connection resuming its session
+func (cm *ConnectionManager) sessionUnsent(conn *Connection, msgs ...*Message) {
+	if conn.session == nil {
+		return
+	}
+	conn.session.mu.Lock()
+	defer conn.session.mu.Unlock()
+	for _, msg := range msgs {
+		if msg.Type == cm.opts.SessionMessageType {
+			continue
+		}
+		if current := conn.session.conn; current != nil && current != conn {
+			cm.forwardSession(conn.session, msg)
+			continue
+		}
+		conn.session.keep(pending{msg: msg}, cm.opts.SessionReplaySize)
+	}
+}
+
+// forwardSession sends msg of a replaced connection to the current connection of session without waiting for
+// the operations goroutine
+func (cm *ConnectionManager) forwardSession(session *replaySession, msg *Message) {
+	go cm.SendSession(session.id, msg)
+}
+
+// detachSession runs on the operations goroutine when conn is removed, before its subscriptions are dropped
+func (cm *ConnectionManager) detachSession(conn *Connection) {
+	session := conn.session
+	if session == nil {
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.conn != conn {
+		return
+	}
+	session.conn = nil
+	session.detached = cm.clock.Now()
+	session.topics = keys(conn.topics)
+	session.interests = keys(conn.interests)
+	session.values = conn.metadata()
+	cm.replay.mu.Lock()
+	if cm.replay.detached == nil {
+		cm.replay.detached = make(map[*replaySession]struct{})
+	}
+	cm.replay.detached[session] = struct{}{}
+	cm.replay.mu.Unlock()
+}
+
+// snapshotSession adds the subscriptions and metadata of the session to snapshot, runs on the operations goroutine
+func (cm *ConnectionManager) snapshotSession(snapshot *SessionSnapshot) {
+	session := cm.replay.get(snapshot.ID)
+	if session == nil {
+		return
+	}
+	session.mu.Lock()
+	conn := session.conn
+	session.mu.Unlock()
+	if conn != nil && cm.registry.Contains(conn) {
+		snapshot.Topics = keys(conn.topics)
+		snapshot.Interests = keys(conn.interests)
+		snapshot.Values = conn.metadata()
+		return
+	}
+	snapshot.Topics = session.topics
+	snapshot.Interests = session.interests
+	snapshot.Values = session.values
+}
+
+// restoreSession adds the session of snapshot as detached, so publishes to its subscriptions are kept and the
+// resuming client gets them back. Detached sessions already known get the subscriptions of snapshot, the
+// sessions of connections are kept. Runs on the operations goroutine.
+func (cm *ConnectionManager) restoreSession(snapshot SessionSnapshot, now time.Time) {
+	cm.replay.mu.Lock()
+	defer cm.replay.mu.Unlock()
+	if session, ok := cm.replay.byID[snapshot.ID]; ok {
+		session.mu.Lock()
+		if session.conn == nil {
+			session.topics, session.interests, session.values = snapshot.Topics, snapshot.Interests, snapshot.Values
+		}
+		session.mu.Unlock()
+		return
+	}
+	session := &replaySession{
+		id:        snapshot.ID,
+		detached:  now,
+		topics:    snapshot.Topics,
+		interests: snapshot.Interests,
+		values:    snapshot.Values,
+	}
+	if cm.replay.byID == nil {
+		cm.replay.byID = make(map[string]*replaySession)
+	}
+	if cm.replay.detached == nil {
+		cm.replay.detached = make(map[*replaySession]struct{})
+	}
+	cm.replay.byID[session.id] = session
+	cm.replay.detached[session] = struct{}{}
+}
+
+func keys(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for key := range set {
+		list = append(list, key)
+	}
+	return list
+}
+
+// session returns the session id to resume, or a new one
+func (s *replaySessions) session(id string, resumed bool, received uint64, now time.Time,
+	window time.Duration) *replaySession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now, window)
+	if s.byID == nil {
+		s.byID = make(map[string]*replaySession)
+	}
+	session, ok := s.byID[id]
+	if !ok || !resumed {
+		session = &replaySession{id: id, base: received}
+		s.byID[id] = session
+	}
+	return session
+}
+
+// attach makes conn the connection of session and returns the previous connection, if any, and the messages to
+// replay
+func (s *replaySessions) attach(session *replaySession, conn *Connection, received uint64, now time.Time,
+	opts Options) (*Connection, []pending) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The session may have been pruned since it was looked up
+	s.byID[session.id] = session
+	delete(s.detached, session)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.trim(now.Add(-opts.SessionReplayWindow), opts.SessionReplaySize)
+	var replay []pending
+	if received >= session.base {
+		// The client received the messages up to received, older ones are gone when it reports fewer
+		skip := received - session.base
+		if skip < uint64(len(session.written)) {
+			for _, entry := range session.written[skip:] {
+				replay = append(replay, pending{msg: entry.msg})
+			}
+		}
+	} else {
+		for _, entry := range session.written {
+			replay = append(replay, pending{msg: entry.msg})
+		}
+	}
+	replay = append(replay, session.unsent...)
+	// Replayed messages are counted again when they are written
+	session.written, session.unsent = nil, nil
+	session.base = received
+	previous := session.conn
+	session.conn = conn
+	return previous, replay
+}
+
+func (s *replaySessions) get(id string) *replaySession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.byID[id]
+}
+
+// prune drops the sessions detached for longer than window, must be called with mu held
+func (s *replaySessions) prune(now time.Time, window time.Duration) {
+	if now.Before(s.nextPrune) {
+		return
+	}
+	for id, session := range s.byID {
+		session.mu.Lock()
+		expired := session.conn == nil && now.Sub(session.detached) > window
+		session.mu.Unlock()
+		if expired {
+			delete(s.byID, id)
+			delete(s.detached, session)
+		}
+	}
+	s.nextPrune = now.Add(window / 10)
+}
+
+// keep queues msg for the next connection of the session, must be called with mu held
+func (s *replaySession) keep(p pending, size int) {
+	s.unsent = append(s.unsent, p)
+	if len(s.unsent) > size {
+		s.unsent = s.unsent[len(s.unsent)-size:]
+	}
+}
+
+// trim drops written messages older than oldest or beyond size, must be called with mu held
+func (s *replaySession) trim(oldest time.Time, size int) {
+	drop := 0
+	for drop < len(s.written) && (len(s.written)-drop > size || s.written[drop].written.Before(oldest)) {
+		drop++
+	}
+	clear(s.written[:drop])
+	s.written = s.written[drop:]
+	s.base += uint64(drop)
+}
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// readSession reads the session message that starts each connection with SessionReplayWindow
+func readSession(t *testing.T, client *gorilla.Conn) SessionInfo {
+	t.Helper()
+	var msg struct {
+		Type string
+		Data SessionInfo
+	}
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != defaultSessionMessageType {
+		t.Fatalf("read session %+v, %v", msg, err)
+	}
+	return msg.Data
+}
+
+// resumeQuery resumes the session of info after received messages
+func resumeQuery(info SessionInfo, received int) string {
+	return url.Values{
+		defaultSessionParam:  {info.Token},
+		sessionReceivedParam: {strconv.Itoa(received)},
+	}.Encode()
+}
+
+func TestSessionResumeReplaysMissedMessages(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.SessionReplayWindow = time.Minute
+	})
+	dial := testServerQuery(t, cm, nil)
+	client, conn := dial("")
+	info := readSession(t, client)
+	if info.Resumed || conn.SessionID() != info.ID {
+		t.Fatalf("session %+v of %s", info, conn.SessionID())
+	}
+	for _, msgType := range []string{"m1", "m2", "m3"} {
+		cm.SendTo(conn, &Message{Type: msgType})
+	}
+	readType(t, client, "m3")
+	client.Close()
+	eventually(t, "the connection to be removed", func() bool { return cm.Stats().Connections == 0 })
+	cm.SendSession(info.ID, &Message{Type: "offline"})
+
+	// The client reports it only received m1
+	client, conn = dial(resumeQuery(info, 1))
+	resumed := readSession(t, client)
+	if !resumed.Resumed || resumed.ID != info.ID || resumed.Token == info.Token || conn.SessionID() != info.ID {
+		t.Fatalf("resumed %+v of %s", resumed, conn.SessionID())
+	}
+	for _, want := range []string{"m2", "m3", "offline"} {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil || msg.Type != want {
+			t.Fatalf("read %+v, %v, want %s", msg, err, want)
+		}
+	}
+
+	// The token of the first connection was replaced, it starts a new session
+	client, _ = dial(resumeQuery(info, 3))
+	if fresh := readSession(t, client); fresh.Resumed || fresh.ID == info.ID {
+		t.Fatalf("stale token resumed %+v", fresh)
+	}
+}
+
+func TestDetachedSessionKeepsBroadcasts(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.SessionReplayWindow = time.Minute
+	})
+	dial := testServerQuery(t, cm, nil)
+	client, conn := dial("")
+	info := readSession(t, client)
+	cm.Join(conn, "room")
+	eventually(t, "the join", func() bool { return len(conn.Topics()) == 1 })
+	client.Close()
+	eventually(t, "the connection to be removed", func() bool { return cm.Stats().Connections == 0 })
+
+	cm.Send(&Message{Type: "everyone"})
+	cm.Publish("room", &Message{Type: "room"})
+	cm.Publish("other", &Message{Type: "other"})
+	client, conn = dial(resumeQuery(info, 0))
+	readSession(t, client)
+	for _, want := range []string{"everyone", "room"} {
+		var msg Message
+		if err := client.ReadJSON(&msg); err != nil || msg.Type != want {
+			t.Fatalf("read %+v, %v, want %s", msg, err, want)
+		}
+	}
+	if topics := conn.Topics(); len(topics) != 1 || topics[0] != "room" {
+		t.Fatalf("resumed with topics %v", topics)
+	}
+	cm.Publish("room", &Message{Type: "live"})
+	readType(t, client, "live")
+}
+
+func TestSessionResumeReplacesOpenConnection(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.SessionReplayWindow = time.Minute
+	})
+	dial := testServerQuery(t, cm, nil)
+	previous, _ := dial("")
+	info := readSession(t, previous)
+	client, _ := dial(resumeQuery(info, 0))
+	readSession(t, client)
+
+	cm.Send(&Message{Type: "after"})
+	readType(t, client, "after")
+	if n := cm.Stats().Connections; n != 1 {
+		t.Fatalf("%d connections, want the previous one replaced", n)
+	}
+	if _, _, err := previous.ReadMessage(); err == nil {
+		t.Fatal("previous connection not closed")
+	}
+}
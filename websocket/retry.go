@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	retryMinBackoff = 100 * time.Millisecond
+	retryMaxBackoff = 30 * time.Second
+)
+
+// keepRunning calls run until ctx is done, again after a growing backoff whenever it returns, so the loops
+// receiving from a JobQueue or a Broker survive outages of their backend
+func (cm *ConnectionManager) keepRunning(ctx context.Context, what string, run func(ctx context.Context) error) {
+	backoff := retryMinBackoff
+	for {
+		started := cm.clock.Now()
+		err := run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			cm.logE(err, what+" failed, retrying")
+		} else {
+			cm.logV(what + " ended, restarting")
+		}
+		if cm.clock.Now().Sub(started) > retryMaxBackoff {
+			backoff = retryMinBackoff
+		}
+		wait := make(chan struct{})
+		timer := cm.clock.AfterFunc(backoff, func() { close(wait) })
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-wait:
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
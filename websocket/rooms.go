@@ -0,0 +1,188 @@
+package websocket
+
+import (
+	"errors"
+)
+
+const defaultTopicSwapMessageType = "topic.swap"
+
+var errInvalidTopic = errors.New("websocket: join and leave message data must be a topic name")
+
+// TopicSwap is the data of the TopicSwapMessageType message telling a subscriber moved by SwapTopic that the
+// messages of From now arrive with Message.Topic set to To
+type TopicSwap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Join subscribes conn to topic, e.g. a chat room. Topics share the entity index with SetInterests but are kept
+// apart from the interest set, so interest messages neither join nor leave topics. Membership is dropped when
+// the connection is removed. With Options.SubscriptionLease joining again renews the
+// subscription.
+func (cm *ConnectionManager) Join(conn *Connection, topic string) {
+	cm.enqueue(&socketOperation{
+		opType: join,
+		conn:   conn,
+		ids:    []string{topic},
+	})
+}
+
+// Leave unsubscribes conn from topic
+func (cm *ConnectionManager) Leave(conn *Connection, topic string) {
+	cm.enqueue(&socketOperation{
+		opType: leave,
+		conn:   conn,
+		ids:    []string{topic},
+	})
+}
+
+// Publish sends msg to the members of topic with Message.Topic set to topic
+func (cm *ConnectionManager) Publish(topic string, msg *Message) {
+	published := *msg
+	published.Topic = topic
+	cm.PublishEntity(topic, &published)
+}
+
+// PublishValue publishes value as the data of a msgType message to the members of topic
+func PublishValue[T any](cm *ConnectionManager, topic string, msgType string, value T) {
+	cm.Publish(topic, &Message{Type: msgType, Data: value})
+}
+
+// SwapTopic moves all subscribers of oldTopic to newTopic in one step, e.g. for a blue/green cutover to a new
+// feed. Messages published to newTopic after the swap reach every moved subscriber, messages to oldTopic none.
+// Moved subscribers are sent a TopicSwapMessageType message, and oldTopic in their later join, leave and
+// interest messages stands for newTopic.
+func (cm *ConnectionManager) SwapTopic(oldTopic, newTopic string) {
+	cm.enqueue(&socketOperation{
+		opType: swapTopic,
+		ids:    []string{oldTopic, newTopic},
+	})
+}
+
+// Topics the connection joined
+func (c *Connection) Topics() []string {
+	var topics []string
+	c.manager.call(func() {
+		topics = make([]string, 0, len(c.topics))
+		for topic := range c.topics {
+			topics = append(topics, topic)
+		}
+	})
+	return topics
+}
+
+// Members connections subscribed to topic
+func (cm *ConnectionManager) Members(topic string) []*Connection {
+	var members []*Connection
+	cm.entities.each(topic, func(conn *Connection) {
+		members = append(members, conn)
+	})
+	return members
+}
+
+// joinTopic runs on the operations goroutine, joining a topic again renews its lease
+func (cm *ConnectionManager) joinTopic(conn *Connection, topic string) {
+	if !cm.registry.Contains(conn) {
+		return
+	}
+	topic = conn.swappedTopic(topic)
+	joined := conn.topics[topic]
+	cm.subscribe(conn, topic)
+	if !joined {
+		cm.publish(JoinEvent{Conn: conn, Topic: topic, Time: cm.clock.Now()})
+	}
+}
+
+// leaveTopic runs on the operations goroutine
+func (cm *ConnectionManager) leaveTopic(conn *Connection, topic string) {
+	topic = conn.swappedTopic(topic)
+	delete(conn.leases, topic)
+	if !conn.topics[topic] {
+		return
+	}
+	delete(conn.topics, topic)
+	cm.unindex(conn, topic)
+	if cm.opts.OnInterestChange != nil {
+		cm.opts.OnInterestChange(conn, nil, []string{topic})
+	}
+	cm.publish(LeaveEvent{Conn: conn, Topic: topic, Time: cm.clock.Now()})
+}
+
+// moveSubscribers runs on the operations goroutine
+func (cm *ConnectionManager) moveSubscribers(oldTopic, newTopic string) {
+	if oldTopic == newTopic {
+		return
+	}
+	var moved []*Connection
+	cm.entities.each(oldTopic, func(conn *Connection) {
+		moved = append(moved, conn)
+	})
+	for _, conn := range moved {
+		if until, ok := conn.leases[oldTopic]; ok {
+			delete(conn.leases, oldTopic)
+			conn.leases[newTopic] = until
+		}
+		var added []string
+		if !conn.topics[newTopic] && !conn.interests[newTopic] {
+			added = []string{newTopic}
+		}
+		moveKey(conn.topics, oldTopic, newTopic)
+		moveKey(conn.interests, oldTopic, newTopic)
+		cm.entities.remove(oldTopic, conn)
+		cm.entities.add(newTopic, conn)
+		conn.swapTopic(oldTopic, newTopic)
+		if cm.opts.OnInterestChange != nil {
+			cm.opts.OnInterestChange(conn, added, []string{oldTopic})
+		}
+		cm.deliver(conn, &Message{Type: cm.opts.TopicSwapMessageType, Data: TopicSwap{From: oldTopic, To: newTopic}})
+	}
+}
+
+// swapTopic makes oldTopic stand for newTopic in the later subscription changes of the connection, runs on the
+// operations goroutine
+func (c *Connection) swapTopic(oldTopic, newTopic string) {
+	if c.swapped == nil {
+		c.swapped = make(map[string]string)
+	}
+	for from, to := range c.swapped {
+		if to == oldTopic {
+			c.swapped[from] = newTopic
+		}
+	}
+	delete(c.swapped, newTopic)
+	c.swapped[oldTopic] = newTopic
+}
+
+// swappedTopic returns the topic topic was moved to by SwapTopic, topic itself when it was not
+func (c *Connection) swappedTopic(topic string) string {
+	if moved, ok := c.swapped[topic]; ok {
+		return moved
+	}
+	return topic
+}
+
+// moveKey renames oldKey of set to newKey when present
+func moveKey(set map[string]bool, oldKey, newKey string) {
+	if set[oldKey] {
+		delete(set, oldKey)
+		set[newKey] = true
+	}
+}
+
+// receiveJoin joins or leaves the topic named by a client message
+func (cm *ConnectionManager) receiveJoin(conn *Connection, msg *Message, join bool) {
+	topic, ok := msg.Data.(string)
+	if !ok || topic == "" {
+		cm.logE(errInvalidTopic, "Ignoring join or leave message")
+		return
+	}
+	if !join {
+		cm.Leave(conn, topic)
+		return
+	}
+	if !cm.authorizeTopic(conn, topic) {
+		cm.logV("Join not authorized")
+		return
+	}
+	cm.Join(conn, topic)
+}
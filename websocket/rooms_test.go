@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPublishReachesRoomMembers(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.JoinMessageType = "join"
+		o.LeaveMessageType = "leave"
+		o.AuthorizeJoin = func(_ *Connection, topic string) bool { return topic != "secret" }
+	})
+	dial := testServer(t, cm, nil)
+	member, conn := dial()
+	outsider, _ := dial()
+	member.WriteJSON(Message{Type: "join", Data: "chat"})
+	member.WriteJSON(Message{Type: "join", Data: "secret"})
+	eventually(t, "the member to join", func() bool { return len(cm.Members("chat")) == 1 })
+	if topics := conn.Topics(); !slices.Equal(topics, []string{"chat"}) {
+		t.Fatalf("topics %v", topics)
+	}
+
+	PublishValue(cm, "chat", "said", "hi")
+	cm.Send(&Message{Type: "broadcast"})
+	if msg := readType(t, member, "said"); msg.Topic != "chat" || msg.Data != "hi" {
+		t.Fatalf("published %+v", msg)
+	}
+	var msg Message
+	if err := outsider.ReadJSON(&msg); err != nil || msg.Type != "broadcast" {
+		t.Fatalf("outsider got %+v, %v, want the broadcast only", msg, err)
+	}
+
+	member.WriteJSON(Message{Type: "leave", Data: "chat"})
+	eventually(t, "the member to leave", func() bool { return len(cm.Members("chat")) == 0 })
+}
+
+func TestInterestsKeepRoomsApart(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.InterestMessageType = "interests"
+		o.AuthorizeJoin = func(_ *Connection, topic string) bool { return topic != "secret" }
+	})
+	client, conn := testServer(t, cm, nil)()
+	cm.Join(conn, "room")
+	client.WriteJSON(Message{Type: "interests", Data: []string{"a", "secret", "room"}})
+	eventually(t, "the interests to be set", func() bool { return len(conn.Interests()) == 2 })
+	if len(cm.Members("secret")) != 0 || len(cm.Members("a")) != 1 || len(cm.Members("room")) != 1 {
+		t.Fatal("unauthorized interest subscribed")
+	}
+
+	client.WriteJSON(Message{Type: "interests", Data: []string{}})
+	eventually(t, "the interests to be cleared", func() bool { return len(conn.Interests()) == 0 })
+	if topics := conn.Topics(); !slices.Equal(topics, []string{"room"}) || len(cm.Members("room")) != 1 {
+		t.Fatalf("topics %v after clearing the interests", topics)
+	}
+}
+
+func TestSwapTopicMovesSubscribers(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.InterestMessageType = "interests"
+	})
+	client, conn := testServer(t, cm, nil)()
+	cm.Join(conn, "feed-blue")
+	client.WriteJSON(Message{Type: "interests", Data: []string{"entity-blue"}})
+	eventually(t, "the interests to be set", func() bool { return len(conn.Interests()) == 1 })
+
+	cm.SwapTopic("feed-blue", "feed-green")
+	cm.SwapTopic("entity-blue", "entity-green")
+	for _, want := range []TopicSwap{{"feed-blue", "feed-green"}, {"entity-blue", "entity-green"}} {
+		swap, _ := readType(t, client, defaultTopicSwapMessageType).Data.(map[string]interface{})
+		if swap["from"] != want.From || swap["to"] != want.To {
+			t.Fatalf("swap %v, want %+v", swap, want)
+		}
+	}
+	if topics := conn.Topics(); !slices.Equal(topics, []string{"feed-green"}) {
+		t.Fatalf("topics %v after the swap", topics)
+	}
+
+	// The old name in later messages stands for the new topic
+	client.WriteJSON(Message{Type: "interests", Data: []string{"entity-blue", "other"}})
+	cm.Publish("feed-blue", &Message{Type: "stale"})
+	cm.Publish("feed-green", &Message{Type: "fresh"})
+	var msg Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "fresh" {
+		t.Fatalf("got %+v, %v, want the message of the new topic only", msg, err)
+	}
+	eventually(t, "the old name to stand for the new topic", func() bool {
+		ids := conn.Interests()
+		slices.Sort(ids)
+		return slices.Equal(ids, []string{"entity-green", "other"})
+	})
+}
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"sync"
+)
+
+type router struct {
+	mu       sync.RWMutex
+	handlers map[string]func(*Connection, *Message)
+	fallback func(*Connection, *Message)
+	calls    map[string]callHandler // Of HandleCall
+}
+
+// Handle routes messages of msgType passed to Route to handler, replacing the earlier handler of msgType, e.g.
+//
+//	cm.Handle("chat.message", onChat)
+//	cm.HandleDefault(onUnknown)
+//	cm.ReceiveConn(w, r, cm.Route)
+func (cm *ConnectionManager) Handle(msgType string, handler func(*Connection, *Message)) {
+	cm.router.mu.Lock()
+	defer cm.router.mu.Unlock()
+	if cm.router.handlers == nil {
+		cm.router.handlers = make(map[string]func(*Connection, *Message))
+	}
+	cm.router.handlers[msgType] = handler
+}
+
+// HandleDefault handles the messages passed to Route whose type has no handler, they are dropped without one
+func (cm *ConnectionManager) HandleDefault(handler func(*Connection, *Message)) {
+	cm.router.mu.Lock()
+	defer cm.router.mu.Unlock()
+	cm.router.fallback = handler
+}
+
+// Route passes msg to the handler of its type, use it as onReceive of ReceiveConn
+func (cm *ConnectionManager) Route(conn *Connection, msg *Message) {
+	cm.router.mu.RLock()
+	handler, ok := cm.router.handlers[msg.Type]
+	if !ok {
+		handler = cm.router.fallback
+	}
+	cm.router.mu.RUnlock()
+	if handler == nil {
+		cm.logV("No handler for message type " + msg.Type)
+		return
+	}
+	handler(conn, msg)
+}
@@ -0,0 +1,39 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRouteByMessageType(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	routed := make(chan string, 4)
+	cm.Handle("chat", func(_ *Connection, msg *Message) { routed <- "chat:" + msg.Data.(string) })
+	cm.Handle("presence", func(*Connection, *Message) { routed <- "replaced" })
+	cm.Handle("presence", func(*Connection, *Message) { routed <- "presence" })
+	client, _ := testServer(t, cm, cm.Route)()
+
+	// Unknown types are dropped until there is a default handler
+	client.WriteJSON(Message{Type: "unknown"})
+	client.WriteJSON(Message{Type: "chat", Data: "hi"})
+	client.WriteJSON(Message{Type: "presence"})
+	var got []string
+	next := func() {
+		t.Helper()
+		select {
+		case r := <-routed:
+			got = append(got, r)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("routed %v", got)
+		}
+	}
+	next()
+	next()
+	cm.HandleDefault(func(_ *Connection, msg *Message) { routed <- "default:" + msg.Type })
+	client.WriteJSON(Message{Type: "unknown"})
+	next()
+	if !slices.Equal(got, []string{"chat:hi", "presence", "default:unknown"}) {
+		t.Fatalf("routed %v", got)
+	}
+}
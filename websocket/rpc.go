@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	defaultCallMessageType    = "call"
+	defaultResultMessageType  = "result"
+	defaultCallTimeout        = 10 * time.Second
+	defaultMaxConcurrentCalls = 16
+)
+
+// ErrTooManyCalls answers a call when Options.MaxConcurrentCalls calls of its connection are running
+var ErrTooManyCalls = errors.New("websocket: too many concurrent calls")
+
+// Call is the data of a call message, a request answered by a result message with the same Message.ID
+type Call struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+// CallResult is the data of a result message, Error is set when the call failed
+type CallResult struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// CallError is the error of a call that failed on the other side
+type CallError struct {
+	Method  string
+	Message string
+}
+
+func (e *CallError) Error() string {
+	return "websocket: call " + e.Method + " failed: " + e.Message
+}
+
+// HandleCall answers the call messages for method with the result of handler, replacing the earlier handler of
+// method. Call messages are not passed to onReceive once a handler is registered. Handlers run in their own
+// goroutine, at most Options.MaxConcurrentCalls per connection, with a context cancelled after
+// Options.CallTimeout or when the connection goes away. A call still running at its timeout is answered with the
+// context error and keeps counting against the limit until the handler returns.
+func (cm *ConnectionManager) HandleCall(method string,
+	handler func(ctx context.Context, conn *Connection, params interface{}) (interface{}, error)) {
+	cm.router.mu.Lock()
+	defer cm.router.mu.Unlock()
+	if cm.router.calls == nil {
+		cm.router.calls = make(map[string]callHandler)
+	}
+	cm.router.calls[method] = handler
+}
+
+// HandleTypedCall is HandleCall with the params decoded as P
+func HandleTypedCall[P, R any](cm *ConnectionManager, method string,
+	handler func(ctx context.Context, conn *Connection, params P) (R, error)) {
+	cm.HandleCall(method, func(ctx context.Context, conn *Connection, raw interface{}) (interface{}, error) {
+		var params P
+		if raw != nil {
+			b, err := json.Marshal(raw)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(b, &params); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, conn, params)
+	})
+}
+
+type callHandler func(ctx context.Context, conn *Connection, params interface{}) (interface{}, error)
+
+// isCall reports whether msg is a call message handled by HandleCall
+func (cm *ConnectionManager) isCall(msg *Message) bool {
+	if msg.Type != cm.opts.CallMessageType {
+		return false
+	}
+	cm.router.mu.RLock()
+	defer cm.router.mu.RUnlock()
+	return len(cm.router.calls) > 0
+}
+
+// answerCall runs the handler of a call message off the reader goroutine and sends its result to conn
+func (cm *ConnectionManager) answerCall(conn *Connection, msg *Message) {
+	var call Call
+	if b, err := json.Marshal(msg.Data); err == nil {
+		json.Unmarshal(b, &call)
+	}
+	cm.router.mu.RLock()
+	handler, ok := cm.router.calls[call.Method]
+	cm.router.mu.RUnlock()
+	if !ok {
+		cm.sendResult(conn, msg.ID, CallResult{Error: "unknown method " + call.Method})
+		return
+	}
+	if conn.calls.Add(1) > int32(cm.opts.MaxConcurrentCalls) {
+		conn.calls.Add(-1)
+		cm.sendResult(conn, msg.ID, CallResult{Error: ErrTooManyCalls.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(conn.Context(), cm.opts.CallTimeout)
+	done := make(chan CallResult, 1)
+	go func() {
+		defer conn.calls.Add(-1)
+		var result CallResult
+		if value, err := handler(ctx, conn, call.Params); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Result = value
+		}
+		done <- result
+	}()
+	go func() {
+		defer cancel()
+		var result CallResult
+		select {
+		case result = <-done:
+		case <-ctx.Done():
+			result.Error = ctx.Err().Error()
+		case <-conn.readDone:
+			return
+		}
+		cm.sendResult(conn, msg.ID, result)
+	}()
+}
+
+func (cm *ConnectionManager) sendResult(conn *Connection, id string, result CallResult) {
+	conn.Send(&Message{Type: cm.opts.ResultMessageType, ID: id, Data: result})
+}
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCallsRunOffReaderWithLimits(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.CallTimeout = 100 * time.Millisecond
+		o.MaxConcurrentCalls = 1
+	})
+	release := make(chan struct{})
+	cm.HandleCall("block", func(context.Context, *Connection, interface{}) (interface{}, error) {
+		<-release
+		return "done", nil
+	})
+	cm.HandleCall("wait", func(ctx context.Context, _ *Connection, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	client, _ := testServer(t, cm, nil)()
+	type result struct {
+		Type string
+		ID   string
+		Data CallResult
+	}
+
+	// The second call is read while the first blocks, and rejected over MaxConcurrentCalls
+	client.WriteJSON(Message{Type: defaultCallMessageType, ID: "1", Data: Call{Method: "block"}})
+	client.WriteJSON(Message{Type: defaultCallMessageType, ID: "2", Data: Call{Method: "block"}})
+	errs := make(map[string]string)
+	for len(errs) < 2 {
+		var msg result
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		errs[msg.ID] = msg.Data.Error
+	}
+	if errs["1"] != context.DeadlineExceeded.Error() || errs["2"] != ErrTooManyCalls.Error() {
+		t.Fatalf("call errors %v", errs)
+	}
+	close(release)
+
+	// Handlers get a context ending with CallTimeout
+	eventually(t, "the blocked call to end", func() bool {
+		client.WriteJSON(Message{Type: defaultCallMessageType, ID: "3", Data: Call{Method: "wait"}})
+		var msg result
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Data.Error == ErrTooManyCalls.Error() {
+			return false
+		}
+		if msg.ID != "3" || msg.Data.Error != context.DeadlineExceeded.Error() {
+			t.Fatalf("read %+v", msg)
+		}
+		return true
+	})
+}
@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrScratchFull is returned by scratch writes exceeding Options.ScratchBudget
+var ErrScratchFull = errors.New("websocket: scratch budget exceeded")
+
+var errScratchName = errors.New("websocket: scratch file name must not contain a path")
+
+// Scratch is the temporary file storage of a connection, e.g. for uploads received in chunks and processed once
+// complete. Its files are removed once the connection is gone and its last message was handled, so partial data
+// never outlives the session. Safe for concurrent use.
+type Scratch struct {
+	parent string
+	budget int64
+
+	mu     sync.Mutex
+	dir    string // Created on first use
+	sizes  map[string]int64
+	used   int64
+	closed bool
+}
+
+// scratchWriter counts the bytes written to a scratch file against the budget
+type scratchWriter struct {
+	scratch *Scratch
+	name    string
+	file    *os.File
+}
+
+// Scratch storage of the connection
+func (c *Connection) Scratch() *Scratch {
+	return c.scratch
+}
+
+// Create creates or truncates the file name and returns a writer to it
+func (s *Scratch) Create(name string) (io.WriteCloser, error) {
+	return s.open(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+}
+
+// Append appends data to the file name, creating it if needed
+func (s *Scratch) Append(name string, data []byte) error {
+	w, err := s.open(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	closeErr := w.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Open opens the file name for reading
+func (s *Scratch) Open(name string) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrConnectionClosed
+	}
+	if _, ok := s.sizes[name]; !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+// Remove removes the file name and returns its bytes to the budget
+func (s *Scratch) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrConnectionClosed
+	}
+	size, ok := s.sizes[name]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(s.sizes, name)
+	s.used -= size
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// Used bytes of the files of the connection
+func (s *Scratch) Used() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+func (s *Scratch) open(name string, flag int) (*scratchWriter, error) {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return nil, errScratchName
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrConnectionClosed
+	}
+	if s.dir == "" {
+		dir, err := os.MkdirTemp(s.parent, "websocket-scratch-")
+		if err != nil {
+			return nil, err
+		}
+		s.dir = dir
+		s.sizes = make(map[string]int64)
+	}
+	file, err := os.OpenFile(filepath.Join(s.dir, name), flag, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if flag&os.O_TRUNC != 0 {
+		s.used -= s.sizes[name]
+		s.sizes[name] = 0
+	} else if _, ok := s.sizes[name]; !ok {
+		s.sizes[name] = 0
+	}
+	return &scratchWriter{scratch: s, name: name, file: file}, nil
+}
+
+// reserve adds n bytes to the file name, failing when they exceed the budget or the file was removed
+func (s *Scratch) reserve(name string, n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrConnectionClosed
+	}
+	if _, ok := s.sizes[name]; !ok {
+		return &os.PathError{Op: "write", Path: name, Err: os.ErrNotExist}
+	}
+	if n > 0 && s.budget > 0 && s.used+n > s.budget {
+		return ErrScratchFull
+	}
+	s.sizes[name] += n
+	s.used += n
+	return nil
+}
+
+// close removes the files and fails any later use
+func (s *Scratch) close() error {
+	s.mu.Lock()
+	s.closed = true
+	dir := s.dir
+	s.mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
+
+func (w *scratchWriter) Write(p []byte) (int, error) {
+	if err := w.scratch.reserve(w.name, int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.file.Write(p)
+	if n < len(p) {
+		// Return the reservation of the bytes not written, the file may have been removed meanwhile
+		_ = w.scratch.reserve(w.name, int64(n-len(p)))
+	}
+	return n, err
+}
+
+func (w *scratchWriter) Close() error {
+	return w.file.Close()
+}
+
+// dropScratch removes the scratch files of conn once its reader is done
+func (cm *ConnectionManager) dropScratch(conn *Connection) {
+	cm.logE(conn.scratch.close(), "Failed to remove scratch files")
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScratchFilesWithinBudget(t *testing.T) {
+	dir := t.TempDir()
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.ScratchDir = dir
+		o.ScratchBudget = 10
+	})
+	client, conn := testServer(t, cm, nil)()
+	scratch := conn.Scratch()
+	for _, chunk := range []string{"abcd", "efgh"} {
+		if err := scratch.Append("upload", []byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := scratch.Append("upload", []byte("xyz")); !errors.Is(err, ErrScratchFull) {
+		t.Fatalf("err = %v over the budget, want ErrScratchFull", err)
+	}
+	if err := scratch.Append("../escape", []byte("a")); err == nil {
+		t.Fatal("file name with a path accepted")
+	}
+	f, err := scratch.Open("upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "abcdefgh" {
+		t.Fatalf("read %q, %v", data, err)
+	}
+	if err := scratch.Remove("upload"); err != nil || scratch.Used() != 0 {
+		t.Fatalf("removed with %v, %d bytes used", err, scratch.Used())
+	}
+
+	// Files of a connection live in its own directory until it is removed
+	if err := scratch.Append("upload", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*", "upload")); len(files) != 1 {
+		t.Fatalf("scratch files %v", files)
+	}
+	client.Close()
+	eventually(t, "the scratch files to be removed", func() bool {
+		entries, _ := os.ReadDir(dir)
+		return len(entries) == 0
+	})
+	if err := scratch.Append("upload", nil); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("err = %v after the connection was removed, want ErrConnectionClosed", err)
+	}
+}
@@ -0,0 +1,40 @@
+package websocket
+
+// SendExcept broadcasts msg to all connections but except, e.g. a chat message to everyone but its sender
+func (cm *ConnectionManager) SendExcept(msg *Message, except ...*Connection) {
+	cm.toBroker("", msg)
+	cm.SendWhere(notIn(except), msg)
+}
+
+// Multicast sends msg to conns, skipping those not receiving broadcasts yet. Local, not relayed through the Broker.
+func (cm *ConnectionManager) Multicast(msg *Message, conns ...*Connection) {
+	set := connectionSet(conns)
+	cm.SendWhere(func(conn *Connection) bool { return set[conn] }, msg)
+}
+
+// PublishExcept publishes msg to the members of topic but except, e.g. to a chat room without echoing the
+// message to its sender. It is recorded in the history of topic like Publish.
+func (cm *ConnectionManager) PublishExcept(topic string, msg *Message, except ...*Connection) {
+	published := *msg
+	published.Topic = topic
+	cm.toBroker(topic, &published)
+	cm.enqueue(&socketOperation{
+		opType: publishEntity,
+		msg:    &published,
+		ids:    []string{topic},
+		filter: notIn(except),
+	})
+}
+
+func notIn(except []*Connection) func(*Connection) bool {
+	set := connectionSet(except)
+	return func(conn *Connection) bool { return !set[conn] }
+}
+
+func connectionSet(conns []*Connection) map[*Connection]bool {
+	set := make(map[*Connection]bool, len(conns))
+	for _, conn := range conns {
+		set[conn] = true
+	}
+	return set
+}
@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestSelectiveFanOut(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	dial := testServer(t, cm, nil)
+	a, aConn := dial()
+	b, bConn := dial()
+	c, cConn := dial()
+	cm.Join(aConn, "room")
+	cm.Join(bConn, "room")
+
+	cm.SendExcept(&Message{Type: "except a"}, aConn)
+	cm.Multicast(&Message{Type: "to a and c"}, aConn, cConn)
+	cm.PublishExcept("room", &Message{Type: "room except b"}, bConn)
+	cm.Send(&Message{Type: "to all"})
+	for _, tc := range []struct {
+		name   string
+		client *gorilla.Conn
+		want   []string
+	}{
+		{"a", a, []string{"to a and c", "room except b", "to all"}},
+		{"b", b, []string{"except a", "to all"}},
+		{"c", c, []string{"except a", "to a and c", "to all"}},
+	} {
+		for _, want := range tc.want {
+			var msg Message
+			if err := tc.client.ReadJSON(&msg); err != nil || msg.Type != want {
+				t.Fatalf("%s read %+v, %v, want %s", tc.name, msg, err, want)
+			}
+			if want == "room except b" && msg.Topic != "room" {
+				t.Fatalf("published message has topic %q", msg.Topic)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrManagerClosed is returned by SendContext and SendToContext once the manager shut down
+	ErrManagerClosed = errors.New("websocket: manager shut down")
+	// ErrConnectionClosed is returned by SendToContext when the connection was removed before the message was
+	// written
+	ErrConnectionClosed = errors.New("websocket: connection closed")
+)
+
+// DropError is returned by SendToContext when the message was dropped, Reason is the reason of the DropEvent
+type DropError struct {
+	Reason string
+}
+
+func (e *DropError) Error() string {
+	return "websocket: message dropped: " + e.Reason
+}
+
+// SendContext broadcasts msg like Send but blocks until the manager accepts it or ctx is done, so producers
+// slow down to the pace of the manager instead of piling up behind a full operations queue
+func (cm *ConnectionManager) SendContext(ctx context.Context, msg *Message) error {
+	err := cm.enqueueContext(ctx, &socketOperation{
+		opType: send,
+		msg:    msg,
+		queued: cm.clock.Now(),
+	})
+	if err == nil {
+		cm.toBroker("", msg)
+	}
+	return err
+}
+
+// SendToContext sends msg to conn and waits until it is written. It returns the write error, a *DropError when
+// the message was dropped, e.g. by the write queue policy, ErrConnectionClosed, or the error of ctx.
+func (cm *ConnectionManager) SendToContext(ctx context.Context, conn *Connection, msg *Message) error {
+	result := make(chan error, 1)
+	tracked := *msg
+	tracked.delivered = func(err error) {
+		// Only the first outcome counts, e.g. a write error and the drop it causes
+		select {
+		case result <- err:
+		default:
+		}
+	}
+	err := cm.enqueueContext(ctx, &socketOperation{
+		opType: sendTo,
+		conn:   conn,
+		msg:    &tracked,
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case err = <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cm.done:
+		return ErrManagerClosed
+	}
+}
+
+func (cm *ConnectionManager) enqueueContext(ctx context.Context, op *socketOperation) error {
+	// The operations channel may still have room after shutdown, check done first
+	select {
+	case <-cm.done:
+		return ErrManagerClosed
+	default:
+	}
+	select {
+	case cm.operations <- op:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cm.done:
+		return ErrManagerClosed
+	}
+}
+
+// report passes the outcome of writing msg to SendToContext
+func (m *Message) report(err error) {
+	if m != nil && m.delivered != nil {
+		m.delivered(err)
+	}
+}
+
+// abandon reports the messages still queued to a removed connection
+func abandon(msgs []*Message) {
+	for _, msg := range msgs {
+		msg.report(ErrConnectionClosed)
+	}
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSendToContextReportsDelivery(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, conn := testServer(t, cm, nil)()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cm.SendToContext(ctx, conn, &Message{Type: "written"}); err != nil {
+		t.Fatal(err)
+	}
+	readType(t, client, "written")
+	if err := cm.SendContext(ctx, &Message{Type: "broadcast"}); err != nil {
+		t.Fatal(err)
+	}
+	readType(t, client, "broadcast")
+
+	client.Close()
+	eventually(t, "the connection to be removed", func() bool { return cm.Stats().Connections == 0 })
+	if err := cm.SendToContext(ctx, conn, &Message{Type: "late"}); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("err = %v, want ErrConnectionClosed", err)
+	}
+
+	cm.Shutdown(ctx)
+	if err := cm.SendContext(ctx, &Message{Type: "after shutdown"}); !errors.Is(err, ErrManagerClosed) {
+		t.Fatalf("err = %v, want ErrManagerClosed", err)
+	}
+}
+
+func TestSendToContextReportsDropsAndDeadlines(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.WriteQueueSize = 2
+		o.WriteQueuePolicy = DropNewest
+	})
+	_, conn, _ := blockedServer(t, cm)
+	fillQueue(t, cm, conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var dropErr *DropError
+	if err := cm.SendToContext(ctx, conn, &Message{Type: "dropped"}); !errors.As(err, &dropErr) ||
+		dropErr.Reason != "write queue full" {
+		t.Fatalf("err = %v, want the DropError of the full queue", err)
+	}
+
+	// Messages waiting behind the blocked write time out with the context
+	cm = NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+	})
+	_, conn, _ = blockedServer(t, cm)
+	short, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cm.SendToContext(short, conn, &Message{Type: "blocked"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the deadline of the context", err)
+	}
+}
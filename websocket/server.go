@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ServerOptions for standing up a standalone websocket server
+type ServerOptions struct {
+	// Path websocket endpoint is served on, defaults to "/"
+	Path string
+	// OnReceive is called for each message read from a connection
+	OnReceive func(*Message)
+	// Handler serves all other paths, optional
+	Handler http.Handler
+	// ReadHeaderTimeout for the http server, defaults to 10s
+	ReadHeaderTimeout time.Duration
+
+	// CertFile and KeyFile used by ListenAndServeTLS when autocert is not enabled
+	CertFile string
+	KeyFile  string
+
+	// AutocertHosts enables Let's Encrypt certificates for the given hosts
+	AutocertHosts []string
+	// AutocertCacheDir stores issued certificates, defaults to "autocert-cache"
+	AutocertCacheDir string
+	// AutocertHTTPAddr serves http-01 challenges and redirects to https, e.g. ":80", optional
+	AutocertHTTPAddr string
+}
+
+// ListenAndServe serves websocket connections on addr over plain http
+func (cm *ConnectionManager) ListenAndServe(addr string, opts ServerOptions) error {
+	cm.logV("Listen and serve")
+	srv := cm.newServer(addr, opts)
+	return srv.ListenAndServe()
+}
+
+// ListenAndServeTLS serves websocket connections on addr over https, using either the
+// configured certificate files or autocert
+func (cm *ConnectionManager) ListenAndServeTLS(addr string, opts ServerOptions) error {
+	cm.logV("Listen and serve TLS")
+	return serveTLS(cm.newServer(addr, opts), opts, cm.opts.Logger)
+}
+
+func serveTLS(srv *http.Server, opts ServerOptions, logger Logger) error {
+	if len(opts.AutocertHosts) == 0 {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return errors.New("websocket: CertFile and KeyFile or AutocertHosts required")
+		}
+		return srv.ListenAndServeTLS(opts.CertFile, opts.KeyFile)
+	}
+
+	cacheDir := opts.AutocertCacheDir
+	if cacheDir == "" {
+		cacheDir = "autocert-cache"
+	}
+	certManager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(opts.AutocertHosts...),
+		Cache:      autocert.DirCache(cacheDir),
+	}
+	srv.TLSConfig = certManager.TLSConfig()
+	srv.TLSConfig.MinVersion = tls.VersionTLS12
+	if opts.AutocertHTTPAddr != "" {
+		go func() {
+			err := http.ListenAndServe(opts.AutocertHTTPAddr, certManager.HTTPHandler(nil))
+			logger.Error(err, "Autocert http listener failed")
+		}()
+	}
+
+	return srv.ListenAndServeTLS("", "")
+}
+
+func (cm *ConnectionManager) newServer(addr string, opts ServerOptions) *http.Server {
+	path := opts.Path
+	if path == "" {
+		path = "/"
+	}
+	onReceive := opts.OnReceive
+	if onReceive == nil {
+		onReceive = func(*Message) {}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if opts.Handler != nil && !websocket.IsWebSocketUpgrade(r) {
+			opts.Handler.ServeHTTP(w, r)
+			return
+		}
+		cm.Receive(w, r, onReceive)
+	})
+	if opts.Handler != nil && path != "/" {
+		mux.Handle("/", opts.Handler)
+	}
+
+	return newHTTPServer(addr, mux, opts)
+}
+
+func newHTTPServer(addr string, handler http.Handler, opts ServerOptions) *http.Server {
+	readHeaderTimeout := opts.ReadHeaderTimeout
+	if readHeaderTimeout == 0 {
+		readHeaderTimeout = 10 * time.Second
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestServerRoutesUpgradesAndPlainRequests(t *testing.T) {
+	cm := NewConnectionManager()
+	got := make(chan *Message, 1)
+	srv := httptest.NewServer(cm.newServer("", ServerOptions{
+		Path:      "/ws",
+		OnReceive: func(msg *Message) { got <- msg },
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "plain "+r.URL.Path)
+		}),
+	}).Handler)
+	defer srv.Close()
+
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.WriteJSON(Message{Type: "hello"})
+	select {
+	case msg := <-got:
+		if msg.Type != "hello" {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	for _, path := range []string{"/ws", "/other"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "plain "+path {
+			t.Errorf("GET %s = %q", path, body)
+		}
+	}
+}
+
+func TestListenAndServeTLSRequiresCertificates(t *testing.T) {
+	cm := NewConnectionManager()
+	if err := cm.ListenAndServeTLS("127.0.0.1:0", ServerOptions{}); err == nil {
+		t.Fatal("served TLS without certificate")
+	}
+}
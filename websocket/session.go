@@ -0,0 +1,200 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSessionInvalid is returned for resume tokens that are unknown, expired, already rotated or revoked
+var ErrSessionInvalid = errors.New("websocket: session token invalid, expired or revoked")
+
+const defaultSessionTokenTTL = time.Hour
+
+// NewSession starts a session and returns its ID and the token the client presents to resume it. With
+// Options.Broker the token is shared with the other instances.
+func (cm *ConnectionManager) NewSession() (sessionID string, token string) {
+	now := cm.clock.Now()
+	sessionID, token = cm.sessions.start(cm.NewID(), now)
+	cm.toBrokerSessions(sessionEvent{Token: token, ID: sessionID, Expires: now.Add(cm.sessions.ttl)})
+	return sessionID, token
+}
+
+// ResumeSession validates token and rotates it, the returned token replaces the presented one which is no longer
+// accepted, so a stolen token stops working once the client resumes
+func (cm *ConnectionManager) ResumeSession(token string) (sessionID string, newToken string, err error) {
+	now := cm.clock.Now()
+	sessionID, newToken, err = cm.sessions.rotate(token, now)
+	if err == nil {
+		expires := now.Add(cm.sessions.ttl)
+		cm.toBrokerSessions(
+			sessionEvent{Token: token, Expires: expires},
+			sessionEvent{Token: newToken, ID: sessionID, Expires: expires})
+	}
+	return sessionID, newToken, err
+}
+
+// RevokeSession invalidates token, the session it belongs to can no longer be resumed
+func (cm *ConnectionManager) RevokeSession(token string) error {
+	err := cm.sessions.revoke(token)
+	if err == nil {
+		cm.toBrokerSessions(sessionEvent{Token: token, Expires: cm.clock.Now().Add(cm.sessions.ttl)})
+	}
+	return err
+}
+
+type sessionToken struct {
+	sessionID string
+	expires   time.Time
+}
+
+// sessionEvent is a token issued, or revoked when ID is empty, by another instance
+type sessionEvent struct {
+	Token   string    `json:"token"`
+	ID      string    `json:"id,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+// sessionTokens keeps exactly one valid token per session
+type sessionTokens struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	tokens    map[string]sessionToken
+	followed  map[string]bool      // Tokens merged from the last snapshot of another instance
+	revoked   map[string]time.Time // Tokens revoked by other instances until they expire, never merged again
+	nextPrune time.Time
+}
+
+func newSessionTokens(ttl time.Duration) *sessionTokens {
+	if ttl <= 0 {
+		ttl = defaultSessionTokenTTL
+	}
+	return &sessionTokens{
+		ttl:     ttl,
+		tokens:  make(map[string]sessionToken),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (s *sessionTokens) start(sessionID string, now time.Time) (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	return sessionID, s.issue(sessionID, now)
+}
+
+func (s *sessionTokens) rotate(token string, now time.Time) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.tokens[token]
+	if !ok || now.After(entry.expires) {
+		return "", "", ErrSessionInvalid
+	}
+	delete(s.tokens, token)
+	return entry.sessionID, s.issue(entry.sessionID, now), nil
+}
+
+func (s *sessionTokens) revoke(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[token]; !ok {
+		return ErrSessionInvalid
+	}
+	delete(s.tokens, token)
+	return nil
+}
+
+func (s *sessionTokens) snapshot() []SessionSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make([]SessionSnapshot, 0, len(s.tokens))
+	for token, entry := range s.tokens {
+		sessions = append(sessions, SessionSnapshot{ID: entry.sessionID, Token: token, Expires: entry.expires})
+	}
+	return sessions
+}
+
+func (s *sessionTokens) restore(sessions []SessionSnapshot, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]sessionToken, len(sessions))
+	s.followed = nil
+	for _, session := range sessions {
+		if now.After(session.Expires) {
+			continue
+		}
+		s.tokens[session.Token] = sessionToken{sessionID: session.ID, expires: session.Expires}
+	}
+}
+
+// merge adds the tokens of the snapshot of another instance and removes those of its previous snapshot that are
+// gone, keeping the tokens issued here or shared through the broker
+func (s *sessionTokens) merge(sessions []SessionSnapshot, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	followed := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		if _, revoked := s.revoked[session.Token]; revoked || now.After(session.Expires) {
+			continue
+		}
+		s.tokens[session.Token] = sessionToken{sessionID: session.ID, expires: session.Expires}
+		followed[session.Token] = true
+	}
+	for token := range s.followed {
+		if !followed[token] {
+			delete(s.tokens, token)
+		}
+	}
+	s.followed = followed
+}
+
+// apply adds and removes the tokens issued and revoked by another instance
+func (s *sessionTokens) apply(events []sessionEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range events {
+		if event.ID == "" {
+			delete(s.tokens, event.Token)
+			delete(s.followed, event.Token)
+			s.revoked[event.Token] = event.Expires
+			continue
+		}
+		s.tokens[event.Token] = sessionToken{sessionID: event.ID, expires: event.Expires}
+	}
+}
+
+// issue must be called with mu held
+func (s *sessionTokens) issue(sessionID string, now time.Time) string {
+	token := randomID()
+	s.tokens[token] = sessionToken{sessionID: sessionID, expires: now.Add(s.ttl)}
+	return token
+}
+
+// prune must be called with mu held
+func (s *sessionTokens) prune(now time.Time) {
+	if now.Before(s.nextPrune) {
+		return
+	}
+	for token, entry := range s.tokens {
+		if now.After(entry.expires) {
+			delete(s.tokens, token)
+		}
+	}
+	for token, expires := range s.revoked {
+		if now.After(expires) {
+			delete(s.revoked, token)
+		}
+	}
+	s.nextPrune = now.Add(s.ttl / 10)
+}
+
+func randomID() string {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
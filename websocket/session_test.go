@@ -0,0 +1,34 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionTokensRotateAndRevoke(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) { o.SessionTokenTTL = time.Hour })
+	id, token := cm.NewSession()
+
+	resumed, rotated, err := cm.ResumeSession(token)
+	if err != nil || resumed != id || rotated == token {
+		t.Fatalf("resume = %q, %q, %v", resumed, rotated, err)
+	}
+	if _, _, err := cm.ResumeSession(token); !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("rotated token resumed again: %v", err)
+	}
+
+	if err := cm.RevokeSession(rotated); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cm.ResumeSession(rotated); !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("revoked token resumed: %v", err)
+	}
+
+	_, token = cm.NewSession()
+	clock.Advance(time.Hour + time.Second)
+	if _, _, err := cm.ResumeSession(token); !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("expired token resumed: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package websocket
+
+import "hash/fnv"
+
+const defaultFanoutShardBuffer = 1024
+
+// ShardStats of a fan-out shard
+type ShardStats struct {
+	Shard       int
+	Connections int
+	// Queued writes and the capacity of the shard queue
+	Queued   int
+	Capacity int
+}
+
+type fanoutShard struct {
+	queue chan shardWrite
+}
+
+type shardWrite struct {
+	conn  *Connection
+	msg   *Message
+	ping  *pingFrame  // Pings conn after the writes queued before, instead of msg
+	close *closeFrame // Closes conn after the writes queued before, instead of msg
+}
+
+// Shard of the connection, the fan-out shard writing to it when FanoutShards is set
+func (c *Connection) Shard() int {
+	return c.shard
+}
+
+// ShardStats connections and queued writes per fan-out shard, empty without FanoutShards
+func (cm *ConnectionManager) ShardStats() []ShardStats {
+	stats := make([]ShardStats, len(cm.shards))
+	for i, shard := range cm.shards {
+		stats[i] = ShardStats{Shard: i, Queued: len(shard.queue), Capacity: cap(shard.queue)}
+	}
+	if len(stats) == 0 {
+		return stats
+	}
+	cm.call(func() {
+		cm.registry.Range(func(conn *Connection) {
+			stats[conn.shard].Connections++
+		})
+	})
+	return stats
+}
+
+// shardFor assigns a connection ID to a shard
+func (cm *ConnectionManager) shardFor(id string) int {
+	if cm.opts.FanoutShards <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(cm.opts.FanoutShards))
+}
+
+func (cm *ConnectionManager) startShards() {
+	for i := 0; i < cm.opts.FanoutShards; i++ {
+		shard := &fanoutShard{queue: make(chan shardWrite, cm.opts.FanoutShardBuffer)}
+		cm.shards = append(cm.shards, shard)
+		go func() {
+			for w := range shard.queue {
+				if w.close != nil {
+					cm.writeClose(w.conn, w.close)
+					go cm.enqueue(&socketOperation{opType: remove, conn: w.conn, err: w.close.err()})
+					continue
+				}
+				if w.ping != nil {
+					cm.writePing(w.conn, w.ping)
+					continue
+				}
+				if cm.holdForStream(w.conn, w.msg) {
+					continue
+				}
+				cm.release(w.msg)
+				if w.msg.stream != nil {
+					cm.streamOffShard(w.conn, w.msg)
+					continue
+				}
+				cm.writeNow(w.conn, w.msg)
+			}
+		}()
+	}
+}
+
+// queueShard queues msg to the shard of conn without blocking, a full shard queue is handled by
+// WriteQueuePolicy. Runs on the operations goroutine.
+func (cm *ConnectionManager) queueShard(conn *Connection, msg *Message) {
+	if !cm.reserve(conn, msg) {
+		return
+	}
+	shard := cm.shards[conn.shard]
+	for {
+		select {
+		case shard.queue <- shardWrite{conn: conn, msg: msg}:
+			return
+		default:
+		}
+		switch cm.opts.WriteQueuePolicy {
+		case DropNewest:
+			cm.release(msg)
+			cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "shard queue full", Time: cm.clock.Now()})
+			return
+		case DisconnectSlow:
+			cm.release(msg)
+			cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "shard queue full", Time: cm.clock.Now()})
+			cm.logV("Removing connection of a full shard")
+			cm.removeSocket(conn, ErrSlowConsumer)
+			return
+		}
+		// Drop the oldest write of the shard, it may belong to another connection of the shard
+		select {
+		case old := <-shard.queue:
+			cm.release(old.msg)
+			cm.publish(DropEvent{Conn: old.conn, Message: old.msg, Reason: "shard queue full", Time: cm.clock.Now()})
+		default:
+		}
+	}
+}
+
+// queueShardClose closes conn after the writes queued to its shard, or right away when the shard queue is full.
+// Runs on the operations goroutine.
+func (cm *ConnectionManager) queueShardClose(conn *Connection, frame *closeFrame) {
+	select {
+	case cm.shards[conn.shard].queue <- shardWrite{conn: conn, close: frame}:
+	default:
+		cm.writeClose(conn, frame)
+		cm.removeSocket(conn, frame.err())
+	}
+}
+
+// holdForStream keeps msg back while a binary stream of conn is written off the shard, so the other connections
+// of the shard are not blocked by it and conn still gets its messages in order
+func (cm *ConnectionManager) holdForStream(conn *Connection, msg *Message) bool {
+	conn.streamMu.Lock()
+	defer conn.streamMu.Unlock()
+	if !conn.streaming {
+		return false
+	}
+	if len(conn.streamHeld) >= cm.opts.WriteQueueSize {
+		cm.release(msg)
+		cm.publish(DropEvent{Conn: conn, Message: msg, Reason: "write queue full", Time: cm.clock.Now()})
+		return true
+	}
+	conn.streamHeld = append(conn.streamHeld, msg)
+	return true
+}
+
+// streamOffShard writes a binary stream of conn on its own goroutine followed by the messages held meanwhile
+func (cm *ConnectionManager) streamOffShard(conn *Connection, msg *Message) {
+	conn.streamMu.Lock()
+	conn.streaming = true
+	conn.streamMu.Unlock()
+	go func() {
+		cm.writeNow(conn, msg)
+		for {
+			conn.streamMu.Lock()
+			held := conn.streamHeld
+			conn.streamHeld = nil
+			if len(held) == 0 {
+				conn.streaming = false
+			}
+			conn.streamMu.Unlock()
+			if len(held) == 0 {
+				return
+			}
+			for _, msg := range held {
+				cm.release(msg)
+				cm.writeNow(conn, msg)
+			}
+		}
+	}()
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestShardAssignment(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.FanoutShards = 4
+	})
+	dial := testServer(t, cm, nil)
+	shards := make(map[int]int)
+	for i := 0; i < 8; i++ {
+		client, conn := dial()
+		if conn.Shard() != cm.shardFor(conn.ID()) {
+			t.Fatalf("connection on shard %d, its ID hashes to %d", conn.Shard(), cm.shardFor(conn.ID()))
+		}
+		shards[conn.Shard()]++
+		conn.Send(&Message{Type: "hello"})
+		readType(t, client, "hello")
+	}
+	stats := cm.ShardStats()
+	if len(stats) != 4 {
+		t.Fatalf("%d shards", len(stats))
+	}
+	for _, s := range stats {
+		if s.Connections != shards[s.Shard] || s.Capacity != defaultFanoutShardBuffer {
+			t.Fatalf("shard stats %+v, want %d connections", s, shards[s.Shard])
+		}
+	}
+	if stats := NewConnectionManager().ShardStats(); len(stats) != 0 {
+		t.Fatalf("shard stats %v without FanoutShards", stats)
+	}
+}
+
+func TestFullShardDoesNotBlockOperations(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.FanoutShards = 1
+		o.FanoutShardBuffer = 2
+		o.WriteQueuePolicy = DropNewest
+	})
+	testServer(t, cm, nil)() // Never reads
+	big := strings.Repeat("x", 64<<10)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2000; i++ {
+			cm.Send(&Message{Type: "big", Data: big})
+		}
+		cm.Stats()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("operations blocked by a full shard")
+	}
+	if cm.Stats().Dropped == 0 {
+		t.Fatal("nothing dropped from the full shard")
+	}
+}
+
+func TestShardStreamDoesNotParkOtherConnections(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.FanoutShards = 1
+	})
+	dial := testServer(t, cm, nil)
+	streaming, conn := dial()
+	other, _ := dial()
+	w, err := conn.NextBinaryWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Send(&Message{Type: "after"})
+	readType(t, other, "after")
+
+	w.Write([]byte("chunk"))
+	w.Close()
+	typ, data, err := streaming.ReadMessage()
+	if err != nil || typ != gorilla.BinaryMessage || string(data) != "chunk" {
+		t.Fatalf("read %d %q, %v, want the stream first", typ, data, err)
+	}
+	readType(t, streaming, "after")
+}
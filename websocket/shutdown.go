@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Shutdown stops accepting upgrades, closes all connections with 1001 (going away), waits for their close
+// handshakes and stops the goroutines of the manager. Operations queued after the connections are closed are
+// discarded. When ctx is done first the remaining connections are closed without close frame, handshakes are
+// no longer waited for and ctx.Err() is returned. Later calls do nothing.
+func (cm *ConnectionManager) Shutdown(ctx context.Context) error {
+	var err error
+	cm.shutdown.Do(func() {
+		cm.logV("Shutting down connection manager")
+		cm.closing.Store(true)
+		closed := make(chan struct{})
+		go func() {
+			cm.call(func() {
+				cm.closeAll(ctx)
+			})
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		close(cm.done)
+		<-cm.stopped
+		if err != nil {
+			cm.terminateAll()
+		} else {
+			err = cm.waitHandshakes(ctx)
+		}
+		for _, shard := range cm.shards {
+			close(shard.queue)
+		}
+	})
+	return err
+}
+
+// closeAll runs on the operations goroutine
+func (cm *ConnectionManager) closeAll(ctx context.Context) {
+	var conns []*Connection
+	cm.registry.Range(func(conn *Connection) {
+		conns = append(conns, conn)
+	})
+	frame := &closeFrame{code: websocket.CloseGoingAway, reason: "server shutting down"}
+	for _, conn := range conns {
+		if ctx.Err() == nil {
+			cm.writeClose(conn, frame)
+		} else {
+			conn.closeSent.Store(true)
+			cm.logE(conn.socket.Close(), "Failed to close socket")
+		}
+		cm.removeSocket(conn, frame.err())
+	}
+}
+
+// waitHandshakes waits for the close handshakes of removed connections until ctx is done. Runs once the
+// operations goroutine stopped, so no handshake starts meanwhile.
+func (cm *ConnectionManager) waitHandshakes(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		cm.handshakes.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// terminateAll closes the network connections closeAll did not get to before ctx of Shutdown was done. Runs
+// once the operations goroutine stopped, so the registry is no longer used by it.
+func (cm *ConnectionManager) terminateAll() {
+	cm.registry.Range(func(conn *Connection) {
+		conn.closeSent.Store(true)
+		cm.logE(conn.socket.Close(), "Failed to close socket")
+	})
+}
+
+// rejectShutdown answers the upgrade with 503 once Shutdown was called
+func (cm *ConnectionManager) rejectShutdown(w http.ResponseWriter, r *http.Request) error {
+	if !cm.closing.Load() {
+		return nil
+	}
+	return cm.reject(w, r, UpgradeRejection{
+		Status: http.StatusServiceUnavailable,
+		Reason: RejectShutdown,
+	})
+}
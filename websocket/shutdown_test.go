@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForCloseHandshakes(t *testing.T) {
+	newManager := func() *ConnectionManager {
+		cm := NewConnectionManager(func(o *Options) {
+			o.CloseTimeout = 300 * time.Millisecond
+			o.SetupTimeout = -1
+		})
+		// The client does not read, so it never answers the close frame
+		testServer(t, cm, nil)()
+		return cm
+	}
+
+	cm := newManager()
+	start := time.Now()
+	if err := cm.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("Shutdown returned after %v, before the close timeout", elapsed)
+	}
+
+	cm = newManager()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := cm.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the error of ctx", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Fatalf("Shutdown waited %v past ctx", elapsed)
+	}
+}
+
+func TestShutdownTimeoutClosesSockets(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, _ := testServer(t, cm, nil)()
+	// The operations goroutine is busy past ctx, so the connections are not removed in time
+	busy := make(chan struct{})
+	go cm.call(func() {
+		close(busy)
+		time.Sleep(200 * time.Millisecond)
+	})
+	<-busy
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := cm.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the error of ctx", err)
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := client.ReadMessage(); err == nil || isTimeout(err) {
+		t.Fatalf("err = %v, want the socket closed", err)
+	}
+}
@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// Snapshot is the restorable state of a manager. Live sockets are not part of it, clients reconnect and resume
+// their sessions against the restored state.
+type Snapshot struct {
+	Taken    time.Time         `json:"taken"`
+	Sessions []SessionSnapshot `json:"sessions"`
+}
+
+// SessionSnapshot a resumable session. With SessionReplayWindow it carries the rooms, interests and metadata of
+// the connection of the session, restored when the client resumes. Metadata values must marshal to JSON.
+type SessionSnapshot struct {
+	ID        string                 `json:"id"`
+	Token     string                 `json:"token"`
+	Expires   time.Time              `json:"expires"`
+	Topics    []string               `json:"topics,omitempty"`
+	Interests []string               `json:"interests,omitempty"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+}
+
+// SnapshotStore persists snapshots, e.g. in a file, a database row or an object store
+type SnapshotStore interface {
+	Save(ctx context.Context, data []byte) error
+	// Load returns os.ErrNotExist when there is no snapshot yet
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// Snapshot captures the current manager state
+func (cm *ConnectionManager) Snapshot() *Snapshot {
+	sessions := cm.sessions.snapshot()
+	if cm.opts.SessionReplayWindow > 0 {
+		cm.call(func() {
+			for i := range sessions {
+				cm.snapshotSession(&sessions[i])
+			}
+		})
+	}
+	return &Snapshot{
+		Taken:    cm.clock.Now(),
+		Sessions: sessions,
+	}
+}
+
+// Restore replaces the manager state with snapshot, call it on startup before serving connections
+func (cm *ConnectionManager) Restore(snapshot *Snapshot) {
+	now := cm.clock.Now()
+	cm.sessions.restore(snapshot.Sessions, now)
+	if cm.opts.SessionReplayWindow > 0 {
+		cm.call(func() {
+			for _, session := range snapshot.Sessions {
+				if !now.After(session.Expires) {
+					cm.restoreSession(session, now)
+				}
+			}
+		})
+	}
+}
+
+// follow merges snapshot of the active instance into the state of a standby, see FollowSnapshots
+func (cm *ConnectionManager) follow(snapshot *Snapshot) {
+	now := cm.clock.Now()
+	cm.sessions.merge(snapshot.Sessions, now)
+	if cm.opts.SessionReplayWindow > 0 {
+		cm.call(func() {
+			for _, session := range snapshot.Sessions {
+				if !now.After(session.Expires) {
+					cm.restoreSession(session, now)
+				}
+			}
+		})
+	}
+}
+
+// SaveSnapshot writes the current manager state to store
+func (cm *ConnectionManager) SaveSnapshot(ctx context.Context, store SnapshotStore) error {
+	data, err := json.Marshal(cm.Snapshot())
+	if err != nil {
+		return err
+	}
+	return store.Save(ctx, data)
+}
+
+// RestoreSnapshot restores the manager state from store, a missing snapshot is not an error
+func (cm *ConnectionManager) RestoreSnapshot(ctx context.Context, store SnapshotStore) error {
+	snapshot, err := loadSnapshot(ctx, store)
+	if snapshot == nil {
+		return err
+	}
+	cm.Restore(snapshot)
+	return nil
+}
+
+// loadSnapshot returns the snapshot of store, nil without error when there is none
+func loadSnapshot(ctx context.Context, store SnapshotStore) (*Snapshot, error) {
+	data, err := store.Load(ctx)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	err = json.Unmarshal(data, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// FileSnapshotStore stores snapshots in a file, replaced atomically on save
+type FileSnapshotStore string
+
+// Save writes data to a temporary file and renames it over the snapshot file
+func (path FileSnapshotStore) Save(ctx context.Context, data []byte) error {
+	tmp := string(path) + ".tmp"
+	err := os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, string(path))
+}
+
+// Load reads the snapshot file
+func (path FileSnapshotStore) Load(ctx context.Context) ([]byte, error) {
+	return os.ReadFile(string(path))
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotFileStoreKeepsSessions(t *testing.T) {
+	store := FileSnapshotStore(filepath.Join(t.TempDir(), "snapshot.json"))
+	ctx := context.Background()
+	restarted := NewConnectionManager()
+	if err := restarted.RestoreSnapshot(ctx, store); err != nil {
+		t.Fatalf("missing snapshot: %v", err)
+	}
+
+	cm := NewConnectionManager()
+	id, token := cm.NewSession()
+	if err := cm.SaveSnapshot(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.RestoreSnapshot(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if resumed, _, err := restarted.ResumeSession(token); err != nil || resumed != id {
+		t.Fatalf("resume after restore = %q, %v", resumed, err)
+	}
+}
+
+func TestSnapshotRestoresSessionRooms(t *testing.T) {
+	opts := func(o *Options) {
+		o.SetupTimeout = -1
+		o.SessionReplayWindow = time.Minute
+	}
+	before, after := NewConnectionManager(opts), NewConnectionManager(opts)
+	client, conn := testServerQuery(t, before, nil)("")
+	msg := readType(t, client, "session")
+	raw, _ := json.Marshal(msg.Data)
+	var info SessionInfo
+	json.Unmarshal(raw, &info)
+	before.Join(conn, "room")
+	conn.Set("user", "bob")
+
+	data, err := json.Marshal(before.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := &Snapshot{}
+	json.Unmarshal(data, snapshot)
+	after.Restore(snapshot)
+	after.Publish("room", &Message{Type: "missed"})
+
+	client, conn = testServerQuery(t, after, nil)("session=" + url.QueryEscape(info.Token) + "&received=0")
+	if topics := conn.Topics(); len(topics) != 1 || topics[0] != "room" {
+		t.Fatalf("topics %v after resuming", topics)
+	}
+	if user, _ := conn.Get("user"); user != "bob" {
+		t.Fatalf("user %v after resuming", user)
+	}
+	readType(t, client, "missed")
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn is the websocket transport of a Connection, the subset of *websocket.Conn of gorilla/websocket the
+// manager uses. Adapters such as the coderws package let other implementations be managed with Accept.
+// Message types are the opcodes of RFC 6455, as the constants of gorilla/websocket, and a read error caused by
+// the close frame of the peer is a *websocket.CloseError.
+type Conn interface {
+	NextReader() (messageType int, r io.Reader, err error)
+	NextWriter(messageType int) (io.WriteCloser, error)
+	WriteMessage(messageType int, data []byte) error
+	// WriteControl writes close and ping frames
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	// SetReadDeadline fails pending and future reads with a timeout error once t passes, zero disables it
+	SetReadDeadline(t time.Time) error
+	SetReadLimit(limit int64)
+	// SetPongHandler sets the handler called with the payload of pongs answering WriteControl pings
+	SetPongHandler(h func(appData string) error)
+	Subprotocol() string
+	// Close closes the network connection without close frame
+	Close() error
+}
+
+var _ Conn = (*websocket.Conn)(nil)
+
+// Pinger is implemented by transports that may not be able to ping, e.g. because they drop pongs. Connections
+// whose transport returns false from CanPing are not pinged and their reads do not time out on missing pongs.
+type Pinger interface {
+	CanPing() bool
+}
+
+// canPing tells whether socket answers the pings of Options.PingInterval with pongs
+func canPing(socket Conn) bool {
+	pinger, ok := socket.(Pinger)
+	return !ok || pinger.CanPing()
+}
+
+// Accept manages socket, a connection upgraded outside of the manager, e.g. by another websocket implementation
+// wrapped in an adapter. r is the upgraded request. The connection limit applies, the other upgrade options such
+// as OnBeforeUpgrade, AuthenticateRequest and affinity do not. The socket is closed when it is not accepted.
+func (cm *ConnectionManager) Accept(
+	r *http.Request, socket Conn, onReceive func(*Connection, *Message)) (*Connection, error) {
+	cm.logV("Accept")
+	if cm.closing.Load() {
+		cm.logE(socket.Close(), "Failed to close socket")
+		return nil, ErrManagerClosed
+	}
+	if !cm.tryAdmit() {
+		cm.rejected(RejectConnectionLimit)
+		cm.logE(socket.Close(), "Failed to close socket")
+		return nil, UpgradeRejection{
+			Status:  http.StatusServiceUnavailable,
+			Reason:  RejectConnectionLimit,
+			Message: http.StatusText(http.StatusServiceUnavailable),
+		}
+	}
+	return cm.manage(r, UpgradeDecision{Request: r}, cm.NewID(), socket, nil, onReceive), nil
+}
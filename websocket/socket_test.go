@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestAcceptAppliesConnectionLimit(t *testing.T) {
+	cm := NewConnectionManager(WithLimits(1, 0, 0), func(o *Options) {
+		o.SetupTimeout = -1
+		o.CloseTimeout = 10 * time.Millisecond
+	})
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := (&gorilla.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_, err = cm.Accept(r, socket, func(*Connection, *Message) {})
+		errs <- err
+	}))
+	defer srv.Close()
+	dial := func() (*gorilla.Conn, error) {
+		t.Helper()
+		client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+		return client, <-errs
+	}
+
+	if _, err := dial(); err != nil {
+		t.Fatal(err)
+	}
+	client, err := dial()
+	var rejection UpgradeRejection
+	if !errors.As(err, &rejection) || rejection.Reason != RejectConnectionLimit {
+		t.Fatalf("err = %v, want the connection limit rejection", err)
+	}
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatal("socket over the limit not closed")
+	}
+
+	cm.Shutdown(context.Background())
+	if _, err := dial(); !errors.Is(err, ErrManagerClosed) {
+		t.Fatalf("err = %v after shutdown, want ErrManagerClosed", err)
+	}
+}
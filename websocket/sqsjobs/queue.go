@@ -0,0 +1,130 @@
+// Package sqsjobs is a websocket.JobQueue on Amazon SQS. Instances send jobs to one queue shared with the
+// workers and receive results on a queue of their own, e.g.
+//
+//	queue := sqsjobs.New(sqs.NewFromConfig(cfg), jobsURL, resultsURL)
+//	cm := websocket.NewConnectionManager(websocket.WithJobQueue(queue, "render"))
+//
+// Workers take jobs with Next and answer with Reply.
+package sqsjobs
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// waitSeconds of each long poll
+const waitSeconds = 20
+
+// API is the part of *sqs.Client used by Queue
+type API interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput,
+		optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput,
+		optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput,
+		optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// Queue of jobs and of the results of one instance
+type Queue struct {
+	client     API
+	jobsURL    string
+	resultsURL string
+}
+
+// New queue sending to the queue at jobsURL and receiving from the queue at resultsURL, which must differ per
+// instance. Workers only use the jobs queue.
+func New(client API, jobsURL, resultsURL string) *Queue {
+	return &Queue{client: client, jobsURL: jobsURL, resultsURL: resultsURL}
+}
+
+// Push sends job to the jobs queue with the results queue of the instance as ReplyTo
+func (q *Queue) Push(ctx context.Context, job websocket.Job) error {
+	job.ReplyTo = q.resultsURL
+	return q.send(ctx, q.jobsURL, job)
+}
+
+// Results calls deliver with the results sent to the results queue until ctx is done
+func (q *Queue) Results(ctx context.Context, deliver func(websocket.JobResult)) error {
+	for {
+		bodies, err := q.receive(ctx, q.resultsURL, 10)
+		// The bodies are deleted from the queue even when receive fails, they are only delivered here
+		for _, body := range bodies {
+			var result websocket.JobResult
+			if json.Unmarshal(body, &result) == nil {
+				deliver(result)
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Next takes the oldest job, waiting until one is sent or ctx is done. The job is deleted from the queue when
+// taken, so it is not retried when the worker fails.
+func (q *Queue) Next(ctx context.Context) (websocket.Job, error) {
+	for {
+		bodies, err := q.receive(ctx, q.jobsURL, 1)
+		if err != nil {
+			return websocket.Job{}, err
+		}
+		if len(bodies) == 0 {
+			continue
+		}
+		var job websocket.Job
+		err = json.Unmarshal(bodies[0], &job)
+		return job, err
+	}
+}
+
+// Reply sends msg to the connection of job
+func (q *Queue) Reply(ctx context.Context, job websocket.Job, msg *websocket.Message) error {
+	return q.send(ctx, job.ReplyTo, websocket.JobResult{ConnectionID: job.ConnectionID, Message: msg})
+}
+
+func (q *Queue) send(ctx context.Context, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = q.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(url),
+		MessageBody: aws.String(string(body)),
+	})
+	return err
+}
+
+// receive long polls up to max messages of the queue at url and deletes them. It returns the bodies of the
+// messages deleted, those that could not be deleted are received again after their visibility timeout.
+func (q *Queue) receive(ctx context.Context, url string, max int32) ([][]byte, error) {
+	out, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(url),
+		MaxNumberOfMessages: max,
+		WaitTimeSeconds:     waitSeconds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bodies := make([][]byte, 0, len(out.Messages))
+	var failed error
+	for _, msg := range out.Messages {
+		_, err = q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(url),
+			ReceiptHandle: msg.ReceiptHandle,
+		})
+		if err != nil {
+			failed = err
+			continue
+		}
+		bodies = append(bodies, []byte(aws.ToString(msg.Body)))
+	}
+	return bodies, failed
+}
@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const defaultStallThreshold = 2 * time.Minute
+
+// ReadStats progress of the read loop of a connection
+type ReadStats struct {
+	Messages int64
+	Pongs    int64
+	// LastRead time of the last message or pong, or of the upgrade when nothing was read yet
+	LastRead time.Time
+}
+
+type readCounters struct {
+	messages atomic.Int64
+	pongs    atomic.Int64
+	lastRead atomic.Int64 // Unix nanoseconds
+}
+
+// ReadStats of the connection read loop
+func (c *Connection) ReadStats() ReadStats {
+	return ReadStats{
+		Messages: c.reads.messages.Load(),
+		Pongs:    c.reads.pongs.Load(),
+		LastRead: time.Unix(0, c.reads.lastRead.Load()),
+	}
+}
+
+func (c *Connection) readProgress(now time.Time, pong bool) {
+	if pong {
+		c.reads.pongs.Add(1)
+	} else {
+		c.reads.messages.Add(1)
+	}
+	c.reads.lastRead.Store(now.UnixNano())
+}
+
+// StalledConnections connections whose read loop has not read a message or pong for StallThreshold although
+// their transport is still open
+func (cm *ConnectionManager) StalledConnections() []*Connection {
+	var stalled []*Connection
+	cm.call(func() {
+		deadline := cm.clock.Now().Add(-cm.opts.StallThreshold)
+		cm.registry.Range(func(conn *Connection) {
+			if conn.ReadStats().LastRead.Before(deadline) {
+				stalled = append(stalled, conn)
+			}
+		})
+	})
+	return stalled
+}
+
+// closeStalled terminates stalled connections every half StallThreshold
+func (cm *ConnectionManager) closeStalled() {
+	ticker := cm.clock.NewTicker(cm.opts.StallThreshold / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		for _, conn := range cm.StalledConnections() {
+			cm.logV("Terminating stalled connection")
+			cm.Terminate(conn)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStalledConnections(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.StallThreshold = time.Minute
+	})
+	client, conn := testServer(t, cm, nil)()
+	client.WriteJSON(Message{Type: "hello"})
+	eventually(t, "the message to be read", func() bool { return conn.ReadStats().Messages == 1 })
+	if stats := conn.ReadStats(); !stats.LastRead.Equal(clock.Now()) || stats.Pongs != 0 {
+		t.Fatalf("read stats %+v", stats)
+	}
+
+	clock.Advance(20 * time.Second)
+	if stalled := cm.StalledConnections(); len(stalled) != 0 {
+		t.Fatalf("%d stalled connections before StallThreshold", len(stalled))
+	}
+	clock.Advance(20 * time.Second)
+	client.WriteJSON(Message{Type: "still alive"})
+	eventually(t, "the second message to be read", func() bool { return conn.ReadStats().Messages == 2 })
+
+	clock.Advance(time.Minute + time.Second)
+	if stalled := cm.StalledConnections(); len(stalled) != 1 || stalled[0] != conn {
+		t.Fatalf("stalled connections %v", stalled)
+	}
+}
+
+func TestCloseStalled(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.StallThreshold = time.Minute
+		o.CloseStalled = true
+	})
+	client, _ := testServer(t, cm, nil)()
+	clock.Advance(30 * time.Second)
+	clock.Advance(time.Minute)
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatal("stalled connection not terminated")
+	}
+	eventually(t, "the stalled connection to be removed", func() bool { return cm.Stats().Connections == 0 })
+}
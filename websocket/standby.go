@@ -0,0 +1,49 @@
+package websocket
+
+import (
+	"context"
+	"time"
+)
+
+// ReplicateSnapshots saves the manager state to store every interval until ctx is done. Run it on the active
+// instance of an active/standby pair sharing store.
+func (cm *ConnectionManager) ReplicateSnapshots(ctx context.Context, store SnapshotStore, interval time.Duration) error {
+	ticker := cm.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+			cm.logE(cm.SaveSnapshot(ctx, store), "Failed to replicate snapshot")
+		}
+	}
+}
+
+// FollowSnapshots merges the manager state from store every interval, keeping a standby instance warm. It
+// returns nil once the first client connects to the standby, which promotes it to active: clients failing over
+// resume their sessions against the last replicated state, back in their rooms. Tokens the standby issued itself
+// are kept. With SessionReplayWindow the sessions of the snapshot are detached on the standby and keep the
+// publishes to their rooms for the resuming clients. Share an Options.Broker with the active instance so these
+// publishes reach the standby and the session tokens rotated after the last snapshot stay valid. Start
+// ReplicateSnapshots on it afterwards to protect the new active instance.
+func (cm *ConnectionManager) FollowSnapshots(ctx context.Context, store SnapshotStore, interval time.Duration) error {
+	ticker := cm.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if cm.load.connections.Load() > 0 {
+			cm.logV("Standby received a connection, promoted to active")
+			return nil
+		}
+		snapshot, err := loadSnapshot(ctx, store)
+		cm.logE(err, "Failed to follow snapshot")
+		if snapshot != nil {
+			cm.follow(snapshot)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
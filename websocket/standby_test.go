@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStandbyResumesFollowedSessions(t *testing.T) {
+	broker := NewMemoryBroker()
+	opts := func(o *Options) {
+		o.SetupTimeout = -1
+		o.SessionReplayWindow = time.Minute
+		o.Broker = broker
+	}
+	active, standby := NewConnectionManager(opts), NewConnectionManager(opts)
+	store := FileSnapshotStore(filepath.Join(t.TempDir(), "snapshot.json"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hasToken := func(cm *ConnectionManager, token string) func() bool {
+		return func() bool {
+			return slices.ContainsFunc(cm.Snapshot().Sessions, func(s SessionSnapshot) bool { return s.Token == token })
+		}
+	}
+
+	client, conn := testServerQuery(t, active, nil)("")
+	msg := readType(t, client, "session")
+	raw, _ := json.Marshal(msg.Data)
+	var info SessionInfo
+	json.Unmarshal(raw, &info)
+	active.Join(conn, "room")
+	conn.Topics()
+	if err := active.SaveSnapshot(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+
+	_, own := standby.NewSession()
+	promoted := make(chan error, 1)
+	go func() { promoted <- standby.FollowSnapshots(ctx, store, 20*time.Millisecond) }()
+	eventually(t, "the standby to follow the snapshot", hasToken(standby, info.Token))
+	if !hasToken(standby, own)() {
+		t.Fatal("token issued by the standby lost merging the snapshot")
+	}
+
+	// Rotated after the last snapshot, the token reaches the standby through the broker
+	_, rotated, err := active.ResumeSession(info.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the rotated token to reach the standby", hasToken(standby, rotated))
+	active.Publish("room", &Message{Type: "missed"})
+
+	client, conn = testServerQuery(t, standby, nil)("session=" + url.QueryEscape(rotated) + "&received=0")
+	if topics := conn.Topics(); len(topics) != 1 || topics[0] != "room" {
+		t.Fatalf("topics %v after failing over", topics)
+	}
+	msg = readType(t, client, "session")
+	raw, _ = json.Marshal(msg.Data)
+	json.Unmarshal(raw, &info)
+	if !info.Resumed {
+		t.Fatal("session not resumed on the standby")
+	}
+	readType(t, client, "missed")
+	select {
+	case err := <-promoted:
+		if err != nil {
+			t.Fatalf("FollowSnapshots = %v, want nil once promoted", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("standby not promoted by the connection")
+	}
+}
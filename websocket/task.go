@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	defaultTaskProgressMessageType = "task.progress"
+	defaultTaskResultMessageType   = "task.result"
+)
+
+// Task is a long running command of a connection, e.g. a report, reporting progress and a final result with
+// its ID in Message.ID
+type Task struct {
+	cm     *ConnectionManager
+	conn   *Connection
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type taskRegistry struct {
+	mu   sync.Mutex
+	byID map[taskKey]*Task
+}
+
+// taskKey scopes task IDs to their connection, clients choose them independently
+type taskKey struct {
+	conn *Connection
+	id   string
+}
+
+// StartTask runs run in a new goroutine and sends its result, or error, to conn as a TaskResultMessageType
+// message with CallResult data. id ties the messages of the task to a request, e.g. the ID of the message
+// starting it, a new ID is used when empty. The context of the task is cancelled when the connection goes
+// away, when the client sends a TaskCancelMessageType message with the ID, or with Cancel.
+func (cm *ConnectionManager) StartTask(conn *Connection, id string, run func(task *Task) (interface{}, error)) *Task {
+	if id == "" {
+		id = cm.NewID()
+	}
+	ctx, cancel := context.WithCancel(conn.Context())
+	task := &Task{cm: cm, conn: conn, id: id, ctx: ctx, cancel: cancel}
+	cm.tasks.mu.Lock()
+	if cm.tasks.byID == nil {
+		cm.tasks.byID = make(map[taskKey]*Task)
+	}
+	cm.tasks.byID[taskKey{conn: conn, id: id}] = task
+	cm.tasks.mu.Unlock()
+	go func() {
+		select {
+		case <-conn.readDone:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go func() {
+		defer task.end()
+		value, err := run(task)
+		result := CallResult{Result: value}
+		if err != nil {
+			result = CallResult{Error: err.Error()}
+		}
+		conn.Send(&Message{Type: cm.opts.TaskResultMessageType, ID: id, Data: result})
+	}()
+	return task
+}
+
+// ID of the task, sent with its messages
+func (t *Task) ID() string {
+	return t.id
+}
+
+// Context is cancelled when the task is to stop early
+func (t *Task) Context() context.Context {
+	return t.ctx
+}
+
+// Progress sends data to the connection of the task as a TaskProgressMessageType message, e.g. a percentage
+func (t *Task) Progress(data interface{}) {
+	t.conn.Send(&Message{Type: t.cm.opts.TaskProgressMessageType, ID: t.id, Data: data})
+}
+
+// Cancel cancels the context of the task
+func (t *Task) Cancel() {
+	t.cancel()
+}
+
+// end forgets the task once run returned
+func (t *Task) end() {
+	t.cancel()
+	t.cm.tasks.mu.Lock()
+	defer t.cm.tasks.mu.Unlock()
+	key := taskKey{conn: t.conn, id: t.id}
+	if t.cm.tasks.byID[key] == t {
+		delete(t.cm.tasks.byID, key)
+	}
+}
+
+// cancelTask cancels the task of conn named by a cancel message
+func (cm *ConnectionManager) cancelTask(conn *Connection, msg *Message) {
+	cm.tasks.mu.Lock()
+	task, ok := cm.tasks.byID[taskKey{conn: conn, id: msg.ID}]
+	cm.tasks.mu.Unlock()
+	if ok {
+		task.Cancel()
+	}
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// taskMessage is a progress or result message of a task
+type taskMessage struct {
+	Type string
+	ID   string
+	Data interface{}
+}
+
+// readTask reads a task message of msgType from client
+func readTask(t *testing.T, client *gorilla.Conn, msgType string) taskMessage {
+	t.Helper()
+	var msg taskMessage
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != msgType {
+		t.Fatalf("read %+v, %v, want %s", msg, err, msgType)
+	}
+	return msg
+}
+
+func TestTaskProgressResultsAndCancel(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.TaskCancelMessageType = "task.cancel"
+	})
+	cancelled := make(chan string, 1)
+	client, _ := testServer(t, cm, func(conn *Connection, msg *Message) {
+		cm.StartTask(conn, msg.ID, func(task *Task) (interface{}, error) {
+			switch msg.Type {
+			case "report":
+				task.Progress(50)
+				return "done", nil
+			case "fail":
+				return nil, errors.New("boom")
+			}
+			task.Progress("started")
+			<-task.Context().Done()
+			cancelled <- task.ID()
+			return nil, task.Context().Err()
+		})
+	})()
+
+	client.WriteJSON(Message{Type: "report", ID: "r1"})
+	if msg := readTask(t, client, defaultTaskProgressMessageType); msg.ID != "r1" || msg.Data != 50.0 {
+		t.Fatalf("progress %+v", msg)
+	}
+	if msg := readTask(t, client, defaultTaskResultMessageType); msg.ID != "r1" ||
+		msg.Data.(map[string]interface{})["result"] != "done" {
+		t.Fatalf("result %+v", msg)
+	}
+	client.WriteJSON(Message{Type: "fail", ID: "f1"})
+	if msg := readTask(t, client, defaultTaskResultMessageType); msg.Data.(map[string]interface{})["error"] != "boom" {
+		t.Fatalf("result %+v", msg)
+	}
+
+	client.WriteJSON(Message{Type: "wait", ID: "w1"})
+	readTask(t, client, defaultTaskProgressMessageType)
+	client.WriteJSON(Message{Type: "task.cancel", ID: "w1"})
+	if id := <-cancelled; id != "w1" {
+		t.Fatalf("cancelled %s", id)
+	}
+	if msg := readTask(t, client, defaultTaskResultMessageType); msg.Data.(map[string]interface{})["error"] != "context canceled" {
+		t.Fatalf("result %+v", msg)
+	}
+
+	// Tasks are cancelled when their connection is removed
+	client.WriteJSON(Message{Type: "wait", ID: "w2"})
+	readTask(t, client, defaultTaskProgressMessageType)
+	client.Close()
+	select {
+	case id := <-cancelled:
+		if id != "w2" {
+			t.Fatalf("cancelled %s", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("task not cancelled on disconnect")
+	}
+}
+
+func TestTaskCancelKeyedByConnection(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.TaskCancelMessageType = "task.cancel"
+	})
+	dial := testServer(t, cm, nil)
+	_, first := dial()
+	client, second := dial()
+	cancelled := make(chan *Connection, 2)
+	for _, conn := range []*Connection{first, second} {
+		cm.StartTask(conn, "1", func(task *Task) (interface{}, error) {
+			<-task.Context().Done()
+			cancelled <- conn
+			return nil, nil
+		})
+	}
+	client.WriteJSON(Message{Type: "task.cancel", ID: "1"})
+	select {
+	case conn := <-cancelled:
+		if conn != second {
+			t.Fatal("cancelled the task of another connection with the same ID")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("task not cancelled")
+	}
+}
@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+// TCPOptions tunes the TCP connection under each upgraded socket. Zero values keep the settings of the listener
+// and the kernel.
+type TCPOptions struct {
+	// Delay disables TCP_NODELAY so small frames are coalesced by Nagle's algorithm, for bulk streaming. Go
+	// enables TCP_NODELAY by default, which suits low latency deployments.
+	Delay bool
+	// KeepAlive idle time before the first SO_KEEPALIVE probe, negative disables keepalive probes
+	KeepAlive time.Duration
+	// KeepAliveInterval between unanswered keepalive probes
+	KeepAliveInterval time.Duration
+	// KeepAliveCount unanswered keepalive probes before the connection is dropped
+	KeepAliveCount int
+	// ReadBuffer and WriteBuffer sizes of the kernel socket buffers in bytes, SO_RCVBUF and SO_SNDBUF
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// netConner is implemented by sockets exposing their network connection, e.g. *websocket.Conn of gorilla
+type netConner interface {
+	NetConn() net.Conn
+}
+
+// netConnOf returns the network connection of socket, nil when the transport does not expose it
+func netConnOf(socket Conn) net.Conn {
+	switch s := socket.(type) {
+	case *flushingSocket:
+		return s.conn.Conn
+	case netConner:
+		return s.NetConn()
+	}
+	return nil
+}
+
+// tuneSocket applies Options.TCP and Options.OnNetConn to the network connection of socket
+func (cm *ConnectionManager) tuneSocket(socket Conn) {
+	netConn := netConnOf(socket)
+	if netConn == nil {
+		return
+	}
+	cm.logE(cm.opts.TCP.apply(netConn), "Failed to set TCP options")
+	if cm.opts.OnNetConn != nil {
+		cm.opts.OnNetConn(netConn)
+	}
+}
+
+// apply sets the options on the TCP connection under netConn, connections that are not TCP are left as is
+func (o *TCPOptions) apply(netConn net.Conn) error {
+	if o.isZero() {
+		return nil
+	}
+	if tlsConn, ok := netConn.(*tls.Conn); ok {
+		netConn = tlsConn.NetConn()
+	}
+	tcpConn, ok := netConn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	if o.Delay {
+		errs = append(errs, tcpConn.SetNoDelay(false))
+	}
+	if o.KeepAlive != 0 || o.KeepAliveInterval != 0 || o.KeepAliveCount != 0 {
+		count := o.KeepAliveCount
+		if count <= 0 {
+			count = -1
+		}
+		errs = append(errs, tcpConn.SetKeepAliveConfig(net.KeepAliveConfig{
+			Enable:   o.KeepAlive >= 0,
+			Idle:     keepOrUnchanged(o.KeepAlive),
+			Interval: keepOrUnchanged(o.KeepAliveInterval),
+			Count:    count,
+		}))
+	}
+	if o.ReadBuffer > 0 {
+		errs = append(errs, tcpConn.SetReadBuffer(o.ReadBuffer))
+	}
+	if o.WriteBuffer > 0 {
+		errs = append(errs, tcpConn.SetWriteBuffer(o.WriteBuffer))
+	}
+	return errors.Join(errs...)
+}
+
+func (o *TCPOptions) isZero() bool {
+	return *o == TCPOptions{}
+}
+
+// keepOrUnchanged maps a zero setting to the negative value net.KeepAliveConfig leaves unchanged
+func keepOrUnchanged(value time.Duration) time.Duration {
+	if value <= 0 {
+		return -1
+	}
+	return value
+}
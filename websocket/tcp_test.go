@@ -0,0 +1,46 @@
+package websocket
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPOptionsAppliedToNetConn(t *testing.T) {
+	tcp := TCPOptions{
+		Delay:             true,
+		KeepAlive:         30 * time.Second,
+		KeepAliveInterval: 5 * time.Second,
+		KeepAliveCount:    3,
+		ReadBuffer:        1 << 16,
+		WriteBuffer:       1 << 16,
+	}
+	for _, flush := range []time.Duration{0, time.Millisecond} {
+		netConns := make(chan net.Conn, 1)
+		cm := NewConnectionManager(WithTCP(tcp), WithFlushInterval(flush), func(o *Options) {
+			o.SetupTimeout = -1
+			o.OnNetConn = func(conn net.Conn) { netConns <- conn }
+		})
+		testServer(t, cm, nil)()
+		select {
+		case conn := <-netConns:
+			tcpConn, ok := conn.(*net.TCPConn)
+			if !ok {
+				t.Fatalf("OnNetConn got %T with FlushInterval %v, want the TCP connection", conn, flush)
+			}
+			if err := tcp.apply(tcpConn); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnNetConn not called with FlushInterval %v", flush)
+		}
+	}
+
+	// Connections that are not TCP are left as is
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	if err := tcp.apply(server); err != nil {
+		t.Fatalf("apply to a pipe: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+type messageTemplate struct {
+	msgType string
+	text    *template.Template
+}
+
+type templates struct {
+	mu     sync.RWMutex
+	byName map[string]*messageTemplate
+}
+
+type templateSend struct {
+	topic string // Of PublishTemplate, empty for SendTemplate
+	name  string
+	data  interface{}
+}
+
+// TemplateContext is the value templates are executed with
+type TemplateContext struct {
+	// Data passed to SendTemplate
+	Data interface{}
+	// Vars of the receiving connection from Options.TemplateVars
+	Vars map[string]interface{}
+	// Locale of the receiving connection
+	Locale string
+}
+
+// RegisterTemplate parses text as a text/template rendering the data of messages of msgType, replacing any
+// template registered under name
+func (cm *ConnectionManager) RegisterTemplate(name string, msgType string, text string) error {
+	parsed, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return err
+	}
+	cm.templates.mu.Lock()
+	defer cm.templates.mu.Unlock()
+	if cm.templates.byName == nil {
+		cm.templates.byName = make(map[string]*messageTemplate)
+	}
+	cm.templates.byName[name] = &messageTemplate{msgType: msgType, text: parsed}
+	return nil
+}
+
+// SendTemplate broadcasts the template registered under name, rendered for each connection with data and the
+// connection variables, e.g. user name and locale
+func (cm *ConnectionManager) SendTemplate(name string, data interface{}) error {
+	if cm.template(name) == nil {
+		return fmt.Errorf("websocket: unknown template %q", name)
+	}
+	cm.toBrokerTemplate("", name, data)
+	cm.enqueue(&socketOperation{
+		opType:   sendTemplate,
+		template: &templateSend{name: name, data: data},
+	})
+	return nil
+}
+
+// PublishTemplate sends the template registered under name to the members of topic like SendTemplate, with
+// Message.Topic set to topic. The rendered messages are not kept in Options.History.
+func (cm *ConnectionManager) PublishTemplate(topic string, name string, data interface{}) error {
+	if cm.template(name) == nil {
+		return fmt.Errorf("websocket: unknown template %q", name)
+	}
+	cm.toBrokerTemplate(topic, name, data)
+	cm.enqueue(&socketOperation{
+		opType:   sendTemplate,
+		template: &templateSend{topic: topic, name: name, data: data},
+	})
+	return nil
+}
+
+func (cm *ConnectionManager) template(name string) *messageTemplate {
+	cm.templates.mu.RLock()
+	defer cm.templates.mu.RUnlock()
+	return cm.templates.byName[name]
+}
+
+// render executes the template for conn
+func (cm *ConnectionManager) render(tmpl *messageTemplate, conn *Connection, data interface{}) (*Message, error) {
+	ctx := TemplateContext{Data: data, Locale: conn.Locale()}
+	if cm.opts.TemplateVars != nil {
+		ctx.Vars = cm.opts.TemplateVars(conn)
+	}
+	var b strings.Builder
+	err := tmpl.text.Execute(&b, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Type: tmpl.msgType, Data: b.String()}, nil
+}
+
+// sendTemplateMessage runs on the operations goroutine
+func (cm *ConnectionManager) sendTemplateMessage(send *templateSend) {
+	tmpl := cm.template(send.name)
+	if tmpl == nil {
+		return
+	}
+	deliver := func(conn *Connection) {
+		if conn.state != stateReady {
+			return
+		}
+		msg, err := cm.render(tmpl, conn, send.data)
+		if err != nil {
+			cm.logE(err, "Failed to render template")
+			return
+		}
+		msg.Topic = send.topic
+		cm.deliver(conn, msg)
+	}
+	if send.topic == "" {
+		cm.registry.Range(deliver)
+		return
+	}
+	var members []*Connection
+	cm.entities.each(send.topic, func(conn *Connection) {
+		members = append(members, conn)
+	})
+	for _, conn := range members {
+		deliver(conn)
+	}
+}
@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestSendTemplatePerConnection(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.TemplateVars = func(conn *Connection) map[string]interface{} {
+			name, _ := conn.Get("name")
+			return map[string]interface{}{"name": name}
+		}
+	})
+	if err := cm.RegisterTemplate("bad", "x", "{{.Data"); err == nil {
+		t.Fatal("invalid template registered")
+	}
+	if err := cm.RegisterTemplate("welcome", "greeting", `{{if eq .Locale "pt"}}olá{{else}}hi{{end}} {{.Vars.name}}, {{.Data}}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.SendTemplate("missing", nil); err == nil {
+		t.Fatal("unknown template sent")
+	}
+	dial := testServer(t, cm, nil)
+	alice, aliceConn := dial()
+	aliceConn.Set("name", "alice")
+	bruno, brunoConn := dial()
+	brunoConn.Set("name", "bruno")
+	brunoConn.SetLocale("pt")
+
+	if err := cm.SendTemplate("welcome", "3 new"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readType(t, alice, "greeting"); msg.Data != "hi alice, 3 new" {
+		t.Fatalf("alice got %v", msg.Data)
+	}
+	if msg := readType(t, bruno, "greeting"); msg.Data != "olá bruno, 3 new" {
+		t.Fatalf("bruno got %v", msg.Data)
+	}
+}
+
+func TestPublishTemplateThroughBroker(t *testing.T) {
+	broker := NewMemoryBroker()
+	local := NewConnectionManager(WithBroker(broker))
+	remote := NewConnectionManager(WithBroker(broker))
+	for _, cm := range []*ConnectionManager{local, remote} {
+		if err := cm.RegisterTemplate("shipped", "order", "shipped {{.Data}}"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	localClient, localConn := testServer(t, local, nil)()
+	remoteClient, remoteConn := testServer(t, remote, nil)()
+	outsider, _ := testServer(t, remote, nil)()
+	local.Join(localConn, "orders")
+	remote.Join(remoteConn, "orders")
+	localConn.Topics()
+	remoteConn.Topics()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := local.PublishTemplate("orders", "shipped", 42); err != nil {
+		t.Fatal(err)
+	}
+	for _, client := range []*gorilla.Conn{localClient, remoteClient} {
+		msg := readType(t, client, "order")
+		if msg.Topic != "orders" || msg.Data != "shipped 42" {
+			t.Fatalf("got %+v", msg)
+		}
+	}
+	expectNone(t, outsider, 200*time.Millisecond)
+}
@@ -0,0 +1,46 @@
+package websocket
+
+// fanout delivers one message to many connections, applying Options.Transform per subscriber and reusing
+// the transformed message for subscribers sharing a TransformKey
+type fanout struct {
+	cm    *ConnectionManager
+	msg   *Message
+	cache map[string]*Message
+	skew  *skewSample // Set by measure
+}
+
+func (cm *ConnectionManager) newFanout(msg *Message) *fanout {
+	return &fanout{cm: cm, msg: msg}
+}
+
+// deliver runs on the operations goroutine
+func (f *fanout) deliver(conn *Connection) {
+	msg := f.transform(conn)
+	if msg == nil {
+		return
+	}
+	if f.skew != nil {
+		f.cm.skew.expect(f.skew, msg)
+	}
+	f.cm.deliver(conn, msg)
+}
+
+func (f *fanout) transform(conn *Connection) *Message {
+	transform := f.cm.opts.Transform
+	if transform == nil {
+		return f.msg
+	}
+	if f.cm.opts.TransformKey == nil {
+		return transform(conn, f.msg)
+	}
+	key := f.cm.opts.TransformKey(conn)
+	if msg, ok := f.cache[key]; ok {
+		return msg
+	}
+	msg := transform(conn, f.msg)
+	if f.cache == nil {
+		f.cache = make(map[string]*Message)
+	}
+	f.cache[key] = msg
+	return msg
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestTransformPerSubscriberGroup(t *testing.T) {
+	var calls atomic.Int32
+	role := func(conn *Connection) string {
+		role, _ := conn.Get("role")
+		return role.(string)
+	}
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.Transform = func(conn *Connection, msg *Message) *Message {
+			calls.Add(1)
+			switch role(conn) {
+			case "admin":
+				return msg
+			case "banned":
+				return nil
+			}
+			return &Message{Type: msg.Type, Data: "redacted"}
+		}
+		o.TransformKey = role
+	})
+	dial := testServer(t, cm, nil)
+	clients := make(map[string]*gorilla.Conn)
+	for _, name := range []string{"admin", "guest", "guest2", "banned"} {
+		client, conn := dial()
+		conn.Set("role", strings.TrimSuffix(name, "2"))
+		clients[name] = client
+	}
+
+	cm.Send(&Message{Type: "doc", Data: "secret"})
+	for name, want := range map[string]string{"admin": "secret", "guest": "redacted", "guest2": "redacted"} {
+		if msg := readType(t, clients[name], "doc"); msg.Data != want {
+			t.Fatalf("%s got %v, want %s", name, msg.Data, want)
+		}
+	}
+	expectNone(t, clients["banned"], 100*time.Millisecond)
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("Transform called %d times, want once per role", n)
+	}
+}
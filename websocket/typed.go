@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// MessageTyper is implemented by the message structs of a TypedManager that carry a type, so the features that
+// only need the type, e.g. ReadyMessageType and TypeStats, see it
+type MessageTyper interface {
+	MessageType() string
+}
+
+// TypedManager is a ConnectionManager exchanging the user defined message struct T as the whole frame instead of
+// the Message envelope, e.g.
+//
+//	type ChatMessage struct {
+//		Room string `json:"room"`
+//		Text string `json:"text"`
+//	}
+//	cm := NewTypedManager[ChatMessage]()
+//	cm.Receive(w, r, func(msg *ChatMessage) { cm.Send(msg) })
+//
+// Messages sent by the manager itself, e.g. maintenance notices, still use the Message envelope. Client messages
+// are decoded into T whole, so the features reading the data of the envelope are unavailable: credits of
+// FlowControl, join and leave, interests, cursors, task cancellation, calls, jobs and AuthMessageType. Client
+// messages whose MessageType is one of their types go to these features instead of onReceive, without the data
+// they expect.
+type TypedManager[T any] struct {
+	*ConnectionManager
+}
+
+// NewTypedManager manager encoding T as JSON, options setting a Codec are overridden
+func NewTypedManager[T any](options ...Option) *TypedManager[T] {
+	options = append(options, func(o *Options) {
+		o.Codec = typedCodec[T]{}
+	})
+	return &TypedManager[T]{ConnectionManager: NewConnectionManager(options...)}
+}
+
+// Send broadcasts v
+func (m *TypedManager[T]) Send(v *T) {
+	m.ConnectionManager.Send(typedMessage(v))
+}
+
+// SendTo sends v to conn only
+func (m *TypedManager[T]) SendTo(conn *Connection, v *T) {
+	m.ConnectionManager.SendTo(conn, typedMessage(v))
+}
+
+// Publish sends v to the subscribers of topic
+func (m *TypedManager[T]) Publish(topic string, v *T) {
+	m.ConnectionManager.Publish(topic, typedMessage(v))
+}
+
+// Receive upgrades the request like ConnectionManager.Receive and calls onReceive with the decoded messages
+func (m *TypedManager[T]) Receive(w http.ResponseWriter, r *http.Request, onReceive func(*T)) (*Connection, error) {
+	return m.ReceiveConn(w, r, func(_ *Connection, v *T) {
+		onReceive(v)
+	})
+}
+
+// ReceiveConn is Receive with onReceive getting the connection each message was read from
+func (m *TypedManager[T]) ReceiveConn(
+	w http.ResponseWriter, r *http.Request, onReceive func(*Connection, *T)) (*Connection, error) {
+	return m.ConnectionManager.ReceiveConn(w, r, func(conn *Connection, msg *Message) {
+		if v, ok := msg.Data.(*T); ok {
+			onReceive(conn, v)
+		}
+	})
+}
+
+func typedMessage[T any](v *T) *Message {
+	msg := &Message{Data: v}
+	if typer, ok := any(v).(MessageTyper); ok {
+		msg.Type = typer.MessageType()
+	}
+	return msg
+}
+
+// typedCodec encodes the *T data of messages as the whole JSON frame
+type typedCodec[T any] struct {
+	JSONCodec
+}
+
+// Encode writes the *T data of msg, or msg itself for the messages of the manager
+func (c typedCodec[T]) Encode(w io.Writer, msg *Message) error {
+	if v, ok := msg.Data.(*T); ok {
+		return json.NewEncoder(w).Encode(v)
+	}
+	return c.JSONCodec.Encode(w, msg)
+}
+
+// Decode reads a T into the data of msg
+func (typedCodec[T]) Decode(r io.Reader, msg *Message) error {
+	v := new(T)
+	err := json.NewDecoder(r).Decode(v)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	*msg = *typedMessage(v)
+	return nil
+}
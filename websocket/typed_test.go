@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+type chatMessage struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+func (m *chatMessage) MessageType() string {
+	return m.Kind
+}
+
+func TestTypedManagerRoundTrip(t *testing.T) {
+	cm := NewTypedManager[chatMessage](func(o *Options) { o.SetupTimeout = -1 })
+	got := make(chan *chatMessage, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.Receive(w, r, func(msg *chatMessage) { got <- msg })
+	}))
+	defer srv.Close()
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.WriteJSON(chatMessage{Kind: "chat", Text: "hi"})
+	select {
+	case msg := <-got:
+		if msg.Text != "hi" {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+	if stats := cm.TypeStats(); stats["chat"].MessagesIn != 1 {
+		t.Fatalf("type stats %+v", stats)
+	}
+	cm.Send(&chatMessage{Kind: "chat", Text: "back"})
+	var msg chatMessage
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := client.ReadJSON(&msg); err != nil || msg.Text != "back" {
+		t.Fatalf("read %+v, %v", msg, err)
+	}
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMaxMessageTypes = 256
+	// otherMessageType counts the types beyond Options.MaxMessageTypes
+	otherMessageType = "other"
+)
+
+// latencyBuckets upper bounds of the handler latency histogram: 1ms, 5ms, 10ms, 50ms, 100ms, 500ms, 1s and 5s
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// TypeStats traffic and handler latency of one message type
+type TypeStats struct {
+	MessagesIn  int64
+	BytesIn     int64
+	MessagesOut int64
+	BytesOut    int64
+	// WriteErrors of outbound messages, the error rate is WriteErrors / (MessagesOut + WriteErrors)
+	WriteErrors int64
+	// Handled inbound messages passed to onReceive, HandlerTime their total handler time
+	Handled     int64
+	HandlerTime time.Duration
+	// HandlerLatency cumulative counts of handled messages per bound of 1ms, 5ms, 10ms, 50ms, 100ms, 500ms, 1s, 5s and +Inf
+	HandlerLatency []int64
+}
+
+type typeMetrics struct {
+	max int // Types counted apart from otherMessageType
+
+	mu     sync.RWMutex
+	byType map[string]*typeCounters
+}
+
+type typeCounters struct {
+	messagesIn  atomic.Int64
+	bytesIn     atomic.Int64
+	messagesOut atomic.Int64
+	bytesOut    atomic.Int64
+	writeErrors atomic.Int64
+	handled     atomic.Int64
+	handlerTime atomic.Int64
+	latency     [9]atomic.Int64 // Per bucket bound and +Inf, not cumulative
+}
+
+// TypeStats per message type
+func (cm *ConnectionManager) TypeStats() map[string]TypeStats {
+	cm.types.mu.RLock()
+	defer cm.types.mu.RUnlock()
+	stats := make(map[string]TypeStats, len(cm.types.byType))
+	for msgType, c := range cm.types.byType {
+		s := TypeStats{
+			MessagesIn:     c.messagesIn.Load(),
+			BytesIn:        c.bytesIn.Load(),
+			MessagesOut:    c.messagesOut.Load(),
+			BytesOut:       c.bytesOut.Load(),
+			WriteErrors:    c.writeErrors.Load(),
+			Handled:        c.handled.Load(),
+			HandlerTime:    time.Duration(c.handlerTime.Load()),
+			HandlerLatency: make([]int64, len(c.latency)),
+		}
+		var total int64
+		for i := range c.latency {
+			total += c.latency[i].Load()
+			s.HandlerLatency[i] = total
+		}
+		stats[msgType] = s
+	}
+	return stats
+}
+
+func (m *typeMetrics) counters(msgType string) *typeCounters {
+	m.mu.RLock()
+	c, ok := m.byType[msgType]
+	m.mu.RUnlock()
+	if ok {
+		return c
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok = m.byType[msgType]
+	if !ok && len(m.byType) >= m.max {
+		msgType = otherMessageType
+		c, ok = m.byType[msgType]
+	}
+	if !ok {
+		if m.byType == nil {
+			m.byType = make(map[string]*typeCounters)
+		}
+		c = &typeCounters{}
+		m.byType[msgType] = c
+	}
+	return c
+}
+
+func (m *typeMetrics) count(msgType string, in bool, bytes int64) {
+	c := m.counters(msgType)
+	if in {
+		c.messagesIn.Add(1)
+		c.bytesIn.Add(bytes)
+	} else {
+		c.messagesOut.Add(1)
+		c.bytesOut.Add(bytes)
+	}
+}
+
+func (m *typeMetrics) failed(msgType string) {
+	m.counters(msgType).writeErrors.Add(1)
+}
+
+func (m *typeMetrics) observe(msgType string, latency time.Duration) {
+	c := m.counters(msgType)
+	c.handled.Add(1)
+	c.handlerTime.Add(int64(latency))
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return latency <= latencyBuckets[i] })
+	c.latency[i].Add(1)
+}
+
+// writeTypeStats writes stats in the Prometheus text format
+func writeTypeStats(w io.Writer, labels string, stats map[string]TypeStats) {
+	if labels != "" {
+		labels += ","
+	}
+	types := make([]string, 0, len(stats))
+	for msgType := range stats {
+		types = append(types, msgType)
+	}
+	sort.Strings(types)
+	for _, msgType := range types {
+		s := stats[msgType]
+		l := labels + "type=" + strconv.Quote(msgType)
+		fmt.Fprintf(w, "websocket_messages_total{%s,direction=\"in\"} %d\n", l, s.MessagesIn)
+		fmt.Fprintf(w, "websocket_messages_total{%s,direction=\"out\"} %d\n", l, s.MessagesOut)
+		fmt.Fprintf(w, "websocket_message_bytes_total{%s,direction=\"in\"} %d\n", l, s.BytesIn)
+		fmt.Fprintf(w, "websocket_message_bytes_total{%s,direction=\"out\"} %d\n", l, s.BytesOut)
+		fmt.Fprintf(w, "websocket_write_errors_total{%s} %d\n", l, s.WriteErrors)
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "websocket_handler_seconds_bucket{%s,le=\"%g\"} %d\n", l, bound.Seconds(), s.HandlerLatency[i])
+		}
+		fmt.Fprintf(w, "websocket_handler_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, s.Handled)
+		fmt.Fprintf(w, "websocket_handler_seconds_sum{%s} %g\n", l, s.HandlerTime.Seconds())
+		fmt.Fprintf(w, "websocket_handler_seconds_count{%s} %d\n", l, s.Handled)
+	}
+}
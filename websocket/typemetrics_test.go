@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypeStats(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.SetupTimeout = -1 })
+	client, _ := testServer(t, cm, func(conn *Connection, msg *Message) {
+		time.Sleep(2 * time.Millisecond)
+		conn.Send(&Message{Type: "ack"})
+	})()
+	client.WriteJSON(Message{Type: "order"})
+	readType(t, client, "ack")
+
+	eventually(t, "the handled message and the ack to be counted", func() bool {
+		stats := cm.TypeStats()
+		return stats["order"].Handled == 1 && stats["ack"].MessagesOut == 1
+	})
+	order := cm.TypeStats()["order"]
+	if order.MessagesIn != 1 || order.BytesIn == 0 || order.HandlerTime < 2*time.Millisecond {
+		t.Fatalf("order stats %+v", order)
+	}
+	// Cumulative counts, the handler took over 1ms
+	if order.HandlerLatency[0] != 0 || order.HandlerLatency[len(order.HandlerLatency)-1] != 1 {
+		t.Fatalf("handler latency %v", order.HandlerLatency)
+	}
+	var metrics bytes.Buffer
+	writeTypeStats(&metrics, "", cm.TypeStats())
+	if !strings.Contains(metrics.String(), `type="order"`) {
+		t.Fatalf("metrics:\n%s", metrics.String())
+	}
+}
+
+func TestTypeStatsCapTypes(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) { o.MaxMessageTypes = 2 })
+	for _, msgType := range []string{"a", "b", "c", "d", "a"} {
+		cm.types.count(msgType, true, 1)
+	}
+	stats := cm.TypeStats()
+	if len(stats) != 3 || stats["a"].MessagesIn != 2 || stats[otherMessageType].MessagesIn != 2 {
+		t.Fatalf("type stats %v", stats)
+	}
+}
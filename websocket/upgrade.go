@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"net/http"
+)
+
+// UpgradeDecision is returned by Options.OnBeforeUpgrade
+type UpgradeDecision struct {
+	// Request replaces the request being upgraded when set, e.g. annotated with context values that are then
+	// available from Connection.Context
+	Request *http.Request
+	// Pool labels the connection, available from Connection.Pool
+	Pool string
+	// Codec of the connection when set, e.g. chosen by a query parameter
+	Codec Codec
+	// Values the connection starts with, available from Connection.Get, e.g. the user ID of a session cookie
+	Values map[string]interface{}
+	// Status aborts the upgrade with this HTTP status when non zero
+	Status int
+	// Reason of the abort reported by RejectResponse, defaults to RejectAborted
+	Reason string
+	// Body written as is when the upgrade is aborted, RejectResponse is used when empty
+	Body string
+	// Header added to the response of both aborted and accepted upgrades
+	Header http.Header
+}
+
+// beforeUpgrade runs OnBeforeUpgrade, returns false when the upgrade was aborted and the response written
+func (cm *ConnectionManager) beforeUpgrade(w http.ResponseWriter, r *http.Request) (UpgradeDecision, error) {
+	decision := UpgradeDecision{Request: r}
+	if cm.opts.OnBeforeUpgrade == nil {
+		return decision, nil
+	}
+	decision = cm.opts.OnBeforeUpgrade(r)
+	if decision.Request == nil {
+		decision.Request = r
+	}
+	if decision.Status == 0 {
+		return decision, nil
+	}
+
+	for key, values := range decision.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	reason := decision.Reason
+	if reason == "" {
+		reason = RejectAborted
+	}
+	if decision.Body == "" {
+		return decision, cm.reject(w, r, UpgradeRejection{Status: decision.Status, Reason: reason})
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(decision.Status)
+	w.Write([]byte(decision.Body))
+	return decision, UpgradeRejection{Status: decision.Status, Reason: reason, Message: decision.Body}
+}
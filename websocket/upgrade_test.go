@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+type upgradeKey struct{}
+
+func TestBeforeUpgradeAnnotatesOrAborts(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.OnBeforeUpgrade = func(r *http.Request) UpgradeDecision {
+			header := http.Header{"X-Upgrade": {"checked"}}
+			if r.URL.Query().Has("banned") {
+				return UpgradeDecision{Status: http.StatusForbidden, Reason: "banned", Body: "go away", Header: header}
+			}
+			return UpgradeDecision{
+				Request: r.WithContext(context.WithValue(r.Context(), upgradeKey{}, "annotated")),
+				Pool:    "mobile",
+				Values:  map[string]interface{}{"user": "u1"},
+				Header:  header,
+			}
+		}
+	})
+	conns := make(chan *Connection, 1)
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := cm.ReceiveConn(w, r, func(*Connection, *Message) {})
+		if err != nil {
+			errs <- err
+			return
+		}
+		conns <- conn
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	client, resp, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn := <-conns
+	if resp.Header.Get("X-Upgrade") != "checked" {
+		t.Fatalf("upgrade response header %v", resp.Header)
+	}
+	if user, _ := conn.Get("user"); conn.Pool() != "mobile" || user != "u1" ||
+		conn.Context().Value(upgradeKey{}) != "annotated" {
+		t.Fatalf("pool %q, user %v, context value %v", conn.Pool(), user, conn.Context().Value(upgradeKey{}))
+	}
+
+	_, resp, err = gorilla.DefaultDialer.Dial(url+"/?banned", nil)
+	if err == nil {
+		t.Fatal("aborted upgrade succeeded")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusForbidden || string(body) != "go away" || resp.Header.Get("X-Upgrade") != "checked" {
+		t.Fatalf("aborted upgrade answered %d %q, header %v", resp.StatusCode, body, resp.Header)
+	}
+	var rejection UpgradeRejection
+	if err := <-errs; !errors.As(err, &rejection) || rejection.Reason != "banned" {
+		t.Fatalf("err = %v, want the banned rejection", err)
+	}
+}
@@ -0,0 +1,252 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultUsageExportInterval = time.Minute
+
+// Usage cumulative traffic of a connection or user
+type Usage struct {
+	BytesIn     int64 `json:"bytesIn"`
+	BytesOut    int64 `json:"bytesOut"`
+	MessagesIn  int64 `json:"messagesIn"`
+	MessagesOut int64 `json:"messagesOut"`
+}
+
+// UsageReport cumulative usage per user key
+type UsageReport struct {
+	Time  time.Time        `json:"time"`
+	Users map[string]Usage `json:"users"`
+}
+
+type usageCounters struct {
+	bytesIn     atomic.Int64
+	bytesOut    atomic.Int64
+	messagesIn  atomic.Int64
+	messagesOut atomic.Int64
+}
+
+type usageAccounts struct {
+	mu     sync.Mutex
+	byUser map[string]*usageCounters
+}
+
+func (c *usageCounters) add(in bool, bytes int64) {
+	if in {
+		c.bytesIn.Add(bytes)
+		c.messagesIn.Add(1)
+	} else {
+		c.bytesOut.Add(bytes)
+		c.messagesOut.Add(1)
+	}
+}
+
+func (c *usageCounters) load() Usage {
+	return Usage{
+		BytesIn:     c.bytesIn.Load(),
+		BytesOut:    c.bytesOut.Load(),
+		MessagesIn:  c.messagesIn.Load(),
+		MessagesOut: c.messagesOut.Load(),
+	}
+}
+
+// Usage of the connection so far
+func (c *Connection) Usage() Usage {
+	return c.usage.load()
+}
+
+// UserUsage cumulative usage of all connections of the user key, including closed ones
+func (cm *ConnectionManager) UserUsage(key string) Usage {
+	cm.usage.mu.Lock()
+	defer cm.usage.mu.Unlock()
+	counters, ok := cm.usage.byUser[key]
+	if !ok {
+		return Usage{}
+	}
+	return counters.load()
+}
+
+// UsageReport of all users
+func (cm *ConnectionManager) UsageReport() UsageReport {
+	cm.usage.mu.Lock()
+	defer cm.usage.mu.Unlock()
+	report := UsageReport{Time: cm.clock.Now(), Users: make(map[string]Usage, len(cm.usage.byUser))}
+	for key, counters := range cm.usage.byUser {
+		report.Users[key] = counters.load()
+	}
+	return report
+}
+
+// UsageFileExporter appends each report as a JSON line to the file at path, for Options.OnUsageExport. Failures
+// are logged to logger.
+func UsageFileExporter(path string, logger Logger) func(UsageReport) {
+	return func(report UsageReport) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			logger.Error(err, "Failed to open usage export file")
+			return
+		}
+		defer f.Close()
+		err = json.NewEncoder(f).Encode(report)
+		if err != nil {
+			logger.Error(err, "Failed to export usage")
+		}
+	}
+}
+
+// countUsage adds a message of bytes to the connection and its user
+func (cm *ConnectionManager) countUsage(conn *Connection, in bool, bytes int64) {
+	conn.usage.add(in, bytes)
+	key := cm.opts.UsageKey(conn)
+	if key == "" {
+		return
+	}
+	cm.usage.mu.Lock()
+	counters, ok := cm.usage.byUser[key]
+	if !ok {
+		if cm.usage.byUser == nil {
+			cm.usage.byUser = make(map[string]*usageCounters)
+		}
+		counters = &usageCounters{}
+		cm.usage.byUser[key] = counters
+	}
+	cm.usage.mu.Unlock()
+	counters.add(in, bytes)
+	cm.checkQuota(conn, key, bytes)
+}
+
+func (cm *ConnectionManager) exportUsage() {
+	ticker := cm.clock.NewTicker(cm.opts.UsageExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-ticker.C():
+		}
+		cm.opts.OnUsageExport(cm.UsageReport())
+	}
+}
+
+// readMessage reads the next message with the codec of conn, counts its bytes and returns their number
+func (cm *ConnectionManager) readMessage(conn *Connection, msg *Message) (int64, error) {
+	frameType, r, err := conn.socket.NextReader()
+	if err != nil {
+		return 0, err
+	}
+	counter := &countingReader{r: r}
+	if ok, err := cm.readBinary(conn, frameType, counter, msg); ok {
+		if err != nil {
+			return 0, err
+		}
+		cm.countUsage(conn, true, counter.n)
+		cm.types.count(msg.Type, true, counter.n)
+		cm.metrics.received.Add(1)
+		return counter.n, nil
+	}
+	err = conn.codec.Decode(counter, msg)
+	if err != nil {
+		return 0, err
+	}
+	attached, err := readAttachment(conn.socket, msg)
+	if err != nil {
+		return 0, err
+	}
+	cm.countUsage(conn, true, counter.n+attached)
+	cm.types.count(msg.Type, true, counter.n+attached)
+	cm.metrics.received.Add(1)
+	return counter.n + attached, nil
+}
+
+// writeMessage writes msg with the codec of conn, followed by its attachment, or as a binary frame, and counts
+// its bytes
+func (cm *ConnectionManager) writeMessage(conn *Connection, msg *Message) error {
+	var n int64
+	var err error
+	if msg.binary {
+		n, err = cm.writeBinary(conn, msg)
+	} else {
+		n, err = cm.writeEncoded(conn, msg)
+	}
+	if err != nil {
+		return err
+	}
+	cm.countUsage(conn, false, n)
+	cm.types.count(msg.Type, false, n)
+	cm.metrics.sent.Add(1)
+	cm.sessionWritten(conn, msg)
+	cm.skewWritten(msg)
+	return nil
+}
+
+// encodeError is a codec failing to encode a message, which is dropped while the connection stays
+type encodeError struct {
+	err error
+}
+
+func (e *encodeError) Error() string {
+	return "websocket: failed to encode message: " + e.err.Error()
+}
+
+func (e *encodeError) Unwrap() error {
+	return e.err
+}
+
+// writeEncoded writes msg with the codec of conn followed by its attachment and returns their size. msg is
+// encoded before the frame is started, so a message the codec cannot encode writes nothing.
+func (cm *ConnectionManager) writeEncoded(conn *Connection, msg *Message) (int64, error) {
+	var encoded bytes.Buffer
+	err := conn.codec.Encode(&encoded, withAttachmentSize(msg))
+	if err != nil {
+		return 0, &encodeError{err: err}
+	}
+	err = conn.socket.WriteMessage(conn.codec.FrameType(), encoded.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	attached, err := writeAttachment(conn.socket, msg)
+	if err != nil {
+		return 0, err
+	}
+	return int64(encoded.Len()) + attached, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func defaultUsageKey(conn *Connection) string {
+	switch identity := conn.Identity().(type) {
+	case string:
+		return identity
+	case fmt.Stringer:
+		return identity.String()
+	}
+	return ""
+}
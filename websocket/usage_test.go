@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestUsagePerConnectionAndUser(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	cm := NewConnectionManager(WithClock(clock), func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.UsageKey = func(conn *Connection) string {
+			user, _ := conn.Get("user")
+			s, _ := user.(string)
+			return s
+		}
+		o.OnUsageExport = UsageFileExporter(path, NopLogger())
+	})
+	received := make(chan struct{}, 1)
+	client, conn := testServer(t, cm, func(*Connection, *Message) { received <- struct{}{} })()
+	conn.Set("user", "acme")
+
+	sent := []byte(`{"type":"hello"}`)
+	client.WriteMessage(gorilla.TextMessage, sent)
+	<-received
+	conn.Send(&Message{Type: "welcome"})
+	_, read, err := client.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Usage{BytesIn: int64(len(sent)), BytesOut: int64(len(read)), MessagesIn: 1, MessagesOut: 1}
+	// The write is counted once it returns, possibly after the client read it
+	eventually(t, "the write to be counted", func() bool { return conn.Usage() == want })
+	if got := cm.UserUsage("acme"); got != want {
+		t.Fatalf("user usage %+v, want %+v", got, want)
+	}
+
+	clock.Advance(defaultUsageExportInterval)
+	var report UsageReport
+	eventually(t, "the usage report to be exported", func() bool {
+		data, err := os.ReadFile(path)
+		return err == nil && json.Unmarshal(data, &report) == nil
+	})
+	if report.Users["acme"] != want {
+		t.Fatalf("exported %+v", report)
+	}
+}
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"errors"
+)
+
+const defaultWriteQueueSize = 256
+
+// ErrSlowConsumer is the error of connections removed by DisconnectSlow
+var ErrSlowConsumer = errors.New("websocket: write queue full")
+
+// QueuePolicy decides what happens to a message for a connection whose write queue is full
+type QueuePolicy int
+
+// Write queue policies
+const (
+	// DropOldest drops the oldest queued message to make room
+	DropOldest QueuePolicy = iota
+	// DropNewest drops the new message
+	DropNewest
+	// DisconnectSlow removes the connection with ErrSlowConsumer
+	DisconnectSlow
+)
+
+// outbound is a message, a ping queued with QueuePing or the close frame ending a connection
+type outbound struct {
+	msg   *Message
+	ping  *pingFrame
+	close *closeFrame
+}
+
+// queueWrite queues out to the writer of conn applying WriteQueuePolicy, runs on the operations goroutine.
+// Nothing is queued after a close frame, so the close frame is always the newest entry and never dropped.
+func (cm *ConnectionManager) queueWrite(conn *Connection, out outbound) {
+	if conn.closing {
+		if out.msg != nil {
+			cm.sessionUnsent(conn, out.msg)
+			out.msg.report(ErrConnectionClosed)
+		}
+		return
+	}
+	if out.msg != nil && !cm.reserve(conn, out.msg) {
+		return
+	}
+	if out.close != nil {
+		conn.closing = true
+	}
+	for {
+		select {
+		case conn.queue <- out:
+			return
+		default:
+		}
+		if out.close == nil && cm.opts.WriteQueuePolicy == DropNewest {
+			cm.release(out.msg)
+			cm.publish(DropEvent{Conn: conn, Message: out.msg, Reason: "write queue full", Time: cm.clock.Now()})
+			return
+		}
+		if out.close == nil && cm.opts.WriteQueuePolicy == DisconnectSlow {
+			cm.release(out.msg)
+			cm.logV("Removing slow connection")
+			cm.removeSocket(conn, ErrSlowConsumer)
+			return
+		}
+		// Drop the oldest message, close frames always get a place and are never queued behind another
+		select {
+		case old := <-conn.queue:
+			cm.dropQueued(conn, old)
+		default:
+		}
+	}
+}
+
+// writeLoop writes the queued messages of conn until it is removed
+func (cm *ConnectionManager) writeLoop(conn *Connection) {
+	for {
+		select {
+		case <-conn.stop:
+			cm.drainQueue(conn)
+			return
+		case <-cm.done:
+			return
+		case out := <-conn.queue:
+			if out.close != nil {
+				cm.writeClose(conn, out.close)
+				cm.enqueue(&socketOperation{opType: remove, conn: conn, err: out.close.err()})
+				cm.drainQueue(conn)
+				return
+			}
+			if out.ping != nil {
+				cm.writePing(conn, out.ping)
+				continue
+			}
+			cm.release(out.msg)
+			cm.writeNow(conn, out.msg)
+		}
+	}
+}
+
+// drainQueue releases the messages left in the queue of a closed connection
+func (cm *ConnectionManager) drainQueue(conn *Connection) {
+	for {
+		select {
+		case out := <-conn.queue:
+			if out.msg != nil {
+				cm.release(out.msg)
+				cm.sessionUnsent(conn, out.msg)
+				out.msg.report(ErrConnectionClosed)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (cm *ConnectionManager) dropQueued(conn *Connection, out outbound) {
+	if out.msg == nil {
+		return
+	}
+	cm.release(out.msg)
+	cm.publish(DropEvent{Conn: conn, Message: out.msg, Reason: "write queue full", Time: cm.clock.Now()})
+}
@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// blockedServer accepts one connection whose writes block until release is called
+func blockedServer(t *testing.T, cm *ConnectionManager) (client *gorilla.Conn, conn *Connection, release func()) {
+	t.Helper()
+	conns := make(chan *Connection, 1)
+	sockets := make(chan *blockedWrites, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := (&gorilla.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		blocked := &blockedWrites{Conn: socket, release: make(chan struct{})}
+		t.Cleanup(func() { blocked.Close() })
+		sockets <- blocked
+		conn, err := cm.Accept(r, blocked, func(*Connection, *Message) {})
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	blocked := <-sockets
+	return client, <-conns, func() { blocked.once.Do(func() { close(blocked.release) }) }
+}
+
+// fillQueue sends "1" for the writer to block on, then 2, 3 and 4 to a write queue of two
+func fillQueue(t *testing.T, cm *ConnectionManager, conn *Connection) {
+	t.Helper()
+	cm.SendTo(conn, &Message{Type: "n", Data: "1"})
+	eventually(t, "the writer to take the first message", func() bool { return len(conn.queue) == 0 })
+	for _, n := range []string{"2", "3", "4"} {
+		cm.SendTo(conn, &Message{Type: "n", Data: n})
+	}
+}
+
+func TestWriteQueuePolicyDrops(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		policy    QueuePolicy
+		dropped   string
+		delivered []string
+	}{
+		{"DropOldest", DropOldest, "2", []string{"1", "3", "4"}},
+		{"DropNewest", DropNewest, "4", []string{"1", "2", "3"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := NewConnectionManager(func(o *Options) {
+				o.SetupTimeout = -1
+				o.PingInterval = 0
+				o.WriteQueueSize = 2
+				o.WriteQueuePolicy = tc.policy
+			})
+			client, conn, release := blockedServer(t, cm)
+			fillQueue(t, cm, conn)
+			for {
+				if drop, ok := nextEvent(t, cm).(DropEvent); ok {
+					if drop.Message.Data != tc.dropped || drop.Reason != "write queue full" {
+						t.Fatalf("dropped %v: %s, want %s", drop.Message.Data, drop.Reason, tc.dropped)
+					}
+					break
+				}
+			}
+			release()
+			for _, want := range tc.delivered {
+				var msg Message
+				if err := client.ReadJSON(&msg); err != nil || msg.Data != want {
+					t.Fatalf("read %v, %v, want %s", msg.Data, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteQueueDisconnectSlow(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.WriteQueueSize = 2
+		o.WriteQueuePolicy = DisconnectSlow
+	})
+	_, conn, _ := blockedServer(t, cm)
+	fillQueue(t, cm, conn)
+	for {
+		if disconnect, ok := nextEvent(t, cm).(DisconnectEvent); ok {
+			if disconnect.Conn != conn || !errors.Is(disconnect.Err, ErrSlowConsumer) {
+				t.Fatalf("disconnected with %v, want ErrSlowConsumer", disconnect.Err)
+			}
+			return
+		}
+	}
+}
+
+func TestCloseFrameNotDroppedFromFullQueue(t *testing.T) {
+	cm := NewConnectionManager(func(o *Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 0
+		o.WriteQueueSize = 4
+	})
+	client, conn := testServer(t, cm, nil)()
+	big := strings.Repeat("x", 64<<10)
+	for i := 0; i < 200; i++ {
+		cm.Send(&Message{Type: "early", Data: big})
+	}
+	cm.Disconnect(conn, 4000, "bye")
+	for i := 0; i < 200; i++ {
+		cm.Send(&Message{Type: "late", Data: big})
+	}
+	for {
+		var msg Message
+		err := client.ReadJSON(&msg)
+		var closeErr *gorilla.CloseError
+		if errors.As(err, &closeErr) {
+			if closeErr.Code != 4000 {
+				t.Fatalf("closed with %d, want 4000", closeErr.Code)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type == "late" {
+			t.Fatal("message written after the close frame")
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package wsclient
+
+import (
+	"fmt"
+	"io"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// writeMessage writes msg as JSON followed by its attachment as a binary frame, like the server
+func writeMessage(socket *gorilla.Conn, msg *websocket.Message) error {
+	if len(msg.Attachment) == 0 && msg.AttachmentSize == 0 {
+		return socket.WriteJSON(msg)
+	}
+	announced := *msg
+	announced.AttachmentSize = len(msg.Attachment)
+	err := socket.WriteJSON(&announced)
+	if err != nil || len(msg.Attachment) == 0 {
+		return err
+	}
+	return socket.WriteMessage(gorilla.BinaryMessage, msg.Attachment)
+}
+
+// readAttachment reads the binary frame of size announced by the message just read
+func readAttachment(socket *gorilla.Conn, size int) ([]byte, error) {
+	frameType, r, err := socket.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	if frameType != gorilla.BinaryMessage {
+		return nil, websocket.ErrAttachmentMissing
+	}
+	attachment, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(attachment) != size {
+		return nil, fmt.Errorf("%w: %d bytes announced, %d read", websocket.ErrAttachmentMissing, size,
+			len(attachment))
+	}
+	return attachment, nil
+}
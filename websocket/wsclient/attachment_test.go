@@ -0,0 +1,40 @@
+package wsclient
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func TestAttachmentsRoundTrip(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) { o.SetupTimeout = -1 })
+	url, received := receivingServer(t, cm, nil)
+	c, err := Dial(context.Background(), url, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Send(&websocket.Message{Type: "upload", Attachment: []byte{0, 1, 2}})
+	select {
+	case msg := <-received:
+		if msg.Type != "upload" || !bytes.Equal(msg.Attachment, []byte{0, 1, 2}) {
+			t.Fatalf("server received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload not received")
+	}
+
+	cm.Send(&websocket.Message{Type: "download", Attachment: []byte{3, 4}})
+	select {
+	case msg := <-c.Receive():
+		if msg.Type != "download" || !bytes.Equal(msg.Attachment, []byte{3, 4}) {
+			t.Fatalf("client received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("download not received")
+	}
+}
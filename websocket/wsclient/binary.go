@@ -0,0 +1,50 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// ErrBinaryDropped is passed to OnError for binary frames received without Options.OnBinary
+var ErrBinaryDropped = errors.New("wsclient: binary frame dropped without OnBinary")
+
+// SendBinary writes data to the server as a binary frame, e.g. for the server's Options.OnBinary. It is not
+// queued while reconnecting and returns ErrDisconnected then.
+func (c *Client) SendBinary(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.socket == nil {
+		return ErrDisconnected
+	}
+	return c.socket.WriteMessage(gorilla.BinaryMessage, data)
+}
+
+// readFrame reads the next frame of socket, decoding text frames into env. Binary frames are passed to
+// OnBinary and reported by the returned flag.
+func (c *Client) readFrame(socket *gorilla.Conn, env *envelope) (bool, error) {
+	frameType, r, err := socket.NextReader()
+	if err != nil {
+		return false, err
+	}
+	if frameType == gorilla.BinaryMessage {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return true, err
+		}
+		if c.opts.OnBinary == nil {
+			c.error(ErrBinaryDropped)
+			return true, nil
+		}
+		c.opts.OnBinary(data)
+		return true, nil
+	}
+	err = json.NewDecoder(r).Decode(env)
+	if err == io.EOF {
+		// A text frame without a JSON value, as ReadJSON reports it
+		err = io.ErrUnexpectedEOF
+	}
+	return false, err
+}
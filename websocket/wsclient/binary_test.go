@@ -0,0 +1,60 @@
+package wsclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func TestBinaryFrames(t *testing.T) {
+	fromClient := make(chan []byte, 1)
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.OnBinary = func(_ *websocket.Connection, data []byte) { fromClient <- data }
+	})
+	fromServer := make(chan []byte, 2)
+	c, conn := testServer(t, cm, Options{OnBinary: func(data []byte) { fromServer <- data }})
+	next := func() string {
+		t.Helper()
+		select {
+		case data := <-fromServer:
+			return string(data)
+		case <-time.After(5 * time.Second):
+			t.Fatal("binary frame not received")
+			return ""
+		}
+	}
+
+	cm.SendBinary([]byte("frame"))
+	cm.Send(&websocket.Message{Type: "text"})
+	if data := next(); data != "frame" {
+		t.Fatalf("received %q", data)
+	}
+	if msg := <-c.Receive(); msg.Type != "text" {
+		t.Fatalf("received %+v", msg)
+	}
+	w, err := conn.NextBinaryWriter(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("ab"))
+	w.Write([]byte("cd"))
+	w.Close()
+	if data := next(); data != "abcd" {
+		t.Fatalf("received stream %q", data)
+	}
+
+	if err := c.SendBinary([]byte("up")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-fromClient:
+		if string(data) != "up" {
+			t.Fatalf("server received %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("binary frame of the client not received")
+	}
+}
@@ -0,0 +1,716 @@
+/*
+Package wsclient is a Go client for servers built on the websocket package, with the same Message type, typed
+topic subscriptions and automatic reconnect.
+*/
+package wsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+const (
+	defaultSubscriptionBuffer = 64
+	defaultReceiveBuffer      = 64
+	defaultMinBackoff         = 100 * time.Millisecond
+	defaultMaxBackoff         = 30 * time.Second
+	defaultFallbackDelay      = 250 * time.Millisecond
+)
+
+var (
+	// ErrSubscriptionFull is passed to OnError when a value is dropped because its subscriber does not keep up
+	ErrSubscriptionFull = errors.New("wsclient: subscription channel full")
+	// ErrReceiveFull is passed to OnError when a message is dropped because the Receive channel is full
+	ErrReceiveFull = errors.New("wsclient: receive channel full")
+	// ErrDisconnected is returned by Send while the client is reconnecting
+	ErrDisconnected = errors.New("wsclient: disconnected")
+	// ErrNoEndpoints is returned by DialEndpoints without any URL
+	ErrNoEndpoints = errors.New("wsclient: no endpoints")
+)
+
+// Options configures a Client
+type Options struct {
+	// Header sent with the upgrade request, optional
+	Header http.Header
+	// Dialer used to connect, defaults to gorilla's DefaultDialer
+	Dialer *gorilla.Dialer
+	// Resolver looks up the endpoint hosts on every dial, so reconnects rotate among their A/AAAA records and
+	// pick up fleet changes instead of pinning the first address. Defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+	// SRVService and SRVProto look up the SRV records of the endpoint hosts instead, e.g. "ws" and "tcp",
+	// rotating among the targets of the best priority. The URL ports are ignored then.
+	SRVService string
+	SRVProto   string
+	// FallbackDelay before DialEndpoints starts dialing the next endpoint while the previous attempts are still
+	// pending, defaults to 250ms
+	FallbackDelay time.Duration
+	// Reconnect redials with exponential backoff between MinBackoff and MaxBackoff when the connection is lost,
+	// and joins the subscribed topics again. Backoff defaults to 100ms up to 30s.
+	Reconnect  bool
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnReconnect is called after the connection is dialed again, optional
+	OnReconnect func()
+	// PingInterval between pings measuring the RTT to the server, reported as RTTEvent and MissedPongEvent, zero
+	// sends no pings. The connection is closed, and redialed with Reconnect, after MaxMissedPongs pings in a row
+	// went unanswered, zero keeps it.
+	PingInterval   time.Duration
+	MaxMissedPongs int
+	// DegradedRTT above which RTTEvent is reported as degraded, optional
+	DegradedRTT time.Duration
+	// EventsBuffer capacity of the Events channel, defaults to 64
+	EventsBuffer int
+	// OfflinePath file keeping the messages sent while disconnected until they are written after the next
+	// reconnect, also across restarts of the process. Send returns ErrOfflineQueueFull once OfflineMaxMessages
+	// are queued, defaults to 1000. Messages older than OfflineTTL are dropped, zero keeps them. Optional.
+	// Queued messages are delivered at most once: they leave the queue once written to the socket, so those still
+	// buffered when the connection drops are lost. With Reconnect set the client starts offline when the first
+	// dial fails, queueing until a redial succeeds.
+	OfflinePath        string
+	OfflineMaxMessages int
+	OfflineTTL         time.Duration
+	// JoinMessageType and LeaveMessageType match the server options, default to "join" and "leave"
+	JoinMessageType  string
+	LeaveMessageType string
+	// TopicSwapMessageType matches the server option, its messages move the subscriptions of a topic swapped by
+	// the server to the new topic, so they keep receiving its messages. Unsubscribe accepts either topic. Defaults
+	// to "topic.swap".
+	TopicSwapMessageType string
+	// RateLimitMessageType matches the server option, its messages are reported by RateLimit and RateLimitEvent
+	// instead of OnMessage. Defaults to "ratelimit".
+	RateLimitMessageType string
+	// SessionMessageType matches the server option with SessionReplayWindow set and enables resuming the session
+	// on reconnect, passing its token as SessionParam, defaults to "session", so the server replays the missed
+	// messages. Session messages are not delivered. Optional.
+	SessionMessageType string
+	SessionParam       string
+	// CallMessageType and ResultMessageType match the server options of Call, default to "call" and "result".
+	// CallTimeout bounds each Call, defaults to 10s as websocket.Options.CallTimeout of the server. Set it above
+	// the timeout of the server to get its timeout error rather than context.DeadlineExceeded. Results are not
+	// delivered, those arriving late are dropped.
+	CallMessageType   string
+	ResultMessageType string
+	CallTimeout       time.Duration
+	// SubscriptionBuffer values buffered per subscription, defaults to 64
+	SubscriptionBuffer int
+	// OnMessage is called for messages that are not for a subscribed topic. When it is not set the messages are
+	// delivered on the Receive channel instead.
+	OnMessage func(msg *websocket.Message)
+	// ReceiveBuffer messages buffered on the Receive channel, defaults to 64
+	ReceiveBuffer int
+	// OnBinary is called from the read loop with the binary frames of the server that are not attachments, e.g.
+	// those of SendBinary and NextBinaryWriter. Without it they are dropped with ErrBinaryDropped.
+	OnBinary func(data []byte)
+	// OnError is called with decode errors and dropped values, optional
+	OnError func(err error)
+}
+
+// Client is a connection to a websocket server
+type Client struct {
+	urls     []string
+	opts     Options
+	received chan *websocket.Message
+	offline  *offlineQueue
+	events   chan Event
+
+	rtt      atomic.Int64 // Smoothed round trip time in nanoseconds
+	awaiting atomic.Int64 // Send time of the unanswered ping
+
+	rateLimit atomic.Pointer[websocket.RateLimit]
+
+	sessionReceived atomic.Uint64 // Messages received in the session, not counting session messages
+	nextCall        atomic.Uint64 // Last call ID
+
+	// socket is nil while reconnecting
+	writeMu sync.Mutex
+	socket  *gorilla.Conn
+
+	mu       sync.Mutex
+	subs     map[string][]subscriber
+	swapped  map[string]string         // Topics swapped by the server to their new topic
+	calls    map[string]chan *envelope // Pending calls by ID
+	closed   bool
+	done     chan struct{}
+	endpoint string             // URL of the current connection
+	loads    map[string]float64 // Last load hint of each endpoint
+	session  websocket.SessionInfo
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// subscriber decodes the data of a topic message and delivers it
+type subscriber interface {
+	deliver(data json.RawMessage) error
+	close()
+}
+
+// envelope is websocket.Message with the data kept raw for typed decoding
+type envelope struct {
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+	Topic  string          `json:"topic,omitempty"`
+	Seq    uint64          `json:"seq,omitempty"`
+	Key    string          `json:"key,omitempty"`
+	Cursor string          `json:"cursor,omitempty"`
+	ID     string          `json:"id,omitempty"`
+
+	AttachmentSize int `json:"attachment,omitempty"`
+	attachment     []byte
+	result         callResult // Of result messages
+}
+
+// Dial connects to the server at url, ctx only bounds the first dial
+func Dial(ctx context.Context, url string, opts Options) (*Client, error) {
+	return DialEndpoints(ctx, []string{url}, opts)
+}
+
+// DialEndpoints connects to the first of the servers at urls to accept, e.g. the regions of a fleet in order of
+// preference. Each endpoint is dialed when the previous attempt fails or after FallbackDelay, so a slow endpoint
+// does not hold up the others, and the first connection established wins. Reconnects dial the same way. With
+// OfflinePath and Reconnect set a failed first dial does not fail DialEndpoints, the client is returned offline
+// and reports the error as DisconnectedEvent and to OnError while it redials.
+func DialEndpoints(ctx context.Context, urls []string, opts Options) (*Client, error) {
+	if len(urls) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	if opts.JoinMessageType == "" {
+		opts.JoinMessageType = "join"
+	}
+	if opts.LeaveMessageType == "" {
+		opts.LeaveMessageType = "leave"
+	}
+	if opts.RateLimitMessageType == "" {
+		opts.RateLimitMessageType = "ratelimit"
+	}
+	if opts.TopicSwapMessageType == "" {
+		opts.TopicSwapMessageType = "topic.swap"
+	}
+	if opts.SessionParam == "" {
+		opts.SessionParam = "session"
+	}
+	if opts.CallMessageType == "" {
+		opts.CallMessageType = "call"
+	}
+	if opts.ResultMessageType == "" {
+		opts.ResultMessageType = "result"
+	}
+	if opts.CallTimeout <= 0 {
+		opts.CallTimeout = defaultCallTimeout
+	}
+	if opts.SubscriptionBuffer <= 0 {
+		opts.SubscriptionBuffer = defaultSubscriptionBuffer
+	}
+	if opts.Dialer == nil {
+		opts.Dialer = gorilla.DefaultDialer
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	if opts.ReceiveBuffer <= 0 {
+		opts.ReceiveBuffer = defaultReceiveBuffer
+	}
+	if opts.FallbackDelay <= 0 {
+		opts.FallbackDelay = defaultFallbackDelay
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.EventsBuffer <= 0 {
+		opts.EventsBuffer = defaultEventsBuffer
+	}
+	if opts.OfflineMaxMessages <= 0 {
+		opts.OfflineMaxMessages = defaultOfflineMaxMessages
+	}
+	opts.Dialer = rotatingDialer(&opts)
+	var offline *offlineQueue
+	if opts.OfflinePath != "" {
+		var err error
+		offline, err = openOfflineQueue(opts.OfflinePath, opts.OfflineMaxMessages, opts.OfflineTTL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	socket, endpoint, err := dialAny(ctx, urls, &opts)
+	if err != nil && (offline == nil || !opts.Reconnect) {
+		return nil, err
+	}
+	c := &Client{
+		urls:    urls,
+		loads:   make(map[string]float64),
+		opts:    opts,
+		offline: offline,
+		socket:  socket,
+		events:  make(chan Event, opts.EventsBuffer),
+		subs:    make(map[string][]subscriber),
+		calls:   make(map[string]chan *envelope),
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	if opts.OnMessage == nil {
+		c.received = make(chan *websocket.Message, opts.ReceiveBuffer)
+	}
+	if socket == nil {
+		// Started offline, Send queues until the read loop redials
+		c.error(err)
+		go c.run(nil, err)
+		return c, nil
+	}
+	if offline != nil {
+		// A failed write fails the read loop too, which reconnects
+		err = offline.flush(socket)
+		if err != nil {
+			c.error(err)
+		}
+	}
+	c.endpoint = endpoint
+	go c.run(socket, nil)
+	return c, nil
+}
+
+// Send writes msg to the server. While reconnecting it queues msg with OfflinePath set and returns
+// ErrDisconnected otherwise.
+func (c *Client) Send(msg *websocket.Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.socket == nil && c.offline != nil {
+		return c.offline.push(msg)
+	}
+	if c.socket == nil {
+		return ErrDisconnected
+	}
+	return writeMessage(c.socket, msg)
+}
+
+// Receive returns the channel of messages that are not for a subscribed topic, nil when Options.OnMessage is
+// set. Messages are dropped while it is full. It is closed with the client.
+func (c *Client) Receive() <-chan *websocket.Message {
+	return c.received
+}
+
+// Unsubscribe leaves topic and closes its subscription channels
+func (c *Client) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	if moved, ok := c.swapped[topic]; ok {
+		topic = moved
+	}
+	subs := c.subs[topic]
+	delete(c.subs, topic)
+	c.mu.Unlock()
+	for _, sub := range subs {
+		sub.close()
+	}
+	return c.Send(&websocket.Message{Type: c.opts.LeaveMessageType, Data: topic})
+}
+
+// Close closes the connection and all subscription channels, and stops reconnecting
+func (c *Client) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	var err error
+	c.writeMu.Lock()
+	if c.socket != nil {
+		err = c.socket.Close()
+	}
+	c.writeMu.Unlock()
+	<-c.done
+	return err
+}
+
+// Done is closed when the client is closed, or when the connection is lost without Reconnect
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Subscribe joins topic and returns a channel of the data of its messages decoded as T. Decode errors and
+// values dropped because the channel is full are passed to Options.OnError. The channel is closed by
+// Unsubscribe and Close.
+func Subscribe[T any](c *Client, topic string) (<-chan T, error) {
+	sub := &typedSubscriber[T]{ch: make(chan T, c.opts.SubscriptionBuffer)}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	if moved, ok := c.swapped[topic]; ok {
+		topic = moved
+	}
+	first := len(c.subs[topic]) == 0
+	c.subs[topic] = append(c.subs[topic], sub)
+	c.mu.Unlock()
+	if first {
+		// While reconnecting the topic is joined after the redial
+		err := c.Send(&websocket.Message{Type: c.opts.JoinMessageType, Data: topic})
+		if err != nil && !errors.Is(err, ErrDisconnected) {
+			return nil, err
+		}
+	}
+	return sub.ch, nil
+}
+
+type typedSubscriber[T any] struct {
+	ch     chan T
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *typedSubscriber[T]) deliver(data json.RawMessage) error {
+	var value T
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	select {
+	case s.ch <- value:
+		return nil
+	default:
+		return ErrSubscriptionFull
+	}
+}
+
+func (s *typedSubscriber[T]) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// run reads from socket and from the sockets redialed after it until the client is closed. It redials first when
+// socket is nil, the first dial having failed with err.
+func (c *Client) run(socket *gorilla.Conn, err error) {
+	defer c.shutdown()
+	for {
+		if socket != nil {
+			err = c.read(socket)
+			c.writeMu.Lock()
+			c.socket = nil
+			c.writeMu.Unlock()
+			socket.Close()
+		}
+		disconnected := time.Now()
+		c.publish(DisconnectedEvent{Err: err, Time: disconnected})
+		if !c.opts.Reconnect {
+			return
+		}
+		var attempts int
+		socket, attempts = c.redial()
+		if socket == nil {
+			return
+		}
+		c.publish(ReconnectedEvent{Attempts: attempts, Downtime: time.Since(disconnected), Time: time.Now()})
+		if c.opts.OnReconnect != nil {
+			c.opts.OnReconnect()
+		}
+	}
+}
+
+// redial dials until it succeeds or the client is closed, then joins the subscribed topics again. It returns the
+// socket and the number of attempts.
+func (c *Client) redial() (*gorilla.Conn, int) {
+	backoff := c.opts.MinBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		c.publish(ReconnectingEvent{Attempt: attempt, Delay: delay, Err: err, Time: time.Now()})
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.stop:
+			timer.Stop()
+			return nil, attempt
+		case <-timer.C:
+		}
+		var socket *gorilla.Conn
+		socket, err = c.dial()
+		if err == nil {
+			return socket, attempt
+		}
+		c.error(err)
+		backoff *= 2
+		if backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) dial() (*gorilla.Conn, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	endpoints := c.endpointsByLoad()
+	resume := c.resumeURLs(endpoints)
+	socket, endpoint, err := dialAny(ctx, resume, &c.opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resume {
+		if resume[i] == endpoint {
+			endpoint = endpoints[i]
+		}
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	select {
+	case <-c.stop:
+		socket.Close()
+		return nil, net.ErrClosed
+	default:
+	}
+	// Topics subscribed from here on are joined by Subscribe once the socket is set
+	c.mu.Lock()
+	topics := make([]string, 0, len(c.subs))
+	for topic := range c.subs {
+		topics = append(topics, topic)
+	}
+	c.mu.Unlock()
+	for _, topic := range topics {
+		err = socket.WriteJSON(&websocket.Message{Type: c.opts.JoinMessageType, Data: topic})
+		if err != nil {
+			socket.Close()
+			return nil, err
+		}
+	}
+	if c.offline != nil {
+		err = c.offline.flush(socket)
+		if err != nil {
+			socket.Close()
+			return nil, err
+		}
+	}
+	c.socket = socket
+	c.mu.Lock()
+	c.endpoint = endpoint
+	c.mu.Unlock()
+	return socket, nil
+}
+
+// dialAny dials urls in order, starting the next attempt when the previous one fails or FallbackDelay passes,
+// and returns the first connection established and its URL
+func dialAny(ctx context.Context, urls []string, opts *Options) (*gorilla.Conn, string, error) {
+	type attempt struct {
+		socket *gorilla.Conn
+		url    string
+		err    error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	attempts := make(chan attempt, len(urls))
+	next, pending := 0, 0
+	var fallback <-chan time.Time
+	start := func() {
+		url := urls[next]
+		next++
+		pending++
+		fallback = nil
+		if next < len(urls) {
+			fallback = time.After(opts.FallbackDelay)
+		}
+		go func() {
+			socket, _, err := opts.Dialer.DialContext(ctx, url, opts.Header)
+			attempts <- attempt{socket: socket, url: url, err: err}
+		}()
+	}
+	start()
+	var errs []error
+	for {
+		select {
+		case <-fallback:
+			start()
+		case result := <-attempts:
+			pending--
+			if result.err == nil {
+				// Close the connections of attempts that complete after the winner
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-attempts; late.err == nil {
+							late.socket.Close()
+						}
+					}
+				}(pending)
+				return result.socket, result.url, nil
+			}
+			errs = append(errs, result.err)
+			if next < len(urls) {
+				start()
+			} else if pending == 0 {
+				return nil, "", errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// read delivers the messages of socket until it fails, pinging it with PingInterval set
+func (c *Client) read(socket *gorilla.Conn) error {
+	socket.SetPingHandler(func(payload string) error {
+		return c.ping(socket, payload)
+	})
+	if c.opts.PingInterval > 0 {
+		c.awaiting.Store(0)
+		socket.SetPongHandler(c.pong)
+		done, exited := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(exited)
+			c.heartbeat(socket, done)
+		}()
+		// Events is closed after read returns
+		defer func() {
+			close(done)
+			<-exited
+		}()
+	}
+	for {
+		var env envelope
+		binary, err := c.readFrame(socket, &env)
+		if err != nil {
+			return err
+		}
+		if binary {
+			continue
+		}
+		if env.AttachmentSize > 0 {
+			env.attachment, err = readAttachment(socket, env.AttachmentSize)
+			if err != nil {
+				return err
+			}
+		}
+		if c.opts.SessionMessageType != "" {
+			if env.Type == c.opts.SessionMessageType {
+				c.startSession(env.Data)
+				continue
+			}
+			c.sessionReceived.Add(1)
+		}
+		if env.Type == c.opts.RateLimitMessageType {
+			c.rateLimited(env.Data)
+			continue
+		}
+		if env.Type == c.opts.TopicSwapMessageType {
+			c.swapTopic(env.Data)
+			continue
+		}
+		if env.Type == c.opts.ResultMessageType && env.ID != "" {
+			c.answer(&env)
+			continue
+		}
+		c.mu.Lock()
+		subs := c.subs[env.Topic]
+		c.mu.Unlock()
+		if env.Topic == "" || len(subs) == 0 {
+			c.message(&env)
+			continue
+		}
+		for _, sub := range subs {
+			err := sub.deliver(env.Data)
+			if err != nil {
+				c.error(fmt.Errorf("wsclient: topic %q: %w", env.Topic, err))
+			}
+		}
+	}
+}
+
+// swapTopic moves the subscriptions of the topic swapped by the server to its new topic
+func (c *Client) swapTopic(data json.RawMessage) {
+	var swap websocket.TopicSwap
+	err := json.Unmarshal(data, &swap)
+	if err != nil {
+		c.error(err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || swap.From == swap.To {
+		return
+	}
+	if subs, ok := c.subs[swap.From]; ok {
+		delete(c.subs, swap.From)
+		c.subs[swap.To] = append(c.subs[swap.To], subs...)
+	}
+	if c.swapped == nil {
+		c.swapped = make(map[string]string)
+	}
+	for from, to := range c.swapped {
+		if to == swap.From {
+			c.swapped[from] = swap.To
+		}
+	}
+	delete(c.swapped, swap.To)
+	c.swapped[swap.From] = swap.To
+}
+
+func (c *Client) message(env *envelope) {
+	msg := &websocket.Message{
+		Type:       env.Type,
+		Topic:      env.Topic,
+		Seq:        env.Seq,
+		Key:        env.Key,
+		Cursor:     env.Cursor,
+		ID:         env.ID,
+		Attachment: env.attachment,
+	}
+	if len(env.Data) > 0 {
+		err := json.Unmarshal(env.Data, &msg.Data)
+		if err != nil {
+			c.error(err)
+			return
+		}
+	}
+	if c.opts.OnMessage != nil {
+		c.opts.OnMessage(msg)
+		return
+	}
+	select {
+	case c.received <- msg:
+	default:
+		c.error(ErrReceiveFull)
+	}
+}
+
+func (c *Client) error(err error) {
+	if c.opts.OnError != nil {
+		c.opts.OnError(err)
+	}
+}
+
+// shutdown closes all subscriptions once the read loop ends
+func (c *Client) shutdown() {
+	c.mu.Lock()
+	c.closed = true
+	subs := c.subs
+	c.subs = nil
+	c.mu.Unlock()
+	for _, topicSubs := range subs {
+		for _, sub := range topicSubs {
+			sub.close()
+		}
+	}
+	if c.received != nil {
+		close(c.received)
+	}
+	close(c.events)
+	close(c.done)
+}
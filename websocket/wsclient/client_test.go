@@ -0,0 +1,219 @@
+package wsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// testServer serves cm and dials it with opts, returning the client and the server side connection
+func testServer(t *testing.T, cm *websocket.ConnectionManager, opts Options) (*Client, *websocket.Connection) {
+	t.Helper()
+	conns := make(chan *websocket.Connection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := cm.ReceiveConn(w, r, func(*websocket.Connection, *websocket.Message) {})
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, <-conns
+}
+
+// eventually fails the test when cond does not hold within 5s
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubscriptionsFollowTopicSwap(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.JoinMessageType = "join"
+		o.LeaveMessageType = "leave"
+	})
+	c, conn := testServer(t, cm, Options{})
+	values, err := Subscribe[string](c, "blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the subscription to join", func() bool { return len(conn.Topics()) == 1 })
+
+	cm.SwapTopic("blue", "green")
+	cm.Publish("green", &websocket.Message{Type: "v", Data: "hello"})
+	select {
+	case v := <-values:
+		if v != "hello" {
+			t.Fatalf("received %q", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message of the new topic not delivered to the moved subscription")
+	}
+
+	// Unsubscribing from the old name leaves the new topic
+	c.Unsubscribe("blue")
+	eventually(t, "the subscription to leave", func() bool { return len(conn.Topics()) == 0 })
+}
+
+type price struct {
+	Symbol string  `json:"symbol"`
+	Value  float64 `json:"value"`
+}
+
+func TestTypedSubscriptions(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.JoinMessageType = "join"
+		o.LeaveMessageType = "leave"
+	})
+	errs := make(chan error, 1)
+	c, conn := testServer(t, cm, Options{OnError: func(err error) { errs <- err }})
+	prices, err := Subscribe[price](c, "prices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the subscription to join", func() bool { return len(conn.Topics()) == 1 })
+
+	websocket.PublishValue(cm, "prices", "price", price{Symbol: "ACME", Value: 12.5})
+	cm.Publish("prices", &websocket.Message{Type: "price", Data: "not a price"})
+	cm.Send(&websocket.Message{Type: "direct"})
+	select {
+	case p := <-prices:
+		if p != (price{Symbol: "ACME", Value: 12.5}) {
+			t.Fatalf("received %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("published value not received")
+	}
+	select {
+	case err := <-errs:
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("OnError got %v, want the decode error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("decode error not reported")
+	}
+	select {
+	case msg := <-c.Receive():
+		if msg.Type != "direct" {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message outside the subscriptions not received")
+	}
+
+	c.Unsubscribe("prices")
+	if _, ok := <-prices; ok {
+		t.Fatal("subscription channel not closed by Unsubscribe")
+	}
+	eventually(t, "the subscription to leave", func() bool { return len(conn.Topics()) == 0 })
+}
+
+func TestReconnectJoinsSubscriptionsAgain(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.JoinMessageType = "join"
+		o.LeaveMessageType = "leave"
+	})
+	reconnected := make(chan struct{}, 1)
+	c, conn := testServer(t, cm, Options{
+		Reconnect:   true,
+		MinBackoff:  10 * time.Millisecond,
+		OnReconnect: func() { reconnected <- struct{}{} },
+	})
+	values, err := Subscribe[string](c, "news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the subscription to join", func() bool { return len(conn.Topics()) == 1 })
+
+	cm.Disconnect(conn, 1000, "bye")
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reconnected")
+	}
+	eventually(t, "the subscription to join again", func() bool {
+		members := cm.Members("news")
+		return len(members) == 1 && members[0] != conn
+	})
+	cm.Publish("news", &websocket.Message{Type: "v", Data: "hello"})
+	select {
+	case v := <-values:
+		if v != "hello" {
+			t.Fatalf("received %q", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received after reconnecting")
+	}
+
+	c.Close()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("client not done after Close")
+	}
+	for range c.Receive() {
+	}
+	if _, ok := <-values; ok {
+		t.Fatal("subscription not closed with the client")
+	}
+}
+
+func TestDialEndpointsFallsBack(t *testing.T) {
+	// The first endpoint refuses connections, the second accepts them but never answers the upgrade
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused.Close()
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) { o.SetupTimeout = -1 })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(*websocket.Connection, *websocket.Message) {})
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	c, err := DialEndpoints(context.Background(), []string{
+		"ws://" + refused.Addr().String(),
+		"ws://" + silent.Addr().String(),
+		"ws" + strings.TrimPrefix(srv.URL, "http"),
+	}, Options{FallbackDelay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("dialing took %v, the silent endpoint held up the fallback", elapsed)
+	}
+	eventually(t, "the connection to the last endpoint", func() bool { return cm.Stats().Connections == 1 })
+
+	if _, err := DialEndpoints(context.Background(), nil, Options{}); !errors.Is(err, ErrNoEndpoints) {
+		t.Fatalf("err = %v, want ErrNoEndpoints", err)
+	}
+}
@@ -0,0 +1,168 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+const (
+	defaultEventsBuffer = 64
+	pingWriteTimeout    = 5 * time.Second
+)
+
+// Event is one of RTTEvent, MissedPongEvent, DisconnectedEvent, ReconnectingEvent, ReconnectedEvent,
+// RateLimitEvent or LoadHintEvent
+type Event interface {
+	event()
+}
+
+// RTTEvent a pong measured the round trip time to the server, Degraded when the smoothed RTT is above
+// Options.DegradedRTT
+type RTTEvent struct {
+	RTT      time.Duration
+	Smoothed time.Duration
+	Degraded bool
+	Time     time.Time
+}
+
+// MissedPongEvent the previous ping was not answered before the next one, Missed in a row
+type MissedPongEvent struct {
+	Missed int
+	Time   time.Time
+}
+
+// DisconnectedEvent the connection was lost, Err is the read error
+type DisconnectedEvent struct {
+	Err  error
+	Time time.Time
+}
+
+// ReconnectingEvent attempt Attempt to reconnect starts after Delay, Err is why the previous attempt failed
+type ReconnectingEvent struct {
+	Attempt int
+	Delay   time.Duration
+	Err     error
+	Time    time.Time
+}
+
+// ReconnectedEvent the connection was dialed again after Attempts attempts and Downtime disconnected
+type ReconnectedEvent struct {
+	Attempts int
+	Downtime time.Duration
+	Time     time.Time
+}
+
+// RateLimitEvent the server throttles the client, it should send at most Limit.Rate messages per second until
+// Limit.Reset
+type RateLimitEvent struct {
+	Limit websocket.RateLimit
+	Time  time.Time
+}
+
+// LoadHintEvent the load hint sent by the server in its pings changed, from 0 (idle) to 1 (overloaded)
+type LoadHintEvent struct {
+	Endpoint string
+	Load     float64
+	Time     time.Time
+}
+
+func (RTTEvent) event()          {}
+func (MissedPongEvent) event()   {}
+func (DisconnectedEvent) event() {}
+func (ReconnectingEvent) event() {}
+func (ReconnectedEvent) event()  {}
+func (RateLimitEvent) event()    {}
+func (LoadHintEvent) event()     {}
+
+// Events stream of connection quality events, e.g. to show a reconnecting banner. Events are dropped when the
+// consumer falls behind by more than Options.EventsBuffer events. It is closed with the client.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// RTT smoothed round trip time measured with pings, zero before the first pong
+func (c *Client) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
+// RateLimit last announced by the server and whether it still applies
+func (c *Client) RateLimit() (websocket.RateLimit, bool) {
+	limit := c.rateLimit.Load()
+	if limit == nil || !time.Now().Before(limit.Reset) {
+		return websocket.RateLimit{}, false
+	}
+	return *limit, true
+}
+
+func (c *Client) rateLimited(data json.RawMessage) {
+	var limit websocket.RateLimit
+	err := json.Unmarshal(data, &limit)
+	if err != nil {
+		c.error(err)
+		return
+	}
+	c.rateLimit.Store(&limit)
+	c.publish(RateLimitEvent{Limit: limit, Time: time.Now()})
+}
+
+func (c *Client) publish(event Event) {
+	select {
+	case c.events <- event:
+	default:
+	}
+}
+
+// heartbeat pings socket every PingInterval with the send time as payload until done is closed, and closes
+// socket after MaxMissedPongs pings in a row went unanswered
+func (c *Client) heartbeat(socket *gorilla.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if c.awaiting.Swap(0) != 0 {
+			missed++
+			c.publish(MissedPongEvent{Missed: missed, Time: time.Now()})
+			if c.opts.MaxMissedPongs > 0 && missed >= c.opts.MaxMissedPongs {
+				socket.Close()
+				return
+			}
+		} else if missed > 0 {
+			missed = 0
+		}
+		now := time.Now()
+		c.awaiting.Store(now.UnixNano())
+		payload := []byte(strconv.FormatInt(now.UnixNano(), 10))
+		// WriteControl may run concurrently with data writes, so pings do not wait behind large messages
+		err := socket.WriteControl(gorilla.PingMessage, payload, now.Add(pingWriteTimeout))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// pong measures the RTT of the ping answered by payload
+func (c *Client) pong(payload string) error {
+	sent, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil || !c.awaiting.CompareAndSwap(sent, 0) {
+		return nil
+	}
+	now := time.Now()
+	rtt := now.Sub(time.Unix(0, sent))
+	smoothed := rtt
+	if prev := time.Duration(c.rtt.Load()); prev > 0 {
+		smoothed = (7*prev + rtt) / 8
+	}
+	c.rtt.Store(int64(smoothed))
+	degraded := c.opts.DegradedRTT > 0 && smoothed > c.opts.DegradedRTT
+	c.publish(RTTEvent{RTT: rtt, Smoothed: smoothed, Degraded: degraded, Time: now})
+	return nil
+}
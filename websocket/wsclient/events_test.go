@@ -0,0 +1,133 @@
+package wsclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func TestEventsReportRTTAndReconnects(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) { o.SetupTimeout = -1 })
+	c, _ := testServer(t, cm, Options{
+		Reconnect:    true,
+		MinBackoff:   10 * time.Millisecond,
+		PingInterval: 20 * time.Millisecond,
+	})
+	var order []string
+	timeout := time.After(5 * time.Second)
+	for len(order) == 0 || order[len(order)-1] != "reconnected" {
+		select {
+		case event := <-c.Events():
+			switch event := event.(type) {
+			case RTTEvent:
+				if event.RTT <= 0 || event.Smoothed <= 0 {
+					t.Fatalf("RTT event %+v", event)
+				}
+				if len(order) == 0 {
+					order = append(order, "rtt")
+					cm.ShedLoad(1)
+				}
+			case DisconnectedEvent:
+				order = append(order, "disconnected")
+			case ReconnectingEvent:
+				if event.Attempt == 1 {
+					order = append(order, "reconnecting")
+				}
+			case ReconnectedEvent:
+				order = append(order, "reconnected")
+			}
+		case <-timeout:
+			t.Fatalf("timed out after events %v", order)
+		}
+	}
+	if got := strings.Join(order, ","); got != "rtt,disconnected,reconnecting,reconnected" {
+		t.Fatalf("events %s", got)
+	}
+	if c.RTT() <= 0 {
+		t.Fatal("no smoothed RTT")
+	}
+}
+
+func TestMissedPongsCloseConnection(t *testing.T) {
+	// The server never reads, so it never answers pings
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		socket, err := (&gorilla.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		<-r.Context().Done()
+		socket.Close()
+	}))
+	defer srv.Close()
+	c, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), Options{
+		PingInterval:   20 * time.Millisecond,
+		MaxMissedPongs: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	missed := 0
+	for event := range c.Events() {
+		switch event := event.(type) {
+		case MissedPongEvent:
+			missed++
+			if event.Missed != missed {
+				t.Fatalf("missed %d pongs in a row, want %d", event.Missed, missed)
+			}
+		case DisconnectedEvent:
+			if missed != 2 {
+				t.Fatalf("disconnected after %d missed pongs", missed)
+			}
+			return
+		}
+	}
+	t.Fatal("events closed before the connection")
+}
+
+func TestRateLimitEvent(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.UsageKey = func(*websocket.Connection) string { return "acme" }
+		o.Quota = func(string) (websocket.Quota, bool) {
+			return websocket.Quota{MaxMessages: 3, Action: websocket.QuotaThrottle, ThrottleRate: 2}, true
+		}
+	})
+	c, _ := testServer(t, cm, Options{})
+	if _, ok := c.RateLimit(); ok {
+		t.Fatal("rate limited before the server throttles")
+	}
+	for i := 0; i < 5; i++ {
+		c.Send(&websocket.Message{Type: "x"})
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-c.Events():
+			limited, ok := event.(RateLimitEvent)
+			if !ok {
+				continue
+			}
+			if limited.Limit.Rate != 2 {
+				t.Fatalf("rate limit %+v", limited.Limit)
+			}
+			if limit, ok := c.RateLimit(); !ok || limit.Rate != 2 || !limit.Reset.Equal(limited.Limit.Reset) {
+				t.Fatalf("RateLimit returned %+v, %v", limit, ok)
+			}
+			select {
+			case msg := <-c.Receive():
+				t.Fatalf("rate limit delivered as message %+v", msg)
+			default:
+			}
+			return
+		case <-timeout:
+			t.Fatal("no rate limit event")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package wsclient
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+const loadHintPayloadSize = 9
+
+// ServerLoad last load hint of the server the client is connected to, from 0 (idle) to 1 (overloaded), zero
+// when the server sends none. Clients can slow their sends as it rises.
+func (c *Client) ServerLoad() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loads[c.endpoint]
+}
+
+// ping answers a server ping like the default handler and records the load hint it carries
+func (c *Client) ping(socket *gorilla.Conn, payload string) error {
+	if len(payload) == loadHintPayloadSize {
+		c.loadHint(float64(payload[8]) / 255)
+	}
+	err := socket.WriteControl(gorilla.PongMessage, []byte(payload), time.Now().Add(pingWriteTimeout))
+	var netErr net.Error
+	if errors.Is(err, gorilla.ErrCloseSent) || errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) loadHint(load float64) {
+	c.mu.Lock()
+	endpoint := c.endpoint
+	changed := c.loads[endpoint] != load
+	c.loads[endpoint] = load
+	c.mu.Unlock()
+	if changed {
+		c.publish(LoadHintEvent{Endpoint: endpoint, Load: load, Time: time.Now()})
+	}
+}
+
+// endpointsByLoad orders the endpoints for redialing by their last load hint, keeping the given order among
+// equally loaded endpoints, so reconnects prefer less loaded servers
+func (c *Client) endpointsByLoad() []string {
+	urls := append([]string(nil), c.urls...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sort.SliceStable(urls, func(i, j int) bool {
+		return c.loads[urls[i]] < c.loads[urls[j]]
+	})
+	return urls
+}
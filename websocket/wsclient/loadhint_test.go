@@ -0,0 +1,67 @@
+package wsclient
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// loadedServer serves a manager pinging every 20ms with load as its load hint
+func loadedServer(t *testing.T, load float64) (*websocket.ConnectionManager, string) {
+	t.Helper()
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 20 * time.Millisecond
+		o.LoadHint = func(websocket.LoadSignals) float64 { return load }
+	})
+	url, _ := receivingServer(t, cm, nil)
+	return cm, url
+}
+
+// nextLoadHint waits for the next LoadHintEvent of c
+func nextLoadHint(t *testing.T, c *Client) LoadHintEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-c.Events():
+			if hint, ok := event.(LoadHintEvent); ok {
+				return hint
+			}
+		case <-timeout:
+			t.Fatal("no load hint")
+		}
+	}
+}
+
+func TestLoadHintReconnectsToLessLoadedEndpoint(t *testing.T) {
+	busy, busyURL := loadedServer(t, 0.9)
+	idle, idleURL := loadedServer(t, 0.1)
+	c, err := DialEndpoints(context.Background(), []string{busyURL, idleURL}, Options{
+		Reconnect:  true,
+		MinBackoff: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The payload carries the load as a byte, so it is accurate to 1/255
+	hint := nextLoadHint(t, c)
+	if hint.Endpoint != busyURL || math.Abs(hint.Load-0.9) > 0.01 || c.ServerLoad() != hint.Load {
+		t.Fatalf("load hint %+v, server load %v", hint, c.ServerLoad())
+	}
+
+	// The idle endpoint has no hint yet, it is preferred over the busy one
+	busy.ShedLoad(1)
+	hint = nextLoadHint(t, c)
+	if hint.Endpoint != idleURL || math.Abs(hint.Load-0.1) > 0.01 {
+		t.Fatalf("load hint %+v after reconnecting", hint)
+	}
+	if idle.Stats().Connections != 1 {
+		t.Fatal("not reconnected to the idle endpoint")
+	}
+}
@@ -0,0 +1,143 @@
+package wsclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+const defaultOfflineMaxMessages = 1000
+
+// ErrOfflineQueueFull is returned by Send while disconnected when OfflineMaxMessages messages are queued
+var ErrOfflineQueueFull = errors.New("wsclient: offline queue full")
+
+// queuedMessage is a line of the offline queue file
+type queuedMessage struct {
+	Queued     time.Time          `json:"queued"`
+	Message    *websocket.Message `json:"message"`
+	Attachment []byte             `json:"attachment,omitempty"`
+}
+
+// offlineQueue keeps the messages sent while disconnected in order, rewriting its file on every change
+type offlineQueue struct {
+	path string
+	max  int
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	messages []queuedMessage
+}
+
+// openOfflineQueue loads the messages left in the file at path by a previous client
+func openOfflineQueue(path string, max int, ttl time.Duration) (*offlineQueue, error) {
+	q := &offlineQueue{path: path, max: max, ttl: ttl}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var queued queuedMessage
+		err := json.Unmarshal(scanner.Bytes(), &queued)
+		if err != nil {
+			return nil, err
+		}
+		q.messages = append(q.messages, queued)
+	}
+	return q, scanner.Err()
+}
+
+// push appends msg to the queue and its file
+func (q *offlineQueue) push(msg *websocket.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(time.Now())
+	if len(q.messages) >= q.max {
+		return ErrOfflineQueueFull
+	}
+	q.messages = append(q.messages, queuedMessage{Queued: time.Now(), Message: msg, Attachment: msg.Attachment})
+	err := q.save()
+	if err != nil {
+		q.messages = q.messages[:len(q.messages)-1]
+	}
+	return err
+}
+
+// flush writes the queued messages that have not expired to socket in order, the messages not written stay
+// queued. A message leaves the queue once written, not once the server received it, so delivery is at most once.
+func (q *offlineQueue) flush(socket *gorilla.Conn) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(time.Now())
+	if len(q.messages) == 0 {
+		return nil
+	}
+	var err error
+	for len(q.messages) > 0 {
+		queued := q.messages[0]
+		msg := *queued.Message
+		msg.Attachment = queued.Attachment
+		err = writeMessage(socket, &msg)
+		if err != nil {
+			break
+		}
+		q.messages = q.messages[1:]
+	}
+	saveErr := q.save()
+	if err != nil {
+		return err
+	}
+	return saveErr
+}
+
+func (q *offlineQueue) expire(now time.Time) {
+	if q.ttl <= 0 {
+		return
+	}
+	i := 0
+	for i < len(q.messages) && now.Sub(q.messages[i].Queued) > q.ttl {
+		i++
+	}
+	q.messages = q.messages[i:]
+}
+
+// save replaces the file with the queued messages, one JSON line each
+func (q *offlineQueue) save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, queued := range q.messages {
+		err = encoder.Encode(queued)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), q.path)
+}
@@ -0,0 +1,123 @@
+package wsclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// receivingServer serves cm, delivering the messages read on received and answering 503 while rejecting is set
+func receivingServer(t *testing.T, cm *websocket.ConnectionManager, rejecting *atomic.Bool) (string, <-chan *websocket.Message) {
+	t.Helper()
+	received := make(chan *websocket.Message, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rejecting != nil && rejecting.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		cm.ReceiveConn(w, r, func(_ *websocket.Connection, msg *websocket.Message) { received <- msg })
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), received
+}
+
+// expectData fails the test unless the next messages of received carry data in order
+func expectData(t *testing.T, received <-chan *websocket.Message, data ...string) {
+	t.Helper()
+	for _, want := range data {
+		select {
+		case msg := <-received:
+			if msg.Data != want {
+				t.Fatalf("received %v, want %s", msg.Data, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not received", want)
+		}
+	}
+}
+
+func TestOfflineQueueFlushesAfterReconnect(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) { o.SetupTimeout = -1 })
+	var rejecting atomic.Bool
+	url, received := receivingServer(t, cm, &rejecting)
+	reconnected := make(chan struct{}, 1)
+	c, err := Dial(context.Background(), url, Options{
+		Reconnect:          true,
+		MinBackoff:         10 * time.Millisecond,
+		MaxBackoff:         20 * time.Millisecond,
+		OfflinePath:        filepath.Join(t.TempDir(), "queue"),
+		OfflineMaxMessages: 2,
+		OnReconnect:        func() { reconnected <- struct{}{} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send(&websocket.Message{Type: "m", Data: "online"})
+	expectData(t, received, "online")
+
+	rejecting.Store(true)
+	cm.ShedLoad(1)
+	eventually(t, "the client to disconnect", func() bool {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		return c.socket == nil
+	})
+	for _, data := range []string{"a", "b"} {
+		if err := c.Send(&websocket.Message{Type: "m", Data: data}); err != nil {
+			t.Fatalf("sending %s offline: %v", data, err)
+		}
+	}
+	if err := c.Send(&websocket.Message{Type: "m", Data: "c"}); !errors.Is(err, ErrOfflineQueueFull) {
+		t.Fatalf("err = %v, want ErrOfflineQueueFull", err)
+	}
+
+	rejecting.Store(false)
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reconnected")
+	}
+	expectData(t, received, "a", "b")
+}
+
+func TestOfflineQueueSurvivesRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	down := "ws://" + ln.Addr().String()
+	if _, err := Dial(context.Background(), down, Options{OfflinePath: path}); err == nil {
+		t.Fatal("failed first dial without Reconnect did not fail Dial")
+	}
+
+	// With Reconnect the client starts offline and queues
+	c, err := Dial(context.Background(), down, Options{OfflinePath: path, Reconnect: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(&websocket.Message{Type: "m", Data: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) { o.SetupTimeout = -1 })
+	url, received := receivingServer(t, cm, nil)
+	c, err = Dial(context.Background(), url, Options{OfflinePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	expectData(t, received, "queued")
+}
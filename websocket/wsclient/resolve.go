@@ -0,0 +1,116 @@
+package wsclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// rotator resolves the host of each dial and starts at the next address every time
+type rotator struct {
+	resolver *net.Resolver
+	service  string
+	proto    string
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu   sync.Mutex
+	next map[string]int
+}
+
+// rotatingDialer copies opts.Dialer with a net dial that resolves hosts again on every dial
+func rotatingDialer(opts *Options) *gorilla.Dialer {
+	dialer := *opts.Dialer
+	r := &rotator{
+		resolver: opts.Resolver,
+		service:  opts.SRVService,
+		proto:    opts.SRVProto,
+		dial:     dialer.NetDialContext,
+		next:     make(map[string]int),
+	}
+	if r.dial == nil && dialer.NetDial != nil {
+		netDial := dialer.NetDial
+		r.dial = func(_ context.Context, network, addr string) (net.Conn, error) {
+			return netDial(network, addr)
+		}
+	}
+	if r.dial == nil {
+		r.dial = (&net.Dialer{}).DialContext
+	}
+	dialer.NetDial = nil
+	dialer.NetDialContext = r.dialContext
+	return &dialer
+}
+
+// dialContext tries the addresses of addr in turn, starting after the one the previous dial of addr started at
+func (r *rotator) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	addrs, err := r.resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	start := r.next[addr]
+	r.next[addr] = start + 1
+	r.mu.Unlock()
+	var errs []error
+	for i := range addrs {
+		conn, err := r.dial(ctx, network, addrs[(start+i)%len(addrs)])
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// resolve returns the host:port addresses of addr, addr itself when its host is an IP
+func (r *rotator) resolve(ctx context.Context, addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return []string{addr}, nil
+	}
+	if r.service != "" {
+		return r.resolveSRV(ctx, host)
+	}
+	ips, err := r.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	// Keep the rotation stable when the resolver shuffles records
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+func (r *rotator) resolveSRV(ctx context.Context, host string) ([]string, error) {
+	_, records, err := r.resolver.LookupSRV(ctx, r.service, r.proto, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: "no SRV records", Name: host, IsNotFound: true}
+	}
+	// Records are sorted by priority, rotate among the best
+	var addrs []string
+	for _, record := range records {
+		if record.Priority != records[0].Priority {
+			break
+		}
+		addrs = append(addrs, net.JoinHostPort(record.Target, strconv.Itoa(int(record.Port))))
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
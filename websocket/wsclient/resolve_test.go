@@ -0,0 +1,98 @@
+package wsclient
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// fakeResolver answers every A query with ips and AAAA queries with no records
+func fakeResolver(ips ...net.IP) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveDNS(server, ips)
+			return client, nil
+		},
+	}
+}
+
+// serveDNS answers the length prefixed queries of the Go resolver over a stream connection
+func serveDNS(conn net.Conn, ips []net.IP) {
+	defer conn.Close()
+	for {
+		var size uint16
+		if binary.Read(conn, binary.BigEndian, &size) != nil {
+			return
+		}
+		query := make([]byte, size)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		end := 12
+		for query[end] != 0 {
+			end += int(query[end]) + 1
+		}
+		end += 5 // The root label, QTYPE and QCLASS
+		var answers []net.IP
+		if binary.BigEndian.Uint16(query[end-4:]) == 1 {
+			answers = ips
+		}
+		resp := append([]byte{}, query[:end]...)
+		binary.BigEndian.PutUint16(resp[2:], 0x8180) // Response, recursion desired and available
+		binary.BigEndian.PutUint16(resp[6:], uint16(len(answers)))
+		binary.BigEndian.PutUint16(resp[8:], 0)
+		binary.BigEndian.PutUint16(resp[10:], 0)
+		for _, ip := range answers {
+			// Name pointer to the question, type A, class IN, TTL 60, 4 bytes of data
+			resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+			resp = append(resp, ip.To4()...)
+		}
+		if binary.Write(conn, binary.BigEndian, uint16(len(resp))) != nil {
+			return
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+func TestRotatingDialerRotatesAddresses(t *testing.T) {
+	var mu sync.Mutex
+	var dialed []string
+	refuse := func(_ context.Context, _, addr string) (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dialed = append(dialed, addr)
+		return nil, errors.New("refused")
+	}
+	dialer := rotatingDialer(&Options{
+		Dialer:   &gorilla.Dialer{NetDialContext: refuse},
+		Resolver: fakeResolver(net.IPv4(10, 0, 0, 3), net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)),
+	})
+	dial := func(addr string) []string {
+		t.Helper()
+		dialed = nil
+		if _, err := dialer.NetDialContext(context.Background(), "tcp", addr); err == nil {
+			t.Fatal("dial succeeded with every address refusing")
+		}
+		return dialed
+	}
+	if got := dial("fleet.test:80"); !slices.Equal(got, []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"}) {
+		t.Fatalf("first dial tried %v", got)
+	}
+	if got := dial("fleet.test:80"); !slices.Equal(got, []string{"10.0.0.2:80", "10.0.0.3:80", "10.0.0.1:80"}) {
+		t.Fatalf("second dial tried %v, want it to start at the next address", got)
+	}
+	if got := dial("192.0.2.1:80"); !slices.Equal(got, []string{"192.0.2.1:80"}) {
+		t.Fatalf("IP address dialed as %v", got)
+	}
+}
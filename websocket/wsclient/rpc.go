@@ -0,0 +1,104 @@
+package wsclient
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+const defaultCallTimeout = 10 * time.Second
+
+// callResult is websocket.CallResult with the result kept raw for typed decoding
+type callResult struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// Call calls method of the server with params, registered there with HandleCall, and returns the result
+// message with the result as data. It fails with a *websocket.CallError when the method failed, and with
+// context.DeadlineExceeded after CallTimeout when ctx has no earlier deadline.
+func (c *Client) Call(ctx context.Context, method string, params interface{}) (*websocket.Message, error) {
+	env, err := c.call(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+	msg := &websocket.Message{Type: env.Type, ID: env.ID}
+	if len(env.result.Result) > 0 {
+		err = json.Unmarshal(env.result.Result, &msg.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// CallValue is Call with the result decoded as R
+func CallValue[R any](ctx context.Context, c *Client, method string, params interface{}) (R, error) {
+	var value R
+	env, err := c.call(ctx, method, params)
+	if err != nil {
+		return value, err
+	}
+	if len(env.result.Result) > 0 {
+		err = json.Unmarshal(env.result.Result, &value)
+	}
+	return value, err
+}
+
+// call sends a call message and waits for its result
+func (c *Client) call(ctx context.Context, method string, params interface{}) (*envelope, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CallTimeout)
+	defer cancel()
+	id := strconv.FormatUint(c.nextCall.Add(1), 10)
+	answer := make(chan *envelope, 1)
+	c.mu.Lock()
+	c.calls[id] = answer
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, id)
+		c.mu.Unlock()
+	}()
+	err := c.Send(&websocket.Message{
+		Type: c.opts.CallMessageType,
+		ID:   id,
+		Data: websocket.Call{Method: method, Params: params},
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case env := <-answer:
+		if env.result.Error != "" {
+			return nil, &websocket.CallError{Method: method, Message: env.result.Error}
+		}
+		return env, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, ErrDisconnected
+	}
+}
+
+// answer passes a result message to its pending call, results of calls that timed out are dropped
+func (c *Client) answer(env *envelope) {
+	c.mu.Lock()
+	answer, ok := c.calls[env.ID]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	err := json.Unmarshal(env.Data, &env.result)
+	if err != nil {
+		c.error(err)
+		return
+	}
+	select {
+	case answer <- env:
+	default:
+		// Duplicate result
+	}
+}
@@ -0,0 +1,92 @@
+package wsclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func TestCallGetsServerTimeoutError(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.CallTimeout = 50 * time.Millisecond
+	})
+	cm.HandleCall("slow", func(ctx context.Context, _ *websocket.Connection, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(*websocket.Connection, *websocket.Message) {})
+	}))
+	defer srv.Close()
+	c, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), Options{CallTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.Call(context.Background(), "slow", nil)
+	var callErr *websocket.CallError
+	if !errors.As(err, &callErr) {
+		t.Fatalf("err = %v, want the CallError of the server", err)
+	}
+}
+
+func TestCallResults(t *testing.T) {
+	received := make(chan string, 1)
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) { o.SetupTimeout = -1 })
+	type sum struct{ A, B int }
+	websocket.HandleTypedCall(cm, "add", func(_ context.Context, _ *websocket.Connection, p sum) (int, error) {
+		return p.A + p.B, nil
+	})
+	cm.HandleCall("fail", func(context.Context, *websocket.Connection, interface{}) (interface{}, error) {
+		return nil, errors.New("nope")
+	})
+	release := make(chan struct{})
+	cm.HandleCall("slow", func(context.Context, *websocket.Connection, interface{}) (interface{}, error) {
+		<-release
+		return "late", nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, func(_ *websocket.Connection, msg *websocket.Message) { received <- msg.Type })
+	}))
+	defer srv.Close()
+	c, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), Options{CallTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if n, err := CallValue[int](context.Background(), c, "add", sum{2, 3}); err != nil || n != 5 {
+		t.Fatalf("add = %d, %v", n, err)
+	}
+	if msg, err := c.Call(context.Background(), "add", map[string]int{"A": 1, "B": 1}); err != nil || msg.Data != float64(2) {
+		t.Fatalf("add = %+v, %v", msg, err)
+	}
+	var callErr *websocket.CallError
+	if _, err := c.Call(context.Background(), "fail", nil); !errors.As(err, &callErr) || callErr.Message != "nope" {
+		t.Fatalf("err = %v, want the error of the handler", err)
+	}
+	if _, err := c.Call(context.Background(), "missing", nil); !errors.As(err, &callErr) {
+		t.Fatalf("err = %v, want a CallError for an unknown method", err)
+	}
+	if _, err := c.Call(context.Background(), "slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the client timeout", err)
+	}
+
+	// The late result is dropped and the connection keeps working
+	close(release)
+	c.Send(&websocket.Message{Type: "plain"})
+	if msgType := <-received; msgType != "plain" {
+		t.Fatalf("server received %s", msgType)
+	}
+	select {
+	case msg := <-c.Receive():
+		t.Fatalf("late result passed to Receive: %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,56 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// SessionID of the server session with SessionMessageType set, empty until the server started it
+func (c *Client) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session.ID
+}
+
+// startSession keeps the token of the session message to resume the session on reconnect
+func (c *Client) startSession(data json.RawMessage) {
+	var info websocket.SessionInfo
+	err := json.Unmarshal(data, &info)
+	if err != nil {
+		c.error(err)
+		return
+	}
+	if !info.Resumed {
+		c.sessionReceived.Store(0)
+	}
+	c.mu.Lock()
+	c.session = info
+	c.mu.Unlock()
+}
+
+// resumeURLs adds the session token and the count of received messages to urls
+func (c *Client) resumeURLs(urls []string) []string {
+	c.mu.Lock()
+	token := c.session.Token
+	c.mu.Unlock()
+	if token == "" {
+		return urls
+	}
+	resume := make([]string, 0, len(urls))
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			resume = append(resume, raw)
+			continue
+		}
+		query := u.Query()
+		query.Set(c.opts.SessionParam, token)
+		query.Set("received", strconv.FormatUint(c.sessionReceived.Load(), 10))
+		u.RawQuery = query.Encode()
+		resume = append(resume, u.String())
+	}
+	return resume
+}
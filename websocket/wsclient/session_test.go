@@ -0,0 +1,38 @@
+package wsclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func TestClientResumesSession(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.SessionReplayWindow = time.Minute
+	})
+	c, conn := testServer(t, cm, Options{Reconnect: true, MinBackoff: 10 * time.Millisecond, SessionMessageType: "session"})
+	cm.SendTo(conn, &websocket.Message{Type: "before"})
+	if msg := <-c.Receive(); msg.Type != "before" {
+		t.Fatalf("received %+v", msg)
+	}
+	id := c.SessionID()
+	if id == "" || id != conn.SessionID() {
+		t.Fatalf("session %q, want %q", id, conn.SessionID())
+	}
+
+	cm.Terminate(conn)
+	cm.SendSession(id, &websocket.Message{Type: "missed"})
+	select {
+	case msg := <-c.Receive():
+		if msg.Type != "missed" {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("missed message not replayed")
+	}
+	if c.SessionID() != id {
+		t.Fatalf("session %q after reconnect, want %q", c.SessionID(), id)
+	}
+}
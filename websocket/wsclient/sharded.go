@@ -0,0 +1,84 @@
+package wsclient
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// ShardedClient spreads topics over several connections to the server by the hash of the topic, for consumers
+// that need more throughput than one connection
+type ShardedClient struct {
+	clients []*Client
+	next    atomic.Uint64
+}
+
+// DialSharded opens shards connections to the servers at urls like DialEndpoints, each with opts. Each shard
+// keeps its offline queue in its own file, OfflinePath followed by "." and the shard number.
+func DialSharded(ctx context.Context, urls []string, shards int, opts Options) (*ShardedClient, error) {
+	if shards <= 0 {
+		shards = 1
+	}
+	s := &ShardedClient{}
+	for i := 0; i < shards; i++ {
+		shardOpts := opts
+		if opts.OfflinePath != "" {
+			shardOpts.OfflinePath = opts.OfflinePath + "." + strconv.Itoa(i)
+		}
+		c, err := DialEndpoints(ctx, urls, shardOpts)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.clients = append(s.clients, c)
+	}
+	return s, nil
+}
+
+// For returns the connection handling key
+func (s *ShardedClient) For(key string) *Client {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.clients[h.Sum32()%uint32(len(s.clients))]
+}
+
+// Clients returns the connections, e.g. to watch their Events
+func (s *ShardedClient) Clients() []*Client {
+	return s.clients
+}
+
+// Send writes msg on the connection of its topic, or of its key, or on the connections in turn when it has
+// neither
+func (s *ShardedClient) Send(msg *websocket.Message) error {
+	switch {
+	case msg.Topic != "":
+		return s.For(msg.Topic).Send(msg)
+	case msg.Key != "":
+		return s.For(msg.Key).Send(msg)
+	}
+	i := s.next.Add(1) % uint64(len(s.clients))
+	return s.clients[i].Send(msg)
+}
+
+// Unsubscribe leaves topic on its connection
+func (s *ShardedClient) Unsubscribe(topic string) error {
+	return s.For(topic).Unsubscribe(topic)
+}
+
+// Close closes all connections
+func (s *ShardedClient) Close() error {
+	var errs []error
+	for _, c := range s.clients {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// SubscribeSharded subscribes to topic like Subscribe on the connection of the topic
+func SubscribeSharded[T any](s *ShardedClient, topic string) (<-chan T, error) {
+	return Subscribe[T](s.For(topic), topic)
+}
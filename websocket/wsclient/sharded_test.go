@@ -0,0 +1,67 @@
+package wsclient
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func TestShardedClientSpreadsTopics(t *testing.T) {
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.JoinMessageType = "join"
+	})
+	url, _ := receivingServer(t, cm, nil)
+	path := filepath.Join(t.TempDir(), "queue")
+	s, err := DialSharded(context.Background(), []string{url}, 3, Options{OfflinePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i, c := range s.Clients() {
+		if want := path + "." + strconv.Itoa(i); c.opts.OfflinePath != want {
+			t.Fatalf("shard %d queues in %s, want %s", i, c.opts.OfflinePath, want)
+		}
+	}
+
+	topics := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	values := make(map[string]<-chan string)
+	for _, topic := range topics {
+		values[topic], err = SubscribeSharded[string](s, topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "the subscriptions to join", func() bool {
+		for _, topic := range topics {
+			if len(cm.Members(topic)) != 1 {
+				return false
+			}
+		}
+		return true
+	})
+	// Topics share a server connection exactly when they share a shard
+	for _, x := range topics {
+		for _, y := range topics {
+			sameShard := s.For(x) == s.For(y)
+			if sameConn := cm.Members(x)[0] == cm.Members(y)[0]; sameConn != sameShard {
+				t.Fatalf("topics %s and %s share a connection %v, share a shard %v", x, y, sameConn, sameShard)
+			}
+		}
+	}
+	for _, topic := range topics {
+		cm.Publish(topic, &websocket.Message{Type: "v", Data: topic})
+		select {
+		case v := <-values[topic]:
+			if v != topic {
+				t.Fatalf("subscription of %s received %q", topic, v)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not received", topic)
+		}
+	}
+}
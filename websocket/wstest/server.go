@@ -0,0 +1,83 @@
+package wstest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// pipeURL has an IP host so dialers resolving hosts, as the one of wsclient, pass it to the pipe unchanged
+const pipeURL = "ws://127.0.0.1/"
+
+// Server serves an http.Handler on a PipeListener, an in-memory httptest.Server for handlers upgrading websocket
+// connections. The host of URL is never dialed, clients reach the server with Dialer.
+type Server struct {
+	URL      string
+	Listener *PipeListener
+	server   *http.Server
+}
+
+// NewServer serves handler, e.g. Handler or a mux calling ConnectionManager.Receive, until Close
+func NewServer(handler http.Handler) *Server {
+	s := &Server{
+		URL:      pipeURL,
+		Listener: NewPipeListener(),
+		server:   &http.Server{Handler: handler},
+	}
+	go s.server.Serve(s.Listener)
+	return s
+}
+
+// Handler upgrades requests with cm.ReceiveConn, for NewServer and httptest.NewServer
+func Handler(cm *websocket.ConnectionManager, onReceive func(*websocket.Connection, *websocket.Message)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cm.ReceiveConn(w, r, onReceive)
+	})
+}
+
+// Dialer connects to the server whatever the URL, for gorilla/websocket clients and wsclient.Options.Dialer
+func (s *Server) Dialer() *gorilla.Dialer {
+	return &gorilla.Dialer{NetDialContext: s.Listener.DialContext}
+}
+
+// Dial connects a client to path, e.g. "/ws?room=a", with the request header
+func (s *Server) Dial(ctx context.Context, path string, header http.Header) (*gorilla.Conn, error) {
+	socket, _, err := s.Dialer().DialContext(ctx, s.URL+strings.TrimPrefix(path, "/"), header)
+	return socket, err
+}
+
+// Close stops the server, upgraded connections stay open
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Pair connects an in-memory client to cm and returns the managed connection and the client end. The connection
+// goes through ReceiveConn as with a real upgrade and onReceive gets the messages the client writes. Closing the
+// client disconnects it.
+func Pair(
+	cm *websocket.ConnectionManager,
+	onReceive func(*websocket.Connection, *websocket.Message)) (*websocket.Connection, *gorilla.Conn, error) {
+	type upgraded struct {
+		conn *websocket.Connection
+		err  error
+	}
+	result := make(chan upgraded, 1)
+	s := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := cm.ReceiveConn(w, r, onReceive)
+		result <- upgraded{conn: conn, err: err}
+	}))
+	defer s.Close()
+	client, err := s.Dial(context.Background(), "/", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	up := <-result
+	if up.err != nil {
+		client.Close()
+		return nil, nil, up.err
+	}
+	return up.conn, client, nil
+}
@@ -0,0 +1,68 @@
+package wstest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+	"github.com/qulia/go-websocket/websocket/wsclient"
+)
+
+func TestPairRoundTrip(t *testing.T) {
+	cm := websocket.NewConnectionManager()
+	got := make(chan *websocket.Message, 1)
+	conn, client, err := Pair(cm, func(_ *websocket.Connection, msg *websocket.Message) { got <- msg })
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	client.WriteJSON(websocket.Message{Type: "ping", Data: "from client"})
+	select {
+	case msg := <-got:
+		if msg.Type != "ping" || msg.Data != "from client" {
+			t.Fatalf("onReceive got %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("onReceive not called")
+	}
+
+	conn.Send(&websocket.Message{Type: "pong", Data: "from server"})
+	var msg websocket.Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "pong" || msg.Data != "from server" {
+		t.Fatalf("client read %+v, %v", msg, err)
+	}
+
+	client.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for cm.Stats().Connections != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("connection not removed after the client closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerDial(t *testing.T) {
+	cm := websocket.NewConnectionManager()
+	s := NewServer(Handler(cm, func(conn *websocket.Connection, msg *websocket.Message) { conn.Send(msg) }))
+	defer s.Close()
+	client, err := s.Dial(context.Background(), "/ws?room=a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	client.WriteJSON(websocket.Message{Type: "echo"})
+	var msg websocket.Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "echo" {
+		t.Fatalf("read %+v, %v", msg, err)
+	}
+
+	wc, err := wsclient.Dial(context.Background(), s.URL, wsclient.Options{Dialer: s.Dialer()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc.Close()
+}
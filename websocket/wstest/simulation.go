@@ -0,0 +1,466 @@
+package wstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+const defaultSettle = 5 * time.Second
+
+// settlePayload of the ping queued to each reading client to settle a step
+const settlePayload = "wstest.settle"
+
+// Simulation runs a ConnectionManager on a fake clock and in-memory transports and records what clients and
+// the manager observe. Each scripted step waits for the signals of its effects: the manager answering a ping
+// behind a sent message, a ping queued after all queued operations reaching every reading client, and the
+// events published so far being logged. The log is ordered by step, actor and per actor sequence, so scenarios
+// produce the same log on every run. The pings are control frames queued with QueuePing, so they are not
+// counted in the stats and usage of the manager. Work started by tickers of the fake clock is not waited for.
+type Simulation struct {
+	Manager *websocket.ConnectionManager
+	Clock   *websocket.FakeClock
+	// Settle is the longest real time a step waits for one of its signals, e.g. for a client that disconnects
+	// before its ping arrives, defaults to 5s
+	Settle time.Duration
+
+	start    time.Time
+	listener *PipeListener
+	server   *http.Server
+	dialer   *gorilla.Dialer
+	clients  map[string]*simClient
+	flush    chan chan struct{} // Requests to log the pending events
+
+	mu        sync.Mutex
+	changed   chan struct{} // Closed and replaced whenever the state below changes
+	step      int
+	entries   []logEntry
+	seq       map[string]int
+	names     map[*websocket.Connection]string
+	conns     map[string]*websocket.Connection // Connected clients by name until their disconnect event
+	connected []string                         // Names waiting for their connect event
+	done      chan struct{}
+}
+
+type logEntry struct {
+	step  int
+	at    time.Duration
+	actor string
+	seq   int
+	what  string
+}
+
+type simClient struct {
+	name   string
+	socket *gorilla.Conn
+	netCon net.Conn
+
+	mu     sync.Mutex
+	lagged chan struct{} // Non nil while the client does not read
+
+	// Guarded by the mutex of the simulation
+	pings   int
+	pongs   int
+	markers int // Settle pings received
+	closed  bool
+}
+
+// NewSimulation starts a manager with opts on a fake clock, onReceive is called for messages read by the manager
+func NewSimulation(opts websocket.Options, onReceive func(*websocket.Message)) *Simulation {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := websocket.NewFakeClock(start)
+	opts.Clock = clock
+	s := &Simulation{
+		Manager:  websocket.NewConnectionManagerWithOptions(opts),
+		Clock:    clock,
+		Settle:   defaultSettle,
+		start:    start,
+		listener: NewPipeListener(),
+		clients:  make(map[string]*simClient),
+		seq:      make(map[string]int),
+		names:    make(map[*websocket.Connection]string),
+		conns:    make(map[string]*websocket.Connection),
+		changed:  make(chan struct{}),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if onReceive == nil {
+		onReceive = func(*websocket.Message) {}
+	}
+	s.server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Manager.Receive(w, r, func(msg *websocket.Message) {
+			s.record("server", "recv "+msg.Type)
+			onReceive(msg)
+		})
+	})}
+	s.dialer = &gorilla.Dialer{NetDialContext: s.listener.DialContext}
+	go s.server.Serve(s.listener)
+	go s.watchEvents()
+	return s
+}
+
+// Connect dials a new client called name
+func (s *Simulation) Connect(name string) error {
+	s.nextStep()
+	s.mu.Lock()
+	s.connected = append(s.connected, name)
+	s.mu.Unlock()
+	socket, _, err := s.dialer.DialContext(context.Background(), "ws://pipe/", nil)
+	if err != nil {
+		return err
+	}
+	client := &simClient{name: name, socket: socket, netCon: socket.NetConn()}
+	socket.SetPongHandler(func(string) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		client.pongs++
+		s.notify()
+		return nil
+	})
+	socket.SetPingHandler(func(payload string) error {
+		if payload == settlePayload {
+			s.mu.Lock()
+			client.markers++
+			s.notify()
+			s.mu.Unlock()
+		}
+		err := socket.WriteControl(gorilla.PongMessage, []byte(payload), time.Now().Add(s.Settle))
+		var netErr net.Error
+		if err == gorilla.ErrCloseSent || errors.As(err, &netErr) {
+			return nil
+		}
+		return err
+	})
+	s.clients[name] = client
+	go s.read(client)
+	s.waitFor(func() bool {
+		_, ok := s.conns[name]
+		return ok
+	})
+	s.settle()
+	return nil
+}
+
+// Send writes msg from client name to the manager. The pong telling that the manager read msg cannot reach a
+// lagging client, so the effects of a message sent while lagging are not waited for.
+func (s *Simulation) Send(name string, msg *websocket.Message) error {
+	s.nextStep()
+	client, err := s.client(name)
+	if err != nil {
+		return err
+	}
+	s.record(name, "send "+msg.Type)
+	err = client.socket.WriteJSON(msg)
+	if err == nil {
+		err = s.ping(client)
+	}
+	s.settle()
+	return err
+}
+
+// Broadcast sends msg from the manager to all connections
+func (s *Simulation) Broadcast(msg *websocket.Message) {
+	s.nextStep()
+	s.record("server", "broadcast "+msg.Type)
+	s.Manager.Send(msg)
+	s.settle()
+}
+
+// Lag stops client name from reading until Resume, a message it is already reading is held until then
+func (s *Simulation) Lag(name string) error {
+	s.nextStep()
+	client, err := s.client(name)
+	if err != nil {
+		return err
+	}
+	client.mu.Lock()
+	if client.lagged == nil {
+		client.lagged = make(chan struct{})
+	}
+	client.mu.Unlock()
+	s.record(name, "lag")
+	return nil
+}
+
+// Resume lets a lagging client read again
+func (s *Simulation) Resume(name string) error {
+	s.nextStep()
+	client, err := s.client(name)
+	if err != nil {
+		return err
+	}
+	client.mu.Lock()
+	if client.lagged != nil {
+		close(client.lagged)
+		client.lagged = nil
+	}
+	client.mu.Unlock()
+	s.record(name, "resume")
+	s.settle()
+	return nil
+}
+
+// Drop closes the transport of client name without a close handshake
+func (s *Simulation) Drop(name string) error {
+	s.nextStep()
+	client, err := s.client(name)
+	if err != nil {
+		return err
+	}
+	s.record(name, "drop")
+	err = client.netCon.Close()
+	s.waitFor(func() bool {
+		_, connected := s.conns[name]
+		return client.closed && !connected
+	})
+	s.settle()
+	return err
+}
+
+// Advance moves the fake clock forward by d
+func (s *Simulation) Advance(d time.Duration) {
+	s.nextStep()
+	s.Clock.Advance(d)
+	s.settle()
+}
+
+// Log entries recorded so far as "<fake elapsed> <actor> <what>" lines in deterministic order
+func (s *Simulation) Log() []string {
+	s.mu.Lock()
+	entries := append([]logEntry(nil), s.entries...)
+	s.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].step != entries[j].step {
+			return entries[i].step < entries[j].step
+		}
+		if entries[i].actor != entries[j].actor {
+			return entries[i].actor < entries[j].actor
+		}
+		return entries[i].seq < entries[j].seq
+	})
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, fmt.Sprintf("%v %s %s", e.at, e.actor, e.what))
+	}
+	return lines
+}
+
+// Close shuts the manager down, waiting up to Settle for the close handshakes, and stops the simulation and its
+// clients
+func (s *Simulation) Close() {
+	for _, client := range s.clients {
+		client.mu.Lock()
+		if client.lagged != nil {
+			close(client.lagged)
+			client.lagged = nil
+		}
+		client.mu.Unlock()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.Settle)
+	defer cancel()
+	s.Manager.Shutdown(ctx)
+	close(s.done)
+	for _, client := range s.clients {
+		client.netCon.Close()
+	}
+	s.listener.Close()
+	s.server.Close()
+}
+
+func (s *Simulation) nextStep() {
+	s.mu.Lock()
+	s.step++
+	s.mu.Unlock()
+}
+
+func (s *Simulation) client(name string) (*simClient, error) {
+	client, ok := s.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("wstest: unknown client %q", name)
+	}
+	return client, nil
+}
+
+func (s *Simulation) read(client *simClient) {
+	for {
+		client.waitResumed()
+		msg := websocket.Message{}
+		err := client.socket.ReadJSON(&msg)
+		if err != nil {
+			s.mu.Lock()
+			client.closed = true
+			s.mu.Unlock()
+			s.record(client.name, "closed")
+			return
+		}
+		// A message read while the client started lagging is held until it resumes
+		client.waitResumed()
+		s.record(client.name, "recv "+msg.Type)
+	}
+}
+
+func (c *simClient) waitResumed() {
+	c.mu.Lock()
+	lagged := c.lagged
+	c.mu.Unlock()
+	if lagged != nil {
+		<-lagged
+	}
+}
+
+func (c *simClient) lagging() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lagged != nil
+}
+
+func (s *Simulation) watchEvents() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-s.Manager.Events():
+			s.recordEvent(event)
+		case flushed := <-s.flush:
+			s.drainEvents()
+			close(flushed)
+		}
+	}
+}
+
+// drainEvents logs the events already published
+func (s *Simulation) drainEvents() {
+	for {
+		select {
+		case event := <-s.Manager.Events():
+			s.recordEvent(event)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Simulation) recordEvent(event websocket.Event) {
+	s.mu.Lock()
+	var conn *websocket.Connection
+	var what string
+	switch e := event.(type) {
+	case websocket.ConnectEvent:
+		if len(s.connected) > 0 {
+			s.names[e.Conn] = s.connected[0]
+			s.conns[s.connected[0]] = e.Conn
+			s.connected = s.connected[1:]
+		}
+		conn, what = e.Conn, "connect"
+	case websocket.DisconnectEvent:
+		if name, ok := s.names[e.Conn]; ok && s.conns[name] == e.Conn {
+			delete(s.conns, name)
+		}
+		conn, what = e.Conn, "disconnect"
+	case websocket.ErrorEvent:
+		conn, what = e.Conn, "error"
+	case websocket.DropEvent:
+		conn, what = e.Conn, "drop "+e.Message.Type
+	case websocket.JoinEvent:
+		conn, what = e.Conn, "join "+e.Topic
+	case websocket.LeaveEvent:
+		conn, what = e.Conn, "leave "+e.Topic
+	default:
+		what = fmt.Sprintf("%T", event)
+	}
+	if name, ok := s.names[conn]; ok {
+		what += " " + name
+	}
+	s.mu.Unlock()
+	s.record("manager", what)
+}
+
+func (s *Simulation) record(actor, what string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq[actor]++
+	s.entries = append(s.entries, logEntry{
+		step:  s.step,
+		at:    s.Clock.Now().Sub(s.start),
+		actor: actor,
+		seq:   s.seq[actor],
+		what:  what,
+	})
+	s.notify()
+}
+
+// notify wakes the steps waiting for a change, must be called with mu held
+func (s *Simulation) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// waitFor waits until ready returns true or Settle passes without it, ready is called with mu held
+func (s *Simulation) waitFor(ready func() bool) bool {
+	timeout := time.NewTimer(s.Settle)
+	defer timeout.Stop()
+	for {
+		s.mu.Lock()
+		if ready() {
+			s.mu.Unlock()
+			return true
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return false
+		}
+	}
+}
+
+// ping asks the manager for a pong, which it sends once it read everything client wrote before. A lagging client
+// is not pinged, it would not read the pong.
+func (s *Simulation) ping(client *simClient) error {
+	if client.lagging() {
+		return nil
+	}
+	s.mu.Lock()
+	client.pings++
+	s.mu.Unlock()
+	err := client.socket.WriteControl(gorilla.PingMessage, nil, time.Now().Add(s.Settle))
+	if err != nil {
+		return err
+	}
+	s.waitFor(func() bool { return client.closed || client.pongs >= client.pings })
+	return nil
+}
+
+// settle waits until the operations queued so far have run, the clients that read have received everything the
+// manager sent them, and the events published meanwhile are logged
+func (s *Simulation) settle() {
+	// A round trip through the operations goroutine
+	s.Manager.StalledConnections()
+	for name, client := range s.clients {
+		s.mu.Lock()
+		conn, connected := s.conns[name]
+		closed := client.closed
+		target := client.markers + 1
+		s.mu.Unlock()
+		if !connected || closed || client.lagging() {
+			continue
+		}
+		conn.QueuePing([]byte(settlePayload))
+		s.waitFor(func() bool { return client.closed || client.markers >= target })
+	}
+	flushed := make(chan struct{})
+	select {
+	case s.flush <- flushed:
+		<-flushed
+	case <-s.done:
+	}
+}
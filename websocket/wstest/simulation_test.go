@@ -0,0 +1,77 @@
+package wstest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qulia/go-websocket/websocket"
+)
+
+func runScenario(t *testing.T) []string {
+	t.Helper()
+	s := NewSimulation(websocket.Options{}, nil)
+	if err := s.Connect("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Connect("b"); err != nil {
+		t.Fatal(err)
+	}
+	s.Lag("b")
+	for i := 0; i < 3; i++ {
+		s.Broadcast(&websocket.Message{Type: "tick"})
+	}
+	for _, line := range s.Log() {
+		if strings.Contains(line, " b recv") {
+			t.Fatalf("lagging client received: %s", line)
+		}
+	}
+	s.Resume("b")
+	s.Send("a", &websocket.Message{Type: "hello"})
+	s.Drop("a")
+	s.Advance(time.Second)
+	log := s.Log()
+	s.Close()
+	if s.Manager.Stats().Connections != 0 {
+		t.Fatal("manager not shut down")
+	}
+	return log
+}
+
+func TestSimulationLogIsDeterministic(t *testing.T) {
+	first := runScenario(t)
+	ticks := 0
+	for _, line := range first {
+		if strings.Contains(line, " b recv tick") {
+			ticks++
+		}
+	}
+	if ticks != 3 {
+		t.Fatalf("b received %d ticks after resuming:\n%s", ticks, strings.Join(first, "\n"))
+	}
+	for i := 0; i < 3; i++ {
+		if again := runScenario(t); strings.Join(again, "\n") != strings.Join(first, "\n") {
+			t.Fatalf("log changed between runs:\n%s\n---\n%s", strings.Join(first, "\n"), strings.Join(again, "\n"))
+		}
+	}
+}
+
+func TestSimulationSettleIsNotAccounted(t *testing.T) {
+	// Without credits a message would be held, the settle ping is not
+	s := NewSimulation(websocket.Options{FlowControl: true}, nil)
+	defer s.Close()
+	start := time.Now()
+	if err := s.Connect("a"); err != nil {
+		t.Fatal(err)
+	}
+	s.Advance(time.Second)
+	if elapsed := time.Since(start); elapsed >= s.Settle {
+		t.Fatalf("steps took %v waiting for settle", elapsed)
+	}
+	if sent := s.Manager.Stats().MessagesSent; sent != 0 {
+		t.Fatalf("%d messages sent", sent)
+	}
+	if len(s.Manager.TypeStats()) != 0 {
+		t.Fatalf("type stats %v", s.Manager.TypeStats())
+	}
+}
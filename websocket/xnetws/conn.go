@@ -0,0 +1,179 @@
+// Package xnetws migrates servers built on golang.org/x/net/websocket to a ConnectionManager. Handlers keep
+// their signature and x/net keeps answering the handshake, e.g.
+//
+//	http.Handle("/ws", xnet.Handler(func(ws *xnet.Conn) {
+//		// Existing checks of ws.Request() or ws.Config()
+//		xnetws.Serve(cm, ws, onReceive)
+//	}))
+//
+// or as a drop-in for the whole handler
+//
+//	http.Handle("/ws", xnetws.Handler(cm, onReceive))
+package xnetws
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+	xnet "golang.org/x/net/websocket"
+)
+
+// ErrPingUnsupported x/net/websocket drops pongs, so the manager neither pings its connections nor times out
+// their reads on missing pongs, see CanPing. It answers the pings of clients.
+var ErrPingUnsupported = errors.New("xnetws: pings are not supported by x/net/websocket")
+
+// Handler serves x/net connections with cm
+func Handler(cm *websocket.ConnectionManager, onReceive func(*websocket.Connection, *websocket.Message)) xnet.Handler {
+	return func(ws *xnet.Conn) {
+		// The manager logs connections it does not accept
+		Serve(cm, ws, onReceive)
+	}
+}
+
+// Serve manages ws with cm and returns once the manager closed it or did not accept it, x/net then closes the
+// network connection
+func Serve(cm *websocket.ConnectionManager, ws *xnet.Conn,
+	onReceive func(*websocket.Connection, *websocket.Message)) error {
+	conn := Wrap(ws)
+	_, err := cm.Accept(ws.Request(), conn, onReceive)
+	if err != nil {
+		return err
+	}
+	<-conn.closed
+	return nil
+}
+
+// Conn is an x/net connection implementing websocket.Conn. Each frame is read as a message. Close does not close
+// the network connection itself but ends Serve, after which the x/net handler closes it.
+type Conn struct {
+	ws        *xnet.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+	writeMu   sync.Mutex // Guards PayloadType and the write deadline of ws for the write that uses them
+}
+
+var (
+	_ websocket.Conn   = (*Conn)(nil)
+	_ websocket.Pinger = (*Conn)(nil)
+)
+
+// Wrap adapts ws
+func Wrap(ws *xnet.Conn) *Conn {
+	return &Conn{ws: ws, closed: make(chan struct{})}
+}
+
+type frame struct {
+	payloadType byte
+	data        []byte
+}
+
+// rawCodec receives frames as they are
+var rawCodec = xnet.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		f := v.(*frame)
+		f.payloadType = payloadType
+		f.data = data
+		return nil
+	},
+}
+
+// NextReader reads the next data frame
+func (c *Conn) NextReader() (int, io.Reader, error) {
+	var f frame
+	err := rawCodec.Receive(c.ws, &f)
+	if err == io.EOF {
+		// x/net reports the close frame of the peer without its code
+		return 0, nil, &gorilla.CloseError{Code: gorilla.CloseNoStatusReceived}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return int(f.payloadType), bytes.NewReader(f.data), nil
+}
+
+// NextWriter buffers a message of messageType that is written as one frame when the writer is closed
+func (c *Conn) NextWriter(messageType int) (io.WriteCloser, error) {
+	return &frameWriter{conn: c, payloadType: byte(messageType)}, nil
+}
+
+// WriteMessage writes data as one frame of messageType
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	return c.writeFrame(byte(messageType), data, time.Time{})
+}
+
+// WriteControl writes close frames, pings return ErrPingUnsupported
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType != gorilla.CloseMessage {
+		return ErrPingUnsupported
+	}
+	return c.writeFrame(xnet.CloseFrame, data, deadline)
+}
+
+// SetReadDeadline of the network connection
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetReadLimit bounds the size of read frames
+func (c *Conn) SetReadLimit(limit int64) {
+	c.ws.MaxPayloadBytes = int(limit)
+}
+
+// SetPongHandler does nothing, x/net drops pongs
+func (c *Conn) SetPongHandler(func(appData string) error) {}
+
+// CanPing returns false, x/net drops pongs
+func (c *Conn) CanPing() bool {
+	return false
+}
+
+// Subprotocol selected by the handshake of the x/net server
+func (c *Conn) Subprotocol() string {
+	if protocols := c.ws.Config().Protocol; len(protocols) == 1 {
+		return protocols[0]
+	}
+	return ""
+}
+
+// Close ends Serve
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+// writeFrame writes data as one frame, bounded by deadline unless it is zero
+func (c *Conn) writeFrame(payloadType byte, data []byte, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if !deadline.IsZero() {
+		err := c.ws.SetWriteDeadline(deadline)
+		if err != nil {
+			return err
+		}
+		defer c.ws.SetWriteDeadline(time.Time{})
+	}
+	c.ws.PayloadType = payloadType
+	_, err := c.ws.Write(data)
+	return err
+}
+
+type frameWriter struct {
+	conn        *Conn
+	payloadType byte
+	buf         bytes.Buffer
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *frameWriter) Close() error {
+	return w.conn.writeFrame(w.payloadType, w.buf.Bytes(), time.Time{})
+}
@@ -0,0 +1,83 @@
+package xnetws
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/qulia/go-websocket/websocket"
+)
+
+// dialShim serves cm with Handler and dials it
+func dialShim(t *testing.T, cm *websocket.ConnectionManager, onReceive func(*websocket.Connection, *websocket.Message)) *gorilla.Conn {
+	t.Helper()
+	srv := httptest.NewServer(Handler(cm, onReceive))
+	t.Cleanup(srv.Close)
+	client, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"),
+		http.Header{"Origin": {srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return client
+}
+
+func TestHandlerServesManager(t *testing.T) {
+	received := make(chan string, 1)
+	disconnected := make(chan error, 1)
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.OnDisconnect = func(_ *websocket.Connection, err error) { disconnected <- err }
+	})
+	client := dialShim(t, cm, func(_ *websocket.Connection, msg *websocket.Message) { received <- msg.Type })
+
+	client.WriteJSON(websocket.Message{Type: "hello"})
+	select {
+	case msgType := <-received:
+		if msgType != "hello" {
+			t.Fatalf("received %s", msgType)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+	cm.Send(&websocket.Message{Type: "news"})
+	var msg websocket.Message
+	if err := client.ReadJSON(&msg); err != nil || msg.Type != "news" {
+		t.Fatalf("read %+v, %v", msg, err)
+	}
+
+	client.WriteMessage(gorilla.CloseMessage, gorilla.FormatCloseMessage(4000, "bye"))
+	select {
+	case err := <-disconnected:
+		// x/net/websocket does not expose the close code of the peer
+		var closeErr *gorilla.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != gorilla.CloseNoStatusReceived {
+			t.Fatalf("removed with %v, want the close frame", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not removed")
+	}
+}
+
+func TestIdleConnectionsNotTimedOutOnPongs(t *testing.T) {
+	disconnected := make(chan error, 1)
+	cm := websocket.NewConnectionManager(func(o *websocket.Options) {
+		o.SetupTimeout = -1
+		o.PingInterval = 50 * time.Millisecond
+		o.PongTimeout = 50 * time.Millisecond
+		o.OnDisconnect = func(_ *websocket.Connection, err error) { disconnected <- err }
+	})
+	client := dialShim(t, cm, func(*websocket.Connection, *websocket.Message) {})
+	client.WriteJSON(websocket.Message{Type: "hello"})
+	// x/net/websocket cannot ping, so the idle connection stays past several PingInterval and PongTimeout
+	select {
+	case err := <-disconnected:
+		t.Fatalf("idle connection removed with %v", err)
+	case <-time.After(400 * time.Millisecond):
+	}
+}